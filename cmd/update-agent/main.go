@@ -5,32 +5,208 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/coreos/go-systemd/v22/login1"
 	"github.com/coreos/pkg/flagutil"
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog/v2"
 
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/agent"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/dbus"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/login1"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/updateengine"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/version"
 )
 
-const defaultGracePeriodSeconds = 600
+const (
+	defaultGracePeriodSeconds      = 600
+	defaultMaxStartupDelay         = 5 * time.Second
+	defaultMaxOperatorResponseTime = 24 * time.Hour
+	defaultDBusConnectTimeout      = 2 * time.Minute
+	// Routine drain output is only useful when debugging.
+	defaultDrainOutputVerbosity = 4
+)
 
 var (
 	node         = flag.String("node", "", "Kubernetes node name")
 	printVersion = flag.Bool("version", false, "Print version and exit")
+	selfTest     = flag.Bool("self-test", false,
+		"Verify that update_engine and logind can be reached over the system D-Bus with required permissions, "+
+			"report the result and exit")
 
 	reapTimeout = flag.Int("grace-period", defaultGracePeriodSeconds,
 		"Period of time in seconds given to a pod to terminate when rebooting for an update")
+	podTerminationGracePeriod = flag.Int("pod-termination-grace-period", -1,
+		"Period of time in seconds given to each pod to terminate gracefully when draining the node, overriding "+
+			"the pod's own termination grace period. Pods are still waited for up to --grace-period, so it should be "+
+			"shorter than that. Pod's own termination grace period is used if negative")
 	forceNodeDrain = flag.Bool("force-drain", false, "Force removal of pods with custom or no owners while draining node")
+
+	preserveNodeStateOnShutdown = flag.Bool("preserve-node-state-on-shutdown", false,
+		"Leave node cordoned and marked as rebooting when agent is terminated before triggering a reboot")
+
+	managedNodeSelector = flag.String("managed-node-selector", "",
+		"Label selector which node must match to be managed by the agent, e.g. 'example.com/managed=true'. "+
+			"If node does not match, agent does nothing until restarted. All nodes are managed if empty")
+
+	heartbeatInterval = flag.Duration("heartbeat-interval", time.Minute,
+		"How often agent heartbeat annotation gets updated on the node")
+
+	rebootRetries = flag.Int("reboot-retries", 0,
+		"Number of times reboot request to logind is retried when it fails. Not retried if zero")
+
+	rebootRetryBackoff = flag.Duration("reboot-retry-backoff", time.Second,
+		"Time to wait before the first retry of failed reboot request, doubled after every retry")
+
+	maxWatchRetryBackoff = flag.Duration("max-watch-retry-backoff", time.Minute,
+		"Maximum time to wait before retrying to get or watch the node after a failure, e.g. while waiting "+
+			"for reboot approval. Retries start after 1 second and the delay is doubled after every consecutive failure")
+
+	rebootTimeout = flag.Duration("reboot-timeout", 0,
+		"Request reboot once again if the node has not rebooted within given period after requesting it, "+
+			"e.g. because an inhibitor lock prevents it, and exit with an error if it does not reboot "+
+			"within the period again. Disabled if zero")
+
+	skipWaitForNotOkToReboot = flag.Bool("skip-wait-for-not-ok-to-reboot", false,
+		"Do not wait on startup for the operator to clear ok-to-reboot annotation left from the previous reboot. "+
+			"Warning: if the annotation is not cleared before another reboot is needed, the node reboots without "+
+			"operator approval, possibly reboot-looping")
+
+	reportRebootBlockedReason = flag.Bool("report-reboot-blocked-reason", false,
+		"Report what agent currently waits for before proceeding with the reboot process using "+
+			"reboot-blocked-reason annotation on the node")
+
+	rebootWindowStart = flag.String("reboot-window-start", "",
+		"Start of the local reboot window in node's local time, e.g. '14:00' or 'Thu 23:00'. "+
+			"Node is only drained and rebooted within the window. Requires --reboot-window-length")
+
+	rebootWindowLength = flag.String("reboot-window-length", "",
+		"Length of the local reboot window, e.g. '1h30m'. Requires --reboot-window-start")
+
+	rebootInteractiveAuth = flag.Bool("reboot-interactive-auth", false,
+		"Allow interactive authentication when requesting a reboot from logind, if polkit policy requires it")
+
+	maxOperatorResponseTime = flag.Duration("max-operator-response-time", defaultMaxOperatorResponseTime,
+		"Maximum time to wait for the operator to approve a reboot or to confirm a finished reboot. "+
+			"Agent exits with an error once it is exceeded. Must be positive")
+
+	maxStartupDelay = flag.Duration("max-startup-delay", defaultMaxStartupDelay,
+		"Maximum random delay before agent starts updating the node, to spread the load on the API server "+
+			"when many agents start at once. Disabled if zero")
+
+	abortRebootOnDrainError = flag.Bool("abort-reboot-on-drain-error", false,
+		"Stop with an error instead of proceeding with the reboot when draining the node fails")
+
+	respectPodDisruptionBudgets = flag.Bool("respect-pod-disruption-budgets", true,
+		"Evict pods while draining the node, so PodDisruptionBudgets are honored. When disabled, pods are deleted")
+
+	maxPodEvictionRate = flag.Float64("max-pod-eviction-rate", 0,
+		"Maximum number of pods per second evicted or deleted while draining the node, e.g. '0.5'. Unlimited if zero")
+
+	stuckPodsPolicy = flag.String("stuck-pods-policy", string(agent.StuckPodsPolicyProceed),
+		"What to do when some pods are still terminating after the grace period is reached while draining the node. "+
+			"One of 'proceed', 'abort' (make node schedulable again and exit with an error) or "+
+			"'extend-once' (wait for another grace period, then proceed)")
+
+	rebootAction = flag.String("reboot-action", string(agent.RebootActionReboot),
+		"What to request from the host once the node is drained. One of 'reboot' or 'poweroff'")
+
+	cordonMethod = flag.String("cordon-method", string(agent.CordonMethodUnschedulable),
+		"How to cordon the node before draining it. One of 'unschedulable' (mark the node as unschedulable) or "+
+			"'taint' (add '"+constants.TaintRebooting+":NoSchedule' taint to the node)")
+
+	manualUncordon = flag.Bool("manual-uncordon", false,
+		"Leave the node cordoned after the reboot and set '"+constants.AnnotationAwaitingManualUncordon+"' "+
+			"annotation on it instead, which should be removed once the node has been verified and uncordoned")
+
+	evictStatefulPodsLast = flag.Bool("evict-stateful-pods-last", false,
+		"Remove pods owned by StatefulSets only after all other pods have been removed and terminated "+
+			"while draining the node. Each group of pods is given the full grace period")
+
+	watchPodTermination = flag.Bool("watch-pod-termination", false,
+		"Wait for removed pods to terminate while draining the node by watching pods instead of polling each of them, "+
+			"to reduce the load on the API server. Falls back to polling if watching fails")
+
+	preserveRebootNeededOnStartup = flag.Bool("preserve-reboot-needed-on-startup", false,
+		"Do not reset reboot-needed annotation and label on startup unless the reboot has already been approved. "+
+			"Instead, reset them only if update_engine does not report that reboot is needed")
+
+	versionOSReleaseKey = flag.String("version-os-release-key", "VERSION",
+		"Key in /etc/os-release which value is used for the version node label, e.g. 'VERSION_ID' or 'BUILD_ID'. "+
+			"Falls back to 'VERSION' if the key is not present")
+
+	updateEngineDBusDestination = flag.String("update-engine-dbus-destination", updateengine.DBusDestination,
+		"D-Bus bus name of update_engine, for images exposing it under a different name")
+
+	updateEngineDBusPath = flag.String("update-engine-dbus-path", updateengine.DBusPath,
+		"D-Bus object path of update_engine, for images exposing it under a different path")
+
+	updateEngineDBusInterface = flag.String("update-engine-dbus-interface", updateengine.DBusInterface,
+		"D-Bus interface name of update_engine, for images exposing it under a different name")
+
+	triggerCheckOnStart = flag.Bool("trigger-check-on-start", false,
+		"Ask update_engine to check for an update once on startup, instead of waiting for its periodic check")
+
+	emitDecisionsJSON = flag.Bool("emit-decisions-json", false,
+		"Write agent lifecycle decisions, like update_engine status changes, cordoning and draining the node "+
+			"or requesting a reboot, to stdout as JSON objects, one per line. Logs are written to stderr")
+
+	drainOutputVerbosity = flag.Int("drain-output-verbosity", defaultDrainOutputVerbosity,
+		"Log verbosity level, as set by -v, at which output of draining the node is logged. "+
+			"Use '0' to always log it. Drain errors are always logged")
+
+	dbusConnectTimeout = flag.Duration("dbus-connect-timeout", defaultDBusConnectTimeout,
+		"Maximum time to retry connecting to update_engine and logind over the system D-Bus on startup, "+
+			"e.g. when agent starts before they are available. Not retried if zero")
+
+	metricsAddress = flag.String("metrics-address", "",
+		"Address to serve Prometheus metrics on at /metrics path, e.g. ':8080'. Disabled if empty")
+
+	drainedDaemonSets   flagutil.StringSliceFlag
+	dbusAuthMethods     flagutil.StringSliceFlag
+	protectedNamespaces flagutil.StringSliceFlag
+
+	gracePeriodOverrides flagutil.StringSliceFlag
+	osReleasePaths       flagutil.StringSliceFlag
+	updateConfPaths      flagutil.StringSliceFlag
 )
 
+//nolint:funlen // Just many configuration options to pass.
 func main() {
+	flag.Var(&drainedDaemonSets, "drain-daemonsets",
+		"List of comma-separated DaemonSets in 'namespace/name' format, which pods are drained from the node "+
+			"before rebooting. Pods of other DaemonSets are not drained")
+
+	flag.Var(&protectedNamespaces, "protected-namespaces",
+		"List of comma-separated namespaces which pods are not drained from the node before rebooting. "+
+			"Defaults to 'kube-system'. Set to an empty value to drain pods from all namespaces")
+
+	flag.Var(&dbusAuthMethods, "dbus-auth-methods",
+		"List of comma-separated authentication methods to try in order when connecting to update_engine and logind "+
+			"over the system D-Bus. "+
+			"Supported methods are 'external', 'cookie-sha1' and 'anonymous'. Defaults to 'external'")
+
+	flag.Var(&gracePeriodOverrides, "grace-period-overrides",
+		"List of comma-separated overrides of --grace-period for pods controlled by objects of given kind "+
+			"in 'Kind=duration' format, e.g. 'DaemonSet=15m,StatefulSet=20m'")
+
+	flag.Var(&osReleasePaths, "os-release-paths",
+		"List of comma-separated paths of os-release file on the host, where the first existing one is used. "+
+			"Defaults to '/etc/os-release,/usr/lib/os-release'")
+
+	flag.Var(&updateConfPaths, "update-conf-paths",
+		"List of comma-separated paths of update.conf file shipped with the image on the host, where the first "+
+			"existing one is used. Defaults to '/usr/share/flatcar/update.conf,/usr/share/coreos/update.conf'")
+
 	klog.InitFlags(nil)
 
 	if err := flag.Set("logtostderr", "true"); err != nil {
@@ -48,12 +224,41 @@ func main() {
 		os.Exit(0)
 	}
 
+	authMethods, err := dbus.AuthMethods(dbusAuthMethods)
+	if err != nil {
+		klog.Fatalf("Failed parsing D-Bus authentication methods: %v", err)
+	}
+
+	if *selfTest {
+		if !runSelfTest(authMethods) {
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
 	clientset, err := k8sutil.GetClient("")
 	if err != nil {
 		klog.Fatalf("Failed creating Kubernetes client: %v", err)
 	}
 
-	updateEngineClient, err := updateengine.New(dbus.SystemPrivateConnector)
+	// Agent treats zero as a default, which would be surprising when given explicitly.
+	if *maxOperatorResponseTime <= 0 {
+		klog.Fatalf("Max operator response time must be positive, got %v", *maxOperatorResponseTime)
+	}
+
+	if *dbusConnectTimeout < 0 {
+		klog.Fatalf("D-Bus connect timeout must not be negative, got %v", *dbusConnectTimeout)
+	}
+
+	var updateEngineClient updateengine.Client
+
+	err = connectWithRetry("update_engine", *dbusConnectTimeout, func() error {
+		updateEngineClient, err = updateengine.NewWithConfig(dbus.SystemPrivateConnector, updateEngineConfig(),
+			authMethods...)
+
+		return err
+	})
 	if err != nil {
 		klog.Fatalf("Failed establishing connection to update_engine dbus: %v", err)
 	}
@@ -64,18 +269,76 @@ func main() {
 		}
 	}()
 
-	rebooter, err := login1.New()
+	var rebooter login1.Client
+
+	err = connectWithRetry("logind", *dbusConnectTimeout, func() error {
+		rebooter, err = login1.New(dbus.SystemPrivateConnector, authMethods...)
+
+		return err
+	})
 	if err != nil {
 		klog.Fatalf("Failed establishing connection to logind dbus: %v", err)
 	}
 
+	defer func() {
+		if err := rebooter.Close(); err != nil {
+			klog.Warningf("Failed gracefully closing logind client: %v", err)
+		}
+	}()
+
+	metricsRegistry := prometheus.NewRegistry()
+
+	var decisionsOutput io.Writer
+	if *emitDecisionsJSON {
+		decisionsOutput = os.Stdout
+	}
+
 	config := &agent.Config{
-		NodeName:               *node,
-		PodDeletionGracePeriod: time.Duration(*reapTimeout) * time.Second,
-		Clientset:              clientset,
-		StatusReceiver:         updateEngineClient,
-		Rebooter:               rebooter,
-		ForceNodeDrain:         *forceNodeDrain,
+		NodeName:                        *node,
+		PodDeletionGracePeriod:          time.Duration(*reapTimeout) * time.Second,
+		Clientset:                       clientset,
+		StatusReceiver:                  updateEngineClient,
+		LastAttemptErrorReader:          updateEngineClient,
+		Rebooter:                        rebooter,
+		ForceNodeDrain:                  *forceNodeDrain,
+		PreserveNodeStateOnShutdown:     *preserveNodeStateOnShutdown,
+		ManagedNodeSelector:             *managedNodeSelector,
+		HeartbeatInterval:               *heartbeatInterval,
+		RebootInteractiveAuth:           *rebootInteractiveAuth,
+		MaxStartupDelay:                 *maxStartupDelay,
+		MaxOperatorResponseTime:         *maxOperatorResponseTime,
+		DrainedDaemonSets:               drainedDaemonSets,
+		ProtectedNamespaces:             protectedNamespaces,
+		AbortRebootOnDrainError:         *abortRebootOnDrainError,
+		IgnorePodDisruptionBudgets:      !*respectPodDisruptionBudgets,
+		MetricsRegisterer:               metricsRegistry,
+		MaxPodEvictionRate:              *maxPodEvictionRate,
+		StuckPodsPolicy:                 agent.StuckPodsPolicy(*stuckPodsPolicy),
+		RebootAction:                    agent.RebootAction(*rebootAction),
+		CordonMethod:                    agent.CordonMethod(*cordonMethod),
+		ManualUncordon:                  *manualUncordon,
+		VersionOSReleaseKey:             *versionOSReleaseKey,
+		EvictStatefulPodsLast:           *evictStatefulPodsLast,
+		PreserveRebootNeededOnStartup:   *preserveRebootNeededOnStartup,
+		RebootRetries:                   *rebootRetries,
+		RebootRetryBackoff:              *rebootRetryBackoff,
+		MaxWatchRetryBackoff:            *maxWatchRetryBackoff,
+		RebootTimeout:                   *rebootTimeout,
+		SkipWaitForNotOkToReboot:        *skipWaitForNotOkToReboot,
+		ReportRebootBlockedReason:       *reportRebootBlockedReason,
+		RebootWindowStart:               *rebootWindowStart,
+		RebootWindowLength:              *rebootWindowLength,
+		WatchPodTermination:             *watchPodTermination,
+		PodDeletionGracePeriodOverrides: gracePeriodOverrides,
+		OSReleasePaths:                  osReleasePaths,
+		DecisionsOutput:                 decisionsOutput,
+		UpdateConfPaths:                 updateConfPaths,
+		DrainOutputVerbosity:            *drainOutputVerbosity,
+	}
+
+	if *podTerminationGracePeriod >= 0 {
+		override := time.Duration(*podTerminationGracePeriod) * time.Second
+		config.PodTerminationGracePeriodOverride = &override
 	}
 
 	agent, err := agent.New(config)
@@ -83,10 +346,159 @@ func main() {
 		klog.Fatalf("Failed to initialize %s: %v", os.Args[0], err)
 	}
 
+	if *metricsAddress != "" {
+		go serveMetrics(*metricsAddress, metricsRegistry)
+	}
+
 	klog.Infof("%s running", os.Args[0])
 
+	// Cancel context on termination, so agent can shut down gracefully.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if *triggerCheckOnStart {
+		if err := updateEngineClient.AttemptUpdate(ctx); err != nil {
+			klog.Warningf("Failed triggering update check: %v", err)
+		} else {
+			klog.Info("Triggered update check")
+		}
+	}
+
 	// Run agent until the context is cancelled.
-	if err := agent.Run(context.Background()); err != nil {
+	if err := agent.Run(ctx); err != nil {
 		klog.Fatalf("Error running agent: %v", err)
 	}
 }
+
+const (
+	initialConnectRetryBackoff = time.Second
+	maxConnectRetryBackoff     = 30 * time.Second
+)
+
+// connectWithRetry calls given connect function until it succeeds, waiting between attempts with
+// exponential backoff. Once given timeout elapses, the error from the last attempt is returned.
+func connectWithRetry(name string, timeout time.Duration, connect func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := initialConnectRetryBackoff
+
+	for {
+		err := connect()
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return err
+		}
+
+		if backoff > remaining {
+			backoff = remaining
+		}
+
+		klog.Warningf("Failed connecting to %s, retrying in %v: %v", name, backoff, err)
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxConnectRetryBackoff {
+			backoff = maxConnectRetryBackoff
+		}
+	}
+}
+
+// updateEngineConfig returns D-Bus names of update_engine configured using flags.
+func updateEngineConfig() updateengine.Config {
+	return updateengine.Config{
+		Path:        *updateEngineDBusPath,
+		Destination: *updateEngineDBusDestination,
+		Interface:   *updateEngineDBusInterface,
+	}
+}
+
+const dbusSetupHint = "ensure host's /var/run/dbus directory is mounted into the container and " +
+	"--dbus-auth-methods match the system bus configuration"
+
+// runSelfTest verifies connectivity and permissions required to talk to update_engine and logind
+// over the system D-Bus, reporting the result of each check. It returns true if all checks passed.
+func runSelfTest(authMethods []godbus.Auth) bool {
+	passed := true
+
+	updateEngineClient, err := updateengine.NewWithConfig(dbus.SystemPrivateConnector, updateEngineConfig(),
+		authMethods...)
+	if err != nil {
+		klog.Errorf("FAIL: connecting to update_engine over the system D-Bus: %v; %s", err, dbusSetupHint)
+
+		passed = false
+	} else {
+		if _, err := updateEngineClient.LastAttemptError(); err != nil {
+			klog.Errorf("FAIL: querying update_engine: %v; ensure update-engine.service is running on the host "+
+				"and D-Bus policy allows the agent's user to talk to it", err)
+
+			passed = false
+		} else {
+			klog.Info("OK: update_engine is reachable over the system D-Bus")
+		}
+
+		if err := updateEngineClient.Close(); err != nil {
+			klog.Warningf("Failed gracefully closing update_engine client: %v", err)
+		}
+	}
+
+	rebooter, err := login1.New(dbus.SystemPrivateConnector, authMethods...)
+	if err != nil {
+		klog.Errorf("FAIL: connecting to logind over the system D-Bus: %v; %s", err, dbusSetupHint)
+
+		return false
+	}
+
+	defer func() {
+		if err := rebooter.Close(); err != nil {
+			klog.Warningf("Failed gracefully closing logind client: %v", err)
+		}
+	}()
+
+	canReboot, err := rebooter.CanReboot()
+
+	switch {
+	case err != nil:
+		klog.Errorf("FAIL: querying logind: %v; ensure systemd-logind.service is running on the host", err)
+
+		return false
+	case canReboot == "yes":
+		klog.Info("OK: logind is reachable over the system D-Bus and allows rebooting")
+	case canReboot == "challenge" && *rebootInteractiveAuth:
+		klog.Info("OK: logind is reachable over the system D-Bus and allows rebooting with interactive authentication")
+	case canReboot == "challenge":
+		klog.Errorf("FAIL: logind requires interactive authentication to reboot; run the agent with " +
+			"--reboot-interactive-auth or adjust polkit policy on the host")
+
+		return false
+	default:
+		klog.Errorf("FAIL: logind does not allow rebooting (%q); ensure the agent runs as root or polkit policy "+
+			"on the host allows it to reboot", canReboot)
+
+		return false
+	}
+
+	return passed
+}
+
+const httpReadHeaderTimeout = 10 * time.Second
+
+func serveMetrics(address string, gatherer prometheus.Gatherer) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+
+	klog.Infof("Serving metrics on %q", address)
+
+	if err := server.ListenAndServe(); err != nil {
+		klog.Fatalf("Failed serving metrics: %v", err)
+	}
+}
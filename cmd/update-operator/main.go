@@ -2,11 +2,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	// Embed time zone database, as container image does not ship one, so reboot window timezone can be used.
+	_ "time/tzdata"
 
 	"github.com/coreos/pkg/flagutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
@@ -14,15 +27,77 @@ import (
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/version"
 )
 
+const (
+	// defaultWarmupPeriod gives agents time to report status of their nodes at least once.
+	defaultWarmupPeriod = 30 * time.Second
+	// defaultLeaderHandoffGracePeriod gives ongoing reconciliation time to finish before leadership is released.
+	defaultLeaderHandoffGracePeriod = 10 * time.Second
+)
+
 type flagsSet struct {
-	beforeRebootAnnotations flagutil.StringSliceFlag
-	afterRebootAnnotations  flagutil.StringSliceFlag
-	kubeconfig              *string
-	rebootWindowStart       *string
-	rebootWindowLength      *string
-	printVersion            *bool
+	beforeRebootAnnotations      flagutil.StringSliceFlag
+	afterRebootAnnotations       flagutil.StringSliceFlag
+	requiredNodeConditions       flagutil.StringSliceFlag
+	updateErrorStatuses          flagutil.StringSliceFlag
+	annotationTrueValues         flagutil.StringSliceFlag
+	hubKubeconfigs               flagutil.StringSliceFlag
+	beforeRebootAnnotationGroups *string
+	afterRebootAnnotationGroups  *string
+	kubeconfig                   *string
+	rebootWindowStart            *string
+	rebootWindowLength           *string
+	rebootWindowCron             *string
+	rebootWindowTimezone         *string
+	nodeUpdateConcurrency        *int
+	hubMaxRebootingNodes         *int
+	overdueMaxRebootingNodes     *int
+	maxPreparingNodes            *int
+	maxRebootingNodesPerZone     *int
+	zoneLabel                    *string
+	adaptiveMaxRebootingNodes    *string
+	maxRebootingNodesPercent     *int
+	minReadyNodes                *int
+	minReadyNodesPercent         *int
+	oneShot                      *bool
+	dryRun                       *bool
+	requireManualApproval        *bool
+	rebootControlPlane           *bool
+	blockDowngrades              *bool
+	cordonBeforeReboot           *bool
+	agentHeartbeatTimeout        *time.Duration
+	staleAnnotationsTimeout      *time.Duration
+	staleRebootInProgressTimeout *time.Duration
+	updateErrorStatusTimeout     *time.Duration
+	rebootPauseTimeout           *time.Duration
+	afterRebootTimeout           *time.Duration
+	afterRebootTimeoutPolicy     *string
+	beforeRebootTimeout          *time.Duration
+	hookPollPeriod               *time.Duration
+	warmupPeriod                 *time.Duration
+	leaderHandoffGracePeriod     *time.Duration
+	leaderElectionLease          *time.Duration
+	leaderElectionRenewDeadline  *time.Duration
+	leaderElectionRetryPeriod    *time.Duration
+	rebootDeadline               *time.Duration
+	postRebootReadyPeriod        *time.Duration
+	rebootBlockingAlertsURL      *string
+	nodeOrdering                 *string
+	rebootPriorityAnnotation     *string
+	maintenanceNodeSelector      *string
+	stateConfigMap               *string
+	nodeSelector                 *string
+	rebootRequestAnnotation      *string
+	httpAddress                  *string
+	healthAddress                *string
+	reconcileTokenFile           *string
+	eventNamespace               *string
+	pauseConfigMap               *string
+	leaderElectionResourceLock   *string
+	dumpSupportBundle            *string
+	printVersion                 *bool
 }
 
+//nolint:funlen // Just many flags to define.
 func handleFlags() *flagsSet {
 	flags := &flagsSet{
 		kubeconfig: flag.String("kubeconfig", "",
@@ -33,7 +108,223 @@ func handleFlags() *flagsSet {
 				"E.g. 'Mon 14:00', '11:00'"),
 
 		rebootWindowLength: flag.String("reboot-window-length", "", "Length of the reboot window. E.g. '1h30m'"),
-		printVersion:       flag.Bool("version", false, "Print version and exit"),
+
+		rebootWindowCron: flag.String("reboot-window-cron", "",
+			"Cron expression describing when the reboot window starts, as an alternative to --reboot-window-start. "+
+				"E.g. '0 14 * * Mon'"),
+
+		rebootWindowTimezone: flag.String("reboot-window-timezone", "",
+			"IANA time zone name in which the reboot window is evaluated. E.g. 'Europe/Berlin'. Defaults to UTC"),
+
+		nodeUpdateConcurrency: flag.Int("node-update-concurrency", 10,
+			"Maximum number of nodes updated in parallel within a single reconciliation step"),
+
+		oneShot: flag.Bool("one-shot", false,
+			"Exit once no node needs a reboot and no node is in the process of rebooting"),
+
+		dryRun: flag.Bool("dry-run", false,
+			"Evaluate nodes and emit events as usual, but only log which nodes would be scheduled for before or "+
+				"after reboot checks or approved to reboot, without updating them"),
+
+		requireManualApproval: flag.Bool("require-manual-approval", false,
+			"Approve reboot of a node which passed before reboot checks only once the approved-by annotation is set "+
+				"on it. Until then, the node is marked with the pending-approval annotation"),
+
+		rebootControlPlane: flag.Bool("reboot-control-plane", false,
+			"Schedule reboots of control-plane nodes, identified by 'node-role.kubernetes.io/control-plane' or "+
+				"'node-role.kubernetes.io/master' label or taint. By default, control-plane nodes are skipped"),
+
+		blockDowngrades: flag.Bool("block-downgrades", false,
+			"Do not schedule reboots of nodes which update_engine reports a new version lower than the version "+
+				"they currently run and emit a warning event about them. Versions which are not valid semver "+
+				"are not checked"),
+
+		cordonBeforeReboot: flag.Bool("cordon-before-reboot", false,
+			"Mark nodes as unschedulable once they are scheduled for rebooting, before running before-reboot "+
+				"checks. Such nodes are marked as schedulable again after they pass after-reboot checks"),
+
+		agentHeartbeatTimeout: flag.Duration("agent-heartbeat-timeout", 0,
+			"Skip nodes which agent has not reported a heartbeat within given period when scheduling reboots, "+
+				"e.g. '10m'. Disabled if zero"),
+
+		postRebootReadyPeriod: flag.Duration("post-reboot-ready-period", 0,
+			"Period for which a node which finished rebooting must stay Ready before other nodes are rebooted, "+
+				"e.g. '15m'. If the node becomes not Ready within the period, reboots are halted until the "+
+				"post-reboot-verification-failed annotation is removed from it. Disabled if zero"),
+
+		staleAnnotationsTimeout: flag.Duration("stale-annotations-timeout", 0,
+			"Remove update_engine status annotations from nodes which are not rebooting and have not reported "+
+				"an update check within given period, e.g. '168h'. Disabled if zero"),
+
+		staleRebootInProgressTimeout: flag.Duration("stale-reboot-in-progress-timeout", 0,
+			"Clear reboot-in-progress annotation and withdraw reboot approval of nodes which agent has not "+
+				"reported a heartbeat within given period, e.g. '1h'. Disabled if zero"),
+
+		updateErrorStatusTimeout: flag.Duration("update-error-status-timeout", 0,
+			"Mark nodes which update_engine has been reporting one of --update-error-statuses for longer than "+
+				"given period, e.g. '24h', with update-failed annotation and emit a warning event about them. "+
+				"Disabled if zero"),
+
+		rebootPauseTimeout: flag.Duration("reboot-pause-timeout", 0,
+			"Mark nodes which have needed a reboot while having reboot paused for longer than given period, "+
+				"e.g. '168h', with reboot-paused-too-long annotation and emit a warning event about them. "+
+				"Disabled if zero"),
+
+		afterRebootTimeout: flag.Duration("after-reboot-timeout", 0,
+			"Stop waiting for after-reboot annotations on nodes which have not got them within given period, "+
+				"e.g. '1h', handling them according to --after-reboot-timeout-policy and emitting a warning "+
+				"event about them. Disabled if zero"),
+
+		afterRebootTimeoutPolicy: flag.String("after-reboot-timeout-policy",
+			string(operator.AfterRebootTimeoutPolicyFinalize),
+			"What to do with nodes which have not passed after-reboot checks within --after-reboot-timeout. "+
+				"One of 'finalize' (finish the reboot as if checks passed) or 'hold' (keep waiting for the checks "+
+				"and mark the node with after-reboot-timed-out annotation)"),
+
+		beforeRebootTimeout: flag.Duration("before-reboot-timeout", 0,
+			"Mark nodes which have not got before-reboot annotations within given period, e.g. '1h', with "+
+				"before-reboot-timed-out annotation and emit a warning event about them. Disabled if zero"),
+
+		warmupPeriod: flag.Duration("warmup-period", defaultWarmupPeriod,
+			"Do not schedule nor approve new reboots for given period after becoming a leader, giving agents "+
+				"time to refresh status of their nodes. Disabled if zero"),
+
+		leaderHandoffGracePeriod: flag.Duration("leader-handoff-grace-period", defaultLeaderHandoffGracePeriod,
+			"Release leadership on shutdown once ongoing reconciliation finishes, waiting up to given period, "+
+				"so a standby operator takes over without waiting for the lease to expire. Disabled if zero"),
+
+		leaderElectionLease: flag.Duration("leader-election-lease-duration", 0,
+			"How long standby operators wait before taking over the leadership from a leader which stopped "+
+				"renewing it. Defaults to 90s"),
+
+		leaderElectionRenewDeadline: flag.Duration("leader-election-renew-deadline", 0,
+			"How long the leader keeps trying to renew the leadership before giving it up. Must be shorter than "+
+				"--leader-election-lease-duration. Defaults to 2/3 of it"),
+
+		leaderElectionRetryPeriod: flag.Duration("leader-election-retry-period", 0,
+			"How often leader election actions are retried. Must be shorter than --leader-election-renew-deadline "+
+				"divided by 1.2. Defaults to 1/3 of --leader-election-lease-duration"),
+
+		hookPollPeriod: flag.Duration("hook-poll-period", 0,
+			"Additionally check nodes waiting for before or after reboot checks each given period, e.g. '5s', "+
+				"and process them as soon as they complete the checks, without waiting for the next "+
+				"reconciliation. Disabled if zero"),
+
+		rebootBlockingAlertsURL: flag.String("reboot-blocking-alerts-url", "",
+			"URL of Alertmanager v2 API compatible endpoint returning list of alerts, e.g. "+
+				"'http://alertmanager:9093/api/v2/alerts?active=true&filter=severity=\"critical\"'. "+
+				"No new reboots are approved while the list is not empty or cannot be fetched. Disabled if empty"),
+
+		rebootDeadline: flag.Duration("reboot-deadline", 0,
+			"Schedule nodes which have needed a reboot for longer than given period, e.g. '72h', "+
+				"for rebooting before other nodes. Disabled if zero"),
+
+		overdueMaxRebootingNodes: flag.Int("overdue-max-rebooting-nodes", 0,
+			"Allow scheduling nodes which have needed a reboot for longer than --reboot-deadline for rebooting "+
+				"even if another node is rebooting, as long as no more than given number of nodes reboot "+
+				"simultaneously. Disabled if zero"),
+
+		adaptiveMaxRebootingNodes: flag.String("adaptive-max-rebooting-nodes", "",
+			"Recompute maximum number of nodes rebooting simultaneously each reconciliation from the number of "+
+				"nodes in the cluster using given formula, e.g. 'percent=10,rounding=ceil,min=1,max=5'. "+
+				"Rounding may be 'floor' or 'ceil' and defaults to 'floor', min defaults to 1 and max is "+
+				"unbounded if omitted. Disabled if empty"),
+
+		maxRebootingNodesPercent: flag.Int("max-rebooting-nodes-percent", 0,
+			"Recompute maximum number of nodes rebooting simultaneously each reconciliation as given percentage "+
+				"of the number of nodes in the cluster, rounded down, but at least 1. Disabled if zero"),
+
+		minReadyNodes: flag.Int("min-ready-nodes", 0,
+			"Do not schedule nor approve new reboots while fewer than given number of nodes are Ready. "+
+				"Disabled if zero"),
+
+		minReadyNodesPercent: flag.Int("min-ready-nodes-percent", 0,
+			"Do not schedule nor approve new reboots while fewer than given percentage of nodes, rounded up, "+
+				"are Ready. Disabled if zero"),
+
+		maxPreparingNodes: flag.Int("max-preparing-nodes", 0,
+			"Allow up to given number of nodes to run before-reboot checks at the same time, even if another "+
+				"node is rebooting. Nodes are still approved to reboot one at a time. Disabled if zero"),
+
+		maxRebootingNodesPerZone: flag.Int("max-rebooting-nodes-per-zone", 0,
+			"Maximum number of nodes in the same zone rebooting simultaneously, on top of the maximum number of "+
+				"rebooting nodes in the cluster. Zone of the node is given by --zone-label. Disabled if zero"),
+
+		zoneLabel: flag.String("zone-label", corev1.LabelTopologyZone,
+			"Label which value identifies the zone of the node for --max-rebooting-nodes-per-zone. "+
+				"Nodes without the label are considered to be in the same zone"),
+
+		nodeOrdering: flag.String("node-ordering", "",
+			"Order in which nodes needing a reboot are scheduled for rebooting, based on node creation time. "+
+				"One of 'oldest-first', 'newest-first'. Order returned by the API server is used if empty"),
+
+		rebootPriorityAnnotation: flag.String("reboot-priority-annotation", "",
+			"Annotation which integer value defines the priority of the node for rebooting, e.g. "+
+				"'example.com/reboot-priority'. Nodes with higher priority are scheduled for rebooting first, on "+
+				"top of --node-ordering. Nodes without the annotation have priority 0. Disabled if empty"),
+
+		maintenanceNodeSelector: flag.String("maintenance-node-selector", "",
+			"Label selector of nodes to reboot once regardless of their update state, respecting reboot window, "+
+				"maximum number of rebooting nodes and reboot checks, e.g. 'example.com/maintenance=true'. "+
+				"Disabled if empty"),
+
+		stateConfigMap: flag.String("state-configmap", "",
+			"Name of ConfigMap in the operator namespace, where scheduling state kept in memory is persisted, so "+
+				"it survives restarts and leader failovers. Requires permission to create, get and update the "+
+				"ConfigMap. Disabled if empty"),
+
+		nodeSelector: flag.String("node-selector", "",
+			"Label selector of nodes managed by the operator, e.g. 'example.com/pool=workers'. Nodes which do not "+
+				"match are ignored entirely. All nodes are managed if empty"),
+
+		rebootRequestAnnotation: flag.String("reboot-request-annotation", "",
+			"Annotation key, e.g. 'flatcar-linux-update.v1.flatcar-linux.net/reboot-requested', which when set to "+
+				"'true' on a node or on a pod running on it requests a reboot of the node through the regular reboot "+
+				"process. Requires permission to list pods. Disabled if empty"),
+
+		httpAddress: flag.String("http-address", "",
+			"Address to serve HTTP endpoints like /converged and Prometheus metrics at /metrics on, e.g. ':8080'. "+
+				"Disabled if empty"),
+
+		healthAddress: flag.String("health-address", "",
+			"Address to serve /healthz and /readyz endpoints for liveness and readiness probes on, e.g. ':8081'. "+
+				"Disabled if empty"),
+
+		eventNamespace: flag.String("event-namespace", "",
+			"Namespace where leader election events are published, created if it does not exist. "+
+				"Defaults to the namespace operator runs in"),
+
+		pauseConfigMap: flag.String("pause-configmap", "",
+			"Name of ConfigMap in the operator namespace, which, when it has key 'paused' set to 'true', pauses "+
+				"scheduling and approving new reboots of all nodes. Requires permission to get the ConfigMap. "+
+				"Disabled if empty"),
+
+		leaderElectionResourceLock: flag.String("leader-election-resource-lock", "configmap",
+			"Type of the leader election lock. One of 'configmap' or 'lease'. As plain ConfigMap lock is no longer "+
+				"supported, 'configmap' lock also maintains a Lease, so migrating to 'lease' keeps the leadership"),
+
+		dumpSupportBundle: flag.String("dump-support-bundle", "",
+			"Write effective flags, update state of all nodes, recent events about nodes and leader election "+
+				"record as JSON to given path, or to stdout if '-', to attach to bug reports, then exit"),
+
+		reconcileTokenFile: flag.String("reconcile-token-file", "",
+			"Path to a file containing a token, which must be sent as a bearer token with POST requests to "+
+				"/reconcile HTTP endpoint to trigger reconciliation immediately. Endpoint is disabled if empty"),
+
+		beforeRebootAnnotationGroups: flag.String("before-reboot-annotation-groups", "",
+			"List of semicolon-separated groups of comma-separated Kubernetes node annotations, where all annotations "+
+				"from any of the groups must be set to 'true' before a reboot is allowed. "+
+				"E.g. 'anno1,anno2;anno3'. Cannot be used together with --before-reboot-annotations"),
+
+		afterRebootAnnotationGroups: flag.String("after-reboot-annotation-groups", "",
+			"List of semicolon-separated groups of comma-separated Kubernetes node annotations, where all annotations "+
+				"from any of the groups must be set to 'true' before a node is marked schedulable and the operator "+
+				"lock is released. Cannot be used together with --after-reboot-annotations"),
+
+		hubMaxRebootingNodes: flag.Int("hub-max-rebooting-nodes", 1,
+			"Maximum number of nodes rebooting simultaneously across all clusters given with --hub-kubeconfigs"),
+
+		printVersion: flag.Bool("version", false, "Print version and exit"),
 	}
 
 	flag.Var(&flags.beforeRebootAnnotations, "before-reboot-annotations",
@@ -43,6 +334,23 @@ func handleFlags() *flagsSet {
 		"List of comma-separated Kubernetes node annotations that must be set to 'true' before a node is marked "+
 			"schedulable and the operator lock is released")
 
+	flag.Var(&flags.requiredNodeConditions, "required-node-conditions",
+		"List of comma-separated node conditions in 'Type=Status' format, e.g. 'StorageHealthy=True', "+
+			"which node must have before a reboot is allowed. Status defaults to 'True' if omitted")
+
+	flag.Var(&flags.updateErrorStatuses, "update-error-statuses",
+		"List of comma-separated update_engine statuses considered as errors when --update-error-status-timeout "+
+			"is set. Defaults to 'UPDATE_STATUS_REPORTING_ERROR_EVENT'")
+
+	flag.Var(&flags.annotationTrueValues, "annotation-true-values",
+		"List of comma-separated values, e.g. 'true,True,1', to which before and after reboot annotations "+
+			"must be set for checks to pass. Defaults to 'true'")
+
+	flag.Var(&flags.hubKubeconfigs, "hub-kubeconfigs",
+		"List of comma-separated paths to kubeconfig files of clusters which nodes are updated by a single operator, "+
+			"allowing at most --hub-max-rebooting-nodes nodes to reboot simultaneously across all of them. "+
+			"Cannot be used together with --kubeconfig, --http-address and --health-address")
+
 	klog.InitFlags(nil)
 
 	if err := flag.Set("logtostderr", "true"); err != nil {
@@ -71,12 +379,6 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Create Kubernetes client (clientset).
-	client, err := k8sutil.GetClient(*flags.kubeconfig)
-	if err != nil {
-		klog.Fatalf("Failed to create Kubernetes client: %v", err)
-	}
-
 	namespace := os.Getenv("POD_NAMESPACE")
 	if namespace == "" {
 		klog.Fatalf("Unable to determine operator namespace: please ensure POD_NAMESPACE environment variable is set")
@@ -91,20 +393,48 @@ func main() {
 		klog.Fatalf("Getting hostname: %v", err)
 	}
 
+	if len(flags.hubKubeconfigs) > 0 {
+		runHub(flags, namespace, hostname)
+
+		return
+	}
+
+	// Create Kubernetes client (clientset).
+	client, err := k8sutil.GetClient(*flags.kubeconfig)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	metricsRegistry := prometheus.NewRegistry()
+
+	config := operatorConfig(flags)
+	config.Client = client
+	config.MetricsRegisterer = metricsRegistry
+	config.Namespace = namespace
+	config.LockID = hostname
+
 	// Construct update-operator.
-	operatorInstance, err := operator.New(operator.Config{
-		Client:                  client,
-		BeforeRebootAnnotations: flags.beforeRebootAnnotations,
-		AfterRebootAnnotations:  flags.afterRebootAnnotations,
-		RebootWindowStart:       *flags.rebootWindowStart,
-		RebootWindowLength:      *flags.rebootWindowLength,
-		Namespace:               namespace,
-		LockID:                  hostname,
-	})
+	operatorInstance, err := operator.New(config)
 	if err != nil {
 		klog.Fatalf("Failed to initialize %s: %v", os.Args[0], err)
 	}
 
+	if *flags.dumpSupportBundle != "" {
+		if err := dumpSupportBundle(operatorInstance, *flags.dumpSupportBundle); err != nil {
+			klog.Fatalf("Failed dumping support bundle: %v", err)
+		}
+
+		return
+	}
+
+	if *flags.httpAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", operatorInstance.HTTPHandler())
+		mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+
+		go serveHTTP(*flags.httpAddress, mux)
+	}
+
 	klog.Infof("%s running", os.Args[0])
 
 	// Run operator until the stop channel is closed.
@@ -115,3 +445,238 @@ func main() {
 		klog.Fatalf("Error while running %s: %v", os.Args[0], err)
 	}
 }
+
+const (
+	supportBundleTimeout  = time.Minute
+	supportBundleFileMode = 0o600
+)
+
+// dumpSupportBundle writes support bundle gathered by given operator instance as JSON to given path,
+// or to stdout if path is "-".
+func dumpSupportBundle(operatorInstance *operator.Kontroller, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), supportBundleTimeout)
+	defer cancel()
+
+	bundle, err := operatorInstance.SupportBundle(ctx)
+	if err != nil {
+		return fmt.Errorf("gathering support bundle: %w", err)
+	}
+
+	bundle.Version = version.Version
+	bundle.Flags = map[string]string{}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		bundle.Flags[f.Name] = f.Value.String()
+	})
+
+	output, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding support bundle: %w", err)
+	}
+
+	output = append(output, '\n')
+
+	if path == "-" {
+		if _, err := os.Stdout.Write(output); err != nil {
+			return fmt.Errorf("writing support bundle to stdout: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := os.WriteFile(path, output, supportBundleFileMode); err != nil {
+		return fmt.Errorf("writing support bundle to %q: %w", path, err)
+	}
+
+	klog.Infof("Support bundle written to %q", path)
+
+	return nil
+}
+
+// runHub runs an operator instance for each cluster given with hub kubeconfigs, sharing the reboot budget
+// between them. Instances are stopped on SIGTERM or SIGINT and it returns once all of them have finished.
+func runHub(flags *flagsSet, namespace, hostname string) {
+	if *flags.kubeconfig != "" {
+		klog.Fatalf("Flag --hub-kubeconfigs cannot be used together with --kubeconfig")
+	}
+
+	if *flags.httpAddress != "" {
+		klog.Fatalf("Flag --hub-kubeconfigs cannot be used together with --http-address")
+	}
+
+	if *flags.healthAddress != "" {
+		klog.Fatalf("Flag --hub-kubeconfigs cannot be used together with --health-address")
+	}
+
+	if *flags.hubMaxRebootingNodes < 1 {
+		klog.Fatalf("Flag --hub-max-rebooting-nodes must be at least 1, got %d", *flags.hubMaxRebootingNodes)
+	}
+
+	rebootBudget := operator.NewRebootBudget(*flags.hubMaxRebootingNodes)
+
+	operatorInstances := make([]*operator.Kontroller, 0, len(flags.hubKubeconfigs))
+
+	for _, kubeconfig := range flags.hubKubeconfigs {
+		client, err := k8sutil.GetClient(kubeconfig)
+		if err != nil {
+			klog.Fatalf("Failed to create Kubernetes client for kubeconfig %q: %v", kubeconfig, err)
+		}
+
+		config := operatorConfig(flags)
+		config.Client = client
+		config.Namespace = namespace
+		config.LockID = hostname
+		config.RebootBudget = rebootBudget
+		config.RebootBudgetMember = kubeconfig
+
+		operatorInstance, err := operator.New(config)
+		if err != nil {
+			klog.Fatalf("Failed to initialize %s for kubeconfig %q: %v", os.Args[0], kubeconfig, err)
+		}
+
+		operatorInstances = append(operatorInstances, operatorInstance)
+	}
+
+	klog.Infof("%s running for %d clusters", os.Args[0], len(operatorInstances))
+
+	// Stop operators on termination, so they can shut down gracefully and release shared reboot budget.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var wg sync.WaitGroup
+
+	for i, operatorInstance := range operatorInstances {
+		wg.Add(1)
+
+		go func(kubeconfig string, operatorInstance *operator.Kontroller) {
+			defer wg.Done()
+
+			if err := operatorInstance.Run(ctx.Done()); err != nil {
+				klog.Fatalf("Error while running %s for kubeconfig %q: %v", os.Args[0], kubeconfig, err)
+			}
+		}(flags.hubKubeconfigs[i], operatorInstance)
+	}
+
+	wg.Wait()
+}
+
+// operatorConfig returns operator configuration based on given flags, without cluster specific options.
+func operatorConfig(flags *flagsSet) operator.Config {
+	var rebootBlocker operator.RebootBlocker
+	if *flags.rebootBlockingAlertsURL != "" {
+		rebootBlocker = operator.NewAlertsRebootBlocker(*flags.rebootBlockingAlertsURL)
+	}
+
+	return operator.Config{
+		BeforeRebootAnnotations:      flags.beforeRebootAnnotations,
+		AfterRebootAnnotations:       flags.afterRebootAnnotations,
+		BeforeRebootAnnotationGroups: parseAnnotationGroups(*flags.beforeRebootAnnotationGroups),
+		AfterRebootAnnotationGroups:  parseAnnotationGroups(*flags.afterRebootAnnotationGroups),
+		RebootWindowStart:            *flags.rebootWindowStart,
+		RebootWindowLength:           *flags.rebootWindowLength,
+		RebootWindowCron:             *flags.rebootWindowCron,
+		RebootWindowTimezone:         *flags.rebootWindowTimezone,
+		NodeUpdateConcurrency:        *flags.nodeUpdateConcurrency,
+		OneShot:                      *flags.oneShot,
+		AgentHeartbeatTimeout:        *flags.agentHeartbeatTimeout,
+		StaleAnnotationsTimeout:      *flags.staleAnnotationsTimeout,
+		StaleRebootInProgressTimeout: *flags.staleRebootInProgressTimeout,
+		UpdateErrorStatusTimeout:     *flags.updateErrorStatusTimeout,
+		UpdateErrorStatuses:          flags.updateErrorStatuses,
+		AnnotationTrueValues:         flags.annotationTrueValues,
+		RebootPauseTimeout:           *flags.rebootPauseTimeout,
+		AfterRebootTimeout:           *flags.afterRebootTimeout,
+		AfterRebootTimeoutPolicy:     operator.AfterRebootTimeoutPolicy(*flags.afterRebootTimeoutPolicy),
+		BeforeRebootTimeout:          *flags.beforeRebootTimeout,
+		HookPollPeriod:               *flags.hookPollPeriod,
+		WarmupPeriod:                 *flags.warmupPeriod,
+		LeaderHandoffGracePeriod:     *flags.leaderHandoffGracePeriod,
+		LeaderElectionLease:          *flags.leaderElectionLease,
+		LeaderElectionRenewDeadline:  *flags.leaderElectionRenewDeadline,
+		LeaderElectionRetryPeriod:    *flags.leaderElectionRetryPeriod,
+		RebootDeadline:               *flags.rebootDeadline,
+		OverdueMaxRebootingNodes:     *flags.overdueMaxRebootingNodes,
+		MaxPreparingNodes:            *flags.maxPreparingNodes,
+		MaxRebootingNodesPerZone:     *flags.maxRebootingNodesPerZone,
+		ZoneLabel:                    *flags.zoneLabel,
+		AdaptiveMaxRebootingNodes:    *flags.adaptiveMaxRebootingNodes,
+		MaxRebootingNodesPercent:     *flags.maxRebootingNodesPercent,
+		MinReadyNodes:                *flags.minReadyNodes,
+		MinReadyNodesPercent:         *flags.minReadyNodesPercent,
+		PostRebootReadyPeriod:        *flags.postRebootReadyPeriod,
+		RebootBlocker:                rebootBlocker,
+		NodeOrdering:                 operator.NodeOrdering(*flags.nodeOrdering),
+		RebootPriorityAnnotation:     *flags.rebootPriorityAnnotation,
+		MaintenanceNodeSelector:      *flags.maintenanceNodeSelector,
+		StateConfigMap:               *flags.stateConfigMap,
+		HealthAddress:                *flags.healthAddress,
+		NodeSelector:                 *flags.nodeSelector,
+		RebootRequestAnnotation:      *flags.rebootRequestAnnotation,
+		RequiredNodeConditions:       flags.requiredNodeConditions,
+		RequireManualApproval:        *flags.requireManualApproval,
+		RebootControlPlane:           *flags.rebootControlPlane,
+		BlockDowngrades:              *flags.blockDowngrades,
+		CordonBeforeReboot:           *flags.cordonBeforeReboot,
+		ReconcileToken:               readReconcileToken(*flags.reconcileTokenFile),
+		EventNamespace:               *flags.eventNamespace,
+		LockType:                     *flags.leaderElectionResourceLock,
+		DryRun:                       *flags.dryRun,
+		PauseConfigMap:               *flags.pauseConfigMap,
+	}
+}
+
+// readReconcileToken reads reconcile token from given file. Empty token is returned if path is empty.
+func readReconcileToken(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	token, err := os.ReadFile(path)
+	if err != nil {
+		klog.Fatalf("Failed reading reconcile token file: %v", err)
+	}
+
+	reconcileToken := strings.TrimSpace(string(token))
+	if reconcileToken == "" {
+		klog.Fatalf("Reconcile token file %q is empty", path)
+	}
+
+	return reconcileToken
+}
+
+// parseAnnotationGroups parses semicolon-separated groups of comma-separated annotations.
+func parseAnnotationGroups(value string) [][]string {
+	groups := [][]string{}
+
+	for _, rawGroup := range strings.Split(value, ";") {
+		group := []string{}
+
+		for _, annotation := range strings.Split(rawGroup, ",") {
+			if annotation = strings.TrimSpace(annotation); annotation != "" {
+				group = append(group, annotation)
+			}
+		}
+
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+const httpReadHeaderTimeout = 10 * time.Second
+
+func serveHTTP(address string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+
+	klog.Infof("Serving HTTP endpoints on %q", address)
+
+	if err := server.ListenAndServe(); err != nil {
+		klog.Fatalf("Failed serving HTTP endpoints: %v", err)
+	}
+}
@@ -8,19 +8,35 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	watchtools "k8s.io/client-go/tools/watch"
 	"k8s.io/klog/v2"
 	"k8s.io/kubectl/pkg/drain"
@@ -28,6 +44,7 @@ import (
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/updateengine"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/window"
 )
 
 // Config represents configurable options for agent.
@@ -41,16 +58,171 @@ type Config struct {
 	HostFilesPrefix         string
 	PollInterval            time.Duration
 	MaxOperatorResponseTime time.Duration
+	// When set, node is left cordoned with reboot-in-progress annotation set if agent gets
+	// terminated after reboot has been approved, but before the reboot has been triggered.
+	PreserveNodeStateOnShutdown bool
+	// Label selector, which node must match to be managed by the agent. All nodes are managed if empty.
+	ManagedNodeSelector string
+	// How often agent heartbeat annotation gets updated on the node.
+	HeartbeatInterval time.Duration
+	// When set, reboot is requested with interactive authentication allowed, so polkit policies
+	// requiring it can be satisfied.
+	RebootInteractiveAuth bool
+	// Maximum random delay before agent starts processing, to spread the load on the API server
+	// when many agents start at the same time. No delay is applied if zero.
+	MaxStartupDelay time.Duration
+	// DaemonSets in "namespace/name" format, which pods are drained from the node before rebooting,
+	// as opposed to pods of other DaemonSets, which are ignored.
+	DrainedDaemonSets []string
+	// Namespaces which pods are not removed when draining the node. Empty names are ignored, so
+	// pods from all namespaces are removed if only empty names are given. Defaults to "kube-system".
+	ProtectedNamespaces []string
+	// When set, agent stops with an error instead of proceeding with the reboot when draining the node fails.
+	AbortRebootOnDrainError bool
+	// When set, pods are deleted instead of evicted while draining the node, so PodDisruptionBudgets
+	// are not honored. When not set and eviction of some pods is blocked by PodDisruptionBudgets
+	// for longer than PodDeletionGracePeriod, an event naming the blocking budgets is emitted on the node.
+	IgnorePodDisruptionBudgets bool
+	// Registerer for agent metrics. Metrics are not exposed if not set.
+	MetricsRegisterer prometheus.Registerer
+	// Maximum number of pods per second evicted or deleted while draining the node. Unlimited if zero.
+	MaxPodEvictionRate float64
+	// What to do when some pods are still terminating after PodDeletionGracePeriod is reached.
+	// Defaults to StuckPodsPolicyProceed.
+	StuckPodsPolicy StuckPodsPolicy
+	// When set, error code of the last update attempt is reported on the node when update_engine reports an error.
+	LastAttemptErrorReader LastAttemptErrorReader
+	// Key in /etc/os-release which value is used for the version label, e.g. "VERSION_ID".
+	// Defaults to "VERSION". If the key is not present, "VERSION" is used.
+	VersionOSReleaseKey string
+	// When set, pods owned by StatefulSets are removed only after all other pods have been removed
+	// and terminated while draining the node.
+	EvictStatefulPodsLast bool
+	// When set, reboot-needed annotation and label are not reset on startup, unless the reboot has already
+	// been approved. Instead, they are cleared only if update_engine does not report that reboot is needed.
+	PreserveRebootNeededOnStartup bool
+	// Number of times reboot request is retried when it fails. Not retried if zero.
+	RebootRetries int
+	// Time to wait before the first retry of failed reboot request, doubled after every retry.
+	// Defaults to 1 second.
+	RebootRetryBackoff time.Duration
+	// Time to wait before retrying to get or watch the node after a failure, e.g. while waiting for reboot
+	// approval, doubled with jitter after every consecutive failure, up to MaxWatchRetryBackoff.
+	// Defaults to 1 second.
+	WatchRetryBackoff time.Duration
+	// Maximum time to wait before retrying to get or watch the node after a failure. Defaults to 1 minute.
+	MaxWatchRetryBackoff time.Duration
+	// Start of the local reboot window in "[Day ]HH:MM" format, e.g. "Thu 23:00". When set together
+	// with RebootWindowLength, agent only drains and reboots the node within the window, in node's
+	// local time. If the window closes while draining, the drained node waits for the next window before
+	// rebooting. Reboots are not restricted if empty.
+	RebootWindowStart string
+	// Length of the local reboot window, e.g. "1h30m".
+	RebootWindowLength string
+	// When set, removed pods are waited for to terminate by watching pods on the node instead of
+	// polling each of them. If watching fails, polling is used.
+	WatchPodTermination bool
+	// When set, pods are requested to terminate within given period, rounded down to whole seconds, instead of
+	// their own termination grace period when draining the node. Removed pods are still waited for up to
+	// PodDeletionGracePeriod, so it should be shorter than that.
+	PodTerminationGracePeriodOverride *time.Duration
+	// Overrides of PodDeletionGracePeriod for pods controlled by objects of given kind, in "Kind=duration"
+	// format, e.g. "DaemonSet=15m".
+	PodDeletionGracePeriodOverrides []string
+	// Ordered list of paths of os-release file, where the first existing one is used. Relative to
+	// HostFilesPrefix. Defaults to "/etc/os-release" and "/usr/lib/os-release".
+	OSReleasePaths []string
+	// Ordered list of paths of update.conf file shipped with the image, where the first existing one is used.
+	// Relative to HostFilesPrefix. Defaults to "/usr/share/flatcar/update.conf" and "/usr/share/coreos/update.conf".
+	UpdateConfPaths []string
+	// When set, lifecycle decisions of the agent, like update_engine status changes, cordoning and draining
+	// the node or requesting a reboot, are written to it as JSON objects, one per line.
+	DecisionsOutput io.Writer
+	// klog verbosity level at which output of draining the node is logged. Logged at info level if zero.
+	// Drain errors are always logged as errors.
+	DrainOutputVerbosity int
+	// When set, reboot is requested once again if agent is still running this long after requesting it,
+	// e.g. because an inhibitor lock prevented the reboot. If the node still does not reboot within this
+	// period, agent stops with an error.
+	RebootTimeout time.Duration
+	// When set, agent does not wait on startup for the operator to clear ok-to-reboot annotation left from
+	// the previous reboot. This speeds up recovery when the operator is unavailable, but if ok-to-reboot
+	// annotation is still set once another reboot is needed, the node reboots without operator approval,
+	// possibly reboot-looping.
+	SkipWaitForNotOkToReboot bool
+	// When set, agent reports what it currently waits for before proceeding with the reboot process
+	// using reboot-blocked-reason annotation on the node.
+	ReportRebootBlockedReason bool
+	// What is requested from the host once the node is drained. Rebooter must implement PowerOffer
+	// for RebootActionPowerOff. Defaults to RebootActionReboot.
+	RebootAction RebootAction
+	// How the node is cordoned before draining it. Defaults to CordonMethodUnschedulable.
+	CordonMethod CordonMethod
+	// When set, agent does not uncordon the node it cordoned once it has rebooted. Instead, it sets
+	// awaiting-manual-uncordon annotation on the node, which should be removed once the node has been
+	// verified and uncordoned by a human. Nodes cordoned by operator are handed over the same way,
+	// so operator does not uncordon them either.
+	ManualUncordon bool
 }
 
+// RebootAction defines what agent requests from the host once the node is drained.
+type RebootAction string
+
+const (
+	// RebootActionReboot reboots the host.
+	RebootActionReboot RebootAction = "reboot"
+	// RebootActionPowerOff powers the host off, e.g. when the capacity is being scaled down.
+	RebootActionPowerOff RebootAction = "poweroff"
+)
+
+// CordonMethod defines how agent prevents new pods from being scheduled on the node before draining it.
+type CordonMethod string
+
+const (
+	// CordonMethodUnschedulable marks the node as unschedulable.
+	CordonMethodUnschedulable CordonMethod = "unschedulable"
+	// CordonMethodTaint adds a NoSchedule taint with constants.TaintRebooting key to the node, e.g. when
+	// the unschedulable field is managed by other controllers.
+	CordonMethodTaint CordonMethod = "taint"
+)
+
+// StuckPodsPolicy defines what agent does when some pods are still terminating after
+// pod deletion grace period is reached while draining the node.
+type StuckPodsPolicy string
+
+const (
+	// StuckPodsPolicyProceed handles stuck pods like any other drain error.
+	StuckPodsPolicyProceed StuckPodsPolicy = "proceed"
+	// StuckPodsPolicyAbort aborts the reboot and makes the node schedulable again.
+	StuckPodsPolicyAbort StuckPodsPolicy = "abort"
+	// StuckPodsPolicyExtendOnce waits for stuck pods for one more grace period, then proceeds.
+	StuckPodsPolicyExtendOnce StuckPodsPolicy = "extend-once"
+)
+
 // StatusReceiver describe dependency of object providing status updates from update_engine.
 type StatusReceiver interface {
 	ReceiveStatuses(rcvr chan<- updateengine.Status, stop <-chan struct{})
 }
 
+// LastAttemptErrorReader describes dependency of object providing error code of the last update attempt
+// from update_engine.
+type LastAttemptErrorReader interface {
+	LastAttemptError() (int32, error)
+}
+
 // Rebooter describes dependency of object providing capability of rebooting host machine.
+//
+// Given argument specifies, if interactive authentication should be allowed for the reboot request.
+// Returned error means the reboot has not been requested.
 type Rebooter interface {
-	Reboot(bool)
+	Reboot(bool) error
+}
+
+// PowerOffer describes optional capability of Rebooter to power off host machine.
+//
+// Given argument and returned error have the same meaning as for Rebooter.
+type PowerOffer interface {
+	PowerOff(bool) error
 }
 
 // Klocksmith represents capabilities of agent.
@@ -60,28 +232,90 @@ type Klocksmith interface {
 
 // Klocksmith implements agent part of FLUO.
 type klocksmith struct {
-	nodeName                string
-	nc                      corev1client.NodeInterface
-	clientset               kubernetes.Interface
-	ue                      StatusReceiver
-	lc                      Rebooter
-	reapTimeout             time.Duration
-	forceNodeDrain          bool
-	hostFilesPrefix         string
-	pollInterval            time.Duration
-	maxOperatorResponseTime time.Duration
+	nodeName                    string
+	nc                          corev1client.NodeInterface
+	clientset                   kubernetes.Interface
+	ue                          StatusReceiver
+	lastAttemptErrorReader      LastAttemptErrorReader
+	requestReboot               func(askForAuth bool) error
+	reapTimeout                 time.Duration
+	forceNodeDrain              bool
+	hostFilesPrefix             string
+	pollInterval                time.Duration
+	maxOperatorResponseTime     time.Duration
+	preserveNodeStateOnShutdown bool
+	managedNodeSelector         labels.Selector
+	heartbeatInterval           time.Duration
+	rebootInteractiveAuth       bool
+	maxStartupDelay             time.Duration
+	drainedDaemonSets           map[string]struct{}
+	protectedNamespaces         []string
+	abortRebootOnDrainError     bool
+	ignorePodDisruptionBudgets  bool
+	maxPodEvictionRate          float64
+	stuckPodsPolicy             StuckPodsPolicy
+	cordonMethod                CordonMethod
+	manualUncordon              bool
+	versionOSReleaseKey         string
+	osReleasePaths              []string
+	updateConfPaths             []string
+	decisions                   *decisionRecorder
+	evictStatefulPodsLast       bool
+	preserveRebootNeeded        bool
+	rebootRetries               int
+	rebootRetryBackoff          time.Duration
+	watchRetryBackoff           time.Duration
+	podTerminationGracePeriod   int
+	maxWatchRetryBackoff        time.Duration
+	rebootTimeout               time.Duration
+	skipWaitForNotOkToReboot    bool
+	reportRebootBlockedReason   bool
+	rebootWindow                *window.Periodic
+	watchPodTermination         bool
+	drainOutputVerbosity        klog.Level
+	// Pod deletion grace periods by kind of pod controller.
+	gracePeriodOverrides map[string]time.Duration
+	metrics              *metrics
+	recorder             record.EventRecorder
 }
 
 const (
 	defaultPollInterval            = 10 * time.Second
 	defaultMaxOperatorResponseTime = 24 * time.Hour
+	defaultHeartbeatInterval       = time.Minute
+	defaultRebootRetryBackoff      = time.Second
+	defaultWatchRetryBackoff       = time.Second
+	defaultMaxWatchRetryBackoff    = time.Minute
+	watchRetryBackoffJitter        = 0.1
+	nodeGetRetrySteps              = 4
+	podRemovalRetryInterval        = 5 * time.Second
+	podTerminationPollInterval     = time.Second
+
+	// Keep evicted pods annotation small, as node objects are frequently read.
+	maxEvictedPodsAnnotationLength = 4096
+	shutdownCleanupTimeout         = 30 * time.Second
+
+	eventSourceComponent  = "flatcar-linux-update-agent"
+	eventReasonDrainError = "DrainError"
+
+	eventReasonRebootNeeded         = "RebootNeeded"
+	eventReasonOkToReboot           = "OkToReboot"
+	eventReasonDrainStarted         = "DrainStarted"
+	eventReasonDrainFinished        = "DrainFinished"
+	eventReasonRebootRequested      = "RebootRequested"
+	eventReasonPodsStuckTerminating = "PodsStuckTerminating"
+	eventReasonDrainBlockedByPDB    = "DrainBlockedByPodDisruptionBudget"
+	eventReasonRebootTimedOut       = "RebootTimedOut"
+	eventReasonUpdateError          = "UpdateError"
 
-	updateConfPath         = "/usr/share/flatcar/update.conf"
 	updateConfOverridePath = "/etc/flatcar/update.conf"
-	osReleasePath          = "/etc/os-release"
+
+	defaultVersionOSReleaseKey = "VERSION"
 )
 
 // New returns initialized klocksmith.
+//
+//nolint:funlen // Just many configuration options to process.
 func New(config *Config) (Klocksmith, error) {
 	if config.Clientset == nil {
 		return nil, fmt.Errorf("no clientset configured")
@@ -99,6 +333,94 @@ func New(config *Config) (Klocksmith, error) {
 		return nil, fmt.Errorf("node name can't be empty")
 	}
 
+	if config.MaxOperatorResponseTime < 0 {
+		return nil, fmt.Errorf("max operator response time can't be negative")
+	}
+
+	if config.MaxStartupDelay < 0 {
+		return nil, fmt.Errorf("max startup delay can't be negative")
+	}
+
+	if config.MaxPodEvictionRate < 0 {
+		return nil, fmt.Errorf("max pod eviction rate can't be negative")
+	}
+
+	if config.RebootRetries < 0 {
+		return nil, fmt.Errorf("reboot retries can't be negative")
+	}
+
+	if config.RebootTimeout < 0 {
+		return nil, fmt.Errorf("reboot timeout can't be negative")
+	}
+
+	if config.DrainOutputVerbosity < 0 {
+		return nil, fmt.Errorf("drain output verbosity can't be negative")
+	}
+
+	podTerminationGracePeriod := -1
+
+	if override := config.PodTerminationGracePeriodOverride; override != nil {
+		if *override < 0 {
+			return nil, fmt.Errorf("pod termination grace period override can't be negative")
+		}
+
+		podTerminationGracePeriod = int(override.Seconds())
+	}
+
+	if config.WatchRetryBackoff < 0 {
+		return nil, fmt.Errorf("watch retry backoff can't be negative")
+	}
+
+	if config.MaxWatchRetryBackoff < 0 {
+		return nil, fmt.Errorf("max watch retry backoff can't be negative")
+	}
+
+	if config.HeartbeatInterval < 0 {
+		return nil, fmt.Errorf("heartbeat interval can't be negative")
+	}
+
+	stuckPodsPolicy := config.StuckPodsPolicy
+	if stuckPodsPolicy == "" {
+		stuckPodsPolicy = StuckPodsPolicyProceed
+	}
+
+	switch stuckPodsPolicy {
+	case StuckPodsPolicyProceed, StuckPodsPolicyAbort, StuckPodsPolicyExtendOnce:
+	default:
+		return nil, fmt.Errorf("unsupported stuck pods policy %q", stuckPodsPolicy)
+	}
+
+	cordonMethod := config.CordonMethod
+	if cordonMethod == "" {
+		cordonMethod = CordonMethodUnschedulable
+	}
+
+	switch cordonMethod {
+	case CordonMethodUnschedulable, CordonMethodTaint:
+	default:
+		return nil, fmt.Errorf("unsupported cordon method %q", cordonMethod)
+	}
+
+	requestReboot, err := rebootActionF(config.RebootAction, config.Rebooter)
+	if err != nil {
+		return nil, fmt.Errorf("configuring reboot action: %w", err)
+	}
+
+	drainedDaemonSets, err := parseDrainedDaemonSets(config.DrainedDaemonSets)
+	if err != nil {
+		return nil, fmt.Errorf("parsing drained DaemonSets: %w", err)
+	}
+
+	gracePeriodOverrides, err := parseGracePeriodOverrides(config.PodDeletionGracePeriodOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pod deletion grace period overrides: %w", err)
+	}
+
+	managedNodeSelector, err := labels.Parse(config.ManagedNodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing managed node selector: %w", err)
+	}
+
 	pollInterval := config.PollInterval
 	if pollInterval == 0 {
 		pollInterval = defaultPollInterval
@@ -109,17 +431,109 @@ func New(config *Config) (Klocksmith, error) {
 		maxOperatorResponseTime = defaultMaxOperatorResponseTime
 	}
 
+	metricsRegisterer := config.MetricsRegisterer
+	if metricsRegisterer == nil {
+		metricsRegisterer = prometheus.NewRegistry()
+	}
+
+	metrics, err := newMetrics(metricsRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("creating metrics: %w", err)
+	}
+
+	heartbeatInterval := config.HeartbeatInterval
+	if heartbeatInterval == 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	rebootRetryBackoff := config.RebootRetryBackoff
+	if rebootRetryBackoff == 0 {
+		rebootRetryBackoff = defaultRebootRetryBackoff
+	}
+
+	watchRetryBackoff := config.WatchRetryBackoff
+	if watchRetryBackoff == 0 {
+		watchRetryBackoff = defaultWatchRetryBackoff
+	}
+
+	maxWatchRetryBackoff := config.MaxWatchRetryBackoff
+	if maxWatchRetryBackoff == 0 {
+		maxWatchRetryBackoff = defaultMaxWatchRetryBackoff
+	}
+
+	var rebootWindow *window.Periodic
+
+	if config.RebootWindowStart != "" || config.RebootWindowLength != "" {
+		if config.RebootWindowStart == "" || config.RebootWindowLength == "" {
+			return nil, fmt.Errorf("reboot window start and length must be configured together")
+		}
+
+		rebootWindow, err = window.ParsePeriodic(config.RebootWindowStart, config.RebootWindowLength)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reboot window: %w", err)
+		}
+	}
+
+	versionOSReleaseKey := config.VersionOSReleaseKey
+	if versionOSReleaseKey == "" {
+		versionOSReleaseKey = defaultVersionOSReleaseKey
+	}
+
+	osReleasePaths, err := hostFilePaths(config.OSReleasePaths, defaultOSReleasePaths())
+	if err != nil {
+		return nil, fmt.Errorf("checking os-release paths: %w", err)
+	}
+
+	updateConfPaths, err := hostFilePaths(config.UpdateConfPaths, defaultUpdateConfPaths())
+	if err != nil {
+		return nil, fmt.Errorf("checking update.conf paths: %w", err)
+	}
+
 	return &klocksmith{
-		nodeName:                config.NodeName,
-		nc:                      config.Clientset.CoreV1().Nodes(),
-		clientset:               config.Clientset,
-		ue:                      config.StatusReceiver,
-		lc:                      config.Rebooter,
-		reapTimeout:             config.PodDeletionGracePeriod,
-		forceNodeDrain:          config.ForceNodeDrain,
-		hostFilesPrefix:         config.HostFilesPrefix,
-		pollInterval:            pollInterval,
-		maxOperatorResponseTime: maxOperatorResponseTime,
+		nodeName:                    config.NodeName,
+		nc:                          config.Clientset.CoreV1().Nodes(),
+		clientset:                   config.Clientset,
+		ue:                          config.StatusReceiver,
+		lastAttemptErrorReader:      config.LastAttemptErrorReader,
+		requestReboot:               requestReboot,
+		reapTimeout:                 config.PodDeletionGracePeriod,
+		forceNodeDrain:              config.ForceNodeDrain,
+		hostFilesPrefix:             config.HostFilesPrefix,
+		pollInterval:                pollInterval,
+		maxOperatorResponseTime:     maxOperatorResponseTime,
+		preserveNodeStateOnShutdown: config.PreserveNodeStateOnShutdown,
+		managedNodeSelector:         managedNodeSelector,
+		heartbeatInterval:           heartbeatInterval,
+		rebootInteractiveAuth:       config.RebootInteractiveAuth,
+		maxStartupDelay:             config.MaxStartupDelay,
+		drainedDaemonSets:           drainedDaemonSets,
+		protectedNamespaces:         k8sutil.ProtectedNamespaces(config.ProtectedNamespaces),
+		abortRebootOnDrainError:     config.AbortRebootOnDrainError,
+		ignorePodDisruptionBudgets:  config.IgnorePodDisruptionBudgets,
+		maxPodEvictionRate:          config.MaxPodEvictionRate,
+		stuckPodsPolicy:             stuckPodsPolicy,
+		cordonMethod:                cordonMethod,
+		manualUncordon:              config.ManualUncordon,
+		versionOSReleaseKey:         versionOSReleaseKey,
+		osReleasePaths:              osReleasePaths,
+		updateConfPaths:             updateConfPaths,
+		decisions:                   newDecisionRecorder(config.NodeName, config.DecisionsOutput),
+		evictStatefulPodsLast:       config.EvictStatefulPodsLast,
+		preserveRebootNeeded:        config.PreserveRebootNeededOnStartup,
+		rebootRetries:               config.RebootRetries,
+		rebootRetryBackoff:          rebootRetryBackoff,
+		watchRetryBackoff:           watchRetryBackoff,
+		podTerminationGracePeriod:   podTerminationGracePeriod,
+		maxWatchRetryBackoff:        maxWatchRetryBackoff,
+		rebootTimeout:               config.RebootTimeout,
+		skipWaitForNotOkToReboot:    config.SkipWaitForNotOkToReboot,
+		reportRebootBlockedReason:   config.ReportRebootBlockedReason,
+		rebootWindow:                rebootWindow,
+		watchPodTermination:         config.WatchPodTermination,
+		drainOutputVerbosity:        klog.Level(config.DrainOutputVerbosity),
+		gracePeriodOverrides:        gracePeriodOverrides,
+		metrics:                     metrics,
+		recorder:                    newEventRecorder(config.Clientset),
 	}, nil
 }
 
@@ -130,6 +544,22 @@ func (k *klocksmith) Run(ctx context.Context) error {
 
 	defer klog.V(5).Info("Stopping agent")
 
+	if k.maxStartupDelay > 0 {
+		// Seed explicitly, so agents started at once do not all pick the same delay.
+		//
+		//nolint:gosec // Startup jitter does not require cryptographically secure randomness.
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		delay := time.Duration(random.Int63n(int64(k.maxStartupDelay)))
+
+		klog.Infof("Delaying startup by %v", delay)
+
+		sleepOrDone(delay, ctx.Done())
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
 	// Agent process should reboot the node, no need to loop.
 	if err := k.process(ctx); err != nil {
 		klog.Errorf("Error running agent process: %v", err)
@@ -145,6 +575,22 @@ func (k *klocksmith) Run(ctx context.Context) error {
 //
 //nolint:funlen,cyclop // TODO: This will be refactored once we have tests in place.
 func (k *klocksmith) process(ctx context.Context) error {
+	managed, err := k.nodeManaged(ctx)
+	if err != nil {
+		return fmt.Errorf("checking if node is managed: %w", err)
+	}
+
+	if !managed {
+		klog.Infof("Node %q does not match managed node selector %q, waiting for termination",
+			k.nodeName, k.managedNodeSelector)
+
+		<-ctx.Done()
+
+		return nil
+	}
+
+	disableEviction := k.ignorePodDisruptionBudgets || k.evictionUnavailable()
+
 	klog.Info("Setting info labels")
 
 	if err := k.setInfoLabels(ctx); err != nil {
@@ -153,25 +599,49 @@ func (k *klocksmith) process(ctx context.Context) error {
 
 	klog.Info("Checking annotations")
 
-	node, err := k8sutil.GetNodeRetry(ctx, k.nc, k.nodeName)
+	node, err := k.getNode(ctx)
 	if err != nil {
-		return fmt.Errorf("getting node %q: %w", k.nodeName, err)
+		return err
 	}
 
 	// Only make a node schedulable if a reboot was in progress. This prevents a node from being made schedulable
-	// if it was made unschedulable by something other than the agent.
+	// if it was made unschedulable by something other than the agent. Nodes made unschedulable by operator
+	// are made schedulable by operator.
 	annotation := constants.AnnotationAgentMadeUnschedulable
 	madeUnschedulableAnnotation, madeUnschedulableAnnotationExists := node.Annotations[annotation]
-	makeSchedulable := madeUnschedulableAnnotation == constants.True
+	makeSchedulable := madeUnschedulableAnnotation == constants.True && !k.cordonedByOperator(node)
 
 	// Set flatcar-linux.net/update1/reboot-in-progress=false and
 	// flatcar-linux.net/update1/reboot-needed=false.
 	anno := map[string]string{
 		constants.AnnotationRebootInProgress: constants.False,
-		constants.AnnotationRebootNeeded:     constants.False,
+		constants.AnnotationAgentHeartbeat:   heartbeat(),
 	}
-	labels := map[string]string{
-		constants.LabelRebootNeeded: constants.False,
+
+	// Node cordoned by operator which was rebooting when agent stopped has most likely just rebooted.
+	rebootedCordonedByOperator := k.cordonedByOperator(node) &&
+		node.Annotations[constants.AnnotationRebootInProgress] == constants.True
+
+	// Hand making node schedulable over to a human right away, so operator keeps considering the node
+	// as rebooting from now on. This includes nodes cordoned by operator, which otherwise would make
+	// them schedulable once after-reboot checks pass.
+	awaitManualUncordon := (makeSchedulable || rebootedCordonedByOperator) && k.manualUncordon
+	if awaitManualUncordon {
+		anno[constants.AnnotationAgentMadeUnschedulable] = constants.False
+		anno[constants.AnnotationAwaitingManualUncordon] = constants.True
+	}
+
+	if awaitManualUncordon && rebootedCordonedByOperator {
+		anno[constants.AnnotationOperatorMadeUnschedulable] = constants.False
+	}
+	labels := map[string]string{}
+
+	// If reboot has been approved, node has most likely just rebooted, so reboot-needed must be reset
+	// to let operator finish the reboot process.
+	preserveRebootNeeded := k.preserveRebootNeeded && node.Annotations[constants.AnnotationOkToReboot] != constants.True
+	if !preserveRebootNeeded {
+		anno[constants.AnnotationRebootNeeded] = constants.False
+		labels[constants.LabelRebootNeeded] = constants.False
 	}
 
 	klog.Infof("Setting annotations %#v", anno)
@@ -180,17 +650,40 @@ func (k *klocksmith) process(ctx context.Context) error {
 		return fmt.Errorf("setting node %q labels and annotations: %w", k.nodeName, err)
 	}
 
+	// Keep reporting heartbeat, so operator knows agent is alive while it waits for the reboot approval.
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+
+	go k.reportHeartbeat(heartbeatCtx)
+
 	// Since we set 'reboot-needed=false', 'ok-to-reboot' should clear.
 	// Wait for it to do so, else we might start reboot-looping.
-	if err := k.waitForNotOkToReboot(ctx); err != nil {
-		return fmt.Errorf("waiting for not ok to reboot signal from operator: %w", err)
+	if k.skipWaitForNotOkToReboot {
+		klog.Warning("Not waiting for operator to clear ok-to-reboot annotation, as configured")
+	} else {
+		waitingForNotOkToReboot := node.Annotations[constants.AnnotationOkToReboot] == constants.True
+		if waitingForNotOkToReboot {
+			k.setRebootBlockedReason(ctx, constants.RebootBlockedReasonWaitingForNotOkToReboot)
+		}
+
+		if err := k.waitForNotOkToReboot(ctx); err != nil {
+			return fmt.Errorf("waiting for not ok to reboot signal from operator: %w", err)
+		}
+
+		if waitingForNotOkToReboot {
+			k.setRebootBlockedReason(ctx, "")
+		}
 	}
 
-	if makeSchedulable {
+	switch {
+	case awaitManualUncordon:
+		klog.Infof("Leaving node unschedulable until it is verified, uncordoned and annotation %q is removed",
+			constants.AnnotationAwaitingManualUncordon)
+	case makeSchedulable:
 		// We are schedulable now.
 		klog.Info("Marking node as schedulable")
 
-		if err := k8sutil.Unschedulable(ctx, k.nc, k.nodeName, false); err != nil {
+		if err := k.cordon(ctx, false); err != nil {
 			return fmt.Errorf("marking node %q as unschedulable: %w", k.nodeName, err)
 		}
 
@@ -203,17 +696,25 @@ func (k *klocksmith) process(ctx context.Context) error {
 		if err := k8sutil.SetNodeAnnotations(ctx, k.nc, k.nodeName, anno); err != nil {
 			return fmt.Errorf("setting node %q annotations: %w", k.nodeName, err)
 		}
-	} else if madeUnschedulableAnnotationExists { // Annotation exists so node was marked unschedulable by external source.
+	case madeUnschedulableAnnotationExists: // Annotation exists so node was marked unschedulable by external source.
 		klog.Info("Skipping marking node as schedulable -- node was marked unschedulable by an external source")
 	}
 
+	statusCallback := k.updateStatusCallback
+	if preserveRebootNeeded {
+		statusCallback = k.rebootNeededRederivingCallback()
+	}
+
 	// Watch update engine for status updates.
-	go k.watchUpdateStatus(ctx, k.updateStatusCallback)
+	go k.watchUpdateStatus(ctx, statusCallback)
+
+	retryBackoff := k.newWatchRetryBackoff()
 
 	// Block until constants.AnnotationOkToReboot is set.
 	for okToReboot := false; !okToReboot; {
 		klog.Infof("Waiting for ok-to-reboot from controller...")
 
+		waitingStarted := time.Now()
 		errCh := make(chan error)
 
 		go func() {
@@ -227,7 +728,16 @@ func (k *klocksmith) process(ctx context.Context) error {
 			return nil
 		case err := <-errCh:
 			if err != nil {
-				klog.Warningf("Error waiting for an ok-to-reboot: %v", err)
+				// Watch which has been running for a while most likely just expired, so start backing off again.
+				if time.Since(waitingStarted) > k.maxWatchRetryBackoff {
+					retryBackoff = k.newWatchRetryBackoff()
+				}
+
+				delay := retryBackoff.Step()
+
+				klog.Warningf("Error waiting for an ok-to-reboot, retrying in %v: %v", delay, err)
+
+				sleepOrDone(delay, ctx.Done())
 
 				// Break select statement to restart watching for ok to reboot.
 				break
@@ -238,22 +748,54 @@ func (k *klocksmith) process(ctx context.Context) error {
 		}
 	}
 
+	k.recorder.Event(k.nodeReference(), corev1.EventTypeNormal, eventReasonOkToReboot,
+		"Reboot approved by update-operator")
+
+	k.setRebootBlockedReason(ctx, "")
+
+	// Wait for local reboot window before draining, so node does not stay drained while the window is closed.
+	if !k.waitForRebootWindow(ctx) {
+		klog.Infof("Got stop signal while waiting for local reboot window to open")
+
+		return nil
+	}
+
 	klog.Info("Checking if node is already unschedulable")
 
-	node, err = k8sutil.GetNodeRetry(ctx, k.nc, k.nodeName)
+	node, err = k.getNode(ctx)
 	if err != nil {
-		return fmt.Errorf("getting node %q: %w", k.nodeName, err)
+		return err
 	}
 
-	alreadyUnschedulable := node.Spec.Unschedulable
+	alreadyUnschedulable := k.cordoned(node)
+
+	// If agent gets terminated before triggering a reboot, revert the changes done below,
+	// so node does not stay cordoned when the agent is not running anymore.
+	rebootTriggered := false
+
+	defer func() {
+		if rebootTriggered || ctx.Err() == nil || k.preserveNodeStateOnShutdown {
+			return
+		}
+
+		klog.Info("Agent terminated before rebooting")
+
+		k.revertRebootInProgress(!alreadyUnschedulable)
+	}()
 
 	// Set constants.AnnotationRebootInProgress and drain self.
 	anno = map[string]string{
 		constants.AnnotationRebootInProgress: constants.True,
 	}
 
-	if !alreadyUnschedulable {
+	operatorMadeUnschedulable := k.cordonedByOperator(node)
+
+	switch {
+	case !alreadyUnschedulable:
 		anno[constants.AnnotationAgentMadeUnschedulable] = constants.True
+	case operatorMadeUnschedulable:
+		// Operator makes node schedulable again once the reboot process finishes.
+		anno[constants.AnnotationAgentMadeUnschedulable] = constants.False
 	}
 
 	klog.Infof("Setting annotations %#v", anno)
@@ -265,36 +807,134 @@ func (k *klocksmith) process(ctx context.Context) error {
 	if !alreadyUnschedulable {
 		klog.Info("Marking node as unschedulable")
 
-		if err := k8sutil.Unschedulable(ctx, k.nc, k.nodeName, true); err != nil {
+		if err := k.cordon(ctx, true); err != nil {
 			return fmt.Errorf("marking node %q as unschedulable: %w", k.nodeName, err)
 		}
+
+		k.decisions.record(decisionEvent{Decision: decisionCordoned})
+	} else if operatorMadeUnschedulable {
+		klog.Info("Node already marked as unschedulable by operator, leaving marking it as schedulable to operator")
 	} else {
 		klog.Info("Node already marked as unschedulable")
 	}
 
-	drainer := newDrainer(ctx, k.clientset, k.reapTimeout, k.forceNodeDrain)
+	newBaseDrainer := func(timeout time.Duration) drainer {
+		drainHelper := k.newDrainHelper(ctx, timeout, disableEviction)
+		if k.watchPodTermination {
+			return newWatchingDrainer(drainHelper, k.nodeName)
+		}
+
+		return drainHelper
+	}
+
+	drainer := newBaseDrainer(k.reapTimeout)
+	if len(k.gracePeriodOverrides) > 0 {
+		drainer = newGracePeriodOverridingDrainer(drainer, k.gracePeriodOverrides, newBaseDrainer)
+	}
+
+	if k.maxPodEvictionRate > 0 {
+		drainer = newRateLimitedDrainer(ctx, drainer, k.maxPodEvictionRate)
+	}
+
+	if k.evictStatefulPodsLast {
+		drainer = newStatefulLastDrainer(ctx, drainer)
+	}
 
 	klog.Info("Getting pod list for deletion")
 
-	pods, errs := drainer.GetPodsForDeletion(k.nodeName)
+	podsForDeletion, errs := drainer.GetPodsForDeletion(k.nodeName)
 	if len(errs) > 0 {
 		return fmt.Errorf("getting pods for deletion: %v", errs)
 	}
 
-	klog.Infof("Deleting/Evicting %d pods", len(pods.Pods()))
+	// Drain helper ignores all DaemonSet pods, so pods of DaemonSets which must be drained are added separately.
+	daemonSetPods, err := k.drainedDaemonSetPods(ctx)
+	if err != nil {
+		return fmt.Errorf("getting DaemonSet pods for deletion: %w", err)
+	}
+
+	pods := append(podsForDeletion.Pods(), daemonSetPods...)
+
+	klog.Infof("Deleting/Evicting %d pods", len(pods))
+
+	podsCount := len(pods)
+	k.decisions.record(decisionEvent{Decision: decisionDrainStarted, Pods: &podsCount})
+	k.recorder.Eventf(k.nodeReference(), corev1.EventTypeNormal, eventReasonDrainStarted,
+		"Draining node, removing %d pods", podsCount)
+
+	k.setRebootBlockedReason(ctx, constants.RebootBlockedReasonDraining)
+
+	err = drainer.DeleteOrEvictPods(pods)
+	if err != nil && ctx.Err() == nil {
+		k.reportPodDisruptionBudgetBlocks(ctx, pods)
+
+		var abort bool
 
-	if err := drainer.DeleteOrEvictPods(pods.Pods()); err != nil {
+		if abort, err = k.handlePodsStuckTerminating(ctx, drainer, pods, err); abort {
+			k.revertRebootInProgress(!alreadyUnschedulable)
+
+			return fmt.Errorf("deleting/evicting pods: %w", err)
+		}
+	}
+
+	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("deleting/evicting pods: %w", ctx.Err())
 		}
 
+		if k.abortRebootOnDrainError {
+			k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonDrainError,
+				"Draining node failed, not rebooting: %v", err)
+
+			return fmt.Errorf("deleting/evicting pods: %w", err)
+		}
+
+		k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonDrainError,
+			"Draining node failed, proceeding with reboot anyway: %v", err)
+		k.metrics.drainErrorsIgnored.Inc()
+
 		klog.Errorf("Ignoring node drain error and proceeding with reboot: %v", err)
 	}
 
+	drainFinished := decisionEvent{Decision: decisionDrainFinished, Pods: &podsCount}
+	if err != nil {
+		drainFinished.Error = err.Error()
+	}
+
+	k.decisions.record(drainFinished)
+
+	if err == nil {
+		k.recorder.Event(k.nodeReference(), corev1.EventTypeNormal, eventReasonDrainFinished, "Node drained")
+	}
+
+	k.recordEvictedPods(ctx, pods)
+
+	// Draining may take long enough for the local reboot window to close, so check it again.
+	if !k.waitForRebootWindow(ctx) {
+		klog.Infof("Got stop signal while waiting for local reboot window to open after draining")
+
+		return nil
+	}
+
 	klog.Info("Node drained, rebooting")
 
+	k.setRebootBlockedReason(ctx, "")
+
 	// Reboot.
-	k.lc.Reboot(false)
+	if err := k.reboot(ctx); err != nil {
+		k.decisions.record(decisionEvent{Decision: decisionRebootFailed, Error: err.Error()})
+
+		return fmt.Errorf("requesting reboot: %w", err)
+	}
+
+	k.decisions.record(decisionEvent{Decision: decisionRebootRequested})
+	k.recorder.Event(k.nodeReference(), corev1.EventTypeNormal, eventReasonRebootRequested, "Reboot requested")
+
+	rebootTriggered = true
+
+	if k.rebootTimeout > 0 {
+		return k.waitForReboot(ctx)
+	}
 
 	// Cross fingers.
 	sleepOrDone(24*7*time.Hour, ctx.Done())
@@ -302,100 +942,769 @@ func (k *klocksmith) process(ctx context.Context) error {
 	return nil
 }
 
-// updateStatusCallback receives Status messages from update engine. If the
-// status is UpdateStatusUpdatedNeedReboot, indicate that with a label on our
-// node.
-func (k *klocksmith) updateStatusCallback(ctx context.Context, status updateengine.Status) {
-	klog.Info("Updating status")
+// waitForReboot waits for the node to go down after the reboot has been requested. If the agent is still
+// running after reboot timeout, reboot is requested once again. If the node does not go down within reboot
+// timeout after that either, an error is returned.
+func (k *klocksmith) waitForReboot(ctx context.Context) error {
+	sleepOrDone(k.rebootTimeout, ctx.Done())
 
-	// update our status.
-	anno := map[string]string{
-		constants.AnnotationStatus:          status.CurrentOperation,
-		constants.AnnotationLastCheckedTime: fmt.Sprintf("%d", status.LastCheckedTime),
-		constants.AnnotationNewVersion:      status.NewVersion,
+	if ctx.Err() != nil {
+		return nil
 	}
 
-	labels := map[string]string{}
+	klog.Warningf("Node did not reboot within %v after requesting reboot, requesting it again", k.rebootTimeout)
 
-	// Indicate we need a reboot.
-	if status.CurrentOperation == updateengine.UpdateStatusUpdatedNeedReboot {
-		klog.Info("Indicating a reboot is needed")
+	k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonRebootTimedOut,
+		"Node did not reboot within %v after requesting reboot, requesting it again", k.rebootTimeout)
 
-		anno[constants.AnnotationRebootNeeded] = constants.True
-		labels[constants.LabelRebootNeeded] = constants.True
+	if err := k.requestReboot(k.rebootInteractiveAuth); err != nil {
+		k.decisions.record(decisionEvent{Decision: decisionRebootFailed, Error: err.Error()})
+
+		return fmt.Errorf("requesting reboot again: %w", err)
 	}
 
-	//nolint:staticcheck // New equivalent is buggy: https://github.com/kubernetes/kubernetes/issues/119533.
-	err := wait.PollImmediateUntil(k.pollInterval, func() (bool, error) {
-		if err := k8sutil.SetNodeAnnotationsLabels(ctx, k.nc, k.nodeName, anno, labels); err != nil {
-			klog.Errorf("Failed to set annotation %q: %v", constants.AnnotationStatus, err)
+	k.decisions.record(decisionEvent{Decision: decisionRebootRequested})
 
-			return false, nil
-		}
+	sleepOrDone(k.rebootTimeout, ctx.Done())
 
-		return true, nil
-	}, ctx.Done())
-	if err != nil {
-		klog.Errorf("Failed updating node annotations and labels: %v", err)
+	if ctx.Err() != nil {
+		return nil
 	}
+
+	k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonRebootTimedOut,
+		"Node did not reboot within %v after requesting reboot again, giving up", k.rebootTimeout)
+
+	return fmt.Errorf("node did not reboot within %v after requesting reboot twice", k.rebootTimeout)
 }
 
-// setInfoLabels labels our node with helpful info about Flatcar Container Linux.
-func (k *klocksmith) setInfoLabels(ctx context.Context) error {
-	versionInfo, err := getVersionInfo(k.hostFilesPrefix)
-	if err != nil {
-		return fmt.Errorf("getting version info: %w", err)
+// setRebootBlockedReason sets reboot blocked reason annotation on the node to a given reason or removes
+// it if the reason is empty. The annotation is informational only, so failing to update it is just logged.
+//
+// If reporting reboot blocked reason is not configured, nothing is done.
+func (k *klocksmith) setRebootBlockedReason(ctx context.Context, reason string) {
+	if !k.reportRebootBlockedReason {
+		return
 	}
 
-	labels := map[string]string{
-		constants.LabelID:      versionInfo.id,
-		constants.LabelGroup:   versionInfo.group,
-		constants.LabelVersion: versionInfo.version,
+	err := k8sutil.UpdateNodeRetry(ctx, k.nc, k.nodeName, func(node *corev1.Node) {
+		if reason == "" {
+			delete(node.Annotations, constants.AnnotationRebootBlockedReason)
+
+			return
+		}
+
+		node.Annotations[constants.AnnotationRebootBlockedReason] = reason
+	})
+	if err != nil {
+		klog.Warningf("Failed setting reboot blocked reason %q on node %q: %v", reason, k.nodeName, err)
 	}
+}
 
-	if err := k8sutil.SetNodeLabels(ctx, k.nc, k.nodeName, labels); err != nil {
-		return fmt.Errorf("setting node %q labels: %w", k.nodeName, err)
+// recordEvictedPods sets evicted pods annotation on the node to given pods, so disrupted workloads
+// can be correlated with the reboot. Failing to do so does not prevent the reboot.
+func (k *klocksmith) recordEvictedPods(ctx context.Context, pods []corev1.Pod) {
+	anno := map[string]string{
+		constants.AnnotationEvictedPods: evictedPodsAnnotationValue(pods),
 	}
 
-	return nil
+	if err := k8sutil.SetNodeAnnotations(ctx, k.nc, k.nodeName, anno); err != nil {
+		klog.Warningf("Failed recording evicted pods on node %q: %v", k.nodeName, err)
+	}
 }
 
-type statusUpdateF func(context.Context, updateengine.Status)
+// evictedPodsAnnotationValue returns sorted, comma-separated list of given pods in "namespace/name" format.
+// If the list exceeds maximum annotation length, it is truncated and the number of omitted pods is appended.
+func evictedPodsAnnotationValue(pods []corev1.Pod) string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
 
-func (k *klocksmith) watchUpdateStatus(ctx context.Context, update statusUpdateF) {
-	klog.Info("Beginning to watch update_engine status")
+	sort.Strings(names)
 
-	oldOperation := ""
-	ch := make(chan updateengine.Status, 1)
+	value := strings.Join(names, ",")
+	if len(value) <= maxEvictedPodsAnnotationLength {
+		return value
+	}
 
-	go k.ue.ReceiveStatuses(ch, ctx.Done())
+	truncated := ""
 
-	for status := range ch {
-		if status.CurrentOperation != oldOperation && update != nil {
-			update(ctx, status)
-			oldOperation = status.CurrentOperation
+	for i, name := range names {
+		remaining := fmt.Sprintf("and %d more", len(names)-i-1)
+
+		if len(truncated)+len(name)+len(",")+len(remaining) > maxEvictedPodsAnnotationLength {
+			return truncated + fmt.Sprintf("and %d more", len(names)-i)
 		}
+
+		truncated += name + ","
 	}
+
+	return value
 }
 
-// waitForOkToReboot waits for both 'ok-to-reboot' and 'needs-reboot' to be true.
-func (k *klocksmith) waitForOkToReboot(ctx context.Context) error {
+// reboot requests a reboot, retrying failed requests up to configured number of times with exponential
+// backoff. When all attempts fail, an error from the last attempt is returned.
+func (k *klocksmith) reboot(ctx context.Context) error {
+	backoff := k.rebootRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := k.requestReboot(k.rebootInteractiveAuth)
+		if err == nil {
+			return nil
+		}
+
+		if attempt > k.rebootRetries {
+			return fmt.Errorf("giving up after %d attempt(s): %w", attempt, err)
+		}
+
+		klog.Warningf("Failed requesting reboot, retrying in %v: %v", backoff, err)
+
+		sleepOrDone(backoff, ctx.Done())
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("retrying reboot request: %w", ctx.Err())
+		}
+
+		backoff *= 2
+	}
+}
+
+// revertRebootInProgress clears reboot-in-progress annotation and makes node schedulable again
+// if it was made unschedulable by the agent. As it may be called on agent shutdown, it uses its own
+// context with a timeout.
+func (k *klocksmith) revertRebootInProgress(madeUnschedulable bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownCleanupTimeout)
+	defer cancel()
+
+	klog.Info("Reverting node state")
+
+	anno := map[string]string{
+		constants.AnnotationRebootInProgress: constants.False,
+	}
+
+	if madeUnschedulable {
+		klog.Info("Marking node as schedulable")
+
+		// Reboot is no longer in progress regardless, so keep agent made unschedulable annotation set
+		// to make node schedulable on the next agent start.
+		if err := k.cordon(ctx, false); err != nil {
+			klog.Errorf("Failed marking node %q as schedulable: %v", k.nodeName, err)
+		} else {
+			anno[constants.AnnotationAgentMadeUnschedulable] = constants.False
+		}
+	}
+
+	klog.Infof("Setting annotations %#v", anno)
+
+	if err := k8sutil.SetNodeAnnotations(ctx, k.nc, k.nodeName, anno); err != nil {
+		klog.Errorf("Failed setting node %q annotations: %v", k.nodeName, err)
+	}
+}
+
+// cordoned returns whether the node is already cordoned using configured cordon method.
+func (k *klocksmith) cordoned(node *corev1.Node) bool {
+	if k.cordonMethod == CordonMethodTaint {
+		for _, taint := range node.Spec.Taints {
+			if taint.MatchTaint(rebootingTaint()) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return node.Spec.Unschedulable
+}
+
+// cordonedByOperator returns whether the node has been cordoned by the operator. As the operator only
+// marks nodes as unschedulable, it never applies to the taint cordon method.
+func (k *klocksmith) cordonedByOperator(node *corev1.Node) bool {
+	return k.cordonMethod == CordonMethodUnschedulable &&
+		node.Annotations[constants.AnnotationOperatorMadeUnschedulable] == constants.True
+}
+
+// cordon cordons or uncordons the node according to cordoned using configured cordon method.
+func (k *klocksmith) cordon(ctx context.Context, cordoned bool) error {
+	if k.cordonMethod == CordonMethodTaint {
+		return k8sutil.Taint(ctx, k.nc, k.nodeName, *rebootingTaint(), cordoned)
+	}
+
+	return k8sutil.Unschedulable(ctx, k.nc, k.nodeName, cordoned)
+}
+
+func rebootingTaint() *corev1.Taint {
+	return &corev1.Taint{
+		Key:    constants.TaintRebooting,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+}
+
+// updateStatusCallback receives Status messages from update engine. If the
+// status is UpdateStatusUpdatedNeedReboot, indicate that with a label on our
+// node. If the status is UpdateStatusReportingErrorEvent, indicate that with
+// an annotation and an event on our node.
+func (k *klocksmith) updateStatusCallback(ctx context.Context, status updateengine.Status) {
+	klog.Info("Updating status")
+
+	k.decisions.record(decisionEvent{
+		Decision:   decisionStatusChanged,
+		Status:     status.CurrentOperation,
+		NewVersion: status.NewVersion,
+	})
+
+	// update our status.
+	anno := map[string]string{
+		constants.AnnotationStatus:          status.CurrentOperation,
+		constants.AnnotationLastCheckedTime: fmt.Sprintf("%d", status.LastCheckedTime),
+		constants.AnnotationNewVersion:      status.NewVersion,
+		constants.AnnotationAgentHeartbeat:  heartbeat(),
+	}
+
+	labels := map[string]string{}
+
+	anno[constants.AnnotationUpdateError] = constants.False
+
+	if status.CurrentOperation == updateengine.UpdateStatusReportingErrorEvent {
+		k.reportUpdateError(anno)
+	}
+
+	// Indicate we need a reboot.
+	if status.CurrentOperation == updateengine.UpdateStatusUpdatedNeedReboot {
+		klog.Info("Indicating a reboot is needed")
+
+		k.recorder.Eventf(k.nodeReference(), corev1.EventTypeNormal, eventReasonRebootNeeded,
+			"Update to version %s installed, reboot needed", status.NewVersion)
+
+		anno[constants.AnnotationRebootNeeded] = constants.True
+		labels[constants.LabelRebootNeeded] = constants.True
+	}
+
+	//nolint:staticcheck // New equivalent is buggy: https://github.com/kubernetes/kubernetes/issues/119533.
+	err := wait.PollImmediateUntil(k.pollInterval, func() (bool, error) {
+		if err := k8sutil.SetNodeAnnotationsLabels(ctx, k.nc, k.nodeName, anno, labels); err != nil {
+			klog.Errorf("Failed to set annotation %q: %v", constants.AnnotationStatus, err)
+
+			return false, nil
+		}
+
+		return true, nil
+	}, ctx.Done())
+	if err != nil {
+		klog.Errorf("Failed updating node annotations and labels: %v", err)
+	}
+}
+
+// reportUpdateError marks the node as having an update error in given annotations and emits an event
+// about it, including error code of the last update attempt, if it can be read.
+func (k *klocksmith) reportUpdateError(anno map[string]string) {
+	klog.Warning("update_engine reported an error")
+
+	anno[constants.AnnotationUpdateError] = constants.True
+
+	message := "update_engine reported an error"
+
+	if k.lastAttemptErrorReader != nil {
+		if code, err := k.lastAttemptErrorReader.LastAttemptError(); err != nil {
+			klog.Warningf("Failed getting last update attempt error: %v", err)
+		} else {
+			anno[constants.AnnotationLastUpdateAttemptError] = strconv.Itoa(int(code))
+			message = fmt.Sprintf("%s, last update attempt failed with error code %d", message, code)
+		}
+	}
+
+	k.recorder.Event(k.nodeReference(), corev1.EventTypeWarning, eventReasonUpdateError, message)
+}
+
+// rebootNeededRederivingCallback returns status update callback, which on the first received status
+// resets reboot-needed annotation and label preserved on startup, if update_engine does not report
+// that reboot is needed. Later statuses are handled as usual, so reboot requested by other means,
+// e.g. by the operator, is not cleared.
+func (k *klocksmith) rebootNeededRederivingCallback() statusUpdateF {
+	var once sync.Once
+
+	return func(ctx context.Context, status updateengine.Status) {
+		once.Do(func() {
+			if status.CurrentOperation == updateengine.UpdateStatusUpdatedNeedReboot {
+				return
+			}
+
+			klog.Infof("Reboot is not needed according to update_engine status %q, resetting reboot-needed",
+				status.CurrentOperation)
+
+			anno := map[string]string{
+				constants.AnnotationRebootNeeded: constants.False,
+			}
+			labels := map[string]string{
+				constants.LabelRebootNeeded: constants.False,
+			}
+
+			if err := k8sutil.SetNodeAnnotationsLabels(ctx, k.nc, k.nodeName, anno, labels); err != nil {
+				klog.Errorf("Failed resetting reboot-needed annotation and label: %v", err)
+			}
+		})
+
+		k.updateStatusCallback(ctx, status)
+	}
+}
+
+// reportHeartbeat updates agent heartbeat annotation on the node every configured interval
+// until given context is cancelled. Failed updates are logged and retried on the next tick.
+func (k *klocksmith) reportHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(k.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		anno := map[string]string{
+			constants.AnnotationAgentHeartbeat: heartbeat(),
+		}
+
+		klog.V(4).Infof("Setting annotations %#v", anno)
+
+		if err := k8sutil.SetNodeAnnotations(ctx, k.nc, k.nodeName, anno); err != nil {
+			klog.Warningf("Failed updating agent heartbeat: %v", err)
+		}
+	}
+}
+
+// drainedDaemonSetPods returns running pods scheduled on the node, which belong to DaemonSets
+// configured to be drained.
+func (k *klocksmith) drainedDaemonSetPods(ctx context.Context) ([]corev1.Pod, error) {
+	if len(k.drainedDaemonSets) == 0 {
+		return []corev1.Pod{}, nil
+	}
+
+	podList, err := k.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", k.nodeName).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods on node %q: %w", k.nodeName, err)
+	}
+
+	return k8sutil.FilterPods(podList.Items, func(pod *corev1.Pod) bool {
+		// Finished pods are already handled by the drain helper.
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return false
+		}
+
+		controllerRef := metav1.GetControllerOf(pod)
+		if controllerRef == nil || controllerRef.Kind != "DaemonSet" {
+			return false
+		}
+
+		_, drained := k.drainedDaemonSets[pod.Namespace+"/"+controllerRef.Name]
+
+		return drained
+	}), nil
+}
+
+// handlePodsStuckTerminating applies configured stuck pods policy if given drain error has been caused
+// by some of given pods still terminating. It returns true if the reboot should be aborted and remaining
+// drain error, if any.
+func (k *klocksmith) handlePodsStuckTerminating(
+	ctx context.Context, d drainer, pods []corev1.Pod, drainErr error,
+) (bool, error) {
+	stuckPods := k.podsStillRunning(ctx, pods)
+	if len(stuckPods) == 0 {
+		return false, drainErr
+	}
+
+	stuckPodNames := make([]string, 0, len(stuckPods))
+	for _, pod := range stuckPods {
+		stuckPodNames = append(stuckPodNames, pod.Namespace+"/"+pod.Name)
+	}
+
+	message := fmt.Sprintf("Pods still terminating after %v grace period: %s", k.reapTimeout,
+		strings.Join(stuckPodNames, ", "))
+
+	switch k.stuckPodsPolicy {
+	case StuckPodsPolicyAbort:
+		k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonPodsStuckTerminating,
+			"%s; aborting reboot", message)
+
+		return true, drainErr
+	case StuckPodsPolicyExtendOnce:
+		k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonPodsStuckTerminating,
+			"%s; waiting for another grace period", message)
+
+		klog.Infof("Waiting for %d stuck pods to terminate for another %v", len(stuckPods), k.reapTimeout)
+
+		return false, d.DeleteOrEvictPods(stuckPods)
+	case StuckPodsPolicyProceed:
+	}
+
+	k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonPodsStuckTerminating,
+		"%s; proceeding", message)
+
+	return false, drainErr
+}
+
+// reportPodDisruptionBudgetBlocks emits an event on the node naming PodDisruptionBudgets which
+// do not allow any disruptions and select given pods, which are still running and not terminating.
+func (k *klocksmith) reportPodDisruptionBudgetBlocks(ctx context.Context, pods []corev1.Pod) {
+	budgets := map[string][]policyv1.PodDisruptionBudget{}
+	blocked := []string{}
+
+	for _, pod := range pods {
+		currentPod, err := k.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil || currentPod.UID != pod.UID || currentPod.DeletionTimestamp != nil {
+			continue
+		}
+
+		namespaceBudgets, ok := budgets[pod.Namespace]
+		if !ok {
+			pdbs, err := k.clientset.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				klog.Warningf("Failed listing PodDisruptionBudgets in namespace %q: %v", pod.Namespace, err)
+
+				continue
+			}
+
+			namespaceBudgets = pdbs.Items
+			budgets[pod.Namespace] = namespaceBudgets
+		}
+
+		for _, pdb := range namespaceBudgets {
+			if pdbBlocksEviction(pdb, currentPod) {
+				blocked = append(blocked, fmt.Sprintf("%s/%s by %s", pod.Namespace, pod.Name, pdb.Name))
+			}
+		}
+	}
+
+	if len(blocked) == 0 {
+		return
+	}
+
+	k.recorder.Eventf(k.nodeReference(), corev1.EventTypeWarning, eventReasonDrainBlockedByPDB,
+		"Eviction of pods blocked by PodDisruptionBudgets after %v grace period: %s", k.reapTimeout,
+		strings.Join(blocked, ", "))
+}
+
+// pdbBlocksEviction checks if given PodDisruptionBudget selects given pod and does not allow any disruptions.
+func pdbBlocksEviction(pdb policyv1.PodDisruptionBudget, pod *corev1.Pod) bool {
+	if pdb.Status.DisruptionsAllowed > 0 {
+		return false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return false
+	}
+
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// podsStillRunning returns given pods which still exist on the node. Pods which state cannot be
+// checked are not returned.
+func (k *klocksmith) podsStillRunning(ctx context.Context, pods []corev1.Pod) []corev1.Pod {
+	return k8sutil.FilterPods(pods, func(pod *corev1.Pod) bool {
+		currentPod, err := k.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				klog.Warningf("Failed checking if pod %s/%s has terminated: %v", pod.Namespace, pod.Name, err)
+			}
+
+			return false
+		}
+
+		// Pod with the same name might have been recreated by its controller.
+		return currentPod.UID == pod.UID
+	})
+}
+
+// rebootActionF returns function requesting given reboot action from given rebooter.
+func rebootActionF(action RebootAction, rebooter Rebooter) (func(askForAuth bool) error, error) {
+	switch action {
+	case "", RebootActionReboot:
+		return rebooter.Reboot, nil
+	case RebootActionPowerOff:
+		powerOffer, ok := rebooter.(PowerOffer)
+		if !ok {
+			return nil, fmt.Errorf("rebooter does not support powering off")
+		}
+
+		return powerOffer.PowerOff, nil
+	default:
+		return nil, fmt.Errorf("unsupported reboot action %q", action)
+	}
+}
+
+// parseDrainedDaemonSets validates given list of DaemonSets in "namespace/name" format
+// and returns them as a set.
+func parseDrainedDaemonSets(daemonSets []string) (map[string]struct{}, error) {
+	result := map[string]struct{}{}
+
+	for _, daemonSet := range daemonSets {
+		//nolint:gomnd // Namespace and name.
+		if parts := strings.Split(daemonSet, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("DaemonSet %q is not in \"namespace/name\" format", daemonSet)
+		}
+
+		result[daemonSet] = struct{}{}
+	}
+
+	return result, nil
+}
+
+// parseGracePeriodOverrides validates given list of grace period overrides in "Kind=duration" format
+// and returns them as a map of durations by kind.
+func parseGracePeriodOverrides(overrides []string) (map[string]time.Duration, error) {
+	result := map[string]time.Duration{}
+
+	for _, override := range overrides {
+		separator := strings.Index(override, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("grace period override %q is not in \"Kind=duration\" format", override)
+		}
+
+		gracePeriod, err := time.ParseDuration(override[separator+1:])
+		if err != nil {
+			return nil, fmt.Errorf("parsing grace period override %q: %w", override, err)
+		}
+
+		if gracePeriod <= 0 {
+			return nil, fmt.Errorf("grace period override %q must be positive", override)
+		}
+
+		result[override[:separator]] = gracePeriod
+	}
+
+	return result, nil
+}
+
+// newEventRecorder creates a recorder for events about the node.
+func newEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{
+		Interface: clientset.CoreV1().Events(""),
+	})
+
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: eventSourceComponent,
+	})
+}
+
+// nodeReference returns reference to the node for emitting events about it. Like kubelet, node name is
+// used as UID, as this is what "kubectl describe node" searches events by.
+func (k *klocksmith) nodeReference() *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind: "Node",
+		Name: k.nodeName,
+		UID:  types.UID(k.nodeName),
+	}
+}
+
+// heartbeat returns value for agent heartbeat annotation, indicating that agent is alive at the time
+// of calling this function.
+func heartbeat() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// evictionUnavailable checks if the cluster supports eviction API, so it can be reported early,
+// that PodDisruptionBudgets will not be honored while draining the node.
+//
+// If the check fails, false is returned and the check is repeated when draining the node.
+func (k *klocksmith) evictionUnavailable() bool {
+	groupVersion, err := drain.CheckEvictionSupport(k.clientset)
+	if err != nil {
+		klog.Warningf("Failed checking eviction API support, will retry when draining node: %v", err)
+
+		return false
+	}
+
+	if groupVersion.Empty() {
+		klog.Warning("Eviction API is not available, pods will be deleted when draining node " +
+			"and PodDisruptionBudgets will not be honored")
+
+		return true
+	}
+
+	klog.Infof("Using eviction API %q when draining node", groupVersion)
+
+	return false
+}
+
+// nodeManaged checks if node matches configured managed node selector.
+func (k *klocksmith) nodeManaged(ctx context.Context) (bool, error) {
+	if k.managedNodeSelector.Empty() {
+		return true, nil
+	}
+
+	node, err := k.getNode(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return k.managedNodeSelector.Matches(labels.Set(node.Labels)), nil
+}
+
+// setInfoLabels labels our node with helpful info about Flatcar Container Linux.
+func (k *klocksmith) setInfoLabels(ctx context.Context) error {
+	versionInfo, err := getVersionInfo(k.hostFilesPrefix, k.updateConfPaths, k.osReleasePaths, k.versionOSReleaseKey)
+	if err != nil {
+		return fmt.Errorf("getting version info: %w", err)
+	}
+
+	labels := map[string]string{
+		constants.LabelID:      versionInfo.id,
+		constants.LabelGroup:   versionInfo.group,
+		constants.LabelVersion: versionInfo.version,
+	}
+
+	if err := k8sutil.SetNodeLabels(ctx, k.nc, k.nodeName, labels); err != nil {
+		return fmt.Errorf("setting node %q labels: %w", k.nodeName, err)
+	}
+
+	return nil
+}
+
+type statusUpdateF func(context.Context, updateengine.Status)
+
+func (k *klocksmith) watchUpdateStatus(ctx context.Context, update statusUpdateF) {
+	klog.Info("Beginning to watch update_engine status")
+
+	oldOperation := ""
+	ch := make(chan updateengine.Status, 1)
+
+	go k.ue.ReceiveStatuses(ch, ctx.Done())
+
+	for status := range ch {
+		// Progress changes without operation changing, so metrics are updated on every status.
+		k.metrics.setUpdateStatus(status.CurrentOperation, status.Progress)
+
+		if status.CurrentOperation != oldOperation && update != nil {
+			update(ctx, status)
+			oldOperation = status.CurrentOperation
+		}
+	}
+}
+
+// newWatchRetryBackoff returns exponential backoff with jitter for retrying failed node watches.
+func (k *klocksmith) newWatchRetryBackoff() *wait.Backoff {
+	return &wait.Backoff{
+		Duration: k.watchRetryBackoff,
+		//nolint:gomnd // Double the delay after every failure.
+		Factor: 2,
+		Jitter: watchRetryBackoffJitter,
+		Steps:  math.MaxInt32,
+		Cap:    k.maxWatchRetryBackoff,
+	}
+}
+
+// nodeGetRetryBackoff returns exponential backoff with jitter for retrying failed node gets. Unlike
+// watches, gets are retried only few times, as the agent gets restarted when they keep failing.
+func (k *klocksmith) nodeGetRetryBackoff() wait.Backoff {
+	backoff := *k.newWatchRetryBackoff()
+	backoff.Steps = nodeGetRetrySteps
+
+	return backoff
+}
+
+// getNode gets agent's node, retrying failures with exponential backoff.
+func (k *klocksmith) getNode(ctx context.Context) (*corev1.Node, error) {
+	node, err := k8sutil.GetNodeRetry(ctx, k.nc, k.nodeName, k.nodeGetRetryBackoff())
+	if err != nil {
+		return nil, fmt.Errorf("getting node %q: %w", k.nodeName, err)
+	}
+
+	return node, nil
+}
+
+// waitForOkToReboot waits for both 'ok-to-reboot' and 'needs-reboot' to be true.
+//
+// If reboot has been cancelled using 'cancel-reboot' annotation, it keeps waiting.
+func (k *klocksmith) waitForOkToReboot(ctx context.Context) error {
 	node, err := k.nc.Get(ctx, k.nodeName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("getting self node (%q): %w", k.nodeName, err)
 	}
 
-	shouldRebootSelector := fields.Set(map[string]string{
-		constants.AnnotationOkToReboot:   constants.True,
-		constants.AnnotationRebootNeeded: constants.True,
-	}).AsSelector()
+	shouldRebootSelector := fields.ParseSelectorOrDie(constants.AnnotationOkToReboot + "==" + constants.True +
+		"," + constants.AnnotationRebootNeeded + "==" + constants.True +
+		"," + constants.AnnotationCancelReboot + "!=" + constants.True)
+
+	waitingReported := node.Annotations[constants.AnnotationRebootBlockedReason] ==
+		constants.RebootBlockedReasonWaitingForOkToReboot
+
+	// Only report waiting for the reboot approval while the reboot is actually needed.
+	reportWaiting := func(annotations map[string]string) {
+		rebootNeeded := annotations[constants.AnnotationRebootNeeded] == constants.True
+		if rebootNeeded == waitingReported {
+			return
+		}
+
+		reason := ""
+		if rebootNeeded {
+			reason = constants.RebootBlockedReasonWaitingForOkToReboot
+		}
+
+		k.setRebootBlockedReason(ctx, reason)
+
+		waitingReported = rebootNeeded
+	}
+
+	reportWaiting(node.Annotations)
 
 	return k.waitForNodeCondition(ctx, node, func(annotations map[string]string) bool {
-		return shouldRebootSelector.Matches(fields.Set(annotations))
+		if shouldRebootSelector.Matches(fields.Set(annotations)) {
+			return true
+		}
+
+		reportWaiting(annotations)
+
+		return false
 	})
 }
 
+// waitForNotOkToReboot waits for 'ok-to-reboot' annotation to be cleared by the operator. Failures are
+// retried with exponential backoff, but only until configured maximum operator response time passes, so
+// agent restarts when the operator does not respond.
 func (k *klocksmith) waitForNotOkToReboot(ctx context.Context) error {
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(ctx, k.maxOperatorResponseTime)
+	defer cancel()
+
+	retryBackoff := k.newWatchRetryBackoff()
+
+	var lastErr error
+
+	for {
+		waitingStarted := time.Now()
+
+		err := k.watchForNotOkToReboot(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			// Report the original failure rather than the context error, if the retry got interrupted.
+			if lastErr != nil {
+				return fmt.Errorf("giving up (%v) after failure: %w", err, lastErr)
+			}
+
+			return err
+		}
+
+		lastErr = err
+
+		// Watch which has been running for a while most likely just expired, so start backing off again.
+		if time.Since(waitingStarted) > k.maxWatchRetryBackoff {
+			retryBackoff = k.newWatchRetryBackoff()
+		}
+
+		delay := retryBackoff.Step()
+
+		klog.Warningf("Error waiting for not ok-to-reboot, retrying in %v: %v", delay, err)
+
+		sleepOrDone(delay, ctx.Done())
+	}
+}
+
+func (k *klocksmith) watchForNotOkToReboot(ctx context.Context) error {
 	node, err := k.nc.Get(ctx, k.nodeName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("getting self node (%q): %w", k.nodeName, err)
@@ -426,8 +1735,8 @@ func (k *klocksmith) waitForNodeCondition(ctx context.Context, node *corev1.Node
 		return fmt.Errorf("creating watcher for self node (%q): %w", k.nodeName, err)
 	}
 
-	// Hopefully 24 hours is enough time between indicating we need a
-	// reboot and the controller telling us to do it.
+	// Hopefully 24 hours by default, or as configured, is enough time between
+	// indicating we need a reboot and the controller telling us to do it.
 	//
 	// If that isn't the case, it likely means the operator isn't running, and
 	// we'll just crash-loop in that case, and hopefully that will help the user realize something's wrong.
@@ -465,29 +1774,454 @@ type drainer interface {
 	DeleteOrEvictPods([]corev1.Pod) error
 }
 
-func newDrainer(ctx context.Context, cs kubernetes.Interface, timeout time.Duration, forceNodeDrain bool) drainer {
+func (k *klocksmith) newDrainHelper(ctx context.Context, timeout time.Duration, disableEviction bool) *drain.Helper {
 	return &drain.Helper{
 		Ctx:                ctx,
-		Client:             cs,
-		Force:              forceNodeDrain,
-		GracePeriodSeconds: -1,
+		Client:             k.clientset,
+		Force:              k.forceNodeDrain,
+		DisableEviction:    disableEviction,
+		GracePeriodSeconds: k.podTerminationGracePeriod,
 		Timeout:            timeout,
 		// Explicitly don't terminate self? we'll probably just be a
 		// Mirror pod or daemonset anyway..
 		IgnoreAllDaemonSets: true,
 		DeleteEmptyDirData:  true,
-		Out:                 &klogWriter{klog.Info},
+		Out:                 &klogWriter{klog.V(k.drainOutputVerbosity).Info},
 		ErrOut:              &klogWriter{klog.Error},
-		AdditionalFilters: []drain.PodFilter{
-			// XXX: Ignoring kube-system is a simple way to avoid eviciting
-			// critical components such as kube-scheduler and
-			// kube-controller-manager.
-			func(pod corev1.Pod) drain.PodDeleteStatus {
-				return drain.PodDeleteStatus{
-					Delete: pod.Namespace != "kube-system",
-				}
-			},
-		},
+		AdditionalFilters:   []drain.PodFilter{k8sutil.ProtectedNamespacesPodFilter(k.protectedNamespaces)},
+	}
+}
+
+// rateLimitedDrainer removes pods using wrapped drainer at a limited rate. Each pod is removed
+// as soon as the rate allows, without waiting for previously removed pods to terminate.
+// DeleteOrEvictPods returns once all pods are removed and terminated, or once removing them fails.
+type rateLimitedDrainer struct {
+	drainer
+
+	ctx     context.Context //nolint:containedctx // Like drain.Helper, as drainer methods do not accept context.
+	limiter *rate.Limiter
+}
+
+func newRateLimitedDrainer(ctx context.Context, d drainer, podsPerSecond float64) drainer {
+	return &rateLimitedDrainer{
+		drainer: d,
+		ctx:     ctx,
+		limiter: rate.NewLimiter(rate.Limit(podsPerSecond), 1),
+	}
+}
+
+// DeleteOrEvictPods implements drainer interface.
+func (r *rateLimitedDrainer) DeleteOrEvictPods(pods []corev1.Pod) error {
+	errCh := make(chan error, len(pods))
+
+	var wg sync.WaitGroup
+
+	for _, pod := range pods {
+		if err := r.limiter.Wait(r.ctx); err != nil {
+			errCh <- fmt.Errorf("waiting for pod removal rate limit: %w", err)
+
+			break
+		}
+
+		wg.Add(1)
+
+		go func(pod corev1.Pod) {
+			defer wg.Done()
+
+			if err := r.drainer.DeleteOrEvictPods([]corev1.Pod{pod}); err != nil {
+				errCh <- err
+			}
+		}(pod)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	errs := []error{}
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// statefulLastDrainer removes pods using wrapped drainer in two rounds, first removing pods not owned
+// by StatefulSets and then, once they are terminated, pods owned by StatefulSets. Each round is subject
+// to the wrapped drainer timeout.
+type statefulLastDrainer struct {
+	drainer
+
+	ctx context.Context //nolint:containedctx // Like drain.Helper, as drainer methods do not accept context.
+}
+
+func newStatefulLastDrainer(ctx context.Context, d drainer) drainer {
+	return &statefulLastDrainer{
+		drainer: d,
+		ctx:     ctx,
+	}
+}
+
+// DeleteOrEvictPods implements drainer interface.
+//
+// If removing stateless pods fails, stateful pods are still removed, as the node may be rebooted anyway.
+func (s *statefulLastDrainer) DeleteOrEvictPods(pods []corev1.Pod) error {
+	stateless := []corev1.Pod{}
+	stateful := []corev1.Pod{}
+
+	for i := range pods {
+		if owner := metav1.GetControllerOf(&pods[i]); owner != nil && owner.Kind == "StatefulSet" {
+			stateful = append(stateful, pods[i])
+
+			continue
+		}
+
+		stateless = append(stateless, pods[i])
+	}
+
+	errs := []error{}
+
+	for _, round := range [][]corev1.Pod{stateless, stateful} {
+		if len(round) == 0 {
+			continue
+		}
+
+		if s.ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("removing pods: %w", s.ctx.Err()))
+
+			break
+		}
+
+		if err := s.drainer.DeleteOrEvictPods(round); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// gracePeriodOverridingDrainer removes pods controlled by objects of kinds with overridden grace period
+// using drainers created with the overridden grace period and other pods using wrapped drainer.
+// Pods with different grace periods are removed in parallel.
+type gracePeriodOverridingDrainer struct {
+	drainer
+
+	gracePeriods map[string]time.Duration
+	drainers     map[time.Duration]drainer
+}
+
+func newGracePeriodOverridingDrainer(
+	d drainer, gracePeriods map[string]time.Duration, newDrainer func(time.Duration) drainer,
+) drainer {
+	drainers := map[time.Duration]drainer{}
+
+	for _, gracePeriod := range gracePeriods {
+		if _, ok := drainers[gracePeriod]; !ok {
+			drainers[gracePeriod] = newDrainer(gracePeriod)
+		}
+	}
+
+	return &gracePeriodOverridingDrainer{
+		drainer:      d,
+		gracePeriods: gracePeriods,
+		drainers:     drainers,
+	}
+}
+
+// DeleteOrEvictPods implements drainer interface.
+func (g *gracePeriodOverridingDrainer) DeleteOrEvictPods(pods []corev1.Pod) error {
+	groups := map[drainer][]corev1.Pod{}
+
+	for i := range pods {
+		d := g.drainer
+
+		if owner := metav1.GetControllerOf(&pods[i]); owner != nil {
+			if gracePeriod, ok := g.gracePeriods[owner.Kind]; ok {
+				d = g.drainers[gracePeriod]
+			}
+		}
+
+		groups[d] = append(groups[d], pods[i])
+	}
+
+	errCh := make(chan error, len(groups))
+
+	var wg sync.WaitGroup
+
+	for d, group := range groups {
+		wg.Add(1)
+
+		go func(d drainer, group []corev1.Pod) {
+			defer wg.Done()
+
+			if err := d.DeleteOrEvictPods(group); err != nil {
+				errCh <- err
+			}
+		}(d, group)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	errs := []error{}
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// watchingDrainer removes pods using wrapped drain helper, but instead of polling each removed pod
+// until it terminates, it waits for their termination by watching pods on the node, which puts less
+// load on the API server. If watching fails, it falls back to polling.
+type watchingDrainer struct {
+	*drain.Helper
+
+	nodeName string
+}
+
+func newWatchingDrainer(helper *drain.Helper, nodeName string) drainer {
+	return &watchingDrainer{
+		Helper:   helper,
+		nodeName: nodeName,
+	}
+}
+
+// DeleteOrEvictPods implements drainer interface.
+func (w *watchingDrainer) DeleteOrEvictPods(pods []corev1.Pod) error {
+	if len(pods) == 0 {
+		return nil
+	}
+
+	ctx := w.Ctx
+
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	evictionGroupVersion := schema.GroupVersion{}
+
+	if !w.DisableEviction {
+		groupVersion, err := drain.CheckEvictionSupport(w.Client)
+		if err != nil {
+			return fmt.Errorf("checking eviction support: %w", err)
+		}
+
+		evictionGroupVersion = groupVersion
+	}
+
+	// Each pod removal reports at most one error, plus waiting for termination may fail.
+	errCh := make(chan error, len(pods)+1)
+	removedCh := make(chan corev1.Pod, len(pods))
+
+	var wg sync.WaitGroup
+
+	for _, pod := range pods {
+		wg.Add(1)
+
+		go func(pod corev1.Pod) {
+			defer wg.Done()
+
+			if err := w.removePod(ctx, pod, evictionGroupVersion); err != nil {
+				errCh <- err
+
+				return
+			}
+
+			removedCh <- pod
+		}(pod)
+	}
+
+	wg.Wait()
+	close(removedCh)
+
+	removed := []corev1.Pod{}
+	for pod := range removedCh {
+		removed = append(removed, pod)
+	}
+
+	if err := w.waitForPodsTerminated(ctx, removed); err != nil {
+		errCh <- err
+	}
+
+	close(errCh)
+
+	errs := []error{}
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// removePod evicts given pod using given eviction API version or deletes it, if eviction API is not
+// available. Eviction is retried while it is blocked, e.g. by PodDisruptionBudget.
+func (w *watchingDrainer) removePod(
+	ctx context.Context, pod corev1.Pod, evictionGroupVersion schema.GroupVersion,
+) error {
+	for {
+		var err error
+
+		if evictionGroupVersion.Empty() {
+			err = w.DeletePod(pod)
+		} else {
+			err = w.EvictPod(pod, evictionGroupVersion)
+		}
+
+		namespaceTerminating := apierrors.IsForbidden(err) &&
+			apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+			return nil
+		case namespaceTerminating && !pod.DeletionTimestamp.IsZero():
+			// Pod in terminating namespace cannot be evicted, but it is already being deleted.
+			return nil
+		case apierrors.IsTooManyRequests(err), namespaceTerminating:
+			klog.Warningf("Removing pod %s/%s failed, will retry after %v: %v",
+				pod.Namespace, pod.Name, podRemovalRetryInterval, err)
+		default:
+			return fmt.Errorf("removing pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("removing pod %s/%s: %w", pod.Namespace, pod.Name, ctx.Err())
+		case <-time.After(podRemovalRetryInterval):
+		}
+	}
+}
+
+// waitForPodsTerminated blocks until all given pods are terminated by watching pods on the node.
+// If watching fails, remaining pods are polled instead.
+func (w *watchingDrainer) waitForPodsTerminated(ctx context.Context, pods []corev1.Pod) error {
+	pending := map[string]types.UID{}
+	for _, pod := range pods {
+		pending[pod.Namespace+"/"+pod.Name] = pod.UID
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	podsClient := w.Client.CoreV1().Pods(metav1.NamespaceAll)
+	listOptions := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", w.nodeName).String(),
+	}
+
+	// List pods first, so no termination is missed between removing the pods and starting the watch.
+	podList, err := podsClient.List(ctx, listOptions)
+	if err != nil {
+		klog.Warningf("Failed listing pods to watch for termination, falling back to polling: %v", err)
+
+		return w.pollPodsTerminated(ctx, pending)
+	}
+
+	existing := map[string]types.UID{}
+	for _, pod := range podList.Items {
+		existing[pod.Namespace+"/"+pod.Name] = pod.UID
+	}
+
+	for key, uid := range pending {
+		// Pod with the same name might have been recreated by its controller.
+		if existingUID, ok := existing[key]; !ok || existingUID != uid {
+			delete(pending, key)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	listOptions.ResourceVersion = podList.ResourceVersion
+
+	watcher, err := podsClient.Watch(ctx, listOptions)
+	if err != nil {
+		klog.Warningf("Failed watching pods for termination, falling back to polling: %v", err)
+
+		return w.pollPodsTerminated(ctx, pending)
+	}
+
+	defer watcher.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %d pods to terminate: %w", len(pending), ctx.Err())
+		case event, ok := <-watcher.ResultChan():
+			if !ok || event.Type == watch.Error {
+				klog.Warningf("Watching pods for termination failed, falling back to polling")
+
+				return w.pollPodsTerminated(ctx, pending)
+			}
+
+			pod, isPod := event.Object.(*corev1.Pod)
+			if !isPod || event.Type != watch.Deleted {
+				continue
+			}
+
+			if uid, ok := pending[pod.Namespace+"/"+pod.Name]; ok && uid == pod.UID {
+				delete(pending, pod.Namespace+"/"+pod.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pollPodsTerminated blocks until all given pods, identified by "namespace/name" key and UID, are terminated
+// by periodically getting each of them.
+func (w *watchingDrainer) pollPodsTerminated(ctx context.Context, pending map[string]types.UID) error {
+	err := wait.PollUntilContextCancel(ctx, podTerminationPollInterval, true, func(ctx context.Context) (bool, error) {
+		for key, uid := range pending {
+			//nolint:gomnd // Namespace and name.
+			parts := strings.SplitN(key, "/", 2)
+
+			pod, err := w.Client.CoreV1().Pods(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+
+			switch {
+			case apierrors.IsNotFound(err), err == nil && pod.UID != uid:
+				delete(pending, key)
+			case err != nil:
+				return false, fmt.Errorf("getting pod %s: %w", key, err)
+			}
+		}
+
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for %d pods to terminate: %w", len(pending), err)
+	}
+
+	return nil
+}
+
+// waitForRebootWindow blocks until configured local reboot window is open. It returns false if
+// given context gets cancelled before that. If no reboot window is configured, it returns immediately.
+func (k *klocksmith) waitForRebootWindow(ctx context.Context) bool {
+	if k.rebootWindow == nil {
+		return true
+	}
+
+	for {
+		now := time.Now()
+		if k.rebootWindow.Contains(now) {
+			return true
+		}
+
+		untilStart := k.rebootWindow.DurationToStart(now)
+
+		klog.Infof("Outside of local reboot window, waiting %v for it to open", untilStart)
+
+		k.setRebootBlockedReason(ctx, constants.RebootBlockedReasonOutsideWindow)
+
+		sleepOrDone(untilStart, ctx.Done())
+
+		if ctx.Err() != nil {
+			return false
+		}
 	}
 }
 
@@ -529,15 +2263,61 @@ type versionInfo struct {
 	version string
 }
 
-func getUpdateMap(filesPathPrefix string) (map[string]string, error) {
-	infomap := map[string]string{}
+// defaultOSReleasePaths returns paths where os-release file is looked for by default.
+func defaultOSReleasePaths() []string {
+	return []string{"/etc/os-release", "/usr/lib/os-release"}
+}
+
+// defaultUpdateConfPaths returns paths where update.conf file shipped with the image is looked for by default.
+func defaultUpdateConfPaths() []string {
+	return []string{"/usr/share/flatcar/update.conf", "/usr/share/coreos/update.conf"}
+}
+
+// hostFilePaths validates given list of host file paths, returning given defaults if the list is empty.
+func hostFilePaths(paths, defaults []string) ([]string, error) {
+	if len(paths) == 0 {
+		return defaults, nil
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			return nil, fmt.Errorf("paths must not be empty")
+		}
+	}
+
+	return paths, nil
+}
+
+// readFirstExistingFile reads the first existing file from given paths with given prefix, returning its content
+// and path. Files which exist, but cannot be read, are not skipped, but cause an error.
+func readFirstExistingFile(filesPathPrefix string, paths []string) ([]byte, string, error) {
+	var err error
 
-	updateConfPathWithPrefix := filepath.Join(filesPathPrefix, updateConfPath)
+	for _, path := range paths {
+		pathWithPrefix := filepath.Join(filesPathPrefix, path)
+
+		var b []byte
+
+		b, err = os.ReadFile(pathWithPrefix)
+		if err == nil {
+			return b, pathWithPrefix, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("reading file %q: %w", pathWithPrefix, err)
+		}
+	}
+
+	return nil, "", fmt.Errorf("none of files %v found in %q: %w", paths, filesPathPrefix, err)
+}
+
+func getUpdateMap(filesPathPrefix string, updateConfPaths []string) (map[string]string, error) {
+	infomap := map[string]string{}
 
 	// This file should always be present on Flatcar.
-	b, err := os.ReadFile(updateConfPathWithPrefix)
+	b, _, err := readFirstExistingFile(filesPathPrefix, updateConfPaths)
 	if err != nil {
-		return nil, fmt.Errorf("reading file %q: %w", updateConfPathWithPrefix, err)
+		return nil, err
 	}
 
 	splitNewlineEnv(infomap, string(b))
@@ -558,40 +2338,50 @@ func getUpdateMap(filesPathPrefix string) (map[string]string, error) {
 	return infomap, nil
 }
 
-func getReleaseMap(filesPathPrefix string) (map[string]string, error) {
+func getReleaseMap(filesPathPrefix string, osReleasePaths []string) (map[string]string, string, error) {
 	infomap := map[string]string{}
 
-	osReleasePathWithPrefix := filepath.Join(filesPathPrefix, osReleasePath)
-
 	// This file should always be present on Flatcar.
-	b, err := os.ReadFile(osReleasePathWithPrefix)
+	b, path, err := readFirstExistingFile(filesPathPrefix, osReleasePaths)
 	if err != nil {
-		return nil, fmt.Errorf("reading file %q: %w", osReleasePathWithPrefix, err)
+		return nil, "", err
 	}
 
 	splitNewlineEnv(infomap, string(b))
 
-	return infomap, nil
+	return infomap, path, nil
 }
 
-// GetVersionInfo returns VersionInfo from the current Flatcar system.
+// GetVersionInfo returns VersionInfo from the current Flatcar system, reading the first existing
+// update.conf and os-release files from given paths. Version is taken from given os-release key,
+// falling back to "VERSION" if the key is not present.
 //
 // Should probably live in a different package.
-func getVersionInfo(filesPathPrefix string) (*versionInfo, error) {
-	updateconf, err := getUpdateMap(filesPathPrefix)
+func getVersionInfo(
+	filesPathPrefix string, updateConfPaths, osReleasePaths []string, versionKey string,
+) (*versionInfo, error) {
+	updateconf, err := getUpdateMap(filesPathPrefix, updateConfPaths)
 	if err != nil {
 		return nil, fmt.Errorf("getting update configuration: %w", err)
 	}
 
-	osrelease, err := getReleaseMap(filesPathPrefix)
+	osrelease, osReleasePath, err := getReleaseMap(filesPathPrefix, osReleasePaths)
 	if err != nil {
 		return nil, fmt.Errorf("getting OS release info: %w", err)
 	}
 
+	version, ok := osrelease[versionKey]
+	if !ok && versionKey != defaultVersionOSReleaseKey {
+		klog.Warningf("Key %q not found in %q, using %q for version label instead",
+			versionKey, osReleasePath, defaultVersionOSReleaseKey)
+
+		version = osrelease[defaultVersionOSReleaseKey]
+	}
+
 	return &versionInfo{
 		id:      osrelease["ID"],
 		group:   updateconf["GROUP"],
-		version: osrelease["VERSION"],
+		version: version,
 	}, nil
 }
 
@@ -2,9 +2,14 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func Test_splitNewlineEnv(t *testing.T) {
@@ -108,3 +113,52 @@ func Test_sleepOrDone_returns_when_given(t *testing.T) {
 		}
 	})
 }
+
+func Test_evictedPodsAnnotationValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists_sorted_pods_in_namespace_name_format", func(t *testing.T) {
+		t.Parallel()
+
+		pods := []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "another", Name: "bar"}},
+		}
+
+		expected := "another/bar,default/foo"
+
+		if value := evictedPodsAnnotationValue(pods); value != expected {
+			t.Fatalf("Expected %q, got %q", expected, value)
+		}
+	})
+
+	t.Run("truncates_too_long_list_with_number_of_omitted_pods", func(t *testing.T) {
+		t.Parallel()
+
+		pods := []corev1.Pod{}
+
+		for i := 0; i < 1000; i++ {
+			pods = append(pods, corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("pod-%04d", i)},
+			})
+		}
+
+		value := evictedPodsAnnotationValue(pods)
+
+		if len(value) > maxEvictedPodsAnnotationLength {
+			t.Fatalf("Expected value to be at most %d characters long, got %d", maxEvictedPodsAnnotationLength, len(value))
+		}
+
+		names := strings.Split(value, ",")
+		last := names[len(names)-1]
+		expectedLast := fmt.Sprintf("and %d more", len(pods)-len(names)+1)
+
+		if last != expectedLast {
+			t.Fatalf("Expected value to end with %q, got %q", expectedLast, last)
+		}
+
+		if names[0] != "default/pod-0000" {
+			t.Fatalf("Expected value to start with first pod, got %q", names[0])
+		}
+	})
+}
@@ -1,24 +1,32 @@
 package agent_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	k8stesting "k8s.io/client-go/testing"
@@ -31,9 +39,11 @@ import (
 )
 
 const (
-	agentRunTimeLimit     = 15 * time.Second
-	agentShutdownLimit    = 10 * time.Second
-	errorResponseThrottle = 100 * time.Millisecond
+	agentRunTimeLimit  = 15 * time.Second
+	agentShutdownLimit = 10 * time.Second
+	// Agents draining pods stuck terminating wait for pod deletion grace period, possibly twice, so give them
+	// more time when tests get executed on a single CPU.
+	stuckPodsAgentRunTimeLimit = 30 * time.Second
 )
 
 //nolint:funlen,cyclop,gocognit // Just many test cases.
@@ -128,6 +138,50 @@ func Test_Creating_new_agent(t *testing.T) {
 			"no_status_receiver_is_configured": func(c *agent.Config) { c.StatusReceiver = nil },
 			"no_rebooter_is_configured":        func(c *agent.Config) { c.Rebooter = nil },
 			"empty_node_name_is_given":         func(c *agent.Config) { c.NodeName = "" },
+			"invalid_managed_node_selector_is_given": func(c *agent.Config) {
+				c.ManagedNodeSelector = "foo=bar=baz"
+			},
+			"negative_max_startup_delay_is_given": func(c *agent.Config) { c.MaxStartupDelay = -1 * time.Second },
+			"malformed_drained_DaemonSet_is_given": func(c *agent.Config) {
+				c.DrainedDaemonSets = []string{"storage-plugin"}
+			},
+			"empty_os_release_path_is_given": func(c *agent.Config) {
+				c.OSReleasePaths = []string{"/etc/os-release", ""}
+			},
+			"empty_update_conf_path_is_given":          func(c *agent.Config) { c.UpdateConfPaths = []string{""} },
+			"negative_max_pod_eviction_rate_is_given":  func(c *agent.Config) { c.MaxPodEvictionRate = -1 },
+			"negative_reboot_retries_are_given":        func(c *agent.Config) { c.RebootRetries = -1 },
+			"negative_drain_output_verbosity_is_given": func(c *agent.Config) { c.DrainOutputVerbosity = -1 },
+			"negative_max_operator_response_time_is_given": func(c *agent.Config) {
+				c.MaxOperatorResponseTime = -1 * time.Second
+			},
+			"negative_reboot_timeout_is_given": func(c *agent.Config) { c.RebootTimeout = -1 * time.Second },
+			"negative_pod_termination_grace_period_override_is_given": func(c *agent.Config) {
+				override := -1 * time.Second
+				c.PodTerminationGracePeriodOverride = &override
+			},
+			"negative_watch_retry_backoff_is_given": func(c *agent.Config) {
+				c.WatchRetryBackoff = -1 * time.Second
+			},
+			"negative_max_watch_retry_backoff_is_given": func(c *agent.Config) {
+				c.MaxWatchRetryBackoff = -1 * time.Second
+			},
+			"negative_heartbeat_interval_is_given": func(c *agent.Config) {
+				c.HeartbeatInterval = -1 * time.Second
+			},
+			"reboot_window_start_is_given_without_length": func(c *agent.Config) {
+				c.RebootWindowStart = "14:00"
+			},
+			"invalid_reboot_window_is_given": func(c *agent.Config) {
+				c.RebootWindowStart = "25:00"
+				c.RebootWindowLength = "1h"
+			},
+			"unsupported_stuck_pods_policy_is_given": func(c *agent.Config) { c.StuckPodsPolicy = "foo" },
+			"unsupported_reboot_action_is_given":     func(c *agent.Config) { c.RebootAction = "foo" },
+			"unsupported_cordon_method_is_given":     func(c *agent.Config) { c.CordonMethod = "foo" },
+			"power_off_reboot_action_is_given_with_rebooter_not_supporting_it": func(c *agent.Config) {
+				c.RebootAction = agent.RebootActionPowerOff
+			},
 		}
 
 		for n, mutateConfigF := range cases {
@@ -159,56 +213,270 @@ func Test_Running_agent(t *testing.T) {
 	t.Run("reads_host_configuration_by", func(t *testing.T) {
 		t.Parallel()
 
-		testConfig, _, _ := validTestConfig(t, testNode())
-
 		expectedGroup := "configuredGroup"
 		expectedOSID := "testID"
 		expectedVersion := "testVersion"
 
-		files := map[string]string{
-			"/usr/share/flatcar/update.conf": "GROUP=" + expectedGroup,
-			"/etc/os-release":                fmt.Sprintf("ID=%s\nVERSION=%s", expectedOSID, expectedVersion),
-		}
+		// Each subtest runs its own agent, so agent does not time out while subtest waits for its turn.
+		assertHostConfigurationRead := func(t *testing.T, testF nodeAssertF) {
+			t.Helper()
 
-		createTestFiles(t, files, testConfig.HostFilesPrefix)
+			testConfig, _, _ := validTestConfig(t, testNode())
 
-		ctx := contextWithTimeout(t, agentRunTimeLimit)
+			files := map[string]string{
+				"/usr/share/flatcar/update.conf": "GROUP=" + expectedGroup,
+				"/etc/os-release":                fmt.Sprintf("ID=%s\nVERSION=%s", expectedOSID, expectedVersion),
+			}
 
-		done := runAgent(ctx, t, testConfig)
+			createTestFiles(t, files, testConfig.HostFilesPrefix)
 
-		t.Run("reading_OS_ID_from_etc_os_release_file", func(t *testing.T) {
-			t.Parallel()
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-			// This is currently the only way to check that agent has read /etc/os-release file.
 			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
+				done:   runAgent(ctx, t, testConfig),
 				config: testConfig,
-				testF:  assertNodeLabelValue(constants.LabelID, expectedOSID),
+				testF:  testF,
 			})
+		}
+
+		t.Run("reading_OS_ID_from_etc_os_release_file", func(t *testing.T) {
+			t.Parallel()
+
+			// This is currently the only way to check that agent has read /etc/os-release file.
+			assertHostConfigurationRead(t, assertNodeLabelValue(constants.LabelID, expectedOSID))
 		})
 
 		t.Run("reading_Flatcar_version_from_etc_os_release_file", func(t *testing.T) {
 			t.Parallel()
 
 			// This is currently the only way to check that agent has read /etc/os-release file.
+			assertHostConfigurationRead(t, assertNodeLabelValue(constants.LabelVersion, expectedVersion))
+		})
+
+		t.Run("reading_Flatcar_group_from_update_configuration_file_in_usr_directory", func(t *testing.T) {
+			t.Parallel()
+
+			// This is currently the only way to check that agent
+			// read /etc/flatcar/update.conf or /usr/share/flatcar/update.conf.
+			assertHostConfigurationRead(t, assertNodeLabelValue(constants.LabelGroup, expectedGroup))
+		})
+	})
+
+	t.Run("reads_Flatcar_version_from_configured_etc_os_release_key", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, _ := validTestConfig(t, testNode())
+		testConfig.VersionOSReleaseKey = "VERSION_ID"
+
+		expectedVersion := "3510.2.0"
+
+		files := map[string]string{
+			"/etc/os-release": "ID=testID\nVERSION=3510.2.0+build\nVERSION_ID=" + expectedVersion,
+		}
+
+		createTestFiles(t, files, testConfig.HostFilesPrefix)
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelVersion, expectedVersion),
+		})
+	})
+
+	t.Run("reads_Flatcar_version_from_VERSION_key_when_configured_etc_os_release_key_is_missing", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, _ := validTestConfig(t, testNode())
+		testConfig.VersionOSReleaseKey = "BUILD_ID"
+
+		expectedVersion := "testVersion"
+
+		files := map[string]string{
+			"/etc/os-release": "ID=testID\nVERSION=" + expectedVersion,
+		}
+
+		createTestFiles(t, files, testConfig.HostFilesPrefix)
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelVersion, expectedVersion),
+		})
+	})
+
+	t.Run("reads_Flatcar_version_from_alternative_os_release_file_when_etc_os_release_file_does_not_exist",
+		func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, _, _ := validTestConfig(t, testNode())
+
+			expectedVersion := "alternativeVersion"
+
+			files := map[string]string{
+				"/usr/lib/os-release": "ID=testID\nVERSION=" + expectedVersion,
+			}
+
+			createTestFiles(t, files, testConfig.HostFilesPrefix)
+
+			if err := os.Remove(filepath.Join(testConfig.HostFilesPrefix, "/etc/os-release")); err != nil {
+				t.Fatalf("Failed removing test file: %v", err)
+			}
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
 			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
+				done:   runAgent(ctx, t, testConfig),
 				config: testConfig,
 				testF:  assertNodeLabelValue(constants.LabelVersion, expectedVersion),
 			})
 		})
 
-		t.Run("reading_Flatcar_group_from_update_configuration_file_in_usr_directory", func(t *testing.T) {
+	t.Run("reads_host_configuration_from_first_existing_configured_paths", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, _ := validTestConfig(t, testNode())
+		testConfig.OSReleasePaths = []string{"/opt/missing/os-release", "/opt/os-release"}
+		testConfig.UpdateConfPaths = []string{"/opt/missing/update.conf", "/opt/update.conf"}
+
+		expectedGroup := "customGroup"
+		expectedVersion := "customVersion"
+
+		files := map[string]string{
+			"/opt/os-release":  "ID=testID\nVERSION=" + expectedVersion,
+			"/opt/update.conf": "GROUP=" + expectedGroup,
+		}
+
+		createTestFiles(t, files, testConfig.HostFilesPrefix)
+
+		// Override from /etc would take precedence over configured update.conf.
+		if err := os.Remove(filepath.Join(testConfig.HostFilesPrefix, "/etc/flatcar/update.conf")); err != nil {
+			t.Fatalf("Failed removing test file: %v", err)
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelVersion, expectedVersion),
+		})
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelGroup, expectedGroup),
+		})
+	})
+
+	t.Run("reports_last_update_attempt_error_when_update_engine_reports_an_error", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, _ := validTestConfig(t, testNode())
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+				ch <- updateengine.Status{
+					CurrentOperation: updateengine.UpdateStatusReportingErrorEvent,
+				}
+			},
+		}
+
+		expectedErrorCode := int32(37)
+
+		testConfig.LastAttemptErrorReader = &mockLastAttemptErrorReader{
+			lastAttemptErrorF: func() (int32, error) {
+				return expectedErrorCode, nil
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF: assertNodeAnnotationValue(constants.AnnotationLastUpdateAttemptError,
+				strconv.Itoa(int(expectedErrorCode))),
+		})
+	})
+
+	t.Run("when_update_engine_reports_an_error", func(t *testing.T) {
+		t.Parallel()
+
+		// Each subtest runs its own agent, so agent does not time out while subtest waits for its turn.
+		runErrorReportingAgent := func(t *testing.T) (context.Context, *agent.Config, *corev1.Node, <-chan error) {
+			t.Helper()
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.StatusReceiver = &mockStatusReceiver{
+				receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+					ch <- updateengine.Status{
+						CurrentOperation: updateengine.UpdateStatusReportingErrorEvent,
+					}
+				},
+			}
+			testConfig.LastAttemptErrorReader = &mockLastAttemptErrorReader{
+				lastAttemptErrorF: func() (int32, error) {
+					return 37, nil
+				},
+			}
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			return ctx, testConfig, node, runAgent(ctx, t, testConfig)
+		}
+
+		t.Run("sets_update_error_annotation_to_true", func(t *testing.T) {
 			t.Parallel()
 
-			// This is currently the only way to check that agent
-			// read /etc/flatcar/update.conf or /usr/share/flatcar/update.conf.
+			ctx, testConfig, _, done := runErrorReportingAgent(t)
+
 			assertNodeProperty(ctx, t, &assertNodePropertyContext{
 				done:   done,
 				config: testConfig,
-				testF:  assertNodeLabelValue(constants.LabelGroup, expectedGroup),
+				testF:  assertNodeAnnotationValue(constants.AnnotationUpdateError, constants.True),
 			})
 		})
+
+		t.Run("emits_warning_event", func(t *testing.T) {
+			t.Parallel()
+
+			ctx, testConfig, node, _ := runErrorReportingAgent(t)
+
+			event := waitForEvent(ctx, t, testConfig.Clientset, node.Name, corev1.EventTypeWarning, "UpdateError")
+
+			if !strings.Contains(event.Message, "error code 37") {
+				t.Fatalf("Expected event message to contain last update attempt error code, got %q", event.Message)
+			}
+		})
+	})
+
+	t.Run("sets_update_error_annotation_to_false_when_update_engine_reports_other_status", func(t *testing.T) {
+		t.Parallel()
+
+		errorNode := testNode()
+		errorNode.Annotations[constants.AnnotationUpdateError] = constants.True
+
+		testConfig, _, _ := validTestConfig(t, errorNode)
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+				ch <- updateengine.Status{
+					CurrentOperation: updateengine.UpdateStatusIdle,
+				}
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationUpdateError, constants.False),
+		})
 	})
 
 	t.Run("prefers_Flatcar_group_from_etc_over_usr", func(t *testing.T) {
@@ -261,6 +529,47 @@ func Test_Running_agent(t *testing.T) {
 		})
 	})
 
+	t.Run("does_not_manage_node_which_does_not_match_configured_managed_node_selector", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.ManagedNodeSelector = "managed=true"
+
+		ctx := contextWithTimeout(t, time.Second)
+
+		if err := <-runAgent(ctx, t, testConfig); err != nil {
+			t.Fatalf("Expected agent to shut down gracefully, got: %v", err)
+		}
+
+		updatedNode, err := testConfig.Clientset.CoreV1().Nodes().Get(contextWithDeadline(t), node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed getting node %q: %v", node.Name, err)
+		}
+
+		if len(updatedNode.Labels) != 0 || len(updatedNode.Annotations) != 0 {
+			t.Fatalf("Expected node to not be modified, got labels %v and annotations %v",
+				updatedNode.Labels, updatedNode.Annotations)
+		}
+	})
+
+	t.Run("manages_node_which_matches_configured_managed_node_selector", func(t *testing.T) {
+		t.Parallel()
+
+		managedNode := testNode()
+		managedNode.Labels["managed"] = constants.True
+
+		testConfig, _, _ := validTestConfig(t, managedNode)
+		testConfig.ManagedNodeSelector = "managed=true"
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelExists(constants.LabelID),
+		})
+	})
+
 	t.Run("on_start", func(t *testing.T) {
 		t.Parallel()
 
@@ -344,113 +653,160 @@ func Test_Running_agent(t *testing.T) {
 				})
 			})
 		})
-	})
 
-	t.Run("waits_for_not_ok_to_reboot_annotation_from_operator_after_updating_node_information", func(t *testing.T) {
-		t.Parallel()
+		t.Run("when_configured_to_preserve_reboot_needed_state_on_startup", func(t *testing.T) {
+			t.Parallel()
 
-		expectStatusPoll := false
-		statusReceived := make(chan bool)
+			t.Run("does_not_reset_reboot_needed_state", func(t *testing.T) {
+				t.Parallel()
 
-		testConfig, node, _ := validTestConfig(t, okToRebootNode())
-		testConfig.StatusReceiver = &mockStatusReceiver{
-			receiveStatusesF: func(chan<- updateengine.Status, <-chan struct{}) {
-				statusReceived <- expectStatusPoll
-			},
-		}
+				testConfig, _, _ := validTestConfig(t, rebootNeededNode())
+				testConfig.StatusReceiver = &mockStatusReceiver{}
+				testConfig.PreserveRebootNeededOnStartup = true
 
-		ctx := contextWithTimeout(t, time.Second)
+				ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		// Ensure we enter wait loop before setting not ok to reboot.
-		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   runAgent(ctx, t, testConfig),
-			config: testConfig,
-			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
-		})
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   runAgent(ctx, t, testConfig),
+					config: testConfig,
+					testF: func(t *testing.T, node *corev1.Node) bool {
+						t.Helper()
 
-		expectStatusPoll = true
+						// Wait for startup annotations to be set.
+						if node.Annotations[constants.AnnotationRebootInProgress] != constants.False {
+							return false
+						}
 
-		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+						if node.Annotations[constants.AnnotationRebootNeeded] != constants.True {
+							t.Fatalf("Expected reboot needed annotation to be preserved, got %q",
+								node.Annotations[constants.AnnotationRebootNeeded])
+						}
 
-		select {
-		case <-ctx.Done():
-			t.Fatal("Timed out waiting for receive statuses call")
-		case expectedStatusPoll := <-statusReceived:
-			if !expectedStatusPoll {
-				t.Fatalf("Observed unexpected status poll")
-			}
-		}
-	})
+						if node.Labels[constants.LabelRebootNeeded] != constants.True {
+							t.Fatalf("Expected reboot needed label to be preserved, got %q",
+								node.Labels[constants.LabelRebootNeeded])
+						}
 
-	t.Run("marks_node_as_schedulable_if_agent_made_it_unschedulable", func(t *testing.T) {
-		t.Parallel()
+						return true
+					},
+				})
+			})
 
-		expectNodeSchedulableUpdateMutex := &sync.Mutex{}
-		expectNodeSchedulableUpdate := false
-		nodeUpdatedAsSchedulable := make(chan bool, 1)
+			t.Run("resets_reboot_needed_state_when", func(t *testing.T) {
+				t.Parallel()
 
-		testConfig, node, fakeClient := validTestConfig(t, nodeMadeUnschedulable())
+				cases := map[string]struct {
+					node           *corev1.Node
+					statusReceiver *mockStatusReceiver
+				}{
+					"update_engine_does_not_report_reboot_needed": {
+						node: rebootNeededNode(),
+						statusReceiver: &mockStatusReceiver{
+							receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+								ch <- updateengine.Status{
+									CurrentOperation: updateengine.UpdateStatusIdle,
+								}
+							},
+						},
+					},
+					"reboot_has_been_approved": {
+						node: func() *corev1.Node {
+							node := rebootNeededNode()
+							node.Annotations[constants.AnnotationOkToReboot] = constants.True
+
+							return node
+						}(),
+						statusReceiver: &mockStatusReceiver{},
+					},
+				}
 
-		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			node := updateActionToNode(t, action)
+				for name, c := range cases {
+					c := c
 
-			if !node.Spec.Unschedulable {
-				expectNodeSchedulableUpdateMutex.Lock()
-				nodeUpdatedAsSchedulable <- expectNodeSchedulableUpdate
-				expectNodeSchedulableUpdateMutex.Unlock()
-			}
+					t.Run(name, func(t *testing.T) {
+						t.Parallel()
 
-			return false, nil, nil
-		})
+						testConfig, _, _ := validTestConfig(t, c.node)
+						testConfig.StatusReceiver = c.statusReceiver
+						testConfig.PreserveRebootNeededOnStartup = true
 
-		ctx := contextWithTimeout(t, agentRunTimeLimit)
+						ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		// Ensure we enter wait loop before setting not ok to reboot.
-		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   runAgent(ctx, t, testConfig),
-			config: testConfig,
-			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
+						assertNodeProperty(ctx, t, &assertNodePropertyContext{
+							done:   runAgent(ctx, t, testConfig),
+							config: testConfig,
+							testF: func(t *testing.T, node *corev1.Node) bool {
+								t.Helper()
+
+								return node.Annotations[constants.AnnotationRebootNeeded] == constants.False &&
+									node.Labels[constants.LabelRebootNeeded] == constants.False
+							},
+						})
+					})
+				}
+			})
 		})
 
-		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		t.Run("reports_agent_heartbeat", func(t *testing.T) {
+			t.Parallel()
 
-		expectNodeSchedulableUpdateMutex.Lock()
-		expectNodeSchedulableUpdate = true
-		expectNodeSchedulableUpdateMutex.Unlock()
+			testConfig, _, _ := validTestConfig(t, testNode())
+			testConfig.StatusReceiver = &mockStatusReceiver{}
 
-		select {
-		case <-ctx.Done():
-			t.Fatal("Timed out waiting for node being marked as schedulable")
-		case expected := <-nodeUpdatedAsSchedulable:
-			if !expected {
-				t.Fatalf("Node was updated as schedulable before it was expected")
-			}
-		}
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			startTime := time.Now().Truncate(time.Second)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF: func(t *testing.T, node *corev1.Node) bool {
+					t.Helper()
+
+					value, ok := node.Annotations[constants.AnnotationAgentHeartbeat]
+					if !ok {
+						return false
+					}
+
+					heartbeat, err := time.Parse(time.RFC3339, value)
+					if err != nil {
+						t.Fatalf("Failed parsing agent heartbeat %q: %v", value, err)
+					}
+
+					if heartbeat.Before(startTime) {
+						t.Fatalf("Expected agent heartbeat to be after %v, got %v", startTime, heartbeat)
+					}
+
+					return true
+				},
+			})
+		})
 	})
 
-	t.Run("leaves_node_unschedulable_if_it_was_made_unschedulable_by_external_source", func(t *testing.T) {
+	t.Run("periodically_updates_agent_heartbeat_while_waiting_for_ok_to_reboot_annotation", func(t *testing.T) {
 		t.Parallel()
 
-		nodeUnschedulableByExternalSource := nodeMadeUnschedulable()
-		nodeUnschedulableByExternalSource.Annotations[constants.AnnotationAgentMadeUnschedulable] = constants.False
-
-		testConfig, node, fakeClient := validTestConfig(t, nodeUnschedulableByExternalSource)
+		testConfig, _, fakeClient := validTestConfig(t, testNode())
+		testConfig.StatusReceiver = &mockStatusReceiver{}
+		testConfig.HeartbeatInterval = 10 * time.Millisecond
 
-		watchStatusStarted := make(chan struct{})
+		// Initial state update and at least 2 periodic updates.
+		expectedHeartbeatUpdates := 3
+		heartbeatsReported := make(chan struct{})
 
-		testConfig.StatusReceiver = &mockStatusReceiver{
-			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
-				watchStatusStarted <- struct{}{}
-			},
-		}
+		var heartbeatsReportedOnce sync.Once
 
-		nodeUnschedulableUpdate := make(chan struct{})
+		heartbeatUpdates := 0
 
 		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
 			node := updateActionToNode(t, action)
 
-			if !node.Spec.Unschedulable {
-				nodeUnschedulableUpdate <- struct{}{}
+			if _, ok := node.Annotations[constants.AnnotationAgentHeartbeat]; ok {
+				heartbeatUpdates++
+			}
+
+			if heartbeatUpdates >= expectedHeartbeatUpdates {
+				heartbeatsReportedOnce.Do(func() { close(heartbeatsReported) })
 			}
 
 			return false, nil, nil
@@ -458,228 +814,1921 @@ func Test_Running_agent(t *testing.T) {
 
 		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		// Ensure we enter wait loop before setting not ok to reboot.
-		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   runAgent(ctx, t, testConfig),
-			config: testConfig,
-			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
-		})
-
-		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		done := runAgent(ctx, t, testConfig)
 
 		select {
 		case <-ctx.Done():
-			t.Fatal("Timed out waiting for node being marked as schedulable")
-		case <-nodeUnschedulableUpdate:
-			t.Fatalf("Unexpected node update as schedulable")
-		case <-watchStatusStarted:
+			t.Fatalf("Timed out waiting for %d heartbeat updates", expectedHeartbeatUpdates)
+		case err := <-done:
+			t.Fatalf("Agent stopped prematurely: %v", err)
+		case <-heartbeatsReported:
 		}
 	})
 
-	t.Run("after_getting_not_ok_to_reboot_annotation", func(t *testing.T) {
+	t.Run("refreshes_agent_heartbeat_while_waiting_for_ok_to_reboot_annotation", func(t *testing.T) {
 		t.Parallel()
 
 		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.StatusReceiver = &mockStatusReceiver{}
+		testConfig.HeartbeatInterval = 10 * time.Millisecond
 
 		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
 		done := runAgent(ctx, t, testConfig)
 
-		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.False),
+		})
 
-		t.Run("updates_node_information_when_update_enging_produces_updated_status", func(t *testing.T) {
-			t.Parallel()
+		staleHeartbeat := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
 
-			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
-				config: testConfig,
-				testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.True),
-			})
-		})
+		nc := testConfig.Clientset.CoreV1().Nodes()
 
-		t.Run("waits_for_ok_to_reboot_annotation_from_operator", func(t *testing.T) {
-			t.Parallel()
+		updatedNode, err := nc.Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed getting node %q: %v", node.Name, err)
+		}
 
-			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
-				config: testConfig,
-				testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.True),
-			})
+		updatedNode.Annotations[constants.AnnotationAgentHeartbeat] = staleHeartbeat
 
-			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		if _, err := nc.Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Failed updating node %q: %v", node.Name, err)
+		}
 
-			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
-				config: testConfig,
-				testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.True),
-			})
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF: func(t *testing.T, node *corev1.Node) bool {
+				t.Helper()
+
+				return node.Annotations[constants.AnnotationAgentHeartbeat] != staleHeartbeat
+			},
 		})
 	})
 
-	t.Run("retries_updating_node_status_from_update_engine_until_it_succeeds", func(t *testing.T) {
+	t.Run("waits_for_not_ok_to_reboot_annotation_from_operator_after_updating_node_information", func(t *testing.T) {
 		t.Parallel()
 
-		testConfig, _, fakeClient := validTestConfig(t, testNode())
+		expectStatusPoll := false
+		statusReceived := make(chan bool)
 
-		firstCall := true
+		testConfig, node, _ := validTestConfig(t, okToRebootNode())
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(chan<- updateengine.Status, <-chan struct{}) {
+				statusReceived <- expectStatusPoll
+			},
+		}
 
-		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			node := updateActionToNode(t, action)
+		ctx := contextWithTimeout(t, time.Second)
 
-			if _, ok := node.Annotations[constants.AnnotationStatus]; ok {
-				if firstCall {
-					firstCall = false
+		// Ensure we enter wait loop before setting not ok to reboot.
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
+		})
 
-					return true, nil, fmt.Errorf(t.Name())
-				}
+		expectStatusPoll = true
+
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for receive statuses call")
+		case expectedStatusPoll := <-statusReceived:
+			if !expectedStatusPoll {
+				t.Fatalf("Observed unexpected status poll")
 			}
+		}
+	})
 
-			return false, nil, nil
-		})
+	t.Run("reports_reboot_blocked_reason_until_requesting_reboot_when_configured", func(t *testing.T) {
+		t.Parallel()
+
+		rebootTriggered := make(chan bool, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.ReportRebootBlockedReason = true
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggered <- auth
+			},
+		}
 
 		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
+		done := runAgent(ctx, t, testConfig)
+
 		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   runAgent(ctx, t, testConfig),
+			done:   done,
 			config: testConfig,
-			testF:  assertNodeAnnotationValue(constants.AnnotationStatus, updateengine.UpdateStatusUpdatedNeedReboot),
+			testF: assertNodeAnnotationValue(constants.AnnotationRebootBlockedReason,
+				constants.RebootBlockedReasonWaitingForOkToReboot),
 		})
-	})
 
-	t.Run("skips_node_status_update_when_update_engine_status_did_not_change", func(t *testing.T) {
-		t.Parallel()
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
-		testConfig, node, fakeClient := validTestConfig(t, testNode())
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case err := <-done:
+			t.Fatalf("Expected reboot, got agent running error: %v", err)
+		case <-rebootTriggered:
+		}
 
-		status := updateengine.Status{
-			CurrentOperation: updateengine.UpdateStatusUpdatedNeedReboot,
+		updatedNode, err := testConfig.Clientset.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Getting node: %v", err)
 		}
 
-		testConfig.StatusReceiver = &mockStatusReceiver{
-			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
-				status.NewVersion = "foo"
-				ch <- status
-				status.NewVersion = "bar"
-				ch <- status
-			},
+		if reason, ok := updatedNode.Annotations[constants.AnnotationRebootBlockedReason]; ok {
+			t.Fatalf("Expected reboot blocked reason to be removed after requesting reboot, got %q", reason)
 		}
+	})
 
-		newVersionReported := make(chan string, 2)
+	t.Run("does_not_report_waiting_for_ok_to_reboot_while_reboot_is_not_needed", func(t *testing.T) {
+		t.Parallel()
 
-		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			node := updateActionToNode(t, action)
+		testConfig, node, fakeClient := validTestConfig(t, testNode())
+		testConfig.ReportRebootBlockedReason = true
+		testConfig.StatusReceiver = &mockStatusReceiver{}
 
-			if _, ok := node.Annotations[constants.AnnotationStatus]; ok {
-				newVersionReported <- node.Annotations[constants.AnnotationNewVersion]
-			}
+		okToRebootWatchStarted := make(chan struct{})
+
+		var okToRebootWatchStartedOnce sync.Once
+
+		fakeClient.PrependWatchReactor("nodes", func(action k8stesting.Action) (bool, watch.Interface, error) {
+			okToRebootWatchStartedOnce.Do(func() { close(okToRebootWatchStarted) })
 
 			return false, nil, nil
 		})
 
 		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		runAgent(ctx, t, testConfig)
-
-		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
-
-		lastNewVersion := ""
+		done := runAgent(ctx, t, testConfig)
 
 		select {
 		case <-ctx.Done():
-			t.Fatalf("Timed out waiting for first node status update")
-		case newVersion := <-newVersionReported:
-			lastNewVersion = newVersion
+			t.Fatal("Timed out waiting for agent to start waiting for ok-to-reboot annotation")
+		case err := <-done:
+			t.Fatalf("Agent stopped prematurely: %v", err)
+		case <-okToRebootWatchStarted:
 		}
 
-		select {
-		case newVersion := <-newVersionReported:
-			if newVersion != lastNewVersion {
-				t.Fatalf("Unexpected node status update")
-			}
-		case <-contextWithTimeout(t, 500*time.Millisecond).Done():
+		updatedNode, err := testConfig.Clientset.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Getting node: %v", err)
+		}
+
+		if reason, ok := updatedNode.Annotations[constants.AnnotationRebootBlockedReason]; ok {
+			t.Fatalf("Expected no reboot blocked reason while reboot is not needed, got %q", reason)
 		}
 	})
 
-	t.Run("after_getting_ok_to_reboot_annotation", func(t *testing.T) {
+	t.Run("clears_waiting_for_not_ok_to_reboot_reason_once_operator_resets_ok_to_reboot", func(t *testing.T) {
 		t.Parallel()
 
-		t.Run("marks_node_as_unschedulable_if_not_done_before_by", func(t *testing.T) {
-			t.Parallel()
+		rebootedNode := testNode()
+		rebootedNode.Annotations[constants.AnnotationOkToReboot] = constants.True
 
-			testConfig, node, fakeClient := validTestConfig(t, testNode())
+		testConfig, node, _ := validTestConfig(t, rebootedNode)
+		testConfig.ReportRebootBlockedReason = true
+		testConfig.StatusReceiver = &mockStatusReceiver{}
 
-			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-			ctx := contextWithTimeout(t, agentRunTimeLimit)
+		done := runAgent(ctx, t, testConfig)
 
-			done := runAgent(ctx, t, testConfig)
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF: assertNodeAnnotationValue(constants.AnnotationRebootBlockedReason,
+				constants.RebootBlockedReasonWaitingForNotOkToReboot),
+		})
 
-			assertNodeProperty(ctx, t, &assertNodePropertyContext{
-				done:   done,
-				config: testConfig,
-				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
-			})
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
-			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF: func(t *testing.T, node *corev1.Node) bool {
+				t.Helper()
+
+				_, ok := node.Annotations[constants.AnnotationRebootBlockedReason]
+
+				return !ok
+			},
+		})
+	})
+
+	t.Run("retries_failed_watching_for_ok_to_reboot_annotation_with_increasing_delays", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, fakeClient := validTestConfig(t, testNode())
+		testConfig.WatchRetryBackoff = 100 * time.Millisecond
+		testConfig.MaxWatchRetryBackoff = time.Second
+
+		const expectedWatches = 4
+
+		watchTimes := make(chan time.Time, expectedWatches)
+
+		fakeClient.PrependWatchReactor("nodes", func(action k8stesting.Action) (bool, watch.Interface, error) {
+			select {
+			case watchTimes <- time.Now():
+			default:
+			}
+
+			return true, nil, fmt.Errorf(t.Name())
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		times := []time.Time{}
+
+		for len(times) < expectedWatches {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for watch retries, got %d watches", len(times))
+			case err := <-done:
+				t.Fatalf("Agent stopped unexpectedly: %v", err)
+			case watchTime := <-watchTimes:
+				times = append(times, watchTime)
+			}
+		}
+
+		previousDelay := time.Duration(0)
+
+		for i := 1; i < len(times); i++ {
+			delay := times[i].Sub(times[i-1])
+
+			if delay < testConfig.WatchRetryBackoff || delay <= previousDelay {
+				t.Fatalf("Expected increasing delays between watch retries, got %v after %v", delay, previousDelay)
+			}
+
+			previousDelay = delay
+		}
+	})
+
+	t.Run("retries_failed_watching_for_not_ok_to_reboot_annotation", func(t *testing.T) {
+		t.Parallel()
+
+		statusReceived := make(chan struct{})
+
+		testConfig, node, fakeClient := validTestConfig(t, okToRebootNode())
+		testConfig.WatchRetryBackoff = 100 * time.Millisecond
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(chan<- updateengine.Status, <-chan struct{}) {
+				close(statusReceived)
+			},
+		}
+
+		watchAttempts := int32(0)
+
+		fakeClient.PrependWatchReactor("nodes", func(action k8stesting.Action) (bool, watch.Interface, error) {
+			if atomic.AddInt32(&watchAttempts, 1) == 1 {
+				return true, nil, fmt.Errorf(t.Name())
+			}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		for atomic.LoadInt32(&watchAttempts) < 2 {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for watch to be retried")
+			case err := <-done:
+				t.Fatalf("Agent stopped unexpectedly: %v", err)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for agent to proceed after ok-to-reboot annotation is cleared")
+		case err := <-done:
+			t.Fatalf("Agent stopped unexpectedly: %v", err)
+		case <-statusReceived:
+		}
+	})
+
+	t.Run("emits_events_about_reboot_lifecycle_on_the_node", func(t *testing.T) {
+		t.Parallel()
+
+		rebootTriggered := make(chan bool, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggered <- auth
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case err := <-done:
+			t.Fatalf("Expected reboot, got agent running error: %v", err)
+		case <-rebootTriggered:
+		}
+
+		for _, reason := range []string{"RebootNeeded", "OkToReboot", "DrainStarted", "DrainFinished", "RebootRequested"} {
+			event := waitForEvent(ctx, t, testConfig.Clientset, node.Name, corev1.EventTypeNormal, reason)
+
+			// Used by "kubectl describe node" to find events about the node.
+			if event.InvolvedObject.UID != types.UID(node.Name) {
+				t.Fatalf("Expected event %q to have node name as UID, got %q", reason, event.InvolvedObject.UID)
+			}
+
+			if event.Source.Component != "flatcar-linux-update-agent" {
+				t.Fatalf("Unexpected source component of event %q: %q", reason, event.Source.Component)
+			}
+		}
+	})
+
+	t.Run("does_not_wait_for_not_ok_to_reboot_annotation_from_operator_when_configured", func(t *testing.T) {
+		t.Parallel()
+
+		statusReceived := make(chan struct{}, 1)
+
+		testConfig, _, _ := validTestConfig(t, okToRebootNode())
+		testConfig.SkipWaitForNotOkToReboot = true
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(chan<- updateengine.Status, <-chan struct{}) {
+				statusReceived <- struct{}{}
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for receive statuses call")
+		case err := <-done:
+			t.Fatalf("Unexpected agent running error: %v", err)
+		case <-statusReceived:
+		}
+	})
+
+	t.Run("marks_node_as_schedulable_if_agent_made_it_unschedulable", func(t *testing.T) {
+		t.Parallel()
+
+		expectNodeSchedulableUpdateMutex := &sync.Mutex{}
+		expectNodeSchedulableUpdate := false
+		nodeUpdatedAsSchedulable := make(chan bool, 1)
+
+		testConfig, node, fakeClient := validTestConfig(t, nodeMadeUnschedulable())
+
+		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			node := updateActionToNode(t, action)
+
+			if !node.Spec.Unschedulable {
+				expectNodeSchedulableUpdateMutex.Lock()
+				nodeUpdatedAsSchedulable <- expectNodeSchedulableUpdate
+				expectNodeSchedulableUpdateMutex.Unlock()
+			}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		// Ensure we enter wait loop before setting not ok to reboot.
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
+		})
+
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		expectNodeSchedulableUpdateMutex.Lock()
+		expectNodeSchedulableUpdate = true
+		expectNodeSchedulableUpdateMutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for node being marked as schedulable")
+		case expected := <-nodeUpdatedAsSchedulable:
+			if !expected {
+				t.Fatalf("Node was updated as schedulable before it was expected")
+			}
+		}
+	})
+
+	t.Run("leaves_node_unschedulable_if_it_was_made_unschedulable_by_external_source", func(t *testing.T) {
+		t.Parallel()
+
+		nodeUnschedulableByExternalSource := nodeMadeUnschedulable()
+		nodeUnschedulableByExternalSource.Annotations[constants.AnnotationAgentMadeUnschedulable] = constants.False
+
+		testConfig, node, fakeClient := validTestConfig(t, nodeUnschedulableByExternalSource)
+
+		watchStatusStarted := make(chan struct{})
+
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+				watchStatusStarted <- struct{}{}
+			},
+		}
+
+		nodeUnschedulableUpdate := make(chan struct{})
+
+		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			node := updateActionToNode(t, action)
+
+			if !node.Spec.Unschedulable {
+				nodeUnschedulableUpdate <- struct{}{}
+			}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		// Ensure we enter wait loop before setting not ok to reboot.
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
+		})
+
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for node being marked as schedulable")
+		case <-nodeUnschedulableUpdate:
+			t.Fatalf("Unexpected node update as schedulable")
+		case <-watchStatusStarted:
+		}
+	})
+
+	t.Run("with_manual_uncordon_configured_leaves_node_unschedulable_after_reboot_until_uncordoned_manually",
+		func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, node, fakeClient := validTestConfig(t, nodeMadeUnschedulable())
+			testConfig.ManualUncordon = true
+
+			watchStatusStarted := make(chan struct{})
+
+			testConfig.StatusReceiver = &mockStatusReceiver{
+				receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+					watchStatusStarted <- struct{}{}
+				},
+			}
+
+			nodeSchedulableUpdate := make(chan struct{})
+
+			fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if !updateActionToNode(t, action).Spec.Unschedulable {
+					nodeSchedulableUpdate <- struct{}{}
+				}
+
+				return false, nil, nil
+			})
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationAwaitingManualUncordon, constants.True),
+			})
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.False),
+			})
+
+			notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for agent to start watching update status")
+			case <-nodeSchedulableUpdate:
+				t.Fatalf("Unexpected node update as schedulable")
+			case <-watchStatusStarted:
+			}
+		})
+
+	t.Run("with_manual_uncordon_configured_hands_node_cordoned_by_operator_over_for_manual_uncordon_after_reboot",
+		func(t *testing.T) {
+			t.Parallel()
+
+			nodeUnschedulableByOperator := testNode()
+			nodeUnschedulableByOperator.Spec.Unschedulable = true
+			nodeUnschedulableByOperator.Annotations[constants.AnnotationOkToReboot] = constants.True
+			nodeUnschedulableByOperator.Annotations[constants.AnnotationRebootInProgress] = constants.True
+			nodeUnschedulableByOperator.Annotations[constants.AnnotationAgentMadeUnschedulable] = constants.False
+			nodeUnschedulableByOperator.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+
+			testConfig, _, _ := validTestConfig(t, nodeUnschedulableByOperator)
+			testConfig.ManualUncordon = true
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationAwaitingManualUncordon, constants.True),
+			})
+
+			// So operator does not make node schedulable once after-reboot checks pass.
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationOperatorMadeUnschedulable, constants.False),
+			})
+		})
+
+	t.Run("after_getting_not_ok_to_reboot_annotation", func(t *testing.T) {
+		t.Parallel()
+
+		// Each subtest runs its own agent, so agent does not time out while subtest waits for its turn.
+		runNotOkToRebootAgent := func(t *testing.T) (context.Context, *agent.Config, *corev1.Node, <-chan error) {
+			t.Helper()
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			return ctx, testConfig, node, done
+		}
+
+		t.Run("updates_node_information_when_update_enging_produces_updated_status", func(t *testing.T) {
+			t.Parallel()
+
+			ctx, testConfig, _, done := runNotOkToRebootAgent(t)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.True),
+			})
+		})
+
+		t.Run("waits_for_ok_to_reboot_annotation_from_operator", func(t *testing.T) {
+			t.Parallel()
+
+			ctx, testConfig, node, done := runNotOkToRebootAgent(t)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.True),
+			})
+		})
+	})
+
+	t.Run("keeps_waiting_for_ok_to_reboot_annotation_while_reboot_is_cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		cancelledNode := testNode()
+		cancelledNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+
+		testConfig, node, fakeClient := validTestConfig(t, cancelledNode)
+
+		nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		done := runAgent(ctx, t, testConfig)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-contextWithTimeout(t, time.Second).Done():
+		case <-nodeUpdatedAsUnschedulable:
+			t.Fatalf("Node has been marked as unschedulable while reboot is cancelled")
+		}
+
+		nc := testConfig.Clientset.CoreV1().Nodes()
+
+		updatedNode, err := nc.Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed getting node %q: %v", node.Name, err)
+		}
+
+		delete(updatedNode.Annotations, constants.AnnotationCancelReboot)
+
+		if _, err := nc.Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Failed updating node: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for node being marked as unschedulable")
+		case <-nodeUpdatedAsUnschedulable:
+		}
+	})
+
+	t.Run("retries_updating_node_status_from_update_engine_until_it_succeeds", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, fakeClient := validTestConfig(t, testNode())
+
+		firstCall := true
+
+		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			node := updateActionToNode(t, action)
+
+			if _, ok := node.Annotations[constants.AnnotationStatus]; ok {
+				if firstCall {
+					firstCall = false
+
+					return true, nil, fmt.Errorf(t.Name())
+				}
+			}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationStatus, updateengine.UpdateStatusUpdatedNeedReboot),
+		})
+	})
+
+	t.Run("skips_node_status_update_when_update_engine_status_did_not_change", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, node, fakeClient := validTestConfig(t, testNode())
+
+		status := updateengine.Status{
+			CurrentOperation: updateengine.UpdateStatusUpdatedNeedReboot,
+		}
+
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+				status.NewVersion = "foo"
+				ch <- status
+				status.NewVersion = "bar"
+				ch <- status
+			},
+		}
+
+		newVersionReported := make(chan string, 2)
+
+		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			node := updateActionToNode(t, action)
+
+			if _, ok := node.Annotations[constants.AnnotationStatus]; ok {
+				newVersionReported <- node.Annotations[constants.AnnotationNewVersion]
+			}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		runAgent(ctx, t, testConfig)
+
+		notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		lastNewVersion := ""
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for first node status update")
+		case newVersion := <-newVersionReported:
+			lastNewVersion = newVersion
+		}
+
+		select {
+		case newVersion := <-newVersionReported:
+			if newVersion != lastNewVersion {
+				t.Fatalf("Unexpected node status update")
+			}
+		case <-contextWithTimeout(t, 500*time.Millisecond).Done():
+		}
+	})
+
+	t.Run("exposes_update_engine_progress_and_current_operation_as_metrics", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, _, _ := validTestConfig(t, testNode())
+		registry := prometheus.NewRegistry()
+		testConfig.MetricsRegisterer = registry
+
+		testConfig.StatusReceiver = &mockStatusReceiver{
+			receiveStatusesF: func(ch chan<- updateengine.Status, _ <-chan struct{}) {
+				ch <- updateengine.Status{CurrentOperation: updateengine.UpdateStatusCheckingForUpdate}
+				ch <- updateengine.Status{CurrentOperation: updateengine.UpdateStatusDownloading, Progress: 0.3}
+				ch <- updateengine.Status{CurrentOperation: updateengine.UpdateStatusDownloading, Progress: 0.6}
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		runAgent(ctx, t, testConfig)
+
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			progress := gaugeValues(t, registry, "flatcar_linux_update_agent_update_progress")
+			status := gaugeValues(t, registry, "flatcar_linux_update_agent_update_status")
+
+			if progress[""] == 0.6 && len(status) == 1 && status[updateengine.UpdateStatusDownloading] == 1 {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for metrics to be updated, got progress %v and status %v", progress, status)
+			case <-ticker.C:
+			}
+		}
+	})
+
+	t.Run("after_getting_ok_to_reboot_annotation", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("marks_node_as_unschedulable_if_not_done_before_by", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, node, fakeClient := validTestConfig(t, testNode())
+
+			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
 			t.Run("setting_unschedulable_field_on_Node_object", func(t *testing.T) {
 				t.Parallel()
 
 				select {
-				case <-ctx.Done():
-					t.Fatal("Timed out waiting for node being marked as unschedulable")
-				case <-nodeUpdatedAsUnschedulable:
+				case <-ctx.Done():
+					t.Fatal("Timed out waiting for node being marked as unschedulable")
+				case <-nodeUpdatedAsUnschedulable:
+				}
+			})
+
+			t.Run("setting_reboot_in_progress_annotation_to_true", func(t *testing.T) {
+				t.Parallel()
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   done,
+					config: testConfig,
+					testF:  assertNodeAnnotationValue(constants.AnnotationRebootInProgress, constants.True),
+				})
+			})
+
+			t.Run("setting_agent_made_unschedulable_annotations_to_true", func(t *testing.T) {
+				t.Parallel()
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   done,
+					config: testConfig,
+					testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.True),
+				})
+			})
+		})
+	})
+
+	t.Run("skips_marking_node_as_unschedulable_if_node_is_already_unschedulable", func(t *testing.T) {
+		t.Parallel()
+
+		nodeUpdatedAsUnschedulable := make(chan struct{})
+		podsListed := make(chan struct{})
+
+		nodeUnschedulable := testNode()
+		nodeUnschedulable.Spec.Unschedulable = true
+
+		testConfig, _, fakeClient := validTestConfig(t, nodeUnschedulable)
+
+		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			node := updateActionToNode(t, action)
+
+			if _, ok := node.Annotations[constants.AnnotationAgentMadeUnschedulable]; ok {
+				nodeUpdatedAsUnschedulable <- struct{}{}
+			}
+
+			return false, nil, nil
+		})
+
+		fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			podsListed <- struct{}{}
+
+			return false, nil, nil
+		})
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), nodeUnschedulable.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for pods listing operation")
+		case <-nodeUpdatedAsUnschedulable:
+			t.Fatalf("Expected node to not be marked as unschedulable")
+		case <-podsListed:
+		}
+	})
+
+	t.Run("records_that_node_was_not_made_unschedulable_by_agent_if_operator_made_it_unschedulable",
+		func(t *testing.T) {
+			t.Parallel()
+
+			nodeUnschedulableByOperator := testNode()
+			nodeUnschedulableByOperator.Spec.Unschedulable = true
+			nodeUnschedulableByOperator.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+
+			testConfig, node, _ := validTestConfig(t, nodeUnschedulableByOperator)
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.False),
+			})
+		})
+
+	t.Run("after_marking_node_as_unschedulable", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("without_exceeding_pod_deletion_grace_period", func(t *testing.T) {
+			t.Parallel()
+
+			expectedPodsRemovedNames := map[string]struct{}{"pod-to-be-removed": {}}
+			podsToCreate := []*corev1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "pod-to-be-removed",
+						Namespace:       "default",
+						OwnerReferences: testPodControllerReference(),
+					},
+					Spec: corev1.PodSpec{
+						NodeName: testNode().Name,
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "pod-filtered-from-removal-because-of-namespace",
+						Namespace:       "kube-system",
+						OwnerReferences: testPodControllerReference(),
+					},
+					Spec: corev1.PodSpec{
+						NodeName: testNode().Name,
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "pod-on-another-node",
+						Namespace:       "another",
+						OwnerReferences: testPodControllerReference(),
+					},
+					Spec: corev1.PodSpec{
+						NodeName: "baz",
+					},
+				},
+			}
+
+			fakeClient := fake.NewSimpleClientset(podsToCreate[0], podsToCreate[1], podsToCreate[2], testNode())
+			addEvictionSupport(t, fakeClient)
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.Clientset = fakeClient
+			testConfig.PodDeletionGracePeriod = time.Hour
+
+			expectedPodRemovedMutex := &sync.Mutex{}
+			expectedPodRemoved := len(expectedPodsRemovedNames)
+			rebootTriggerred := make(chan bool, 1)
+
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(auth bool) {
+					expectedPodRemovedMutex.Lock()
+					rebootTriggerred <- expectedPodRemoved < 0
+					expectedPodRemovedMutex.Unlock()
+				},
+			}
+
+			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, &fakeClient.Fake)
+
+			fakeClient.PrependReactor("list", "pods", listPodsWithFieldSelector(podsToCreate))
+
+			allExpectedPodsScheduledForRemoval := make(chan struct{}, 2)
+
+			testPodRemoval := func(podName string) bool {
+				t.Helper()
+
+				if _, ok := expectedPodsRemovedNames[podName]; !ok {
+					t.Fatalf("Unexpected pod %q removed", podName)
+				}
+
+				expectedPodRemovedMutex.Lock()
+				defer expectedPodRemovedMutex.Unlock()
+				expectedPodRemoved--
+
+				if expectedPodRemoved == 0 {
+					allExpectedPodsScheduledForRemoval <- struct{}{}
+					allExpectedPodsScheduledForRemoval <- struct{}{}
+				}
+
+				return expectedPodRemoved >= 0
+			}
+
+			fakeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				deleteAction, ok := action.(k8stesting.DeleteActionImpl)
+				if !ok {
+					del := k8stesting.DeleteActionImpl{}
+
+					return true, nil, fmt.Errorf("unexpected action, expected %T, got %T", del, action)
+				}
+
+				return testPodRemoval(deleteAction.Name), nil, nil
+			})
+
+			fakeClient.PrependReactor("create", "pods/eviction",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					createAction, ok := action.(k8stesting.CreateActionImpl)
+					if !ok {
+						del := k8stesting.CreateActionImpl{}
+
+						return true, nil, fmt.Errorf("unexpected action, expected %T, got %T", del, action)
+					}
+
+					eviction, ok := createAction.Object.(*policyv1.Eviction)
+					if !ok {
+						expectedEviction := &policyv1.Eviction{}
+
+						return true, nil, fmt.Errorf("unexpected eviction type, expected %T, got %T", expectedEviction, eviction)
+					}
+
+					return testPodRemoval(eviction.Name), nil, nil
+				})
+
+			podGetRequest := make(chan struct{}, 1)
+
+			fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				podGetRequest <- struct{}{}
+
+				return false, nil, nil
+			})
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for node being marked as unschedulable")
+			case <-nodeUpdatedAsUnschedulable:
+			}
+
+			t.Run("remove_pods_scheduled_on_running_node_without_pods_from_kube-system_namespace", func(t *testing.T) {
+				t.Parallel()
+
+				select {
+				case <-ctx.Done():
+					t.Fatal("Timed out waiting for expected number of pods to be removed")
+				case <-allExpectedPodsScheduledForRemoval:
+				}
+			})
+
+			t.Run("waits_for_removed_pods_to_terminate_before_rebooting", func(t *testing.T) {
+				select {
+				case <-ctx.Done():
+					t.Fatalf("Timed out waiting for all pods to be scheduled for removal")
+				case <-allExpectedPodsScheduledForRemoval:
+				}
+
+				select {
+				case <-ctx.Done():
+					t.Fatalf("Timed out waiting pod GET request")
+				case <-podGetRequest:
+				}
+
+				client := testConfig.Clientset.CoreV1().Pods(podsToCreate[0].Namespace)
+
+				if err := client.Delete(ctx, podsToCreate[0].Name, metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("Failed removing test pod: %v", err)
+				}
+
+				select {
+				case <-ctx.Done():
+					t.Fatalf("Timed out waiting for reboot to be triggered")
+				case expected := <-rebootTriggerred:
+					if !expected {
+						t.Fatalf("Got unexpected reboot call")
+					}
+				}
+			})
+		})
+
+		t.Run("ignores_pods_which_has_not_terminated_when_pod_deletion_grace_period_is_reached", func(t *testing.T) {
+			t.Parallel()
+
+			rebootTriggerred := make(chan bool)
+
+			podsToCreate := []*corev1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "foo",
+						Namespace:       "default",
+						OwnerReferences: testPodControllerReference(),
+					},
+					Spec: corev1.PodSpec{
+						NodeName: testNode().Name,
+					},
+				},
+			}
+
+			fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
+			addEvictionSupport(t, fakeClient)
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.Clientset = fakeClient
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(auth bool) {
+					rebootTriggerred <- auth
+				},
+			}
+
+			// We only handle the creating of the eviction and not the delete which will block and cause the timeout
+			// condition we're looking for.
+			fakeClient.PrependReactor("create", "pods/eviction", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for reboot to be triggered")
+			case <-rebootTriggerred:
+			}
+		})
+	})
+
+	t.Run("deletes_pods_when_eviction_API_is_not_available", func(t *testing.T) {
+		t.Parallel()
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "foo",
+				Namespace:       "default",
+				OwnerReferences: testPodControllerReference(),
+			},
+			Spec: corev1.PodSpec{
+				NodeName: testNode().Name,
+			},
+		}
+
+		fakeClient := fake.NewSimpleClientset(pod, testNode())
+		// Core API without eviction subresource.
+		fakeClient.Resources = append(fakeClient.Resources, &metav1.APIResourceList{
+			GroupVersion: "v1",
+		})
+
+		podDeleted := make(chan struct{}, 1)
+
+		fakeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if len(podDeleted) == 0 {
+				podDeleted <- struct{}{}
+			}
+
+			return false, nil, nil
+		})
+
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() == "eviction" {
+				t.Errorf("Unexpected pod eviction")
+			}
+
+			return false, nil, nil
+		})
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for pod to be deleted")
+		case <-podDeleted:
+		}
+	})
+
+	t.Run("deletes_pods_when_configured_to_ignore_pod_disruption_budgets", func(t *testing.T) {
+		t.Parallel()
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "foo",
+				Namespace:       "default",
+				OwnerReferences: testPodControllerReference(),
+			},
+			Spec: corev1.PodSpec{
+				NodeName: testNode().Name,
+			},
+		}
+
+		fakeClient := fake.NewSimpleClientset(pod, testNode())
+		addEvictionSupport(t, fakeClient)
+
+		podDeleted := make(chan struct{}, 1)
+
+		fakeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if len(podDeleted) == 0 {
+				podDeleted <- struct{}{}
+			}
+
+			return false, nil, nil
+		})
+
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() == "eviction" {
+				t.Errorf("Unexpected pod eviction")
+			}
+
+			return false, nil, nil
+		})
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.IgnorePodDisruptionBudgets = true
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for pod to be deleted")
+		case <-podDeleted:
+		}
+	})
+
+	t.Run("evicts_pods_with_termination_grace_period", func(t *testing.T) {
+		t.Parallel()
+
+		override := 15 * time.Second
+
+		for name, testCase := range map[string]struct {
+			override            *time.Duration
+			expectedGracePeriod *int64
+		}{
+			"of_the_pod_by_default": {},
+			"overridden_when_configured": {
+				override:            &override,
+				expectedGracePeriod: pointer.Int64(15),
+			},
+		} {
+			testCase := testCase
+
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "foo",
+						Namespace:       "default",
+						OwnerReferences: testPodControllerReference(),
+					},
+					Spec: corev1.PodSpec{
+						NodeName: testNode().Name,
+					},
+				}
+
+				fakeClient := fake.NewSimpleClientset(pod, testNode())
+				addEvictionSupport(t, fakeClient)
+
+				evictions := make(chan *policyv1.Eviction, 1)
+
+				fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					createAction, ok := action.(k8stesting.CreateAction)
+					if !ok || action.GetSubresource() != "eviction" {
+						return false, nil, nil
+					}
+
+					if eviction, ok := createAction.GetObject().(*policyv1.Eviction); ok && len(evictions) == 0 {
+						evictions <- eviction
+					}
+
+					return false, nil, nil
+				})
+
+				testConfig, node, _ := validTestConfig(t, testNode())
+				testConfig.Clientset = fakeClient
+				testConfig.PodTerminationGracePeriodOverride = testCase.override
+
+				ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   runAgent(ctx, t, testConfig),
+					config: testConfig,
+					testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+				})
+
+				okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+				select {
+				case <-ctx.Done():
+					t.Fatal("Timed out waiting for pod to be evicted")
+				case eviction := <-evictions:
+					expected, got := "unset", "unset"
+
+					if testCase.expectedGracePeriod != nil {
+						expected = fmt.Sprint(*testCase.expectedGracePeriod)
+					}
+
+					if eviction.DeleteOptions != nil && eviction.DeleteOptions.GracePeriodSeconds != nil {
+						got = fmt.Sprint(*eviction.DeleteOptions.GracePeriodSeconds)
+					}
+
+					if expected != got {
+						t.Fatalf("Expected eviction grace period %s, got %s", expected, got)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("emits_warning_event_when_eviction_is_blocked_by_pod_disruption_budget_after_grace_period", func(t *testing.T) {
+		t.Parallel()
+
+		testConfig, node, fakeClient, _ := stuckPodTestConfig(t)
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		pod, err := fakeClient.CoreV1().Pods("default").Get(ctx, "foo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Getting pod: %v", err)
+		}
+
+		pod.Labels = map[string]string{"app": "foo"}
+
+		if _, err := fakeClient.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Updating pod: %v", err)
+		}
+
+		pdb := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: pod.Namespace,
+			},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: pod.Labels},
+			},
+		}
+
+		if _, err := fakeClient.PolicyV1().PodDisruptionBudgets(pod.Namespace).Create(
+			ctx, pdb, metav1.CreateOptions{},
+		); err != nil {
+			t.Fatalf("Creating PodDisruptionBudget: %v", err)
+		}
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		waitForWarningEvent(ctx, t, fakeClient, node.Name, "DrainBlockedByPodDisruptionBudget")
+	})
+
+	t.Run("removes_pods_of_DaemonSets_configured_to_be_drained", func(t *testing.T) {
+		t.Parallel()
+
+		daemonSets := []*appsv1.DaemonSet{
+			{ObjectMeta: metav1.ObjectMeta{Name: "storage-plugin", Namespace: "storage"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Namespace: "default"}},
+		}
+
+		podsToCreate := []*corev1.Pod{}
+
+		for _, daemonSet := range daemonSets {
+			podsToCreate = append(podsToCreate, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      daemonSet.Name + "-pod",
+					Namespace: daemonSet.Namespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							Kind:       "DaemonSet",
+							Name:       daemonSet.Name,
+							Controller: pointer.Bool(true),
+						},
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			})
+		}
+
+		fakeClient := fake.NewSimpleClientset(daemonSets[0], daemonSets[1], podsToCreate[0], podsToCreate[1], testNode())
+		addEvictionSupport(t, fakeClient)
+
+		fakeClient.PrependReactor("list", "pods", listPodsWithFieldSelector(podsToCreate))
+
+		podsRemoved := make(chan string, len(podsToCreate))
+
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+
+			if createAction, ok := action.(k8stesting.CreateActionImpl); ok {
+				if eviction, ok := createAction.Object.(*policyv1.Eviction); ok {
+					podsRemoved <- eviction.Name
+				}
+			}
+
+			return true, nil, nil
+		})
+
+		rebootTriggerred := make(chan struct{}, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.DrainedDaemonSets = []string{"storage/storage-plugin"}
+		// Evicted pods never terminate, so do not wait for them longer than necessary.
+		testConfig.PodDeletionGracePeriod = 500 * time.Millisecond
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(bool) {
+				rebootTriggerred <- struct{}{}
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+
+		close(podsRemoved)
+
+		removedPods := []string{}
+		for podName := range podsRemoved {
+			removedPods = append(removedPods, podName)
+		}
+
+		if len(removedPods) != 1 || removedPods[0] != podsToCreate[0].Name {
+			t.Fatalf("Expected only pod %q to be removed, got %v", podsToCreate[0].Name, removedPods)
+		}
+	})
+
+	t.Run("removes_pods_from_all_namespaces_except_configured_protected_namespaces", func(t *testing.T) {
+		t.Parallel()
+
+		podsToCreate := []*corev1.Pod{}
+
+		for _, namespace := range []string{"kube-system", "platform"} {
+			podsToCreate = append(podsToCreate, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            namespace + "-pod",
+					Namespace:       namespace,
+					OwnerReferences: testPodControllerReference(),
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			})
+		}
+
+		fakeClient := fake.NewSimpleClientset(podsToCreate[0], podsToCreate[1], testNode())
+		addEvictionSupport(t, fakeClient)
+
+		fakeClient.PrependReactor("list", "pods", listPodsWithFieldSelector(podsToCreate))
+
+		podsRemoved := make(chan string, len(podsToCreate))
+
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+
+			if createAction, ok := action.(k8stesting.CreateActionImpl); ok {
+				if eviction, ok := createAction.Object.(*policyv1.Eviction); ok {
+					podsRemoved <- eviction.Name
+				}
+			}
+
+			return true, nil, nil
+		})
+
+		rebootTriggerred := make(chan struct{}, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.ProtectedNamespaces = []string{"platform"}
+		// Evicted pods never terminate, so do not wait for them longer than necessary.
+		testConfig.PodDeletionGracePeriod = 500 * time.Millisecond
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(bool) {
+				rebootTriggerred <- struct{}{}
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+
+		close(podsRemoved)
+
+		removedPods := []string{}
+		for podName := range podsRemoved {
+			removedPods = append(removedPods, podName)
+		}
+
+		if len(removedPods) != 1 || removedPods[0] != podsToCreate[0].Name {
+			t.Fatalf("Expected only pod %q to be removed, got %v", podsToCreate[0].Name, removedPods)
+		}
+	})
+
+	t.Run("removes_pod_without_owner_when_force_drain_is_configured", func(t *testing.T) {
+		t.Parallel()
+
+		rebootTriggerred := make(chan bool)
+
+		podsToCreate := []*corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			},
+		}
+
+		fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
+		addEvictionSupport(t, fakeClient)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.ForceNodeDrain = true
+		testConfig.Clientset = fakeClient
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggerred <- auth
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+	})
+
+	t.Run("records_removed_pods_on_node_before_rebooting", func(t *testing.T) {
+		t.Parallel()
+
+		podsToCreate := []*corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "foo",
+					Namespace:       "default",
+					OwnerReferences: testPodControllerReference(),
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			},
+		}
+
+		fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
+
+		rebootTriggerred := make(chan bool, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggerred <- auth
+			},
+		}
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+
+		updatedNode, err := fakeClient.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed getting node %q: %v", node.Name, err)
+		}
+
+		expected := "default/foo"
+
+		if value := updatedNode.Annotations[constants.AnnotationEvictedPods]; value != expected {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationEvictedPods, expected, value)
+		}
+	})
+
+	t.Run("writes_lifecycle_decisions_as_JSON_objects_to_configured_output", func(t *testing.T) {
+		t.Parallel()
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "foo",
+				Namespace:       "default",
+				OwnerReferences: testPodControllerReference(),
+			},
+			Spec: corev1.PodSpec{
+				NodeName: testNode().Name,
+			},
+		}
+
+		output := &syncBuffer{}
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fake.NewSimpleClientset(pod, testNode())
+		testConfig.DecisionsOutput = output
+
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		expectedDecisions := []string{"status_changed", "cordoned", "drain_started", "drain_finished", "reboot_requested"}
+
+		// Other decisions, like further status changes, may be interleaved, so expected decisions are
+		// looked for in order.
+		for found, drainStarted := 0, map[string]interface{}(nil); found < len(expectedDecisions); {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for decision %q, got: %s", expectedDecisions[found], output.String())
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			found = 0
+
+			for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+				decision := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(line), &decision); err != nil {
+					continue
+				}
+
+				if decision["node"] != node.Name || decision["time"] == "" {
+					t.Fatalf("Expected decision to include node name and time, got %v", decision)
+				}
+
+				if found < len(expectedDecisions) && decision["decision"] == expectedDecisions[found] {
+					found++
+				}
+
+				if decision["decision"] == "drain_started" {
+					drainStarted = decision
+				}
+			}
+
+			if found == len(expectedDecisions) && drainStarted["pods"] != float64(1) {
+				t.Fatalf("Expected drain started decision to include number of pods, got %v", drainStarted)
+			}
+		}
+	})
+
+	t.Run("when_configured_to_watch_pod_termination", func(t *testing.T) {
+		t.Parallel()
+
+		newWatchingTestConfig := func(t *testing.T) (*agent.Config, *fake.Clientset, chan bool) {
+			t.Helper()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "foo",
+					Namespace:       "default",
+					OwnerReferences: testPodControllerReference(),
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			}
+
+			fakeClient := fake.NewSimpleClientset(pod, testNode())
+			addEvictionSupport(t, fakeClient)
+
+			rebootTriggerred := make(chan bool, 1)
+
+			testConfig, _, _ := validTestConfig(t, testNode())
+			testConfig.Clientset = fakeClient
+			testConfig.PodDeletionGracePeriod = time.Hour
+			testConfig.WatchPodTermination = true
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(auth bool) {
+					rebootTriggerred <- auth
+				},
+			}
+
+			return testConfig, fakeClient, rebootTriggerred
+		}
+
+		t.Run("waits_for_removed_pods_to_terminate_before_rebooting_without_polling_them", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, fakeClient, rebootTriggerred := newWatchingTestConfig(t)
+
+			podsWatched := make(chan struct{}, 1)
+
+			fakeClient.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+				select {
+				case podsWatched <- struct{}{}:
+				default:
+				}
+
+				return false, nil, nil
+			})
+
+			podGetRequest := make(chan struct{}, 1)
+
+			fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				select {
+				case podGetRequest <- struct{}{}:
+				default:
+				}
+
+				return false, nil, nil
+			})
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), testConfig.NodeName)
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for pods to be watched")
+			case <-podsWatched:
+			}
+
+			select {
+			case <-rebootTriggerred:
+				t.Fatalf("Reboot triggered before removed pod terminated")
+			default:
+			}
+
+			if err := fakeClient.CoreV1().Pods("default").Delete(ctx, "foo", metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("Failed removing test pod: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for reboot to be triggered")
+			case <-rebootTriggerred:
+			}
+
+			select {
+			case <-podGetRequest:
+				t.Fatalf("Expected removed pods to not be polled")
+			default:
+			}
+		})
+
+		t.Run("falls_back_to_polling_removed_pods_when_watching_them_fails", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, fakeClient, rebootTriggerred := newWatchingTestConfig(t)
+
+			fakeClient.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+				return true, nil, fmt.Errorf("watch not supported")
+			})
+
+			podGetRequest := make(chan struct{}, 1)
+
+			fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				select {
+				case podGetRequest <- struct{}{}:
+				default:
 				}
+
+				return false, nil, nil
 			})
 
-			t.Run("setting_reboot_in_progress_annotation_to_true", func(t *testing.T) {
-				t.Parallel()
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-				assertNodeProperty(ctx, t, &assertNodePropertyContext{
-					done:   done,
-					config: testConfig,
-					testF:  assertNodeAnnotationValue(constants.AnnotationRebootInProgress, constants.True),
-				})
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
 			})
 
-			t.Run("setting_agent_made_unschedulable_annotations_to_true", func(t *testing.T) {
-				t.Parallel()
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), testConfig.NodeName)
 
-				assertNodeProperty(ctx, t, &assertNodePropertyContext{
-					done:   done,
-					config: testConfig,
-					testF:  assertNodeAnnotationValue(constants.AnnotationAgentMadeUnschedulable, constants.True),
-				})
-			})
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for pod GET request")
+			case <-podGetRequest:
+			}
+
+			if err := fakeClient.CoreV1().Pods("default").Delete(ctx, "foo", metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("Failed removing test pod: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for reboot to be triggered")
+			case <-rebootTriggerred:
+			}
 		})
 	})
 
-	t.Run("skips_marking_node_as_unschedulable_if_node_is_already_unschedulable", func(t *testing.T) {
+	t.Run("removes_pods_not_faster_than_configured_max_pod_eviction_rate", func(t *testing.T) {
 		t.Parallel()
 
-		nodeUpdatedAsUnschedulable := make(chan struct{})
-		podsListed := make(chan struct{})
+		podsToCreate := []runtime.Object{testNode()}
 
-		nodeUnschedulable := testNode()
-		nodeUnschedulable.Spec.Unschedulable = true
+		for _, name := range []string{"foo", "bar", "baz"} {
+			podsToCreate = append(podsToCreate, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
+				},
+			})
+		}
 
-		testConfig, _, fakeClient := validTestConfig(t, nodeUnschedulable)
+		fakeClient := fake.NewSimpleClientset(podsToCreate...)
+		addEvictionSupport(t, fakeClient)
 
-		fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			node := updateActionToNode(t, action)
+		evictionTimes := make(chan time.Time, len(podsToCreate))
 
-			if _, ok := node.Annotations[constants.AnnotationAgentMadeUnschedulable]; ok {
-				nodeUpdatedAsUnschedulable <- struct{}{}
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() == "eviction" {
+				evictionTimes <- time.Now()
 			}
 
 			return false, nil, nil
 		})
 
-		fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
-			podsListed <- struct{}{}
+		rebootTriggerred := make(chan struct{}, 1)
 
-			return false, nil, nil
-		})
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.ForceNodeDrain = true
+		testConfig.MaxPodEvictionRate = 10
+		// Evicted pods never terminate, so do not wait for them longer than necessary.
+		testConfig.PodDeletionGracePeriod = 500 * time.Millisecond
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(bool) {
+				rebootTriggerred <- struct{}{}
+			},
+		}
 
 		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
@@ -689,138 +2738,275 @@ func Test_Running_agent(t *testing.T) {
 			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
 		})
 
-		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), nodeUnschedulable.Name)
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
 		select {
 		case <-ctx.Done():
-			t.Fatalf("Timed out waiting for pods listing operation")
-		case <-nodeUpdatedAsUnschedulable:
-			t.Fatalf("Expected node to not be marked as unschedulable")
-		case <-podsListed:
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+
+		close(evictionTimes)
+
+		times := []time.Time{}
+		for evictionTime := range evictionTimes {
+			times = append(times, evictionTime)
+		}
+
+		if len(times) != len(podsToCreate)-1 {
+			t.Fatalf("Expected %d pods to be evicted, got %d", len(podsToCreate)-1, len(times))
+		}
+
+		// With 10 pods per second, 3 evictions should take at least 200ms.
+		minimumDuration := 180 * time.Millisecond
+
+		if d := times[len(times)-1].Sub(times[0]); d < minimumDuration {
+			t.Fatalf("Expected evictions to take at least %v, took %v", minimumDuration, d)
 		}
 	})
 
-	t.Run("after_marking_node_as_unschedulable", func(t *testing.T) {
+	t.Run("removes_stateful_pods_after_other_pods_terminate_when_configured", func(t *testing.T) {
 		t.Parallel()
 
-		t.Run("without_exceeding_pod_deletion_grace_period", func(t *testing.T) {
-			t.Parallel()
-
-			expectedPodsRemovedNames := map[string]struct{}{"pod-to-be-removed": {}}
-			podsToCreate := []*corev1.Pod{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:            "pod-to-be-removed",
-						Namespace:       "default",
-						OwnerReferences: testPodControllerReference(),
-					},
-					Spec: corev1.PodSpec{
-						NodeName: testNode().Name,
-					},
-				},
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:            "pod-filtered-from-removal-because-of-namespace",
-						Namespace:       "kube-system",
-						OwnerReferences: testPodControllerReference(),
-					},
-					Spec: corev1.PodSpec{
-						NodeName: testNode().Name,
+		ownedPod := func(name, ownerKind string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							Kind:       ownerKind,
+							Name:       name,
+							Controller: pointer.Bool(true),
+						},
 					},
 				},
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:            "pod-on-another-node",
-						Namespace:       "another",
-						OwnerReferences: testPodControllerReference(),
-					},
-					Spec: corev1.PodSpec{
-						NodeName: "baz",
-					},
+				Spec: corev1.PodSpec{
+					NodeName: testNode().Name,
 				},
 			}
+		}
+
+		// Stateful pod is listed last, as drain helper evicts pods in parallel, so the last pod is likely
+		// evicted first. This ensures expected order is not observed by accident.
+		fakeClient := fake.NewSimpleClientset(testNode(), ownedPod("stateless", "ReplicaSet"),
+			ownedPod("zz-stateful", "StatefulSet"))
+		addEvictionSupport(t, fakeClient)
+
+		podsGVR := corev1.SchemeGroupVersion.WithResource("pods")
+
+		evictedPods := make(chan string, 2)
+		statelessPodRunning := make(chan bool, 1)
+
+		fakeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
 
-			fakeClient := fake.NewSimpleClientset(podsToCreate[0], podsToCreate[1], podsToCreate[2], testNode())
-			addEvictionSupport(t, fakeClient)
+			eviction, ok := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+			if !ok {
+				t.Errorf("Unexpected eviction object: %v", action)
 
-			testConfig, node, _ := validTestConfig(t, testNode())
-			testConfig.Clientset = fakeClient
-			testConfig.PodDeletionGracePeriod = time.Hour
+				return false, nil, nil
+			}
 
-			expectedPodRemovedMutex := &sync.Mutex{}
-			expectedPodRemoved := len(expectedPodsRemovedNames)
-			rebootTriggerred := make(chan bool, 1)
+			if eviction.Name == "zz-stateful" {
+				_, err := fakeClient.Tracker().Get(podsGVR, eviction.Namespace, "stateless")
+				statelessPodRunning <- err == nil
+			}
 
-			testConfig.Rebooter = &mockRebooter{
-				rebootF: func(auth bool) {
-					expectedPodRemovedMutex.Lock()
-					rebootTriggerred <- expectedPodRemoved < 0
-					expectedPodRemovedMutex.Unlock()
-				},
+			evictedPods <- eviction.Name
+
+			// Evicted pods terminate immediately.
+			if err := fakeClient.Tracker().Delete(podsGVR, eviction.Namespace, eviction.Name); err != nil {
+				t.Errorf("Deleting evicted pod %q: %v", eviction.Name, err)
 			}
 
-			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, &fakeClient.Fake)
+			return false, nil, nil
+		})
 
-			fakeClient.PrependReactor("list", "pods", listPodsWithFieldSelector(podsToCreate))
+		rebootTriggerred := make(chan struct{}, 1)
 
-			allExpectedPodsScheduledForRemoval := make(chan struct{}, 2)
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.Clientset = fakeClient
+		testConfig.EvictStatefulPodsLast = true
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(bool) {
+				rebootTriggerred <- struct{}{}
+			},
+		}
 
-			testPodRemoval := func(podName string) bool {
-				t.Helper()
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case <-rebootTriggerred:
+		}
+
+		close(evictedPods)
+
+		order := []string{}
+		for name := range evictedPods {
+			order = append(order, name)
+		}
+
+		if len(order) != 2 || order[0] != "stateless" || order[1] != "zz-stateful" {
+			t.Fatalf("Expected stateless pod to be evicted before stateful pod, got order: %v", order)
+		}
+
+		if <-statelessPodRunning {
+			t.Fatalf("Stateful pod has been evicted before stateless pod terminated")
+		}
+	})
+
+	t.Run("after_draining_node", func(t *testing.T) {
+		t.Parallel()
+
+		rebootTriggerred := make(chan bool, 1)
+
+		ctx, cancel := context.WithCancel(contextWithTimeout(t, agentRunTimeLimit))
+
+		testConfig, node, fakeClient := validTestConfig(t, testNode())
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggerred <- auth
+				cancel()
+			},
+		}
+
+		nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+
+		done := runAgent(ctx, t, testConfig)
+
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   done,
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
+
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for node being marked as unschedulable")
+		case <-nodeUpdatedAsUnschedulable:
+		}
+
+		t.Run("triggers_a_reboot_without_interactive_authentication", func(t *testing.T) {
+			t.Parallel()
+
+			select {
+			case <-contextWithTimeout(t, 5*time.Second).Done():
+				t.Fatalf("Timed out waiting for reboot to be triggered")
+			case interactiveAuthRequested := <-rebootTriggerred:
+				if interactiveAuthRequested {
+					t.Fatalf("Got reboot call with interactive auth requested")
+				}
+			}
+		})
+
+		t.Run("waits_until_termination_signal_comes", func(t *testing.T) {
+			t.Parallel()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Got agent running error: %v", err)
+				}
+			case <-contextWithTimeout(t, 30*time.Second).Done():
+				t.Fatalf("Timed out waiting for agent to exit gracefully after receiving exit signal")
+			}
+		})
+	})
+
+	t.Run("triggers_a_reboot_with_interactive_authentication_when_configured", func(t *testing.T) {
+		t.Parallel()
+
+		rebootTriggerred := make(chan bool, 1)
+
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.RebootInteractiveAuth = true
+		testConfig.Rebooter = &mockRebooter{
+			rebootF: func(auth bool) {
+				rebootTriggerred <- auth
+			},
+		}
 
-				if _, ok := expectedPodsRemovedNames[podName]; !ok {
-					t.Fatalf("Unexpected pod %q removed", podName)
-				}
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-				expectedPodRemovedMutex.Lock()
-				defer expectedPodRemovedMutex.Unlock()
-				expectedPodRemoved--
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
 
-				if expectedPodRemoved == 0 {
-					allExpectedPodsScheduledForRemoval <- struct{}{}
-					allExpectedPodsScheduledForRemoval <- struct{}{}
-				}
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
-				return expectedPodRemoved >= 0
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for reboot to be triggered")
+		case interactiveAuthRequested := <-rebootTriggerred:
+			if !interactiveAuthRequested {
+				t.Fatalf("Got reboot call without interactive auth requested")
 			}
+		}
+	})
 
-			fakeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
-				deleteAction, ok := action.(k8stesting.DeleteActionImpl)
-				if !ok {
-					del := k8stesting.DeleteActionImpl{}
+	t.Run("powers_off_the_host_instead_of_rebooting_when_configured", func(t *testing.T) {
+		t.Parallel()
 
-					return true, nil, fmt.Errorf("unexpected action, expected %T, got %T", del, action)
-				}
+		powerOffTriggerred := make(chan struct{}, 1)
 
-				return testPodRemoval(deleteAction.Name), nil, nil
-			})
+		testConfig, node, _ := validTestConfig(t, testNode())
+		testConfig.RebootAction = agent.RebootActionPowerOff
+		testConfig.Rebooter = &mockPowerOffer{
+			mockRebooter: mockRebooter{
+				rebootF: func(bool) {
+					t.Errorf("Unexpected reboot")
+				},
+			},
+			powerOffF: func(bool) {
+				powerOffTriggerred <- struct{}{}
+			},
+		}
 
-			fakeClient.PrependReactor("create", "pods/eviction",
-				func(action k8stesting.Action) (bool, runtime.Object, error) {
-					createAction, ok := action.(k8stesting.CreateActionImpl)
-					if !ok {
-						del := k8stesting.CreateActionImpl{}
+		ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-						return true, nil, fmt.Errorf("unexpected action, expected %T, got %T", del, action)
-					}
+		assertNodeProperty(ctx, t, &assertNodePropertyContext{
+			done:   runAgent(ctx, t, testConfig),
+			config: testConfig,
+			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+		})
 
-					eviction, ok := createAction.Object.(*policyv1.Eviction)
-					if !ok {
-						expectedEviction := &policyv1.Eviction{}
+		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
-						return true, nil, fmt.Errorf("unexpected eviction type, expected %T, got %T", expectedEviction, eviction)
-					}
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for power off to be triggered")
+		case <-powerOffTriggerred:
+		}
+	})
 
-					return testPodRemoval(eviction.Name), nil, nil
-				})
+	t.Run("when_local_reboot_window_is_configured", func(t *testing.T) {
+		t.Parallel()
 
-			podGetRequest := make(chan struct{}, 1)
+		t.Run("does_not_drain_node_while_outside_of_reboot_window", func(t *testing.T) {
+			t.Parallel()
 
-			fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
-				podGetRequest <- struct{}{}
+			testConfig, node, fakeClient := validTestConfig(t, testNode())
+			testConfig.RebootWindowStart = time.Now().Add(2 * time.Hour).Format("15:04")
+			testConfig.RebootWindowLength = "1h"
 
-				return false, nil, nil
-			})
+			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
 
 			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
@@ -833,84 +3019,80 @@ func Test_Running_agent(t *testing.T) {
 			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
 			select {
-			case <-ctx.Done():
-				t.Fatal("Timed out waiting for node being marked as unschedulable")
+			case <-contextWithTimeout(t, time.Second).Done():
 			case <-nodeUpdatedAsUnschedulable:
+				t.Fatalf("Node has been marked as unschedulable outside of reboot window")
 			}
+		})
 
-			t.Run("remove_pods_scheduled_on_running_node_without_pods_from_kube-system_namespace", func(t *testing.T) {
-				t.Parallel()
+		t.Run("triggers_a_reboot_inside_of_reboot_window", func(t *testing.T) {
+			t.Parallel()
 
-				select {
-				case <-ctx.Done():
-					t.Fatal("Timed out waiting for expected number of pods to be removed")
-				case <-allExpectedPodsScheduledForRemoval:
-				}
-			})
+			rebootTriggerred := make(chan struct{}, 1)
 
-			t.Run("waits_for_removed_pods_to_terminate_before_rebooting", func(t *testing.T) {
-				select {
-				case <-ctx.Done():
-					t.Fatalf("Timed out waiting for all pods to be scheduled for removal")
-				case <-allExpectedPodsScheduledForRemoval:
-				}
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.RebootWindowStart = time.Now().Add(-time.Minute).Format("15:04")
+			testConfig.RebootWindowLength = "1h"
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(bool) {
+					rebootTriggerred <- struct{}{}
+				},
+			}
 
-				select {
-				case <-ctx.Done():
-					t.Fatalf("Timed out waiting pod GET request")
-				case <-podGetRequest:
-				}
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-				client := testConfig.Clientset.CoreV1().Pods(podsToCreate[0].Namespace)
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   runAgent(ctx, t, testConfig),
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
 
-				if err := client.Delete(ctx, podsToCreate[0].Name, metav1.DeleteOptions{}); err != nil {
-					t.Fatalf("Failed removing test pod: %v", err)
-				}
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
-				select {
-				case <-ctx.Done():
-					t.Fatalf("Timed out waiting for reboot to be triggered")
-				case expected := <-rebootTriggerred:
-					if !expected {
-						t.Fatalf("Got unexpected reboot call")
-					}
-				}
-			})
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for reboot to be triggered")
+			case <-rebootTriggerred:
+			}
 		})
 
-		t.Run("ignores_pods_which_has_not_terminated_when_pod_deletion_grace_period_is_reached", func(t *testing.T) {
+		t.Run("does_not_trigger_a_reboot_when_reboot_window_closes_while_draining", func(t *testing.T) {
 			t.Parallel()
 
-			rebootTriggerred := make(chan bool)
-
-			podsToCreate := []*corev1.Pod{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:            "foo",
-						Namespace:       "default",
-						OwnerReferences: testPodControllerReference(),
-					},
-					Spec: corev1.PodSpec{
-						NodeName: testNode().Name,
-					},
-				},
-			}
+			rebootTriggerred := make(chan struct{}, 1)
 
-			fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
-			addEvictionSupport(t, fakeClient)
+			now := time.Now()
+			windowStart := now.Truncate(time.Minute)
+			windowEnd := now.Add(3 * time.Second)
 
-			testConfig, node, _ := validTestConfig(t, testNode())
-			testConfig.Clientset = fakeClient
+			testConfig, node, fakeClient := validTestConfig(t, testNode())
+			testConfig.RebootWindowStart = windowStart.Format("15:04")
+			testConfig.RebootWindowLength = windowEnd.Sub(windowStart).String()
 			testConfig.Rebooter = &mockRebooter{
-				rebootF: func(auth bool) {
-					rebootTriggerred <- auth
+				rebootF: func(bool) {
+					rebootTriggerred <- struct{}{}
 				},
 			}
 
-			// We only handle the creating of the eviction and not the delete which will block and cause the timeout
-			// condition we're looking for.
-			fakeClient.PrependReactor("create", "pods/eviction", func(action k8stesting.Action) (bool, runtime.Object, error) {
-				return true, nil, nil
+			var cordoned int32
+
+			fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if updateActionToNode(t, action).Spec.Unschedulable {
+					atomic.StoreInt32(&cordoned, 1)
+				}
+
+				return false, nil, nil
+			})
+
+			nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+
+			// Keep draining until reboot window closes.
+			fakeClient.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+				if atomic.LoadInt32(&cordoned) == 1 {
+					time.Sleep(time.Until(windowEnd))
+				}
+
+				return false, nil, nil
 			})
 
 			ctx := contextWithTimeout(t, agentRunTimeLimit)
@@ -925,115 +3107,170 @@ func Test_Running_agent(t *testing.T) {
 
 			select {
 			case <-ctx.Done():
-				t.Fatal("Timed out waiting for reboot to be triggered")
+				t.Fatal("Timed out waiting for node to be drained")
+			case <-rebootTriggerred:
+				t.Fatalf("Reboot has been triggered before draining")
+			case <-nodeUpdatedAsUnschedulable:
+			}
+
+			select {
+			case <-contextWithTimeout(t, time.Until(windowEnd)+time.Second).Done():
 			case <-rebootTriggerred:
+				t.Fatalf("Reboot has been triggered outside of reboot window")
 			}
 		})
 	})
 
-	t.Run("removes_pod_without_owner_when_force_drain_is_configured", func(t *testing.T) {
+	t.Run("when_requesting_reboot_fails", func(t *testing.T) {
 		t.Parallel()
 
-		rebootTriggerred := make(chan bool)
+		t.Run("retries_configured_number_of_times_with_exponential_backoff", func(t *testing.T) {
+			t.Parallel()
 
-		podsToCreate := []*corev1.Pod{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "foo",
-					Namespace: "default",
+			rebootRequests := make(chan time.Time, 3)
+			attempts := 0
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.RebootRetries = 2
+			testConfig.RebootRetryBackoff = 100 * time.Millisecond
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(bool) {
+					rebootRequests <- time.Now()
 				},
-				Spec: corev1.PodSpec{
-					NodeName: testNode().Name,
+				errorF: func() error {
+					attempts++
+
+					if attempts <= testConfig.RebootRetries {
+						return fmt.Errorf("transient error")
+					}
+
+					return nil
 				},
-			},
-		}
+			}
 
-		fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
-		addEvictionSupport(t, fakeClient)
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		testConfig, node, _ := validTestConfig(t, testNode())
-		testConfig.ForceNodeDrain = true
-		testConfig.Clientset = fakeClient
-		testConfig.Rebooter = &mockRebooter{
-			rebootF: func(auth bool) {
-				rebootTriggerred <- auth
-			},
-		}
+			done := runAgent(ctx, t, testConfig)
 
-		ctx := contextWithTimeout(t, agentRunTimeLimit)
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
 
-		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   runAgent(ctx, t, testConfig),
-			config: testConfig,
-			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			requestTimes := []time.Time{}
+
+			for len(requestTimes) < cap(rebootRequests) {
+				select {
+				case <-ctx.Done():
+					t.Fatalf("Timed out waiting for reboot to be requested, got %d requests", len(requestTimes))
+				case err := <-done:
+					t.Fatalf("Agent stopped prematurely: %v", err)
+				case requestTime := <-rebootRequests:
+					requestTimes = append(requestTimes, requestTime)
+				}
+			}
+
+			// Backoff is doubled after every retry.
+			if elapsed := requestTimes[2].Sub(requestTimes[1]); elapsed < 2*testConfig.RebootRetryBackoff {
+				t.Fatalf("Expected second retry to happen after at least %v, got %v",
+					2*testConfig.RebootRetryBackoff, elapsed)
+			}
 		})
 
-		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+		t.Run("stops_with_error_once_all_retries_fail", func(t *testing.T) {
+			t.Parallel()
 
-		select {
-		case <-ctx.Done():
-			t.Fatal("Timed out waiting for reboot to be triggered")
-		case <-rebootTriggerred:
-		}
+			expectedError := fmt.Errorf("persistent error")
+			attempts := 0
+
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.RebootRetries = 1
+			testConfig.RebootRetryBackoff = 10 * time.Millisecond
+			testConfig.Rebooter = &mockRebooter{
+				errorF: func() error {
+					attempts++
+
+					return expectedError
+				},
+			}
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for agent to stop")
+			case err := <-done:
+				if !errors.Is(err, expectedError) {
+					t.Fatalf("Expected error %q, got %q", expectedError, err)
+				}
+			}
+
+			if expectedAttempts := testConfig.RebootRetries + 1; attempts != expectedAttempts {
+				t.Fatalf("Expected %d reboot attempts, got %d", expectedAttempts, attempts)
+			}
+		})
 	})
 
-	t.Run("after_draining_node", func(t *testing.T) {
+	t.Run("when_node_does_not_reboot_within_configured_reboot_timeout", func(t *testing.T) {
 		t.Parallel()
 
-		rebootTriggerred := make(chan bool, 1)
-
-		ctx, cancel := context.WithCancel(contextWithTimeout(t, agentRunTimeLimit))
-
-		testConfig, node, fakeClient := validTestConfig(t, testNode())
-		testConfig.Rebooter = &mockRebooter{
-			rebootF: func(auth bool) {
-				rebootTriggerred <- auth
-				cancel()
-			},
-		}
+		t.Run("requests_reboot_again_and_stops_with_error_when_node_still_does_not_reboot", func(t *testing.T) {
+			t.Parallel()
 
-		nodeUpdatedAsUnschedulable := notifyOnNodeUnschedulableUpdate(t, fakeClient)
+			attempts := 0
 
-		done := runAgent(ctx, t, testConfig)
+			testConfig, node, _ := validTestConfig(t, testNode())
+			testConfig.RebootTimeout = 200 * time.Millisecond
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(bool) {
+					attempts++
+				},
+			}
 
-		assertNodeProperty(ctx, t, &assertNodePropertyContext{
-			done:   done,
-			config: testConfig,
-			testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
-		})
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
 
-		okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+			done := runAgent(ctx, t, testConfig)
 
-		select {
-		case <-ctx.Done():
-			t.Fatal("Timed out waiting for node being marked as unschedulable")
-		case <-nodeUpdatedAsUnschedulable:
-		}
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
 
-		t.Run("triggers_a_reboot_without_interactive_authentication", func(t *testing.T) {
-			t.Parallel()
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
 			select {
-			case <-contextWithTimeout(t, 5*time.Second).Done():
-				t.Fatalf("Timed out waiting for reboot to be triggered")
-			case interactiveAuthRequested := <-rebootTriggerred:
-				if interactiveAuthRequested {
-					t.Fatalf("Got reboot call with interactive auth requested")
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for agent to stop")
+			case err := <-done:
+				if err == nil {
+					t.Fatalf("Expected agent to stop with error")
 				}
 			}
-		})
 
-		t.Run("waits_until_termination_signal_comes", func(t *testing.T) {
-			t.Parallel()
+			if expectedAttempts := 2; attempts != expectedAttempts {
+				t.Fatalf("Expected %d reboot attempts, got %d", expectedAttempts, attempts)
+			}
 
-			select {
-			case err := <-done:
-				if err != nil {
-					t.Fatalf("Got agent running error: %v", err)
-				}
-			case <-contextWithTimeout(t, 30*time.Second).Done():
-				t.Fatalf("Timed out waiting for agent to exit gracefully after receiving exit signal")
+			fakeClient, ok := testConfig.Clientset.(*fake.Clientset)
+			if !ok {
+				t.Fatalf("Expected clientset to be a fake clientset")
 			}
+
+			waitForWarningEvent(ctx, t, fakeClient, node.Name, "RebootTimedOut")
 		})
 	})
 
@@ -1091,12 +3328,6 @@ func Test_Running_agent(t *testing.T) {
 						failingWatcherCreation <- struct{}{}
 					}
 
-					// Agent may currently send requests without a backoff, so slow it down a bit to avoid exceesive logging,
-					// which may make tests more flaky when there is not enough CPU available.
-					//
-					// TODO: This should not be needed once we implement backoff.
-					time.Sleep(errorResponseThrottle)
-
 					return true, nil, fmt.Errorf(t.Name())
 				}
 
@@ -1133,12 +3364,6 @@ func Test_Running_agent(t *testing.T) {
 				watcher.Error(nil)
 
 				fakeClient.PrependWatchReactor("nodes", func(action k8stesting.Action) (bool, watch.Interface, error) {
-					// Agent may currently send requests without a backoff, so slow it down a bit to avoid exceesive logging,
-					// which may make tests more flaky when there is not enough CPU available.
-					//
-					// TODO: This should not be needed once we implement backoff.
-					time.Sleep(errorResponseThrottle)
-
 					return true, watcher, nil
 				})
 
@@ -1147,18 +3372,262 @@ func Test_Running_agent(t *testing.T) {
 				}
 			})
 
-			t.Run("max_operator_response_time_is_exceeded", func(t *testing.T) {
-				t.Parallel()
+			t.Run("max_operator_response_time_is_exceeded", func(t *testing.T) {
+				t.Parallel()
+
+				testConfig, _, _ := validTestConfig(t, testNode())
+				testConfig.MaxOperatorResponseTime = 500 * time.Millisecond
+
+				if err := <-runAgent(contextWithTimeout(t, time.Second), t, testConfig); err != nil {
+					t.Fatalf("Expected agent to shut down gracefully after waiting for OK error, got: %v", err)
+				}
+			})
+
+			t.Run("draining_node_fails", func(t *testing.T) {
+				t.Parallel()
+
+				podsToCreate := []*corev1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:            "foo",
+							Namespace:       "default",
+							OwnerReferences: testPodControllerReference(),
+						},
+						Spec: corev1.PodSpec{
+							NodeName: testNode().Name,
+						},
+					},
+				}
+
+				fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
+				addEvictionSupport(t, fakeClient)
+
+				rebootTriggerred := make(chan bool, 1)
+
+				testConfig, node, _ := validTestConfig(t, testNode())
+				testConfig.Clientset = fakeClient
+				testConfig.Rebooter = &mockRebooter{
+					rebootF: func(auth bool) {
+						rebootTriggerred <- auth
+					},
+				}
+
+				fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, fmt.Errorf(t.Name())
+				})
+
+				ctx := contextWithTimeout(t, time.Second)
+
+				done := runAgent(ctx, t, testConfig)
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   done,
+					config: testConfig,
+					testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+				})
+
+				okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+				select {
+				case err := <-done:
+					t.Fatalf("Expected reboot, got agent running error: %v", err)
+				case <-rebootTriggerred:
+				}
+			})
+		})
+	})
+
+	t.Run("when_draining_node_fails", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("emits_warning_event_and_counts_ignored_drain_error_when_proceeding_with_reboot", func(t *testing.T) {
+			t.Parallel()
+
+			rebootTriggerred := make(chan bool, 1)
+
+			testConfig, node, fakeClient := failingDrainTestConfig(t)
+			registry := prometheus.NewRegistry()
+			testConfig.MetricsRegisterer = registry
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(auth bool) {
+					rebootTriggerred <- auth
+				},
+			}
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for reboot to be triggered")
+			case err := <-done:
+				t.Fatalf("Expected reboot, got agent running error: %v", err)
+			case <-rebootTriggerred:
+			}
+
+			if value := counterValue(t, registry, "flatcar_linux_update_agent_drain_errors_ignored_total"); value != 1 {
+				t.Fatalf("Expected ignored drain errors counter to be 1, got %v", value)
+			}
+
+			waitForWarningEvent(ctx, t, fakeClient, node.Name, "DrainError")
+		})
+
+		t.Run("emits_warning_event_and_stops_with_error_without_rebooting_when_configured", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, node, fakeClient := failingDrainTestConfig(t)
+			testConfig.AbortRebootOnDrainError = true
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(bool) {
+					t.Errorf("Unexpected reboot")
+				},
+			}
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for agent to stop")
+			case err := <-done:
+				if err == nil {
+					t.Fatalf("Expected agent running error")
+				}
+			}
+
+			waitForWarningEvent(ctx, t, fakeClient, node.Name, "DrainError")
+		})
+	})
+
+	t.Run("when_pods_are_still_terminating_after_pod_deletion_grace_period", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("makes_node_schedulable_and_stops_with_error_when_configured_to_abort", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, node, fakeClient, _ := stuckPodTestConfig(t)
+			testConfig.StuckPodsPolicy = agent.StuckPodsPolicyAbort
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(bool) {
+					t.Errorf("Unexpected reboot")
+				},
+			}
+
+			ctx := contextWithTimeout(t, stuckPodsAgentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for agent to stop")
+			case err := <-done:
+				if err == nil {
+					t.Fatalf("Expected agent running error")
+				}
+			}
+
+			updatedNode, err := fakeClient.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Getting node: %v", err)
+			}
+
+			if updatedNode.Spec.Unschedulable {
+				t.Fatalf("Expected node to be schedulable")
+			}
+
+			if v := updatedNode.Annotations[constants.AnnotationRebootInProgress]; v != constants.False {
+				t.Fatalf("Expected annotation %q to be %q, got %q",
+					constants.AnnotationRebootInProgress, constants.False, v)
+			}
+
+			waitForWarningEvent(ctx, t, fakeClient, node.Name, "PodsStuckTerminating")
+		})
+
+		t.Run("waits_for_another_grace_period_before_rebooting_when_configured_to_extend_once", func(t *testing.T) {
+			t.Parallel()
+
+			rebootTriggerred := make(chan bool, 1)
+
+			testConfig, node, fakeClient, evictions := stuckPodTestConfig(t)
+			testConfig.StuckPodsPolicy = agent.StuckPodsPolicyExtendOnce
+			testConfig.Rebooter = &mockRebooter{
+				rebootF: func(auth bool) {
+					rebootTriggerred <- auth
+				},
+			}
+
+			ctx := contextWithTimeout(t, stuckPodsAgentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+			})
+
+			okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for reboot to be triggered")
+			case err := <-done:
+				t.Fatalf("Expected reboot, got agent running error: %v", err)
+			case <-rebootTriggerred:
+			}
+
+			if evictionsCount := atomic.LoadInt32(evictions); evictionsCount != 2 {
+				t.Fatalf("Expected pod to be evicted twice, got %d evictions", evictionsCount)
+			}
+
+			waitForWarningEvent(ctx, t, fakeClient, node.Name, "PodsStuckTerminating")
+		})
+	})
 
-				testConfig, _, _ := validTestConfig(t, testNode())
-				testConfig.MaxOperatorResponseTime = 500 * time.Millisecond
+	t.Run("on_shutdown_before_triggering_a_reboot", func(t *testing.T) {
+		t.Parallel()
 
-				if err := <-runAgent(contextWithTimeout(t, time.Second), t, testConfig); err != nil {
-					t.Fatalf("Expected agent to shut down gracefully after waiting for OK error, got: %v", err)
-				}
-			})
+		for name, testCase := range map[string]struct {
+			preserveNodeState bool
+			failUncordon      bool
+		}{
+			"reverts_node_state_by_default": {},
+			"preserves_node_state_when_configured_to_do_so": {
+				preserveNodeState: true,
+			},
+			"clears_reboot_in_progress_annotation_when_making_node_schedulable_fails": {
+				failUncordon: true,
+			},
+		} {
+			testCase := testCase
 
-			t.Run("draining_node_fails", func(t *testing.T) {
+			t.Run(name, func(t *testing.T) {
 				t.Parallel()
 
 				podsToCreate := []*corev1.Pod{
@@ -1168,6 +3637,7 @@ func Test_Running_agent(t *testing.T) {
 							Namespace:       "default",
 							OwnerReferences: testPodControllerReference(),
 						},
+
 						Spec: corev1.PodSpec{
 							NodeName: testNode().Name,
 						},
@@ -1177,21 +3647,42 @@ func Test_Running_agent(t *testing.T) {
 				fakeClient := fake.NewSimpleClientset(podsToCreate[0], testNode())
 				addEvictionSupport(t, fakeClient)
 
-				rebootTriggerred := make(chan bool, 1)
+				podDeletionAttemptCh := make(chan struct{}, 1)
+				podDeletionAttempted := int32(0)
 
-				testConfig, node, _ := validTestConfig(t, testNode())
-				testConfig.Clientset = fakeClient
-				testConfig.Rebooter = &mockRebooter{
-					rebootF: func(auth bool) {
-						rebootTriggerred <- auth
-					},
-				}
+				fakeClient.PrependReactor("create", "pods/eviction", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if len(podDeletionAttemptCh) == 0 {
+						podDeletionAttemptCh <- struct{}{}
+					}
 
-				fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
-					return true, nil, fmt.Errorf(t.Name())
+					atomic.StoreInt32(&podDeletionAttempted, 1)
+
+					// Never actually remove any pod.
+					return true, nil, nil
 				})
 
-				ctx := contextWithTimeout(t, time.Second)
+				uncordonAttempted := int32(0)
+
+				// Fail making node schedulable again once draining has started.
+				fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					node, ok := action.(k8stesting.UpdateAction).GetObject().(*corev1.Node)
+					draining := atomic.LoadInt32(&podDeletionAttempted) == 1
+
+					if !testCase.failUncordon || !ok || !draining || node.Spec.Unschedulable {
+						return false, nil, nil
+					}
+
+					atomic.StoreInt32(&uncordonAttempted, 1)
+
+					return true, nil, fmt.Errorf("test error")
+				})
+
+				testConfig, node, _ := validTestConfig(t, testNode())
+				testConfig.Clientset = fakeClient
+				testConfig.PodDeletionGracePeriod = 30 * time.Second
+				testConfig.PreserveNodeStateOnShutdown = testCase.preserveNodeState
+
+				ctx, cancel := context.WithCancel(contextWithTimeout(t, agentRunTimeLimit))
 
 				done := runAgent(ctx, t, testConfig)
 
@@ -1203,13 +3694,51 @@ func Test_Running_agent(t *testing.T) {
 
 				okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
 
+				// Wait until we try to delete a pod.
+				<-podDeletionAttemptCh
+
+				cancel()
+
 				select {
-				case err := <-done:
-					t.Fatalf("Expected reboot, got agent running error: %v", err)
-				case <-rebootTriggerred:
+				case <-done:
+				case <-contextWithTimeout(t, agentShutdownLimit).Done():
+					t.Fatalf("Timed out waiting for agent to shut down")
+				}
+
+				updatedNode, err := fakeClient.CoreV1().Nodes().Get(contextWithDeadline(t), node.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Failed getting node %q: %v", node.Name, err)
+				}
+
+				expectedRebootInProgress, expectedMadeUnschedulable := constants.False, constants.False
+				if testCase.preserveNodeState {
+					expectedRebootInProgress, expectedMadeUnschedulable = constants.True, constants.True
+				}
+
+				// Node remains unschedulable, so agent can make it schedulable again on the next start.
+				expectedUnschedulable := testCase.preserveNodeState
+				if testCase.failUncordon {
+					expectedUnschedulable, expectedMadeUnschedulable = true, constants.True
+
+					if atomic.LoadInt32(&uncordonAttempted) == 0 {
+						t.Fatalf("Expected agent to attempt making node schedulable")
+					}
+				}
+
+				if updatedNode.Spec.Unschedulable != expectedUnschedulable {
+					t.Fatalf("Expected node unschedulable field to be %t", expectedUnschedulable)
+				}
+
+				for annotation, expectedValue := range map[string]string{
+					constants.AnnotationRebootInProgress:       expectedRebootInProgress,
+					constants.AnnotationAgentMadeUnschedulable: expectedMadeUnschedulable,
+				} {
+					if v := updatedNode.Annotations[annotation]; v != expectedValue {
+						t.Fatalf("Expected annotation %q to be %q, got %q", annotation, expectedValue, v)
+					}
 				}
 			})
-		})
+		}
 	})
 
 	t.Run("stops_gracefully_when_shutdown_is_requested_and_agent_is", func(t *testing.T) {
@@ -1226,6 +3755,25 @@ func Test_Running_agent(t *testing.T) {
 				t.Fatalf("Expected agent to shut down gracefully after waiting for OK error, got: %v", err)
 			}
 		})
+
+		t.Run("waiting_for_startup_delay", func(t *testing.T) {
+			t.Parallel()
+
+			testConfig, _, fakeClient := validTestConfig(t, testNode())
+			testConfig.MaxStartupDelay = 24 * time.Hour
+
+			fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				t.Errorf("Unexpected node update before startup delay passes")
+
+				return false, nil, nil
+			})
+
+			ctx := contextWithTimeout(t, 500*time.Millisecond)
+
+			if err := <-runAgent(ctx, t, testConfig); err != nil {
+				t.Fatalf("Expected agent to shut down gracefully while waiting for startup delay, got: %v", err)
+			}
+		})
 	})
 
 	t.Run("stops_with_error_when", func(t *testing.T) {
@@ -1316,6 +3864,8 @@ func Test_Running_agent(t *testing.T) {
 				t.Parallel()
 
 				testConfig, _, fakeClient := validTestConfig(t, okToRebootNode())
+				// Failing node gets are retried with backoff, so speed it up.
+				testConfig.WatchRetryBackoff = 10 * time.Millisecond
 
 				expectedError := errors.New("Error node operation " + method)
 
@@ -1335,15 +3885,28 @@ func Test_Running_agent(t *testing.T) {
 			t.Run("getting_Node_object_fails", func(t *testing.T) {
 				t.Parallel()
 
-				testConfig, _, fakeClient := validTestConfig(t, testNode())
+				testConfig, _, fakeClient := validTestConfig(t, okToRebootNode())
+				testConfig.WatchRetryBackoff = 100 * time.Millisecond
+				testConfig.MaxOperatorResponseTime = time.Second
 
 				expectedError := errors.New("Error getting node")
 
-				// 1. Updating info labels. TODO: Could be done with patch instead.
-				// 2. Checking made unschedulable.
-				// 3. Updating annotations and labels.
-				_, f := failOnNthCall(3, expectedError)
-				fakeClient.PrependReactor("get", "*", f)
+				// Failures are retried until max operator response time passes, so keep failing.
+				gets := 0
+
+				// 1. Checking eviction API support.
+				// 2. Updating info labels. TODO: Could be done with patch instead.
+				// 3. Checking made unschedulable.
+				// 4. Updating annotations and labels.
+				fakeClient.PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if gets < 4 {
+						gets++
+
+						return false, nil, nil
+					}
+
+					return true, nil, expectedError
+				})
 
 				err := getAgentRunningError(t, testConfig)
 				if !errors.Is(err, expectedError) {
@@ -1355,6 +3918,8 @@ func Test_Running_agent(t *testing.T) {
 				t.Parallel()
 
 				testConfig, _, fakeClient := validTestConfig(t, okToRebootNode())
+				testConfig.WatchRetryBackoff = 100 * time.Millisecond
+				testConfig.MaxOperatorResponseTime = time.Second
 
 				expectedError := errors.New("creating watcher")
 				f := func(action k8stesting.Action) (bool, watch.Interface, error) {
@@ -1405,11 +3970,16 @@ func Test_Running_agent(t *testing.T) {
 						t.Parallel()
 
 						testConfig, _, fakeClient := validTestConfig(t, okToRebootNode())
+						testConfig.WatchRetryBackoff = 100 * time.Millisecond
+						testConfig.MaxOperatorResponseTime = time.Second
 
-						// Mock sending custom watch event.
-						watcher := watch.NewFakeWithChanSize(1, true)
-						testCase.watchEvent(watcher)
-						fakeClient.PrependWatchReactor("nodes", k8stesting.DefaultWatchReactor(watcher, nil))
+						// Mock sending custom watch event on every retry.
+						fakeClient.PrependWatchReactor("nodes", func(action k8stesting.Action) (bool, watch.Interface, error) {
+							watcher := watch.NewFakeWithChanSize(1, true)
+							testCase.watchEvent(watcher)
+
+							return true, watcher, nil
+						})
 
 						err := getAgentRunningError(t, testConfig)
 						if err == nil {
@@ -1504,17 +4074,20 @@ func Test_Running_agent(t *testing.T) {
 
 			testConfig, node, fakeClient := validTestConfig(t, testNode())
 			testConfig.StatusReceiver = &mockStatusReceiver{}
+			// Failing node gets are retried with backoff, so speed it up.
+			testConfig.WatchRetryBackoff = 10 * time.Millisecond
 
 			withOkToRebootTrueUpdate(fakeClient, node)
 
 			expectedError := errors.New("Error getting node")
 
-			// 1. Updating info labels. TODO: Could be done with patch instead.
-			// 2. Checking made unschedulable.
-			// 3. Updating annotations and labels.
-			// 4. Getting initial state while waiting for ok-to-reboot.
-			// 5. Getting node object to mark it unschedulable etc.
-			_, f := failOnNthCall(5, expectedError)
+			// 1. Checking eviction API support.
+			// 2. Updating info labels. TODO: Could be done with patch instead.
+			// 3. Checking made unschedulable.
+			// 4. Updating annotations and labels.
+			// 5. Getting initial state while waiting for ok-to-reboot.
+			// 6. Getting node object to mark it unschedulable etc.
+			_, f := failOnNthCall(6, expectedError)
 			fakeClient.PrependReactor("get", "*", f)
 
 			err := getAgentRunningError(t, testConfig)
@@ -1661,6 +4234,100 @@ func Test_Running_agent(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("with_taint_cordon_method", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("taints_node_instead_of_marking_it_unschedulable_after_getting_ok_to_reboot_annotation",
+			func(t *testing.T) {
+				t.Parallel()
+
+				testConfig, node, _ := validTestConfig(t, testNode())
+				testConfig.CordonMethod = agent.CordonMethodTaint
+
+				ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+				done := runAgent(ctx, t, testConfig)
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   done,
+					config: testConfig,
+					testF:  assertNodeAnnotationValue(constants.AnnotationRebootNeeded, constants.True),
+				})
+
+				okToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+				assertNodeProperty(ctx, t, &assertNodePropertyContext{
+					done:   done,
+					config: testConfig,
+					testF: func(t *testing.T, node *corev1.Node) bool {
+						t.Helper()
+
+						if node.Spec.Unschedulable {
+							t.Fatalf("Node should not be marked as unschedulable")
+						}
+
+						return hasRebootingTaint(node) &&
+							node.Annotations[constants.AnnotationAgentMadeUnschedulable] == constants.True
+					},
+				})
+			})
+
+		t.Run("removes_taint_from_node_if_agent_tainted_it", func(t *testing.T) {
+			t.Parallel()
+
+			taintedNode := okToRebootNode()
+			taintedNode.Annotations[constants.AnnotationAgentMadeUnschedulable] = constants.True
+			taintedNode.Spec.Taints = []corev1.Taint{
+				{Key: "example.com/other", Effect: corev1.TaintEffectNoExecute},
+				{Key: constants.TaintRebooting, Effect: corev1.TaintEffectNoSchedule},
+			}
+
+			testConfig, node, _ := validTestConfig(t, taintedNode)
+			testConfig.CordonMethod = agent.CordonMethodTaint
+
+			ctx := contextWithTimeout(t, agentRunTimeLimit)
+
+			done := runAgent(ctx, t, testConfig)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF:  assertNodeLabelValue(constants.LabelRebootNeeded, constants.False),
+			})
+
+			notOkToReboot(ctx, t, testConfig.Clientset.CoreV1().Nodes(), node.Name)
+
+			assertNodeProperty(ctx, t, &assertNodePropertyContext{
+				done:   done,
+				config: testConfig,
+				testF: func(t *testing.T, node *corev1.Node) bool {
+					t.Helper()
+
+					if hasRebootingTaint(node) ||
+						node.Annotations[constants.AnnotationAgentMadeUnschedulable] != constants.False {
+						return false
+					}
+
+					if len(node.Spec.Taints) != 1 {
+						t.Fatalf("Expected only rebooting taint to be removed, got taints %v", node.Spec.Taints)
+					}
+
+					return true
+				},
+			})
+		})
+	})
+}
+
+func hasRebootingTaint(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == constants.TaintRebooting && taint.Effect == corev1.TaintEffectNoSchedule {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Expose klog flags to be able to increase verbosity for agent logs.
@@ -1702,6 +4369,154 @@ func validTestConfig(t *testing.T, node *corev1.Node) (*agent.Config, *corev1.No
 	}, node, &fakeClient.Fake
 }
 
+// failingDrainTestConfig returns agent configuration, where waiting for deleted pods to terminate
+// fails when draining the node.
+func failingDrainTestConfig(t *testing.T) (*agent.Config, *corev1.Node, *fake.Clientset) {
+	t.Helper()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo",
+			Namespace:       "default",
+			OwnerReferences: testPodControllerReference(),
+		},
+		Spec: corev1.PodSpec{
+			NodeName: testNode().Name,
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset(pod, testNode())
+	addEvictionSupport(t, fakeClient)
+
+	fakeClient.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf(t.Name())
+	})
+
+	testConfig, node, _ := validTestConfig(t, testNode())
+	testConfig.Clientset = fakeClient
+
+	return testConfig, node, fakeClient
+}
+
+// stuckPodTestConfig returns agent configuration with a pod on the node, which never terminates when evicted
+// and a counter of evictions.
+func stuckPodTestConfig(t *testing.T) (*agent.Config, *corev1.Node, *fake.Clientset, *int32) {
+	t.Helper()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo",
+			Namespace:       "default",
+			OwnerReferences: testPodControllerReference(),
+		},
+		Spec: corev1.PodSpec{
+			NodeName: testNode().Name,
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset(pod, testNode())
+	addEvictionSupport(t, fakeClient)
+
+	var evictions int32
+
+	fakeClient.PrependReactor("create", "pods/eviction", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		atomic.AddInt32(&evictions, 1)
+
+		return true, nil, nil
+	})
+
+	testConfig, node, _ := validTestConfig(t, testNode())
+	testConfig.Clientset = fakeClient
+	// Evicted pods never terminate, so do not wait for them longer than necessary.
+	testConfig.PodDeletionGracePeriod = 500 * time.Millisecond
+
+	return testConfig, node, fakeClient, &evictions
+}
+
+// gaugeValues returns values of gauge with given name, keyed by the value of its first label.
+func gaugeValues(t *testing.T, gatherer prometheus.Gatherer, name string) map[string]float64 {
+	t.Helper()
+
+	metricFamilies, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed gathering metrics: %v", err)
+	}
+
+	values := map[string]float64{}
+
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() != name {
+			continue
+		}
+
+		for _, metric := range metricFamily.GetMetric() {
+			label := ""
+			if labels := metric.GetLabel(); len(labels) > 0 {
+				label = labels[0].GetValue()
+			}
+
+			values[label] = metric.GetGauge().GetValue()
+		}
+	}
+
+	return values
+}
+
+func counterValue(t *testing.T, gatherer prometheus.Gatherer, name string) float64 {
+	t.Helper()
+
+	metricFamilies, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed gathering metrics: %v", err)
+	}
+
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() == name && len(metricFamily.GetMetric()) > 0 {
+			return metricFamily.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	t.Fatalf("Metric %q not found", name)
+
+	return 0
+}
+
+func waitForWarningEvent(ctx context.Context, t *testing.T, clientset *fake.Clientset, nodeName, reason string) {
+	t.Helper()
+
+	waitForEvent(ctx, t, clientset, nodeName, corev1.EventTypeWarning, reason)
+}
+
+// waitForEvent waits for event of given type and reason about given node and returns it.
+func waitForEvent(
+	ctx context.Context, t *testing.T, clientset kubernetes.Interface, nodeName, eventType, reason string,
+) corev1.Event {
+	t.Helper()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for %s event %q about node %q", eventType, reason, nodeName)
+		case <-ticker.C:
+		}
+
+		events, err := clientset.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed listing events: %v", err)
+		}
+
+		for _, event := range events.Items {
+			if event.InvolvedObject.Kind == "Node" && event.InvolvedObject.Name == nodeName &&
+				event.Type == eventType && event.Reason == reason {
+				return event
+			}
+		}
+	}
+}
+
 type mockStatusReceiver struct {
 	receiveStatusesF func(chan<- updateengine.Status, <-chan struct{})
 }
@@ -1712,14 +4527,44 @@ func (m *mockStatusReceiver) ReceiveStatuses(rcvr chan<- updateengine.Status, st
 	}
 }
 
+type mockLastAttemptErrorReader struct {
+	lastAttemptErrorF func() (int32, error)
+}
+
+func (m *mockLastAttemptErrorReader) LastAttemptError() (int32, error) {
+	return m.lastAttemptErrorF()
+}
+
 type mockRebooter struct {
 	rebootF func(bool)
+	// When set, returned error is returned from Reboot.
+	errorF func() error
 }
 
-func (m *mockRebooter) Reboot(auth bool) {
+func (m *mockRebooter) Reboot(auth bool) error {
 	if m.rebootF != nil {
 		m.rebootF(auth)
 	}
+
+	if m.errorF != nil {
+		return m.errorF()
+	}
+
+	return nil
+}
+
+type mockPowerOffer struct {
+	mockRebooter
+
+	powerOffF func(bool)
+}
+
+func (m *mockPowerOffer) PowerOff(auth bool) error {
+	if m.powerOffF != nil {
+		m.powerOffF(auth)
+	}
+
+	return nil
 }
 
 func contextWithDeadline(t *testing.T) context.Context {
@@ -1885,6 +4730,15 @@ func okToRebootNode() *corev1.Node {
 	return node
 }
 
+func rebootNeededNode() *corev1.Node {
+	node := testNode()
+
+	node.Annotations[constants.AnnotationRebootNeeded] = constants.True
+	node.Labels[constants.LabelRebootNeeded] = constants.True
+
+	return node
+}
+
 func nodeMadeUnschedulable() *corev1.Node {
 	node := testNode()
 
@@ -1989,12 +4843,6 @@ func failOnNthCall(failingCall int, err error) (chan struct{}, k8stesting.Reacti
 			return false, nil, nil
 		}
 
-		// Agent may currently send requests without a backoff, so slow it down a bit to avoid exceesive logging,
-		// which may make tests more flaky when there is not enough CPU available.
-		//
-		// TODO: This should not be needed once we implement backoff.
-		time.Sleep(errorResponseThrottle)
-
 		if len(errorReached) == 0 {
 			errorReached <- struct{}{}
 		}
@@ -2091,3 +4939,23 @@ func testPodControllerReference() []metav1.OwnerReference {
 		},
 	}
 }
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.String()
+}
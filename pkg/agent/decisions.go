@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Lifecycle decisions reported by the agent.
+const (
+	decisionStatusChanged   = "status_changed"
+	decisionCordoned        = "cordoned"
+	decisionDrainStarted    = "drain_started"
+	decisionDrainFinished   = "drain_finished"
+	decisionRebootRequested = "reboot_requested"
+	decisionRebootFailed    = "reboot_failed"
+)
+
+// decisionEvent describes a single lifecycle decision of the agent. Field names are part of the output
+// format consumed by log-based pipelines, so they must not change.
+type decisionEvent struct {
+	Time       string `json:"time"`
+	Node       string `json:"node"`
+	Decision   string `json:"decision"`
+	Status     string `json:"status,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	Pods       *int   `json:"pods,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// decisionRecorder writes lifecycle decisions of the agent as JSON objects, one per line.
+// Nothing is written if no output is configured.
+type decisionRecorder struct {
+	nodeName string
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newDecisionRecorder(nodeName string, output io.Writer) *decisionRecorder {
+	recorder := &decisionRecorder{
+		nodeName: nodeName,
+	}
+
+	if output != nil {
+		recorder.encoder = json.NewEncoder(output)
+	}
+
+	return recorder
+}
+
+// record writes given decision, filling in time and node name. Failing to write it is only logged,
+// as it must not affect the update process.
+func (d *decisionRecorder) record(event decisionEvent) {
+	if d.encoder == nil {
+		return
+	}
+
+	event.Time = time.Now().UTC().Format(time.RFC3339)
+	event.Node = d.nodeName
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.encoder.Encode(event); err != nil {
+		klog.Warningf("Failed writing %q decision: %v", event.Decision, err)
+	}
+}
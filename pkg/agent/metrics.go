@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "flatcar_linux_update_agent"
+
+// metrics holds Prometheus metrics exposed by the agent.
+type metrics struct {
+	drainErrorsIgnored prometheus.Counter
+	updateProgress     prometheus.Gauge
+	updateStatus       *prometheus.GaugeVec
+}
+
+// newMetrics creates agent metrics and registers them using given registerer.
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		drainErrorsIgnored: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "drain_errors_ignored_total",
+			Help:      "Number of times a node drain error has been ignored and the node has been rebooted anyway.",
+		}),
+		updateProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "update_progress",
+			Help:      "Progress of the current update operation reported by update_engine, from 0 to 1.",
+		}),
+		updateStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "update_status",
+			Help:      "Current update operation reported by update_engine. Always 1 for the current operation.",
+		}, []string{"operation"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.drainErrorsIgnored, m.updateProgress, m.updateStatus} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("registering metric: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// setUpdateStatus records given update_engine operation and its progress.
+func (m *metrics) setUpdateStatus(operation string, progress float64) {
+	m.updateProgress.Set(progress)
+	m.updateStatus.Reset()
+	m.updateStatus.WithLabelValues(operation).Set(1)
+}
@@ -31,6 +31,14 @@ const (
 	// the update-agent or update-operator.
 	AnnotationRebootPaused = Prefix + "reboot-paused"
 
+	// AnnotationCancelReboot is a key that may be set by the administrator to "true" to cancel a reboot,
+	// which has been scheduled or approved by the update-operator, but not started by the update-agent yet.
+	//
+	// While set, the update-operator withdraws given reboot approval, cleans up before-reboot state and does not
+	// consider a node for rebooting, and the update-agent keeps waiting for the reboot approval. Never set by the
+	// update-agent or update-operator.
+	AnnotationCancelReboot = Prefix + "cancel-reboot"
+
 	// AnnotationStatus is a key set by the update-agent to the current operator status of update_agent.
 	//
 	// Possible values are:
@@ -57,10 +65,224 @@ const (
 	// It is an opaque string, but might be semver.
 	AnnotationNewVersion = Prefix + "new-version"
 
+	// AnnotationLastUpdateAttemptError is a key set by the update-agent to the error code of the last update
+	// attempt reported by update_engine, when update_engine reports an error.
+	AnnotationLastUpdateAttemptError = Prefix + "last-update-attempt-error"
+
 	// AnnotationAgentMadeUnschedulable is a key set by update-agent to indicate
 	// it was responsible for making node unschedulable.
 	AnnotationAgentMadeUnschedulable = Prefix + "agent-made-unschedulable"
 
+	// AnnotationOperatorMadeUnschedulable is a key set by update-operator to indicate
+	// it was responsible for making node unschedulable. In such case, update-agent
+	// leaves making node schedulable again to update-operator, which does it once
+	// the reboot process of the node finishes.
+	AnnotationOperatorMadeUnschedulable = Prefix + "operator-made-unschedulable"
+
+	// AnnotationAgentHeartbeat is a key set by the update-agent to the time when it has last reported
+	// being alive, in RFC 3339 format.
+	//
+	// It allows the update-operator to skip nodes which agent is not running.
+	AnnotationAgentHeartbeat = Prefix + "agent-heartbeat"
+
+	// AnnotationEvictedPods is a key set by the update-agent to comma-separated list of pods in "namespace/name"
+	// format, which were evicted or deleted while draining the node for the last reboot. If the list is too
+	// long, it is truncated and the number of omitted pods is appended.
+	AnnotationEvictedPods = Prefix + "evicted-pods"
+
+	// AnnotationRebootDeferredReason is a key set by the update-operator to the reason why a node which needs
+	// a reboot is not scheduled for rebooting. It is removed once the reason no longer applies.
+	//
+	// Possible values are:
+	//  - "outside-window"
+	//  - "warmup"
+	//  - "blocked"
+	//  - "paused"
+	//  - "not-enough-ready"
+	//  - "post-reboot-verification"
+	//  - "downgrade"
+	//  - "max-version"
+	AnnotationRebootDeferredReason = Prefix + "reboot-deferred-reason"
+
+	// RebootDeferredReasonOutsideWindow is a value of AnnotationRebootDeferredReason set when the reboot
+	// is deferred until the configured reboot window opens.
+	RebootDeferredReasonOutsideWindow = "outside-window"
+
+	// RebootDeferredReasonWarmup is a value of AnnotationRebootDeferredReason set when the reboot is deferred
+	// until the configured warmup period after becoming a leader passes.
+	RebootDeferredReasonWarmup = "warmup"
+
+	// RebootDeferredReasonBlocked is a value of AnnotationRebootDeferredReason set when the reboot is deferred,
+	// as the configured reboot blocker blocks reboots.
+	RebootDeferredReasonBlocked = "blocked"
+
+	// RebootDeferredReasonPaused is a value of AnnotationRebootDeferredReason set when the reboot is deferred,
+	// as reboots of all nodes are paused using the configured ConfigMap.
+	RebootDeferredReasonPaused = "paused"
+
+	// RebootDeferredReasonNotEnoughReady is a value of AnnotationRebootDeferredReason set when the reboot is
+	// deferred, as fewer nodes than the configured minimum are Ready.
+	RebootDeferredReasonNotEnoughReady = "not-enough-ready"
+
+	// RebootDeferredReasonPostRebootVerification is a value of AnnotationRebootDeferredReason set when the
+	// reboot is deferred, as recently rebooted nodes are still being verified to stay Ready or failed it.
+	RebootDeferredReasonPostRebootVerification = "post-reboot-verification"
+
+	// RebootDeferredReasonDowngrade is a value of AnnotationRebootDeferredReason set when the reboot
+	// is deferred, as it would downgrade the node while downgrades are blocked.
+	RebootDeferredReasonDowngrade = "downgrade"
+
+	// RebootDeferredReasonMaxVersion is a value of AnnotationRebootDeferredReason set when the reboot
+	// is deferred, as the node would be updated past the version it is pinned to using AnnotationMaxVersion.
+	RebootDeferredReasonMaxVersion = "max-version"
+
+	// AnnotationRebootBlockedReason is a key set by the update-agent, if configured, to what it currently
+	// waits for before it proceeds with the reboot process. It is removed once the agent requests a reboot.
+	//
+	// Possible values are:
+	//  - "waiting-for-not-ok-to-reboot"
+	//  - "waiting-for-ok-to-reboot"
+	//  - "outside-window"
+	//  - "draining"
+	AnnotationRebootBlockedReason = Prefix + "reboot-blocked-reason"
+
+	// RebootBlockedReasonWaitingForNotOkToReboot is a value of AnnotationRebootBlockedReason set on startup
+	// while the agent waits for the operator to finish the previous reboot process and reset ok-to-reboot.
+	RebootBlockedReasonWaitingForNotOkToReboot = "waiting-for-not-ok-to-reboot"
+
+	// RebootBlockedReasonWaitingForOkToReboot is a value of AnnotationRebootBlockedReason set while the agent
+	// waits for the operator to approve the reboot. It is only set while reboot-needed is "true".
+	RebootBlockedReasonWaitingForOkToReboot = "waiting-for-ok-to-reboot"
+
+	// RebootBlockedReasonOutsideWindow is a value of AnnotationRebootBlockedReason set while the agent waits
+	// for the configured local reboot window to open.
+	RebootBlockedReasonOutsideWindow = "outside-window"
+
+	// RebootBlockedReasonDraining is a value of AnnotationRebootBlockedReason set while the agent waits for
+	// pods to be removed from the node.
+	RebootBlockedReasonDraining = "draining"
+
+	// AnnotationMaintenanceReboot is a key set by the update-operator on nodes matching configured maintenance
+	// node selector to track maintenance reboot, which is requested regardless of the update state.
+	// It is removed once the node no longer matches the selector after the maintenance reboot has completed.
+	//
+	// Possible values are:
+	//  - "requested"
+	//  - "completed"
+	AnnotationMaintenanceReboot = Prefix + "maintenance-reboot"
+
+	// MaintenanceRebootRequested is a value of AnnotationMaintenanceReboot set when maintenance reboot
+	// has been requested, but not completed yet.
+	MaintenanceRebootRequested = "requested"
+
+	// MaintenanceRebootCompleted is a value of AnnotationMaintenanceReboot set when node has been
+	// rebooted as part of the maintenance.
+	MaintenanceRebootCompleted = "completed"
+
+	// AnnotationRebootRequested is a suggested key of reboot request annotation, which workloads may set
+	// to "true" on their pod or on their node to request a reboot of the node, when the update-operator
+	// is configured with it.
+	AnnotationRebootRequested = Prefix + "reboot-requested"
+
+	// AnnotationRebootRequestedBy is a key set by the update-operator on nodes which reboot has been requested
+	// using configured reboot request annotation, to track the requested reboot until it completes.
+	//
+	// Value is "node" if the reboot has been requested on the node itself or "pod/<namespace>/<name>"
+	// if it has been requested by a pod running on the node.
+	AnnotationRebootRequestedBy = Prefix + "reboot-requested-by"
+
+	// AnnotationRebootRequestCompletedTime is a key set by the update-operator to the time when the last
+	// requested reboot of the node has completed, in RFC 3339 format. Pods created before this time
+	// are not considered when looking for reboot requests.
+	AnnotationRebootRequestCompletedTime = Prefix + "reboot-request-completed-time"
+
+	// AnnotationRebootFinishedTime is a key set by the update-operator to the time when the node has finished
+	// rebooting, when post reboot ready period is configured. It is removed once the node has stayed Ready
+	// for the configured period or has failed to do so.
+	AnnotationRebootFinishedTime = Prefix + "reboot-finished-time"
+
+	// AnnotationRebootStartedTime is a key set by the update-operator to the time when it has approved the last
+	// reboot of the node, in RFC 3339 format.
+	AnnotationRebootStartedTime = Prefix + "reboot-started-time"
+
+	// AnnotationRebootCompletedTime is a key set by the update-operator to the time when the last reboot of the
+	// node has completed, including after-reboot checks, in RFC 3339 format. It is removed when a new reboot of
+	// the node is approved.
+	AnnotationRebootCompletedTime = Prefix + "reboot-completed-time"
+
+	// AnnotationPostRebootVerificationFailed is a key set to "true" by the update-operator when the node has
+	// not stayed Ready for the configured post reboot ready period. While set on any node, the update-operator
+	// does not schedule nor approve new reboots. Must be removed by the administrator to resume reboots.
+	AnnotationPostRebootVerificationFailed = Prefix + "post-reboot-verification-failed"
+
+	// AnnotationPendingApproval is a key set to "true" by the update-operator when manual reboot approval
+	// is required and the node has passed before reboot checks, but its reboot has not been approved yet.
+	AnnotationPendingApproval = Prefix + "pending-approval"
+
+	// AnnotationRebootEscalated is a key set to "true" by the update-operator when the node has been scheduled
+	// for rebooting beyond regular rebooting capacity, as its reboot was overdue. Such node is also approved to
+	// reboot beyond regular rebooting capacity. Removed once the node is approved to reboot.
+	AnnotationRebootEscalated = Prefix + "reboot-escalated"
+
+	// AnnotationMaxVersion is a key which may be set by the administrator to pin the node to a maximum
+	// version. Reboots into a greater version than given one, compared as semver, are not scheduled.
+	AnnotationMaxVersion = Prefix + "max-version"
+
+	// AnnotationApprovedBy is a key which may be set by the administrator to their name to approve the reboot
+	// of a node pending approval, when manual reboot approval is required.
+	AnnotationApprovedBy = Prefix + "approved-by"
+
+	// AnnotationPhaseTransitionTime is a key set by the update-operator to the time when the node has entered
+	// its current phase of the update process, in RFC 3339 format. It is removed once the node is no longer
+	// in the process of updating.
+	AnnotationPhaseTransitionTime = Prefix + "phase-transition-time"
+
+	// AnnotationErrorStatusSince is a key set by the update-operator to the time when it has first observed
+	// the node reporting one of configured update_engine error statuses, in RFC 3339 format, when update error
+	// status timeout is configured. It is removed once the node reports a different status.
+	AnnotationErrorStatusSince = Prefix + "error-status-since"
+
+	// AnnotationUpdateError is a key set to "true" by the update-agent when update_engine reports an error
+	// event, e.g. when the last update attempt has failed. It is set to "false" once update_engine reports
+	// a different status.
+	AnnotationUpdateError = Prefix + "update-error"
+
+	// AnnotationUpdateFailed is a key set to "true" by the update-operator when the node has been reporting
+	// one of configured update_engine error statuses for longer than configured update error status timeout.
+	// It is removed once the node reports a different status.
+	AnnotationUpdateFailed = Prefix + "update-failed"
+
+	// AnnotationRebootPausedTooLong is a key set to "true" by the update-operator when the node has needed
+	// a reboot while having reboot paused for longer than configured reboot pause timeout. It is removed once
+	// the node no longer needs a reboot or has reboot no longer paused.
+	AnnotationRebootPausedTooLong = Prefix + "reboot-paused-too-long"
+
+	// AnnotationAfterRebootTimedOut is a key set to "true" by the update-operator when the node has not passed
+	// after-reboot checks within configured after reboot timeout and the node is held in the after-reboot phase.
+	// It is removed once the node passes after-reboot checks.
+	AnnotationAfterRebootTimedOut = Prefix + "after-reboot-timed-out"
+
+	// AnnotationBeforeRebootTimedOut is a key set to "true" by the update-operator when the node has not passed
+	// before-reboot checks within configured before reboot timeout. The node keeps waiting for the checks.
+	// It is removed once the node no longer runs before-reboot checks.
+	AnnotationBeforeRebootTimedOut = Prefix + "before-reboot-timed-out"
+
+	// AnnotationAwaitingManualUncordon is a key set to "true" by the update-agent when configured to leave
+	// the node cordoned after the reboot, so it can be verified by a human before it rejoins scheduling.
+	// The update-operator considers the node as still rebooting until the annotation is removed, which
+	// should be done once the node has been verified and uncordoned.
+	AnnotationAwaitingManualUncordon = Prefix + "awaiting-manual-uncordon"
+
+	// AnnotationVersionBeforeReboot is a key set by the update-operator to the version the node runs when its
+	// reboot into an update reported by update_engine is approved. Reboots not caused by updates, e.g. maintenance
+	// reboots, are not recorded. It is removed once the node has rebooted.
+	AnnotationVersionBeforeReboot = Prefix + "version-before-reboot"
+
+	// AnnotationRebootFailed is a key set to "true" by the update-operator when the node has rebooted, but it
+	// does not run a newer version than before the reboot, e.g. because the update has been rolled back.
+	// It is removed once the node reboots into a newer version.
+	AnnotationRebootFailed = Prefix + "reboot-failed"
+
 	// LabelBeforeReboot is a key set to true when the operator is waiting for configured annotation
 	// before and after the reboot respectively.
 	LabelBeforeReboot = Prefix + "before-reboot"
@@ -77,9 +299,14 @@ const (
 	// /etc/flatcar/update.conf.
 	LabelGroup = Prefix + "group"
 
-	// LabelVersion is a key set by the update-agent to the value of "VERSION" in /etc/os-release.
+	// LabelVersion is a key set by the update-agent to the value of "VERSION" in /etc/os-release,
+	// or of other key in this file, if configured.
 	LabelVersion = Prefix + "version"
 
+	// TaintRebooting is a key of the NoSchedule taint set by the update-agent on its node while it reboots,
+	// when configured to cordon the node using a taint instead of marking it unschedulable.
+	TaintRebooting = "flatcar-linux-update/rebooting"
+
 	// AgentVersion is the key used to indicate the
 	// flatcar-linux-update-operator's agent's version.
 	// The value is a semver-parseable string. It should be present on each agent
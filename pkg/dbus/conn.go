@@ -33,8 +33,39 @@ func SystemPrivateConnector() (Connection, error) {
 	return godbus.SystemBusPrivate()
 }
 
-// New creates new D-Bus client using given connector.
-func New(connector Connector) (Client, error) {
+// Names of supported authentication methods.
+const (
+	AuthMethodExternal   = "external"
+	AuthMethodCookieSHA1 = "cookie-sha1"
+	AuthMethodAnonymous  = "anonymous"
+)
+
+// AuthMethods returns D-Bus authentication methods for given method names, preserving their order.
+// Methods requiring user identity use the current user.
+func AuthMethods(names []string) ([]godbus.Auth, error) {
+	methods := make([]godbus.Auth, 0, len(names))
+
+	for _, name := range names {
+		switch name {
+		case AuthMethodExternal:
+			methods = append(methods, godbus.AuthExternal(strconv.Itoa(os.Getuid())))
+		case AuthMethodCookieSHA1:
+			methods = append(methods, godbus.AuthCookieSha1(strconv.Itoa(os.Getuid()), os.Getenv("HOME")))
+		case AuthMethodAnonymous:
+			methods = append(methods, godbus.AuthAnonymous())
+		default:
+			return nil, fmt.Errorf("unsupported authentication method %q, expected one of: %q, %q, %q",
+				name, AuthMethodExternal, AuthMethodCookieSHA1, AuthMethodAnonymous)
+		}
+	}
+
+	return methods, nil
+}
+
+// New creates new D-Bus client using given connector and authentication methods.
+//
+// If no authentication methods are given, external authentication with current user ID is used.
+func New(connector Connector, authMethods ...godbus.Auth) (Client, error) {
 	if connector == nil {
 		return nil, fmt.Errorf("no connection creator given")
 	}
@@ -44,7 +75,10 @@ func New(connector Connector) (Client, error) {
 		return nil, fmt.Errorf("connecting to D-Bus: %w", err)
 	}
 
-	methods := []godbus.Auth{godbus.AuthExternal(strconv.Itoa(os.Getuid()))}
+	methods := authMethods
+	if len(methods) == 0 {
+		methods = []godbus.Auth{godbus.AuthExternal(strconv.Itoa(os.Getuid()))}
+	}
 
 	if err := conn.Auth(methods); err != nil {
 		// Best effort closing the connection.
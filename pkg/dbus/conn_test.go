@@ -56,6 +56,65 @@ func Test_Creating_client_authenticates_using_user_id(t *testing.T) {
 	}
 }
 
+func Test_Creating_client_authenticates_using_given_auth_methods(t *testing.T) {
+	t.Parallel()
+
+	authCheckingConnection := &mockConnection{
+		authF: func(authMethods []godbus.Auth) error {
+			if len(authMethods) != 1 {
+				t.Fatalf("Expected exactly one auth method, got %d", len(authMethods))
+			}
+
+			if name, _, _ := authMethods[0].FirstData(); string(name) != "ANONYMOUS" {
+				t.Fatalf("Expected anonymous auth method, got %q", string(name))
+			}
+
+			return nil
+		},
+	}
+
+	connector := func() (dbus.Connection, error) { return authCheckingConnection, nil }
+
+	if _, err := dbus.New(connector, godbus.AuthAnonymous()); err != nil {
+		t.Fatalf("Unexpected error creating client: %v", err)
+	}
+}
+
+func Test_Auth_methods(t *testing.T) {
+	t.Parallel()
+
+	t.Run("are_returned_in_given_order", func(t *testing.T) {
+		t.Parallel()
+
+		names := []string{dbus.AuthMethodAnonymous, dbus.AuthMethodCookieSHA1, dbus.AuthMethodExternal}
+
+		methods, err := dbus.AuthMethods(names)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedNames := []string{"ANONYMOUS", "DBUS_COOKIE_SHA1", "EXTERNAL"}
+
+		if len(methods) != len(expectedNames) {
+			t.Fatalf("Expected %d auth methods, got %d", len(expectedNames), len(methods))
+		}
+
+		for i, method := range methods {
+			if name, _, _ := method.FirstData(); string(name) != expectedNames[i] {
+				t.Fatalf("Expected auth method %d to be %q, got %q", i, expectedNames[i], string(name))
+			}
+		}
+	})
+
+	t.Run("cannot_be_created_from_unsupported_method_name", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := dbus.AuthMethods([]string{"foo"}); err == nil {
+			t.Fatalf("Expected error")
+		}
+	})
+}
+
 //nolint:funlen // Just many subtests.
 func Test_Creating_client_returns_error_when(t *testing.T) {
 	t.Parallel()
@@ -6,6 +6,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -14,11 +15,11 @@ type NodeGetter interface {
 	Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Node, error)
 }
 
-// GetNodeRetry gets a node object, retrying up to DefaultBackoff number of times if it fails.
-func GetNodeRetry(ctx context.Context, nc NodeGetter, node string) (*corev1.Node, error) {
+// GetNodeRetry gets a node object, retrying with given backoff if it fails.
+func GetNodeRetry(ctx context.Context, nc NodeGetter, node string, backoff wait.Backoff) (*corev1.Node, error) {
 	var apiNode *corev1.Node
 
-	err := retry.OnError(retry.DefaultBackoff, func(error) bool { return true }, func() error {
+	err := retry.OnError(backoff, func(error) bool { return true }, func() error {
 		n, getErr := nc.Get(ctx, node, metav1.GetOptions{})
 		if getErr != nil {
 			return fmt.Errorf("getting node %q: %w", node, getErr)
@@ -113,3 +114,22 @@ func Unschedulable(ctx context.Context, nc NodeUpdater, node string, sched bool)
 		n.Spec.Unschedulable = sched
 	})
 }
+
+// Taint adds given taint to the node or removes taints with the same key and effect from it according to tainted.
+func Taint(ctx context.Context, nc NodeUpdater, node string, taint corev1.Taint, tainted bool) error {
+	return UpdateNodeRetry(ctx, nc, node, func(n *corev1.Node) {
+		taints := make([]corev1.Taint, 0, len(n.Spec.Taints)+1)
+
+		for _, t := range n.Spec.Taints {
+			if !t.MatchTaint(&taint) {
+				taints = append(taints, t)
+			}
+		}
+
+		if tainted {
+			taints = append(taints, taint)
+		}
+
+		n.Spec.Taints = taints
+	})
+}
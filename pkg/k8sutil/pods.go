@@ -1,7 +1,15 @@
 package k8sutil
 
 import (
+	"context"
+	"fmt"
+	"io"
+
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
 )
 
 // FilterPods filters given list of pods using given function.
@@ -18,3 +26,81 @@ func FilterPods(pods []corev1.Pod, filter func(*corev1.Pod) bool) []corev1.Pod {
 
 	return result
 }
+
+// DefaultProtectedNamespaces returns namespaces which pods are not evicted by default when draining a node
+// for rebooting.
+//
+// XXX: Ignoring kube-system is a simple way to avoid eviciting critical components such as
+// kube-scheduler and kube-controller-manager.
+func DefaultProtectedNamespaces() []string {
+	return []string{metav1.NamespaceSystem}
+}
+
+// ProtectedNamespaces returns given namespaces without empty names, or default protected namespaces
+// if none are given.
+func ProtectedNamespaces(namespaces []string) []string {
+	if namespaces == nil {
+		return DefaultProtectedNamespaces()
+	}
+
+	protected := []string{}
+
+	for _, namespace := range namespaces {
+		if namespace != "" {
+			protected = append(protected, namespace)
+		}
+	}
+
+	return protected
+}
+
+// EvictionPodFilter is a drain pod filter skipping pods which are not evicted when draining a node
+// for rebooting, i.e. pods in default protected namespaces.
+func EvictionPodFilter(pod corev1.Pod) drain.PodDeleteStatus {
+	return ProtectedNamespacesPodFilter(DefaultProtectedNamespaces())(pod)
+}
+
+// ProtectedNamespacesPodFilter returns a drain pod filter skipping pods in given namespaces.
+func ProtectedNamespacesPodFilter(namespaces []string) drain.PodFilter {
+	protected := map[string]struct{}{}
+
+	for _, namespace := range namespaces {
+		protected[namespace] = struct{}{}
+	}
+
+	return func(pod corev1.Pod) drain.PodDeleteStatus {
+		_, ok := protected[pod.Namespace]
+
+		return drain.PodDeleteStatus{
+			Delete: !ok,
+		}
+	}
+}
+
+// PodsForEviction returns pods which update-agent would delete or evict from a node with given name
+// when draining it for rebooting. Mirror pods, DaemonSet pods and pods in given protected namespaces
+// are skipped. If protected namespaces are nil, default protected namespaces are used, like update-agent does.
+//
+// Unless force is set, an error is returned when the node runs pods not managed by any controller,
+// the same way as draining the node fails.
+func PodsForEviction(
+	ctx context.Context, client kubernetes.Interface, nodeName string, force bool, protectedNamespaces []string,
+) ([]corev1.Pod, error) {
+	drainHelper := &drain.Helper{
+		Ctx:                 ctx,
+		Client:              client,
+		Force:               force,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		Out:                 io.Discard,
+		ErrOut:              io.Discard,
+		AdditionalFilters:   []drain.PodFilter{ProtectedNamespacesPodFilter(ProtectedNamespaces(protectedNamespaces))},
+	}
+
+	podsForDeletion, errs := drainHelper.GetPodsForDeletion(nodeName)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("getting pods for deletion: %w", utilerrors.NewAggregate(errs))
+	}
+
+	return podsForDeletion.Pods(), nil
+}
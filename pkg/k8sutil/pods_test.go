@@ -0,0 +1,150 @@
+package k8sutil_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const testNodeName = "test-node"
+
+//nolint:funlen // Just subtests.
+func Test_Getting_pods_for_eviction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns_pods_running_on_given_node_except_pods_which_are_not_evicted", func(t *testing.T) {
+		t.Parallel()
+
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "test-ds", Namespace: "default"}}
+
+		mirrorPod := testPod("mirror", "default", testNodeName, "ReplicaSet")
+		mirrorPod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "foo"}
+
+		client := fakeClientWithPods(daemonSet,
+			testPod("evicted", "default", testNodeName, "ReplicaSet"),
+			testPod("other-node", "default", "other-node", "ReplicaSet"),
+			testPod("kube-system", "kube-system", testNodeName, "ReplicaSet"),
+			testPod(daemonSet.Name, "default", testNodeName, "DaemonSet"),
+			mirrorPod,
+		)
+
+		pods, err := k8sutil.PodsForEviction(context.TODO(), client, testNodeName, false, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error getting pods for eviction: %v", err)
+		}
+
+		if len(pods) != 1 || pods[0].Name != "evicted" {
+			t.Fatalf("Expected only pod %q to be returned, got %v", "evicted", pods)
+		}
+	})
+
+	t.Run("skips_pods_in_given_protected_namespaces", func(t *testing.T) {
+		t.Parallel()
+
+		client := fakeClientWithPods(
+			testPod("kube-system", "kube-system", testNodeName, "ReplicaSet"),
+			testPod("platform", "platform", testNodeName, "ReplicaSet"),
+		)
+
+		pods, err := k8sutil.PodsForEviction(context.TODO(), client, testNodeName, false, []string{"platform"})
+		if err != nil {
+			t.Fatalf("Unexpected error getting pods for eviction: %v", err)
+		}
+
+		if len(pods) != 1 || pods[0].Name != "kube-system" {
+			t.Fatalf("Expected only pod %q to be returned, got %v", "kube-system", pods)
+		}
+	})
+
+	t.Run("returns_error_when_node_runs_pods_not_managed_by_controller", func(t *testing.T) {
+		t.Parallel()
+
+		client := fakeClientWithPods(testPod("unmanaged", "default", testNodeName, ""))
+
+		if _, err := k8sutil.PodsForEviction(context.TODO(), client, testNodeName, false, nil); err == nil {
+			t.Fatalf("Expected error getting pods for eviction")
+		}
+	})
+
+	t.Run("returns_pods_not_managed_by_controller_when_forced", func(t *testing.T) {
+		t.Parallel()
+
+		client := fakeClientWithPods(testPod("unmanaged", "default", testNodeName, ""))
+
+		pods, err := k8sutil.PodsForEviction(context.TODO(), client, testNodeName, true, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error getting pods for eviction: %v", err)
+		}
+
+		if len(pods) != 1 || pods[0].Name != "unmanaged" {
+			t.Fatalf("Expected pod %q to be returned, got %v", "unmanaged", pods)
+		}
+	})
+}
+
+func testPod(name, namespace, nodeName, controllerKind string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+		},
+	}
+
+	if controllerKind != "" {
+		controller := true
+
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{Kind: controllerKind, Name: name, Controller: &controller},
+		}
+	}
+
+	return pod
+}
+
+// fakeClientWithPods returns fake client with given objects, which supports listing given pods
+// using node name field selector.
+func fakeClientWithPods(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+
+	pods := []corev1.Pod{}
+
+	for _, object := range objects {
+		if pod, ok := object.(*corev1.Pod); ok {
+			pods = append(pods, *pod)
+		}
+	}
+
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listAction, ok := action.(k8stesting.ListActionImpl)
+		if !ok {
+			return true, nil, fmt.Errorf("unexpected action type, expected %T, got %T", k8stesting.ListActionImpl{}, action)
+		}
+
+		selector := listAction.GetListRestrictions().Fields
+
+		podList := &corev1.PodList{}
+
+		for _, pod := range pods {
+			if selector.Matches(fields.Set{"spec.nodeName": pod.Spec.NodeName}) {
+				podList.Items = append(podList.Items, pod)
+			}
+		}
+
+		return true, podList, nil
+	})
+
+	return client
+}
@@ -0,0 +1,108 @@
+package login1
+
+import (
+	"fmt"
+
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/dbus"
+)
+
+const (
+	// DBusPath is an object path used by systemd-logind.
+	DBusPath = "/org/freedesktop/login1"
+	// DBusDestination is a bus name of systemd-logind service.
+	DBusDestination = "org.freedesktop.login1"
+	// DBusInterface is a systemd-logind manager interface name.
+	DBusInterface = "org.freedesktop.login1.Manager"
+	// DBusMethodNameReboot is a name of the method to reboot the host.
+	DBusMethodNameReboot = "Reboot"
+	// DBusMethodNameCanReboot is a name of the method checking if the caller is allowed to reboot the host.
+	DBusMethodNameCanReboot = "CanReboot"
+	// DBusMethodNamePowerOff is a name of the method to power off the host.
+	DBusMethodNamePowerOff = "PowerOff"
+)
+
+// Client allows requesting host reboots from systemd-logind using D-Bus.
+type Client interface {
+	// Reboot asks systemd-logind to reboot the host, optionally allowing interactive authentication.
+	// Unlike github.com/coreos/go-systemd/v22/login1, it returns an error if the D-Bus call fails.
+	Reboot(askForAuth bool) error
+
+	// PowerOff asks systemd-logind to power off the host, optionally allowing interactive authentication.
+	PowerOff(askForAuth bool) error
+
+	// CanReboot asks systemd-logind if the caller is allowed to reboot the host. Returned value is one of
+	// "yes", "no", "challenge" (allowed with interactive authentication) or "na" (not supported).
+	CanReboot() (string, error)
+
+	// Close closes underlying connection to the DBus broker. It is up to the user to close the connection
+	// and avoid leaking it.
+	Close() error
+}
+
+type caller interface {
+	Call(method string, flags godbus.Flags, args ...interface{}) *godbus.Call
+}
+
+type client struct {
+	conn   dbus.Client
+	object caller
+}
+
+// New creates new instance of Client and initializes it. Given authentication methods are passed
+// to dbus.New.
+func New(connector dbus.Connector, authMethods ...godbus.Auth) (Client, error) {
+	conn, err := dbus.New(connector, authMethods...)
+	if err != nil {
+		return nil, fmt.Errorf("creating D-Bus client: %w", err)
+	}
+
+	return &client{
+		conn:   conn,
+		object: conn.Object(DBusDestination, godbus.ObjectPath(DBusPath)),
+	}, nil
+}
+
+// Reboot calls systemd-logind Reboot method.
+func (c *client) Reboot(askForAuth bool) error {
+	if call := c.object.Call(DBusInterface+"."+DBusMethodNameReboot, 0, askForAuth); call.Err != nil {
+		return fmt.Errorf("calling %q method: %w", DBusMethodNameReboot, call.Err)
+	}
+
+	return nil
+}
+
+// PowerOff calls systemd-logind PowerOff method.
+func (c *client) PowerOff(askForAuth bool) error {
+	if call := c.object.Call(DBusInterface+"."+DBusMethodNamePowerOff, 0, askForAuth); call.Err != nil {
+		return fmt.Errorf("calling %q method: %w", DBusMethodNamePowerOff, call.Err)
+	}
+
+	return nil
+}
+
+// CanReboot calls systemd-logind CanReboot method.
+func (c *client) CanReboot() (string, error) {
+	call := c.object.Call(DBusInterface+"."+DBusMethodNameCanReboot, 0)
+	if call.Err != nil {
+		return "", fmt.Errorf("calling %q method: %w", DBusMethodNameCanReboot, call.Err)
+	}
+
+	var result string
+
+	if err := call.Store(&result); err != nil {
+		return "", fmt.Errorf("decoding %q method response: %w", DBusMethodNameCanReboot, err)
+	}
+
+	return result, nil
+}
+
+// Close closes internal D-Bus connection.
+func (c *client) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package login1_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/dbus"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/login1"
+)
+
+func Test_Creating_client(t *testing.T) {
+	t.Parallel()
+
+	t.Run("uses_systemd_logind_object", func(t *testing.T) {
+		t.Parallel()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(dest string, path godbus.ObjectPath) godbus.BusObject {
+				if dest != login1.DBusDestination {
+					t.Fatalf("Expected destination %q, got %q", login1.DBusDestination, dest)
+				}
+
+				if path != login1.DBusPath {
+					t.Fatalf("Expected path %q, got %q", login1.DBusPath, path)
+				}
+
+				return &dbus.MockObject{}
+			},
+		}
+
+		if _, err := login1.New(func() (dbus.Connection, error) { return mockConnection, nil }); err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+	})
+
+	t.Run("fails_when_creating_D-Bus_client_fails", func(t *testing.T) {
+		t.Parallel()
+
+		expectedError := fmt.Errorf("D-Bus connection error")
+
+		client, err := login1.New(func() (dbus.Connection, error) { return nil, expectedError })
+		if !errors.Is(err, expectedError) {
+			t.Fatalf("Got unexpected error while creating client, expected %q, got %q", expectedError, err)
+		}
+
+		if client != nil {
+			t.Fatalf("Expected client to be nil when creating fails")
+		}
+	})
+}
+
+func Test_Rebooting(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, callF func(string, godbus.Flags, ...interface{}) *godbus.Call) login1.Client {
+		t.Helper()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: callF,
+				}
+			},
+		}
+
+		client, err := login1.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		return client
+	}
+
+	t.Run("calls_reboot_method_with_given_interactive_authentication_argument", func(t *testing.T) {
+		t.Parallel()
+
+		called := false
+
+		client := newClient(t, func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+			expectedMethod := login1.DBusInterface + "." + login1.DBusMethodNameReboot
+			if method != expectedMethod {
+				t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+			}
+
+			if len(args) != 1 || args[0] != true {
+				t.Fatalf("Expected interactive authentication argument to be true, got %v", args)
+			}
+
+			called = true
+
+			return &godbus.Call{}
+		})
+
+		if err := client.Reboot(true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !called {
+			t.Fatalf("Expected reboot method to be called")
+		}
+	})
+
+	t.Run("returns_error_when_calling_reboot_method_fails", func(t *testing.T) {
+		t.Parallel()
+
+		expectedError := fmt.Errorf("call error")
+
+		client := newClient(t, func(string, godbus.Flags, ...interface{}) *godbus.Call {
+			return &godbus.Call{Err: expectedError}
+		})
+
+		if err := client.Reboot(false); !errors.Is(err, expectedError) {
+			t.Fatalf("Expected error %q, got %q", expectedError, err)
+		}
+	})
+}
+
+func Test_Powering_off(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, callF func(string, godbus.Flags, ...interface{}) *godbus.Call) login1.Client {
+		t.Helper()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: callF,
+				}
+			},
+		}
+
+		client, err := login1.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		return client
+	}
+
+	t.Run("calls_power_off_method_with_given_interactive_authentication_argument", func(t *testing.T) {
+		t.Parallel()
+
+		called := false
+
+		client := newClient(t, func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+			expectedMethod := login1.DBusInterface + "." + login1.DBusMethodNamePowerOff
+			if method != expectedMethod {
+				t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+			}
+
+			if len(args) != 1 || args[0] != true {
+				t.Fatalf("Expected interactive authentication argument to be true, got %v", args)
+			}
+
+			called = true
+
+			return &godbus.Call{}
+		})
+
+		if err := client.PowerOff(true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !called {
+			t.Fatalf("Expected power off method to be called")
+		}
+	})
+
+	t.Run("returns_error_when_calling_power_off_method_fails", func(t *testing.T) {
+		t.Parallel()
+
+		expectedError := fmt.Errorf("call error")
+
+		client := newClient(t, func(string, godbus.Flags, ...interface{}) *godbus.Call {
+			return &godbus.Call{Err: expectedError}
+		})
+
+		if err := client.PowerOff(false); !errors.Is(err, expectedError) {
+			t.Fatalf("Expected error %q, got %q", expectedError, err)
+		}
+	})
+}
+
+func Test_Checking_if_reboot_is_allowed(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, callF func(string, godbus.Flags, ...interface{}) *godbus.Call) login1.Client {
+		t.Helper()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: callF,
+				}
+			},
+		}
+
+		client, err := login1.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		return client
+	}
+
+	t.Run("returns_result_of_can_reboot_method", func(t *testing.T) {
+		t.Parallel()
+
+		expectedResult := "challenge"
+
+		client := newClient(t, func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+			expectedMethod := login1.DBusInterface + "." + login1.DBusMethodNameCanReboot
+			if method != expectedMethod {
+				t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+			}
+
+			return &godbus.Call{Body: []interface{}{expectedResult}}
+		})
+
+		result, err := client.CanReboot()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if result != expectedResult {
+			t.Fatalf("Expected result %q, got %q", expectedResult, result)
+		}
+	})
+
+	t.Run("returns_error_when", func(t *testing.T) {
+		t.Parallel()
+
+		for name, call := range map[string]*godbus.Call{
+			"calling_can_reboot_method_fails": {Err: fmt.Errorf("call error")},
+			"decoding_response_fails":         {Body: []interface{}{true}},
+		} {
+			call := call
+
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				client := newClient(t, func(string, godbus.Flags, ...interface{}) *godbus.Call {
+					return call
+				})
+
+				if _, err := client.CanReboot(); err == nil {
+					t.Fatalf("Expected error")
+				}
+			})
+		}
+	})
+}
+
+func Test_Closing_client(t *testing.T) {
+	t.Parallel()
+
+	t.Run("closes_underlying_D-Bus_connection", func(t *testing.T) {
+		t.Parallel()
+
+		closeCalled := false
+
+		mockConnection := &dbus.MockConnection{
+			CloseF: func() error {
+				closeCalled = true
+
+				return nil
+			},
+		}
+
+		client, err := login1.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		if err := client.Close(); err != nil {
+			t.Fatalf("Unexpected error closing client: %v", err)
+		}
+
+		if !closeCalled {
+			t.Fatalf("Expected client to close D-Bus connection")
+		}
+	})
+}
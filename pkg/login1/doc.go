@@ -0,0 +1,3 @@
+// Package login1 provides an interface for requesting host reboots from
+// systemd-logind via D-BUS interface on the host.
+package login1
@@ -0,0 +1,131 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const eventReasonAfterRebootChecksTimedOut = "AfterRebootChecksTimedOut"
+
+// AfterRebootTimeoutPolicy defines what happens with a node which has not passed after-reboot checks
+// within after reboot timeout.
+type AfterRebootTimeoutPolicy string
+
+const (
+	// AfterRebootTimeoutPolicyFinalize finalizes the reboot of the node as if it passed after-reboot checks.
+	AfterRebootTimeoutPolicyFinalize AfterRebootTimeoutPolicy = "finalize"
+	// AfterRebootTimeoutPolicyHold keeps the node waiting for after-reboot checks and marks it as timed out.
+	AfterRebootTimeoutPolicyHold AfterRebootTimeoutPolicy = "hold"
+)
+
+// checkAfterRebootTimeoutPolicy checks if given after reboot timeout policy is supported.
+func checkAfterRebootTimeoutPolicy(policy AfterRebootTimeoutPolicy) error {
+	switch policy {
+	case "", AfterRebootTimeoutPolicyFinalize, AfterRebootTimeoutPolicyHold:
+		return nil
+	default:
+		return fmt.Errorf("unsupported after reboot timeout policy %q, expected one of: %q, %q",
+			policy, AfterRebootTimeoutPolicyFinalize, AfterRebootTimeoutPolicyHold)
+	}
+}
+
+// checkAfterRebootTimeouts handles nodes which have been running after-reboot checks for longer than
+// configured after reboot timeout according to configured policy, so failing after-reboot hooks do not
+// pin nodes in the after-reboot phase unnoticed. Time since the node runs after-reboot checks is taken
+// from its phase transition time. An event is emitted for each such node.
+//
+// If after reboot timeout is not configured, nothing is done.
+//
+// If there is an error getting the list of nodes or updating any of them, an
+// error is immediately returned.
+func (k *Kontroller) checkAfterRebootTimeouts(ctx context.Context) error {
+	if k.afterRebootTimeout == 0 {
+		return nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	now := time.Now()
+	timedOutNodes := map[string]*corev1.Node{}
+	nodeNames := []string{}
+
+	for i, node := range nodelist.Items {
+		if !k.afterRebootTimedOut(&nodelist.Items[i], now) {
+			continue
+		}
+
+		timedOutNodes[node.Name] = &nodelist.Items[i]
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		return k.handleAfterRebootTimeout(ctx, timedOutNodes[nodeName])
+	})
+}
+
+// afterRebootTimedOut checks if given node has been running after-reboot checks for longer than
+// configured after reboot timeout at a given time. Nodes already held after timing out are skipped.
+func (k *Kontroller) afterRebootTimedOut(node *corev1.Node, now time.Time) bool {
+	if nodePhase(node) != phaseAfterReboot ||
+		node.Annotations[constants.AnnotationAfterRebootTimedOut] == constants.True {
+		return false
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationPhaseTransitionTime])
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(since) > k.afterRebootTimeout
+}
+
+// handleAfterRebootTimeout either finalizes the reboot of a given node or marks it as timed out,
+// depending on configured after reboot timeout policy.
+func (k *Kontroller) handleAfterRebootTimeout(ctx context.Context, node *corev1.Node) error {
+	if k.afterRebootTimeoutPolicy == AfterRebootTimeoutPolicyHold {
+		klog.Warningf("Node %q has not passed after-reboot checks within %v, holding it", node.Name,
+			k.afterRebootTimeout)
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, node.Name, func(node *corev1.Node) {
+			node.Annotations[constants.AnnotationAfterRebootTimedOut] = constants.True
+		})
+		if err != nil {
+			return fmt.Errorf("marking node %q as timed out: %w", node.Name, err)
+		}
+
+		k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonAfterRebootChecksTimedOut,
+			"Node has not passed after-reboot checks within %v, holding it until they pass", k.afterRebootTimeout)
+
+		return nil
+	}
+
+	klog.Warningf("Node %q has not passed after-reboot checks within %v, finalizing its reboot", node.Name,
+		k.afterRebootTimeout)
+
+	if err := k.updateCheckedNode(ctx, node.Name, k.afterRebootCheckOptions()); err != nil {
+		return fmt.Errorf("finalizing reboot: %w", err)
+	}
+
+	k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonAfterRebootChecksTimedOut,
+		"Node has not passed after-reboot checks within %v, finalizing its reboot", k.afterRebootTimeout)
+
+	return nil
+}
+
+// clearAfterRebootTimeoutState removes after-reboot timed out annotation from a given node which
+// is no longer running after-reboot checks.
+func clearAfterRebootTimeoutState(node *corev1.Node) {
+	if nodePhase(node) != phaseAfterReboot {
+		delete(node.Annotations, constants.AnnotationAfterRebootTimedOut)
+	}
+}
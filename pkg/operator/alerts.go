@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const alertsRequestTimeout = 10 * time.Second
+
+// RebootBlocker decides if reboots can be currently approved.
+type RebootBlocker interface {
+	// RebootBlocked returns true with a human readable reason if no reboots should be approved at the moment.
+	RebootBlocked(ctx context.Context) (bool, string, error)
+}
+
+// AlertsRebootBlocker blocks reboots while alerts are firing according to Alertmanager compatible
+// HTTP endpoint.
+type AlertsRebootBlocker struct {
+	url    string
+	client *http.Client
+}
+
+// NewAlertsRebootBlocker returns a RebootBlocker querying given URL for firing alerts.
+//
+// URL must respond with JSON list of alerts in the format of Alertmanager v2 API, e.g.
+// http://alertmanager:9093/api/v2/alerts?active=true&silenced=false&filter=severity="critical".
+// Reboots are blocked when the list is not empty.
+func NewAlertsRebootBlocker(url string) *AlertsRebootBlocker {
+	return &AlertsRebootBlocker{
+		url: url,
+		client: &http.Client{
+			Timeout: alertsRequestTimeout,
+		},
+	}
+}
+
+type alert struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// RebootBlocked implements RebootBlocker interface.
+func (a *AlertsRebootBlocker) RebootBlocked(ctx context.Context) (bool, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return false, "", fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("querying alerts: %w", err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck // Nothing to do if closing the body fails.
+
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("querying alerts: unexpected status code %d", resp.StatusCode)
+	}
+
+	alerts := []alert{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+		return false, "", fmt.Errorf("decoding alerts: %w", err)
+	}
+
+	if len(alerts) == 0 {
+		return false, "", nil
+	}
+
+	names := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		names = append(names, alert.Labels["alertname"])
+	}
+
+	return true, fmt.Sprintf("%d alerts firing: %s", len(alerts), strings.Join(names, ", ")), nil
+}
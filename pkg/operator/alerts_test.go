@@ -0,0 +1,69 @@
+package operator_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/operator"
+)
+
+func Test_Alerts_reboot_blocker(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		statusCode      int
+		body            string
+		expectedBlocked bool
+		expectError     bool
+	}{
+		"does_not_block_reboots_when_no_alerts_are_firing": {
+			statusCode: http.StatusOK,
+			body:       `[]`,
+		},
+		"blocks_reboots_when_alerts_are_firing": {
+			statusCode:      http.StatusOK,
+			body:            `[{"labels":{"alertname":"NodeDown","severity":"critical"}}]`,
+			expectedBlocked: true,
+		},
+		"returns_error_when_endpoint_responds_with_unexpected_status_code": {
+			statusCode:  http.StatusInternalServerError,
+			expectError: true,
+		},
+		"returns_error_when_endpoint_responds_with_malformed_body": {
+			statusCode:  http.StatusOK,
+			body:        `{`,
+			expectError: true,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(testCase.statusCode)
+
+				if _, err := fmt.Fprint(w, testCase.body); err != nil {
+					t.Errorf("Failed writing response: %v", err)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			blocked, _, err := operator.NewAlertsRebootBlocker(server.URL).RebootBlocked(contextWithDeadline(t))
+
+			if testCase.expectError && err == nil {
+				t.Fatalf("Expected error")
+			}
+
+			if !testCase.expectError && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if blocked != testCase.expectedBlocked {
+				t.Fatalf("Expected reboots blocked: %t, got %t", testCase.expectedBlocked, blocked)
+			}
+		})
+	}
+}
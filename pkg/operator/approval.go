@@ -0,0 +1,42 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const (
+	eventReasonRebootPendingApproval = "RebootPendingApproval"
+	eventReasonRebootApproved        = "RebootApproved"
+)
+
+// markPendingApproval marks given nodes, which passed before reboot checks, as waiting for the administrator
+// to approve their reboot using approved-by annotation and emits an event about it for each node.
+func (k *Kontroller) markPendingApproval(ctx context.Context, nodeNames []string) error {
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		updatedNode := &corev1.Node{}
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			node.Annotations[constants.AnnotationPendingApproval] = constants.True
+
+			updatedNode = node
+		})
+		if err != nil {
+			return fmt.Errorf("marking node %q as pending approval: %w", nodeName, err)
+		}
+
+		klog.Infof("Reboot of node %q is pending approval, waiting for annotation %q",
+			nodeName, constants.AnnotationApprovedBy)
+
+		k.recorder.Eventf(updatedNode, corev1.EventTypeNormal, eventReasonRebootPendingApproval,
+			"Reboot is pending approval, set annotation %q to approve it", constants.AnnotationApprovedBy)
+
+		return nil
+	})
+}
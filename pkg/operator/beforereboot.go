@@ -0,0 +1,89 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const eventReasonBeforeRebootChecksTimedOut = "BeforeRebootChecksTimedOut"
+
+// checkBeforeRebootTimeouts marks nodes which have been running before-reboot checks for longer than
+// configured before reboot timeout as timed out, so stuck before-reboot hooks can be alerted on. Time since
+// the node runs before-reboot checks is taken from its phase transition time. An event is emitted for
+// each such node. Timed out nodes keep waiting for before-reboot checks.
+//
+// If before reboot timeout is not configured, nothing is done.
+//
+// If there is an error getting the list of nodes or updating any of them, an
+// error is immediately returned.
+func (k *Kontroller) checkBeforeRebootTimeouts(ctx context.Context) error {
+	if k.beforeRebootTimeout == 0 {
+		return nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	now := time.Now()
+	nodeNames := []string{}
+
+	for i, node := range nodelist.Items {
+		if k.beforeRebootTimedOut(&nodelist.Items[i], now) {
+			nodeNames = append(nodeNames, node.Name)
+		}
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		klog.Warningf("Node %q has not passed before-reboot checks within %v", nodeName, k.beforeRebootTimeout)
+
+		updatedNode := &corev1.Node{}
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			node.Annotations[constants.AnnotationBeforeRebootTimedOut] = constants.True
+
+			updatedNode = node
+		})
+		if err != nil {
+			return fmt.Errorf("marking node %q as timed out: %w", nodeName, err)
+		}
+
+		k.recorder.Eventf(updatedNode, corev1.EventTypeWarning, eventReasonBeforeRebootChecksTimedOut,
+			"Node has not passed before-reboot checks within %v, still waiting for them to pass",
+			k.beforeRebootTimeout)
+
+		return nil
+	})
+}
+
+// beforeRebootTimedOut checks if given node has been running before-reboot checks for longer than
+// configured before reboot timeout at a given time. Nodes already marked as timed out are skipped.
+func (k *Kontroller) beforeRebootTimedOut(node *corev1.Node, now time.Time) bool {
+	if nodePhase(node) != phaseBeforeReboot ||
+		node.Annotations[constants.AnnotationBeforeRebootTimedOut] == constants.True {
+		return false
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationPhaseTransitionTime])
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(since) > k.beforeRebootTimeout
+}
+
+// clearBeforeRebootTimeoutState removes before-reboot timed out annotation from a given node which
+// is no longer running before-reboot checks.
+func clearBeforeRebootTimeoutState(node *corev1.Node) {
+	if nodePhase(node) != phaseBeforeReboot {
+		delete(node.Annotations, constants.AnnotationBeforeRebootTimedOut)
+	}
+}
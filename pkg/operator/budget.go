@@ -0,0 +1,80 @@
+package operator
+
+import (
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// RebootBudget limits the number of nodes rebooting simultaneously across multiple Kontrollers sharing it,
+// e.g. when a single operator coordinates reboots of nodes in multiple clusters having shared dependencies.
+//
+// Each Kontroller reports the number of its rebooting nodes at the start of every reconciliation and every
+// time it schedules new reboots, so the budget is only as up to date as the latest reconciliation of each
+// Kontroller. Once a Kontroller stops, its rebooting nodes are no longer counted.
+type RebootBudget struct {
+	maxRebootingNodes int
+
+	mu sync.Mutex
+	// Number of rebooting nodes by member.
+	rebootingNodes map[string]int
+}
+
+// NewRebootBudget returns a RebootBudget allowing up to given number of nodes to reboot simultaneously
+// across all Kontrollers sharing it.
+func NewRebootBudget(maxRebootingNodes int) *RebootBudget {
+	return &RebootBudget{
+		maxRebootingNodes: maxRebootingNodes,
+		rebootingNodes:    map[string]int{},
+	}
+}
+
+// report records that given member has given number of rebooting nodes, without reserving any more.
+func (b *RebootBudget) report(member string, rebooting int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rebootingNodes[member] = rebooting
+}
+
+// release stops counting rebooting nodes of given member, e.g. when it stops.
+func (b *RebootBudget) release(member string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.rebootingNodes, member)
+}
+
+// reserve records that given member has given number of rebooting nodes and returns how many of wanted
+// additional nodes it may start rebooting. Returned number of nodes is counted as rebooting for the member
+// until it reports again.
+func (b *RebootBudget) reserve(member string, rebooting, wanted int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	othersRebooting := 0
+
+	for otherMember, otherRebooting := range b.rebootingNodes {
+		if otherMember != member {
+			othersRebooting += otherRebooting
+		}
+	}
+
+	granted := b.maxRebootingNodes - othersRebooting - rebooting
+	if granted > wanted {
+		granted = wanted
+	}
+
+	if granted < 0 {
+		granted = 0
+	}
+
+	if granted < wanted {
+		klog.Infof("Found %d (of max %d) nodes rebooting across shared reboot budget; waiting for completion",
+			othersRebooting+rebooting, b.maxRebootingNodes)
+	}
+
+	b.rebootingNodes[member] = rebooting + granted
+
+	return granted
+}
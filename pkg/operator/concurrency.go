@@ -0,0 +1,129 @@
+package operator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	roundingFloor = "floor"
+	roundingCeil  = "ceil"
+
+	maxPercent = 100
+)
+
+// adaptiveConcurrency computes maximum number of rebooting nodes as a percentage of the number of nodes
+// in the cluster, rounded as configured and clamped to absolute bounds.
+type adaptiveConcurrency struct {
+	percent int
+	ceil    bool
+	min     int
+	// Zero means no upper bound.
+	max int
+}
+
+// parseAdaptiveConcurrency parses given formula in "percent=N[,rounding=floor|ceil][,min=N][,max=N]" format,
+// e.g. "percent=10,rounding=ceil,min=1,max=5". Rounding defaults to "floor" and minimum defaults to 1.
+func parseAdaptiveConcurrency(formula string) (*adaptiveConcurrency, error) {
+	ac := &adaptiveConcurrency{
+		min: 1,
+	}
+
+	for _, parameter := range strings.Split(formula, ",") {
+		parts := strings.SplitN(parameter, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("parameter %q must be in key=value format", parameter)
+		}
+
+		key, value := parts[0], parts[1]
+
+		if key == "rounding" {
+			switch value {
+			case roundingFloor:
+			case roundingCeil:
+				ac.ceil = true
+			default:
+				return nil, fmt.Errorf("unsupported rounding %q, expected one of: %q, %q", value, roundingFloor, roundingCeil)
+			}
+
+			continue
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing value of parameter %q: %w", key, err)
+		}
+
+		switch key {
+		case "percent":
+			ac.percent = number
+		case "min":
+			ac.min = number
+		case "max":
+			ac.max = number
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", key)
+		}
+	}
+
+	if ac.percent < 1 || ac.percent > maxPercent {
+		return nil, fmt.Errorf("percent must be between 1 and %d, got %d", maxPercent, ac.percent)
+	}
+
+	if ac.min < 1 {
+		return nil, fmt.Errorf("min must be at least 1, got %d", ac.min)
+	}
+
+	if ac.max != 0 && ac.max < ac.min {
+		return nil, fmt.Errorf("max must not be lower than min %d, got %d", ac.min, ac.max)
+	}
+
+	return ac, nil
+}
+
+// percentConcurrency returns adaptive concurrency allowing given percentage of nodes to reboot, rounded down,
+// but at least one node and at most given maximum number of nodes, unless it is zero.
+func percentConcurrency(percent, max int) *adaptiveConcurrency {
+	return &adaptiveConcurrency{
+		percent: percent,
+		min:     1,
+		max:     max,
+	}
+}
+
+// maxRebootingNodes returns maximum number of rebooting nodes for a cluster with given number of nodes.
+func (ac *adaptiveConcurrency) maxRebootingNodes(nodes int) int {
+	limit := nodes * ac.percent / maxPercent
+	if ac.ceil && nodes*ac.percent%maxPercent != 0 {
+		limit++
+	}
+
+	if limit < ac.min {
+		limit = ac.min
+	}
+
+	if ac.max != 0 && limit > ac.max {
+		limit = ac.max
+	}
+
+	return limit
+}
+
+// effectiveMaxRebootingNodes returns maximum number of rebooting nodes for a cluster with given nodes.
+//
+// If adaptive concurrency is not configured, configured maximum number of rebooting nodes is returned.
+func (k *Kontroller) effectiveMaxRebootingNodes(nodelist *corev1.NodeList) int {
+	if k.adaptiveConcurrency == nil {
+		return k.maxRebootingNodes
+	}
+
+	limit := k.adaptiveConcurrency.maxRebootingNodes(len(nodelist.Items))
+
+	klog.V(4).Infof("Allowing %d rebooting nodes in cluster of %d nodes", limit, len(nodelist.Items))
+
+	return limit
+}
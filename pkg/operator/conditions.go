@@ -0,0 +1,90 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+const eventReasonRebootBlockedByNodeConditions = "RebootBlockedByNodeConditions"
+
+// parseRequiredNodeConditions parses given node conditions in "Type=Status" format into map of
+// required statuses by condition type. If status is omitted, "True" is required.
+func parseRequiredNodeConditions(conditions []string) (map[corev1.NodeConditionType]corev1.ConditionStatus, error) {
+	requiredConditions := map[corev1.NodeConditionType]corev1.ConditionStatus{}
+
+	for _, condition := range conditions {
+		parts := strings.SplitN(condition, "=", 2)
+
+		conditionType, status := parts[0], string(corev1.ConditionTrue)
+		if len(parts) == 2 {
+			status = parts[1]
+		}
+
+		if errs := validation.IsQualifiedName(conditionType); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid node condition type %q: %s", conditionType, strings.Join(errs, "; "))
+		}
+
+		switch corev1.ConditionStatus(status) {
+		case corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown:
+		default:
+			return nil, fmt.Errorf("invalid status %q of node condition %q, must be one of %q, %q or %q",
+				status, conditionType, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown)
+		}
+
+		requiredConditions[corev1.NodeConditionType(conditionType)] = corev1.ConditionStatus(status)
+	}
+
+	return requiredConditions, nil
+}
+
+// requiredNodeConditionsMet checks if given node has all configured required node conditions with
+// required statuses. If not, an event listing unmet conditions is emitted for the node.
+//
+// Missing conditions are considered unmet. If no conditions are required, true is always returned.
+func (k *Kontroller) requiredNodeConditionsMet(node *corev1.Node) bool {
+	statuses := map[corev1.NodeConditionType]corev1.ConditionStatus{}
+	for _, condition := range node.Status.Conditions {
+		statuses[condition.Type] = condition.Status
+	}
+
+	conditionTypes := make([]string, 0, len(k.requiredNodeConditions))
+	for conditionType := range k.requiredNodeConditions {
+		conditionTypes = append(conditionTypes, string(conditionType))
+	}
+
+	// Sort to produce stable event messages.
+	sort.Strings(conditionTypes)
+
+	unmet := []string{}
+
+	for _, conditionType := range conditionTypes {
+		requiredStatus := k.requiredNodeConditions[corev1.NodeConditionType(conditionType)]
+
+		status, ok := statuses[corev1.NodeConditionType(conditionType)]
+		if !ok {
+			status = "missing"
+		}
+
+		if status != requiredStatus {
+			unmet = append(unmet, fmt.Sprintf("%s is %s, %s required", conditionType, status, requiredStatus))
+		}
+	}
+
+	if len(unmet) == 0 {
+		return true
+	}
+
+	message := strings.Join(unmet, "; ")
+
+	klog.Infof("Node %q does not meet required node conditions, not approving reboot: %s", node.Name, message)
+
+	k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonRebootBlockedByNodeConditions,
+		"Reboot blocked by node conditions: %s", message)
+
+	return false
+}
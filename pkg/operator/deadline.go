@@ -0,0 +1,112 @@
+package operator
+
+import (
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const eventReasonRebootDeadlineExceeded = "RebootDeadlineExceeded"
+
+// rebootOverdue checks if given node requiring a reboot has been waiting to be scheduled for rebooting
+// for longer than configured reboot deadline at a given time.
+//
+// If reboot deadline is not configured, false is always returned.
+func (k *Kontroller) rebootOverdue(node *corev1.Node, now time.Time) bool {
+	if k.rebootDeadline == 0 || nodePhase(node) != phaseScheduling {
+		return false
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationPhaseTransitionTime])
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(since) > k.rebootDeadline
+}
+
+// prioritizeOverdueNodes moves nodes which reboot is overdue to the front of given list, keeping the order
+// of the remaining nodes.
+func (k *Kontroller) prioritizeOverdueNodes(nodes []corev1.Node, now time.Time) {
+	if k.rebootDeadline == 0 {
+		return
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return k.rebootOverdue(&nodes[i], now) && !k.rebootOverdue(&nodes[j], now)
+	})
+}
+
+// escalatedNodes returns nodes from given list of nodes requiring a reboot, which reboot is overdue and
+// which can be rebooted on top of regular rebooting capacity, up to configured maximum number of rebooting
+// nodes for overdue reboots. Given number of nodes are already rebooting or chosen for rebooting.
+func (k *Kontroller) escalatedNodes(nodes []corev1.Node, rebooting int) []*corev1.Node {
+	escalated := []*corev1.Node{}
+
+	now := time.Now()
+
+	for i := range nodes {
+		if rebooting+len(escalated) >= k.overdueMaxRebootingNodes {
+			break
+		}
+
+		if !k.rebootOverdue(&nodes[i], now) {
+			continue
+		}
+
+		escalated = append(escalated, &nodes[i])
+	}
+
+	return escalated
+}
+
+// escalatedNodeNames returns names from given node names of nodes which have been scheduled for rebooting
+// beyond regular rebooting capacity and which can be approved to reboot beyond it, up to configured maximum
+// number of rebooting nodes for overdue reboots. Given number of nodes are already rebooting or approved
+// to reboot.
+func (k *Kontroller) escalatedNodeNames(nodelist *corev1.NodeList, nodeNames []string, rebooting int) []string {
+	escalatedNodes := map[string]struct{}{}
+
+	for _, node := range nodelist.Items {
+		if node.Annotations[constants.AnnotationRebootEscalated] == constants.True {
+			escalatedNodes[node.Name] = struct{}{}
+		}
+	}
+
+	escalated := []string{}
+
+	for _, nodeName := range nodeNames {
+		if rebooting+len(escalated) >= k.overdueMaxRebootingNodes {
+			break
+		}
+
+		if _, ok := escalatedNodes[nodeName]; !ok {
+			continue
+		}
+
+		escalated = append(escalated, nodeName)
+	}
+
+	if len(escalated) > 0 {
+		klog.Infof("Approving %d nodes which reboot is overdue beyond regular rebooting capacity", len(escalated))
+	}
+
+	return escalated
+}
+
+// reportEscalatedNodes logs and emits an event for each given node scheduled for rebooting beyond regular
+// rebooting capacity.
+func (k *Kontroller) reportEscalatedNodes(nodes []*corev1.Node) {
+	for _, node := range nodes {
+		klog.Infof("Node %q has needed a reboot for more than %v, scheduling it beyond regular rebooting capacity",
+			node.Name, k.rebootDeadline)
+
+		k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonRebootDeadlineExceeded,
+			"Node has needed a reboot for more than %v, rebooting it with up to %d nodes rebooting simultaneously",
+			k.rebootDeadline, k.overdueMaxRebootingNodes)
+	}
+}
@@ -0,0 +1,82 @@
+package operator
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const eventReasonRebootBlockedByDowngrade = "RebootBlockedByDowngrade"
+
+// rebootDeferral describes why reboot of a node which needs a reboot is deferred.
+type rebootDeferral struct {
+	// Value of reboot deferred reason annotation.
+	reason string
+
+	// When set, a warning event with this reason and message is emitted for the node once its
+	// reboot gets deferred.
+	eventReason string
+	message     string
+}
+
+// versionDeferral checks if reboot of a given node should be deferred because of the version
+// it would reboot into. Such nodes are never scheduled for rebooting, so they do not hold
+// rebooting capacity while waiting.
+func (k *Kontroller) versionDeferral(node *corev1.Node) (rebootDeferral, bool) {
+	if message := k.downgradeBlocked(node); message != "" {
+		return rebootDeferral{
+			reason:      constants.RebootDeferredReasonDowngrade,
+			eventReason: eventReasonRebootBlockedByDowngrade,
+			message:     message,
+		}, true
+	}
+
+	if message := maxVersionExceeded(node); message != "" {
+		return rebootDeferral{
+			reason:      constants.RebootDeferredReasonMaxVersion,
+			eventReason: eventReasonRebootBlockedByMaxVersion,
+			message:     message,
+		}, true
+	}
+
+	return rebootDeferral{}, false
+}
+
+// downgradeBlocked checks if given node is going to reboot into a version lower than the version it
+// currently runs while downgrades are blocked. If it is, a message describing it is returned,
+// otherwise an empty string is returned.
+//
+// If either of versions is not a valid semver, the check is skipped, as versions cannot be compared.
+func (k *Kontroller) downgradeBlocked(node *corev1.Node) string {
+	if !k.blockDowngrades {
+		return ""
+	}
+
+	currentVersion, newVersion := node.Labels[constants.LabelVersion], node.Annotations[constants.AnnotationNewVersion]
+
+	current, err := semver.ParseTolerant(currentVersion)
+	if err != nil {
+		klog.V(4).Infof("Skipping downgrade check for node %q, current version %q is not a semver: %v",
+			node.Name, currentVersion, err)
+
+		return ""
+	}
+
+	updated, err := semver.ParseTolerant(newVersion)
+	if err != nil {
+		klog.V(4).Infof("Skipping downgrade check for node %q, new version %q is not a semver: %v",
+			node.Name, newVersion, err)
+
+		return ""
+	}
+
+	if !updated.LT(current) {
+		return ""
+	}
+
+	return fmt.Sprintf("Reboot blocked, as it would downgrade node from version %q to %q", currentVersion, newVersion)
+}
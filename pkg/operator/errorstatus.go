@@ -0,0 +1,81 @@
+package operator
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const (
+	eventReasonUpdateStuckInErrorStatus = "UpdateStuckInErrorStatus"
+
+	// defaultUpdateErrorStatus is update_engine status reported when the update attempt has failed.
+	defaultUpdateErrorStatus = "UPDATE_STATUS_REPORTING_ERROR_EVENT"
+)
+
+// updateErrorStatusState tracks since when update_engine on a given node reports one of configured
+// error statuses and marks the node as failing to update, once it has been reporting it for longer
+// than configured update error status timeout. Tracking state is cleared once the node reports
+// a different status. It returns true if the node has just been marked as failing to update.
+//
+// If update error status timeout is not configured, nothing is done.
+func (k *Kontroller) updateErrorStatusState(node *corev1.Node, now time.Time) bool {
+	if k.updateErrorStatusTimeout == 0 {
+		return false
+	}
+
+	if _, isError := k.updateErrorStatuses[node.Annotations[constants.AnnotationStatus]]; !isError {
+		delete(node.Annotations, constants.AnnotationErrorStatusSince)
+		delete(node.Annotations, constants.AnnotationUpdateFailed)
+
+		return false
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationErrorStatusSince])
+	if err != nil {
+		node.Annotations[constants.AnnotationErrorStatusSince] = now.UTC().Format(time.RFC3339)
+
+		return false
+	}
+
+	if now.Sub(since) <= k.updateErrorStatusTimeout ||
+		node.Annotations[constants.AnnotationUpdateFailed] == constants.True {
+		return false
+	}
+
+	klog.Warningf("Node %q has been reporting update_engine status %q for more than %v, marking update as failed",
+		node.Name, node.Annotations[constants.AnnotationStatus], k.updateErrorStatusTimeout)
+
+	node.Annotations[constants.AnnotationUpdateFailed] = constants.True
+
+	return true
+}
+
+// emitUpdateFailedEvent emits an event about a given node failing to update.
+func (k *Kontroller) emitUpdateFailedEvent(node *corev1.Node) {
+	k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonUpdateStuckInErrorStatus,
+		"update_engine has been reporting status %q for more than %v",
+		node.Annotations[constants.AnnotationStatus], k.updateErrorStatusTimeout)
+}
+
+// updateErrorStatusesSet returns given update_engine statuses as a set, defaulting to the status reported
+// when the update attempt has failed.
+func updateErrorStatusesSet(statuses []string) map[string]struct{} {
+	if len(statuses) == 0 {
+		statuses = []string{defaultUpdateErrorStatus}
+	}
+
+	result := map[string]struct{}{}
+	for _, status := range statuses {
+		result[status] = struct{}{}
+	}
+
+	return result
+}
@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	healthReadHeaderTimeout     = 10 * time.Second
+	healthServerShutdownTimeout = 5 * time.Second
+)
+
+// HealthHandler returns HTTP handler exposing operator health endpoints for Kubernetes probes.
+//
+// Path /healthz always responds with 200 status code.
+//
+// Path /readyz responds with 200 status code once the operator has become a leader and the first
+// reconciliation has succeeded and with 503 status code otherwise.
+func (k *Kontroller) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthResponse(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-k.ready:
+			writeHealthResponse(w, "ready")
+		default:
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+	})
+
+	return mux
+}
+
+func writeHealthResponse(w http.ResponseWriter, body string) {
+	if _, err := fmt.Fprintln(w, body); err != nil {
+		klog.Errorf("Failed writing health response: %v", err)
+	}
+}
+
+// startHealthServer starts serving health endpoints on configured address. Returned function
+// shuts the server down.
+func (k *Kontroller) startHealthServer() (func(), error) {
+	listener, err := net.Listen("tcp", k.healthAddress)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %q: %w", k.healthAddress, err)
+	}
+
+	server := &http.Server{
+		Handler:           k.HealthHandler(),
+		ReadHeaderTimeout: healthReadHeaderTimeout,
+	}
+
+	klog.Infof("Serving health endpoints on %q", listener.Addr())
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Failed serving health endpoints: %v", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), healthServerShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			klog.Warningf("Failed gracefully shutting down health server: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,161 @@
+package operator_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_health_endpoints(t *testing.T) {
+	t.Parallel()
+
+	t.Run("healthz_responds_with_ok_status_before_operator_is_running", func(t *testing.T) {
+		t.Parallel()
+
+		config, _ := testConfig(idleNode())
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		recorder := httptest.NewRecorder()
+
+		kontrollerWithObjects(t, config).HealthHandler().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+		}
+	})
+
+	t.Run("readyz_responds_with_service_unavailable_status_before_first_reconciliation", func(t *testing.T) {
+		t.Parallel()
+
+		config, _ := testConfig(idleNode())
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		recorder := httptest.NewRecorder()
+
+		kontrollerWithObjects(t, config).HealthHandler().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+		}
+	})
+
+	t.Run("readyz_responds_with_ok_status_after_first_reconciliation", func(t *testing.T) {
+		t.Parallel()
+
+		config, _ := testConfig(idleNode())
+
+		ctx := contextWithDeadline(t)
+
+		kontroller := kontrollerWithObjects(t, config)
+
+		stop := make(chan struct{})
+		t.Cleanup(func() {
+			close(stop)
+		})
+
+		runOperator(ctx, t, kontroller, stop)
+
+		waitForStatusCode(ctx, t, func() int {
+			recorder := httptest.NewRecorder()
+
+			kontroller.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			return recorder.Code
+		}, http.StatusOK)
+	})
+
+	t.Run("are_served_on_configured_address_until_operator_is_stopped", func(t *testing.T) {
+		t.Parallel()
+
+		config, _ := testConfig(idleNode())
+		config.HealthAddress = freeAddress(t)
+
+		ctx := contextWithDeadline(t)
+
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+
+		go func() {
+			defer close(stopped)
+
+			if err := kontrollerWithObjects(t, config).Run(stop); err != nil {
+				t.Errorf("Unexpected error running operator: %v", err)
+			}
+		}()
+
+		healthzStatusCode := func() int {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+config.HealthAddress+"/healthz", nil)
+			if err != nil {
+				t.Fatalf("Creating request: %v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return 0
+			}
+
+			if err := resp.Body.Close(); err != nil {
+				t.Fatalf("Closing response body: %v", err)
+			}
+
+			return resp.StatusCode
+		}
+
+		waitForStatusCode(ctx, t, healthzStatusCode, http.StatusOK)
+
+		close(stop)
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for operator to stop")
+		case <-stopped:
+		}
+
+		if code := healthzStatusCode(); code != 0 {
+			t.Fatalf("Expected health endpoints not to be served after operator stopped, got status code %d", code)
+		}
+	})
+}
+
+// waitForStatusCode polls given function until it returns expected status code.
+func waitForStatusCode(ctx context.Context, t *testing.T, statusCode func() int, expected int) {
+	t.Helper()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		code := statusCode()
+		if code == expected {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for status code %d, last got %d", expected, code)
+		case <-ticker.C:
+		}
+	}
+}
+
+// freeAddress returns local address with a port which is free at the time of calling.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on free port: %v", err)
+	}
+
+	address := listener.Addr().String()
+
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Closing listener: %v", err)
+	}
+
+	return address
+}
@@ -0,0 +1,80 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+// pollHooksUntil checks each hook poll period if any node waiting for before or after reboot checks
+// has completed them and if so, requests reconciliation, so the node is processed without waiting for
+// the next reconciliation period. It runs until given context is cancelled.
+//
+// Reconciliation is requested once per node completing checks, so nodes which stay waiting after
+// completing them, e.g. for manual approval, do not cause reconciliation to run each hook poll period.
+func (k *Kontroller) pollHooksUntil(ctx context.Context) {
+	ticker := time.NewTicker(k.hookPollPeriod)
+	defer ticker.Stop()
+
+	notified := map[string]struct{}{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		completed, err := k.hooksCompletedNodes(ctx)
+		if err != nil {
+			klog.Errorf("Failed polling before and after reboot checks: %v", err)
+
+			continue
+		}
+
+		newlyCompleted := false
+
+		for nodeName := range completed {
+			if _, ok := notified[nodeName]; !ok {
+				newlyCompleted = true
+			}
+		}
+
+		notified = completed
+
+		if newlyCompleted {
+			klog.V(4).Info("Before or after reboot checks completed, requesting reconciliation")
+
+			k.requestReconcile()
+		}
+	}
+}
+
+// hooksCompletedNodes returns set of names of nodes waiting for before or after reboot checks, which
+// have all configured annotations from any of the annotation groups of given checks set.
+func (k *Kontroller) hooksCompletedNodes(ctx context.Context) (map[string]struct{}, error) {
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	completed := map[string]struct{}{}
+
+	for _, node := range k8sutil.FilterNodesByRequirement(nodelist.Items, beforeRebootReq) {
+		if k.hasAnyAnnotationGroup(node, k.beforeRebootAnnotationGroups) {
+			completed[node.Name] = struct{}{}
+		}
+	}
+
+	for _, node := range k8sutil.FilterNodesByRequirement(nodelist.Items, afterRebootReq) {
+		if k.hasAnyAnnotationGroup(node, k.afterRebootAnnotationGroups) {
+			completed[node.Name] = struct{}{}
+		}
+	}
+
+	return completed, nil
+}
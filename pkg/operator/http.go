@@ -0,0 +1,75 @@
+package operator
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// HTTPHandler returns HTTP handler exposing operator endpoints.
+//
+// Path /converged responds with 200 status code when no node needs a reboot and no node is
+// in the process of rebooting and with 503 status code otherwise.
+//
+// Path /reconcile accepts POST requests carrying configured reconcile token as a bearer token in
+// Authorization header and triggers reconciliation to run immediately, responding with 202 status code.
+// It is only served when reconcile token is configured.
+func (k *Kontroller) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/converged", k.handleConverged)
+
+	if k.reconcileToken != "" {
+		mux.HandleFunc("/reconcile", k.handleReconcile)
+	}
+
+	return mux
+}
+
+func (k *Kontroller) handleConverged(w http.ResponseWriter, r *http.Request) {
+	nodelist, err := k.listNodes(r.Context(), "")
+	if err != nil {
+		klog.Errorf("Failed listing nodes to check convergence: %v", err)
+
+		http.Error(w, "listing nodes failed", http.StatusInternalServerError)
+
+		return
+	}
+
+	if !k.nodesConverged(nodelist.Items) {
+		http.Error(w, "not converged", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	if _, err := fmt.Fprintln(w, "converged"); err != nil {
+		klog.Errorf("Failed writing converged response: %v", err)
+	}
+}
+
+func (k *Kontroller) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	expectedAuthorization := "Bearer " + k.reconcileToken
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expectedAuthorization)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	k.requestReconcile()
+
+	w.WriteHeader(http.StatusAccepted)
+
+	if _, err := fmt.Fprintln(w, "reconciliation requested"); err != nil {
+		klog.Errorf("Failed writing reconcile response: %v", err)
+	}
+}
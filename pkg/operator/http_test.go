@@ -0,0 +1,198 @@
+package operator_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+func Test_Operator_converged_endpoint_responds_with(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		nodes              []runtime.Object
+		failListing        bool
+		expectedStatusCode int
+	}{
+		"ok_status_when_no_node_needs_reboot": {
+			nodes:              []runtime.Object{idleNode()},
+			expectedStatusCode: http.StatusOK,
+		},
+		"ok_status_when_only_nodes_with_reboot_paused_need_reboot": {
+			nodes: []runtime.Object{func() *corev1.Node {
+				n := rebootableNode()
+				n.Annotations[constants.AnnotationRebootPaused] = constants.True
+
+				return n
+			}()},
+			expectedStatusCode: http.StatusOK,
+		},
+		"service_unavailable_status_when_some_node_needs_reboot": {
+			nodes:              []runtime.Object{idleNode(), rebootableNode()},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		"service_unavailable_status_when_some_node_runs_before_reboot_checks": {
+			nodes:              []runtime.Object{scheduledForRebootNode()},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		"service_unavailable_status_when_some_node_is_rebooting": {
+			nodes:              []runtime.Object{rebootingNode()},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		"service_unavailable_status_when_some_node_runs_after_reboot_checks": {
+			nodes:              []runtime.Object{finishedRebootingNode()},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		"internal_server_error_status_when_listing_nodes_fails": {
+			nodes:              []runtime.Object{idleNode()},
+			failListing:        true,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, fakeClient := testConfig(testCase.nodes...)
+
+			if testCase.failListing {
+				fakeClient.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, fmt.Errorf(t.Name())
+				})
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/converged", nil)
+			recorder := httptest.NewRecorder()
+
+			kontrollerWithObjects(t, config).HTTPHandler().ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("Expected status code %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func Test_Operator_reconcile_endpoint_responds_with(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		reconcileToken     string
+		method             string
+		authorization      string
+		expectedStatusCode int
+	}{
+		"accepted_status_when_request_carries_configured_token": {
+			reconcileToken:     "test-token",
+			method:             http.MethodPost,
+			authorization:      "Bearer test-token",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		"unauthorized_status_when_request_carries_no_token": {
+			reconcileToken:     "test-token",
+			method:             http.MethodPost,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"unauthorized_status_when_request_carries_wrong_token": {
+			reconcileToken:     "test-token",
+			method:             http.MethodPost,
+			authorization:      "Bearer other-token",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"method_not_allowed_status_for_non_POST_request": {
+			reconcileToken:     "test-token",
+			method:             http.MethodGet,
+			authorization:      "Bearer test-token",
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		"not_found_status_when_no_token_is_configured": {
+			method:             http.MethodPost,
+			authorization:      "Bearer ",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, _ := testConfig(idleNode())
+			config.ReconcileToken = testCase.reconcileToken
+
+			req := httptest.NewRequest(testCase.method, "/reconcile", nil)
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+
+			kontrollerWithObjects(t, config).HTTPHandler().ServeHTTP(recorder, req)
+
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("Expected status code %d, got %d", testCase.expectedStatusCode, recorder.Code)
+			}
+		})
+	}
+}
+
+func Test_Operator_runs_reconciliation_immediately_when_requested_via_reconcile_endpoint(t *testing.T) {
+	t.Parallel()
+
+	config, _ := testConfig(idleNode())
+	config.ReconcileToken = "test-token"
+
+	// Reboot blocker is consulted once per reconciliation cycle, so use it to observe cycles.
+	cycles := make(chan struct{}, 2)
+
+	config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+		cycles <- struct{}{}
+
+		return false, "", nil
+	})
+
+	// Default reconciliation period is long enough, so the second cycle only runs when requested.
+	ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+	t.Cleanup(cancel)
+
+	kontroller := kontrollerWithObjects(t, config)
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, kontroller, stop)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for first reconciliation cycle")
+	case <-cycles:
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/reconcile", nil)
+	req.Header.Set("Authorization", "Bearer "+config.ReconcileToken)
+
+	recorder := httptest.NewRecorder()
+
+	kontroller.HTTPHandler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, recorder.Code)
+	}
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for requested reconciliation cycle")
+	case <-cycles:
+	}
+}
@@ -0,0 +1,80 @@
+package operator
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+// updateMaintenanceState requests a maintenance reboot of a given node if it matches configured
+// maintenance node selector and tracks the maintenance reboot until it completes.
+//
+// Maintenance reboot is requested by setting reboot-needed annotation, so the node goes through
+// regular reboot process, respecting reboot window, maximum number of rebooting nodes and before
+// and after reboot checks. Once requested, maintenance reboot is carried out even if the node
+// stops matching the selector. State is cleared when completed node no longer matches the selector.
+func (k *Kontroller) updateMaintenanceState(node *corev1.Node) {
+	matches := k.maintenanceNodeSelector != nil && k.maintenanceNodeSelector.Matches(labels.Set(node.Labels))
+
+	switch node.Annotations[constants.AnnotationMaintenanceReboot] {
+	case constants.MaintenanceRebootRequested:
+		if rebootCompleted(node) {
+			klog.Infof("Maintenance reboot of node %q has completed", node.Name)
+
+			node.Annotations[constants.AnnotationMaintenanceReboot] = constants.MaintenanceRebootCompleted
+
+			return
+		}
+
+		keepRequestingReboot(node)
+	case constants.MaintenanceRebootCompleted:
+		if !matches {
+			klog.Infof("Node %q no longer matches maintenance node selector, clearing maintenance state", node.Name)
+
+			delete(node.Annotations, constants.AnnotationMaintenanceReboot)
+		}
+	default:
+		if !matches {
+			return
+		}
+
+		klog.Infof("Node %q matches maintenance node selector, requesting maintenance reboot", node.Name)
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+
+		node.Annotations[constants.AnnotationMaintenanceReboot] = constants.MaintenanceRebootRequested
+
+		// Node which is already rebooting will have maintenance reboot completed once it reboots.
+		keepRequestingReboot(node)
+	}
+}
+
+// rebootCompleted checks if reboot requested by the operator for a given node has completed, i.e. node has
+// rebooted and is about to run or runs after-reboot checks.
+func rebootCompleted(node *corev1.Node) bool {
+	return justRebootedSelector.Matches(fields.Set(node.Annotations)) ||
+		node.Labels[constants.LabelAfterReboot] == constants.True
+}
+
+// keepRequestingReboot requests a reboot of a given node unless it has already been approved. Agent resets
+// reboot-needed annotation when it starts, so requested reboot must be requested again until it gets approved.
+func keepRequestingReboot(node *corev1.Node) {
+	if node.Annotations[constants.AnnotationOkToReboot] != constants.True {
+		requestReboot(node)
+	}
+}
+
+// requestReboot marks given node as needing a reboot the same way as the agent does.
+func requestReboot(node *corev1.Node) {
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+
+	node.Annotations[constants.AnnotationRebootNeeded] = constants.True
+	node.Labels[constants.LabelRebootNeeded] = constants.True
+}
@@ -0,0 +1,48 @@
+package operator
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const eventReasonRebootBlockedByMaxVersion = "RebootBlockedByMaxVersion"
+
+// maxVersionExceeded checks if given node is going to reboot into a version greater than the version
+// it is pinned to using max-version annotation. If it is, a message describing it is returned,
+// otherwise an empty string is returned.
+//
+// As pinning is requested explicitly, versions which cannot be compared are also considered exceeding.
+func maxVersionExceeded(node *corev1.Node) string {
+	maxVersion, ok := node.Annotations[constants.AnnotationMaxVersion]
+	if !ok {
+		return ""
+	}
+
+	newVersion := node.Annotations[constants.AnnotationNewVersion]
+
+	pinned, err := semver.ParseTolerant(maxVersion)
+	if err != nil {
+		klog.Warningf("Node %q has invalid max version %q: %v", node.Name, maxVersion, err)
+
+		return fmt.Sprintf("Reboot blocked, as max version %q is not a valid semver", maxVersion)
+	}
+
+	updated, err := semver.ParseTolerant(newVersion)
+	if err != nil {
+		klog.Warningf("Node %q has new version %q which is not a semver: %v", node.Name, newVersion, err)
+
+		return fmt.Sprintf("Reboot blocked, as new version %q cannot be compared with max version %q",
+			newVersion, maxVersion)
+	}
+
+	if !updated.GT(pinned) {
+		return ""
+	}
+
+	return fmt.Sprintf("Reboot blocked, as new version %q is greater than max version %q", newVersion, maxVersion)
+}
@@ -0,0 +1,198 @@
+package operator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const (
+	metricsNamespace = "flatcar_linux_update_operator"
+
+	// Buckets of reboot duration metric start at 1 minute and end at over 2 hours.
+	rebootDurationBucketStart  = 60
+	rebootDurationBucketFactor = 2
+	rebootDurationBucketCount  = 8
+)
+
+// Phases of the update process node can be in.
+const (
+	phaseScheduling   = "scheduling"
+	phaseBeforeReboot = "before-reboot"
+	phaseRebooting    = "rebooting"
+	phaseAfterReboot  = "after-reboot"
+	phasePaused       = "paused"
+)
+
+// nodePhase returns the phase of the update process given node is in. Empty string is returned
+// if node is not in the process of updating.
+func nodePhase(node *corev1.Node) string {
+	switch {
+	case node.Labels[constants.LabelAfterReboot] == constants.True:
+		return phaseAfterReboot
+	case node.Labels[constants.LabelBeforeReboot] == constants.True:
+		return phaseBeforeReboot
+	case node.Annotations[constants.AnnotationOkToReboot] == constants.True:
+		return phaseRebooting
+	case node.Annotations[constants.AnnotationRebootNeeded] == constants.True &&
+		node.Annotations[constants.AnnotationRebootPaused] == constants.True:
+		return phasePaused
+	case rebootableSelector.Matches(fields.Set(node.Annotations)):
+		return phaseScheduling
+	default:
+		return ""
+	}
+}
+
+// updatePhaseTransitionTime sets phase transition time annotation on given node to the current time
+// if node phase differs from given previous phase or if the annotation is missing. The annotation is
+// removed from nodes which are not in the process of updating.
+func updatePhaseTransitionTime(node *corev1.Node, previousPhase string) {
+	phase := nodePhase(node)
+	_, stamped := node.Annotations[constants.AnnotationPhaseTransitionTime]
+
+	switch {
+	case phase == "":
+		delete(node.Annotations, constants.AnnotationPhaseTransitionTime)
+	case phase != previousPhase || !stamped:
+		node.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().UTC().Format(time.RFC3339)
+	}
+}
+
+// nodePhaseState describes since when node is in which phase.
+type nodePhaseState struct {
+	phase string
+	since time.Time
+}
+
+// phaseCollector exposes for how long nodes have been in their current phase of the update process.
+// Durations are calculated when metrics are collected, so they stay accurate between reconciliations.
+type phaseCollector struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	phases map[string]nodePhaseState
+}
+
+func newPhaseCollector() *phaseCollector {
+	return &phaseCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "node_seconds_in_current_phase"),
+			"Number of seconds node has been in its current phase of the update process.",
+			[]string{"node", "phase"},
+			nil,
+		),
+		phases: map[string]nodePhaseState{},
+	}
+}
+
+// Describe implements prometheus.Collector interface.
+func (pc *phaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.desc
+}
+
+// Collect implements prometheus.Collector interface.
+func (pc *phaseCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	now := time.Now()
+
+	for nodeName, state := range pc.phases {
+		ch <- prometheus.MustNewConstMetric(pc.desc, prometheus.GaugeValue, now.Sub(state.since).Seconds(),
+			nodeName, state.phase)
+	}
+}
+
+// update records the current phase of given node based on its labels and annotations.
+func (pc *phaseCollector) update(node *corev1.Node) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	phase := nodePhase(node)
+	if phase == "" {
+		delete(pc.phases, node.Name)
+
+		return
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationPhaseTransitionTime])
+	if err != nil {
+		klog.Warningf("Node %q has malformed phase transition time: %v", node.Name, err)
+
+		delete(pc.phases, node.Name)
+
+		return
+	}
+
+	pc.phases[node.Name] = nodePhaseState{phase: phase, since: since}
+}
+
+// retain forgets phases of all nodes except given ones, e.g. nodes removed from the cluster.
+func (pc *phaseCollector) retain(nodeNames []string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	retained := map[string]struct{}{}
+	for _, nodeName := range nodeNames {
+		retained[nodeName] = struct{}{}
+	}
+
+	for nodeName := range pc.phases {
+		if _, ok := retained[nodeName]; !ok {
+			delete(pc.phases, nodeName)
+		}
+	}
+}
+
+// newRebootDurationHistogram creates a histogram of durations of finished reboots of nodes.
+func newRebootDurationHistogram() prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_reboot_duration_seconds",
+		Help:      "Number of seconds from approving the reboot of a node until it has finished after-reboot checks.",
+		Buckets: prometheus.ExponentialBuckets(rebootDurationBucketStart, rebootDurationBucketFactor,
+			rebootDurationBucketCount),
+	})
+}
+
+// observeRebootDuration observes duration of a finished reboot of a given node, which has been approved at a given
+// time in RFC 3339 format. Reboots which approval time is not known are not observed.
+func (k *Kontroller) observeRebootDuration(nodeName, rebootStartedTime string) {
+	if rebootStartedTime == "" {
+		return
+	}
+
+	started, err := time.Parse(time.RFC3339, rebootStartedTime)
+	if err != nil {
+		klog.Warningf("Node %q has malformed reboot started time: %v", nodeName, err)
+
+		return
+	}
+
+	k.rebootDuration.Observe(time.Since(started).Seconds())
+}
+
+// newMetrics creates operator metrics and registers them using given registerer.
+func newMetrics(registerer prometheus.Registerer) (*phaseCollector, prometheus.Histogram, error) {
+	phases := newPhaseCollector()
+
+	if err := registerer.Register(phases); err != nil {
+		return nil, nil, fmt.Errorf("registering node phase metric: %w", err)
+	}
+
+	rebootDuration := newRebootDurationHistogram()
+
+	if err := registerer.Register(rebootDuration); err != nil {
+		return nil, nil, fmt.Errorf("registering reboot duration metric: %w", err)
+	}
+
+	return phases, rebootDuration, nil
+}
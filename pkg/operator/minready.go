@@ -0,0 +1,92 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const eventReasonRebootBlockedByNotReadyNodes = "RebootBlockedByNotReadyNodes"
+
+// enoughNodesReady checks if at least configured minimum number and minimum percentage of nodes, rounded up,
+// are Ready. If not, an event about it is emitted for each node waiting to be scheduled or approved for rebooting.
+// Event is emitted only once per node while the shortage of Ready nodes lasts, not on every reconciliation.
+//
+// If neither minimum is configured, true is always returned.
+func (k *Kontroller) enoughNodesReady(ctx context.Context) (bool, error) {
+	if k.minReadyNodes == 0 && k.minReadyNodesPercent == 0 {
+		return true, nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return false, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	ready := 0
+
+	for _, node := range nodelist.Items {
+		if nodeReady(node) {
+			ready++
+		}
+	}
+
+	required := k.requiredReadyNodes(len(nodelist.Items))
+	if ready >= required {
+		k.notReadyNodesReported = map[string]struct{}{}
+
+		return true, nil
+	}
+
+	klog.Infof("Found %d Ready nodes (of min %d), not approving new reboots", ready, required)
+
+	waitingNodes := append(k.nodesRequiringReboot(nodelist),
+		k8sutil.FilterNodesByRequirement(nodelist.Items, beforeRebootReq)...)
+
+	reported := map[string]struct{}{}
+
+	for i, node := range waitingNodes {
+		reported[node.Name] = struct{}{}
+
+		if _, ok := k.notReadyNodesReported[node.Name]; ok {
+			continue
+		}
+
+		k.recorder.Eventf(&waitingNodes[i], corev1.EventTypeNormal, eventReasonRebootBlockedByNotReadyNodes,
+			"Reboot blocked, only %d (of min %d) nodes are Ready", ready, required)
+	}
+
+	// Nodes which stopped waiting are forgotten, so they get reported again if they start waiting again.
+	k.notReadyNodesReported = reported
+
+	return false, nil
+}
+
+// requiredReadyNodes returns minimum number of Ready nodes for a cluster with given number of nodes.
+func (k *Kontroller) requiredReadyNodes(nodes int) int {
+	required := nodes * k.minReadyNodesPercent / maxPercent
+	if nodes*k.minReadyNodesPercent%maxPercent != 0 {
+		required++
+	}
+
+	if required < k.minReadyNodes {
+		required = k.minReadyNodes
+	}
+
+	return required
+}
+
+// nodeReady checks if given node has Ready condition with status True.
+func nodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -4,14 +4,18 @@ package operator
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -22,15 +26,26 @@ import (
 
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/window"
 )
 
 const (
 	leaderElectionEventSourceComponent = "update-operator-leader-election"
+	eventSourceComponent               = "flatcar-linux-update-operator"
 	defaultMaxRebootingNodes           = 1
+	defaultNodeUpdateConcurrency       = 10
 	defaultLockType                    = resourcelock.ConfigMapsLeasesResourceLock
 
 	leaderElectionResourceName = "flatcar-linux-update-operator-lock"
 
+	// Name of ConfigMap leader election events are recorded against when they are published to a namespace
+	// other than the namespace of the lock, as events must be in the same namespace as the involved object.
+	leaderElectionEventsConfigMapName = "flatcar-linux-update-operator-leader-election"
+
+	// Standard label and taint keys identifying control-plane nodes.
+	labelNodeRoleControlPlane = "node-role.kubernetes.io/control-plane"
+	labelNodeRoleMaster       = "node-role.kubernetes.io/master"
+
 	// Arbitrarily copied from KVO.
 	defaultLeaderElectionLease = 90 * time.Second
 	// ReconciliationPeriod.
@@ -57,9 +72,11 @@ var (
 	// The update-agent sets constants.AnnotationRebootNeeded to true when
 	// it would like to reboot, and false when it starts up.
 	//
-	// If constants.AnnotationRebootPaused is set to "true", the update-agent will not consider it for rebooting.
+	// If constants.AnnotationRebootPaused or constants.AnnotationCancelReboot is set to "true", the update-agent
+	// will not consider it for rebooting.
 	rebootableSelector = fields.ParseSelectorOrDie(constants.AnnotationRebootNeeded + "==" + constants.True +
 		"," + constants.AnnotationRebootPaused + "!=" + constants.True +
+		"," + constants.AnnotationCancelReboot + "!=" + constants.True +
 		"," + constants.AnnotationOkToReboot + "!=" + constants.True +
 		"," + constants.AnnotationRebootInProgress + "!=" + constants.True)
 
@@ -70,6 +87,19 @@ var (
 		constants.AnnotationRebootNeeded: constants.True,
 	}).AsSelector()
 
+	// awaitingManualUncordonSelector is a selector for the annotation set expected to be on a node, which
+	// has rebooted, but waits for the administrator to verify and uncordon it.
+	awaitingManualUncordonSelector = fields.Set(map[string]string{
+		constants.AnnotationAwaitingManualUncordon: constants.True,
+	}).AsSelector()
+
+	// rebootCancelledSelector is a selector for the annotation set expected to be on a node, which reboot
+	// has been approved, but then cancelled by the administrator before the update-agent started rebooting.
+	rebootCancelledSelector = fields.ParseSelectorOrDie(constants.AnnotationCancelReboot + "==" + constants.True +
+		"," + constants.AnnotationOkToReboot + "==" + constants.True +
+		"," + constants.AnnotationRebootNeeded + "==" + constants.True +
+		"," + constants.AnnotationRebootInProgress + "!=" + constants.True)
+
 	// beforeRebootReq requires a node to be waiting for before reboot checks to complete.
 	beforeRebootReq = k8sutil.NewRequirementOrDie(constants.LabelBeforeReboot, selection.In, []string{constants.True})
 
@@ -81,6 +111,11 @@ var (
 		constants.LabelBeforeReboot, selection.NotIn, []string{constants.True})
 )
 
+// rebootWindow represents a repeating period of time during which nodes can be rebooted.
+type rebootWindow interface {
+	Contains(ref time.Time) bool
+}
+
 // Config configures a Kontroller.
 type Config struct {
 	// Kubernetes client.
@@ -88,15 +123,171 @@ type Config struct {
 	// Annotations to look for before and after reboots.
 	BeforeRebootAnnotations []string
 	AfterRebootAnnotations  []string
+	// Alternatives to annotations above, where checks pass if all annotations from any of the groups are set.
+	BeforeRebootAnnotationGroups [][]string
+	AfterRebootAnnotationGroups  [][]string
 	// Reboot window.
-	RebootWindowStart    string
-	RebootWindowLength   string
+	RebootWindowStart  string
+	RebootWindowLength string
+	// Alternative to RebootWindowStart using cron expression.
+	RebootWindowCron string
+	// IANA time zone name, e.g. "Europe/Berlin", in which reboot window is evaluated. Defaults to UTC.
+	RebootWindowTimezone string
 	Namespace            string
 	LockID               string
+	// Type of the leader election lock, either "configmap" or "lease". Aliases accepted by client-go,
+	// i.e. "configmapsleases" and "leases", are also supported. Defaults to "configmap".
 	LockType             string
 	ReconciliationPeriod time.Duration
 	LeaderElectionLease  time.Duration
-	MaxRebootingNodes    int
+	// How long the leader keeps trying to renew the leadership before giving it up. Must be shorter than
+	// LeaderElectionLease. Defaults to 2/3 of LeaderElectionLease.
+	LeaderElectionRenewDeadline time.Duration
+	// How often leader election actions are retried. Must be shorter than LeaderElectionRenewDeadline
+	// divided by client-go jitter factor of 1.2. Defaults to 1/3 of LeaderElectionLease.
+	LeaderElectionRetryPeriod time.Duration
+	MaxRebootingNodes         int
+	// Maximum number of nodes updated in parallel within a single reconciliation step. Defaults to 10.
+	NodeUpdateConcurrency int
+	// When set, Run returns once no node needs a reboot and no node is in the process of rebooting.
+	// Nodes which are not going to be rebooted, e.g. with reboot cancelled, are ignored.
+	OneShot bool
+	// When set, nodes which agent has not reported a heartbeat within this period are not scheduled
+	// for rebooting, as their agent is most likely not running.
+	AgentHeartbeatTimeout time.Duration
+	// When set, no new reboots are scheduled nor approved while it reports reboots as blocked.
+	RebootBlocker RebootBlocker
+	// Order in which nodes requiring a reboot are scheduled for rebooting. By default, the order
+	// returned by the API server is used.
+	NodeOrdering NodeOrdering
+	// When set, nodes requiring a reboot are scheduled for rebooting in descending order of integer value of
+	// this annotation, on top of NodeOrdering. Nodes without the annotation have priority 0.
+	RebootPriorityAnnotation string
+	// Bearer token required to trigger reconciliation using POST /reconcile HTTP endpoint.
+	// Endpoint is disabled if empty.
+	ReconcileToken string
+	// When set, update_engine status annotations are removed from nodes which are not in the process
+	// of rebooting and which have not reported an update check within this period.
+	StaleAnnotationsTimeout time.Duration
+	// When set, reboot-in-progress annotation is cleared and reboot approval is withdrawn from nodes which
+	// agent has not reported a heartbeat within this period, e.g. because agent pod has been killed while
+	// rebooting. Disabled if zero.
+	StaleRebootInProgressTimeout time.Duration
+	// Label selector of nodes, which should be rebooted regardless of their update state, e.g. for
+	// maintenance purposes. Each matching node is rebooted once. Disabled if empty.
+	MaintenanceNodeSelector string
+	// Name of ConfigMap in Namespace, where scheduling state kept in memory is persisted after every
+	// reconciliation and reloaded from when becoming a leader, so it survives restarts and leader failovers.
+	// Disabled if empty.
+	StateConfigMap string
+	// Label selector, which node must match to be managed by the operator. Nodes which do not match
+	// are ignored entirely. All nodes are managed if empty.
+	NodeSelector string
+	// When set, it is called with an error each time reconciliation fails. It is called from
+	// the reconciliation loop, so it should not block.
+	ReconcileErrorHandler func(error)
+	// When set, node which finished rebooting must stay Ready for this period before new reboots
+	// are scheduled or approved. If it becomes not Ready within the period, further reboots are halted.
+	PostRebootReadyPeriod time.Duration
+	// Node conditions in "Type=Status" format, e.g. "StorageHealthy=True", which node must have before
+	// its reboot is approved. If status is omitted, "True" is required. No conditions are required if empty.
+	RequiredNodeConditions []string
+	// When set, reboot of a node which passed before reboot checks is approved only once the administrator
+	// sets approved-by annotation on it. Until then, the node is marked with pending-approval annotation.
+	RequireManualApproval bool
+	// When set, nodes which update_engine has been reporting one of update error statuses for longer than
+	// this period are marked with update-failed annotation and a warning event is emitted about them.
+	UpdateErrorStatusTimeout time.Duration
+	// update_engine statuses considered as errors. Defaults to "UPDATE_STATUS_REPORTING_ERROR_EVENT".
+	UpdateErrorStatuses []string
+	// When set, nodes which have needed a reboot while having reboot paused for longer than this period
+	// are marked with reboot-paused-too-long annotation and a warning event is emitted about them.
+	RebootPauseTimeout time.Duration
+	// When set, number of nodes rebooting simultaneously is additionally limited by the budget shared with
+	// other Kontrollers, e.g. coordinating reboots of nodes in other clusters.
+	RebootBudget *RebootBudget
+	// Identifies Kontroller within the shared reboot budget. Required when RebootBudget is set.
+	RebootBudgetMember string
+	// When set, control-plane nodes are scheduled for rebooting as well. By default they are skipped.
+	RebootControlPlane bool
+	// Key of annotation, e.g. constants.AnnotationRebootRequested, which when set to "true" on a node or on
+	// a pod running on it, requests a reboot of the node. Reboot requests are ignored if empty.
+	RebootRequestAnnotation string
+	// When set, reboots of nodes which update_engine reports a new version lower than the version they
+	// currently run are not scheduled. Check is skipped for versions which are not valid semver.
+	BlockDowngrades bool
+	// When set, nodes are made unschedulable once they are scheduled for rebooting, so no new pods land
+	// on them while before-reboot checks run. Such nodes are made schedulable again by the operator after
+	// they pass after-reboot checks or if they no longer need a reboot.
+	CordonBeforeReboot bool
+	// When set, nodes waiting for before or after reboot checks are additionally checked each this period
+	// and reconciliation runs as soon as any of them completes the checks. Disabled if zero.
+	HookPollPeriod time.Duration
+	// When set, no new reboots are scheduled nor approved for this period after becoming a leader, so agents
+	// have time to refresh possibly stale status of their nodes. Disabled if zero.
+	WarmupPeriod time.Duration
+	// Values of before and after reboot annotations considered as set, e.g. "True" or "1", for hooks which
+	// do not set annotations to "true". Defaults to "true". Operator itself always sets annotations to "true".
+	AnnotationTrueValues []string
+	// When set, nodes which have needed a reboot for longer than this period are scheduled for rebooting
+	// before other nodes.
+	RebootDeadline time.Duration
+	// When set, nodes which reboot is overdue according to RebootDeadline may be scheduled for rebooting
+	// even if maximum number of rebooting nodes is reached, as long as no more than this number of nodes
+	// would reboot simultaneously. Such reboots are still limited by RebootBudget.
+	OverdueMaxRebootingNodes int
+	// When set, up to this number of nodes may run before-reboot checks at the same time, regardless of
+	// how many nodes are rebooting, while no more than MaxRebootingNodes of them are approved to reboot
+	// at a time. If zero, nodes running before-reboot checks count toward MaxRebootingNodes.
+	MaxPreparingNodes int
+	// When set, leadership is released on shutdown once ongoing reconciliation finishes, so a standby
+	// operator can take over without waiting for the lease to expire. Run waits up to this period for
+	// leadership to be released. Disabled if zero.
+	LeaderHandoffGracePeriod time.Duration
+	// When set, maximum number of rebooting nodes is recomputed each reconciliation from the number of
+	// nodes in the cluster using given formula in "percent=N[,rounding=floor|ceil][,min=N][,max=N]" format,
+	// e.g. "percent=10,rounding=ceil,min=1,max=5". Rounding defaults to "floor" and min defaults to 1.
+	// Mutually exclusive with MaxRebootingNodes.
+	AdaptiveMaxRebootingNodes string
+	// When set, maximum number of rebooting nodes is recomputed each reconciliation as given percentage
+	// of the number of nodes in the cluster, rounded down, but never lower than 1. If MaxRebootingNodes
+	// is also set, the smaller of both values is used. Mutually exclusive with AdaptiveMaxRebootingNodes.
+	MaxRebootingNodesPercent int
+	// When set, no new reboots are scheduled nor approved while fewer than this number of nodes are Ready.
+	MinReadyNodes int
+	// When set, no new reboots are scheduled nor approved while fewer than this percentage of nodes, rounded up,
+	// are Ready. If MinReadyNodes is also set, both minimums must be met.
+	MinReadyNodesPercent int
+	// Namespace where leader election events are published. It is created if it does not exist.
+	// Defaults to Namespace.
+	EventNamespace string
+	// When set, no more than this number of nodes in the same zone are rebooting at a time, on top of
+	// the maximum number of rebooting nodes in the cluster. Disabled if zero.
+	MaxRebootingNodesPerZone int
+	// Label which value identifies the zone of the node for MaxRebootingNodesPerZone. Nodes without the
+	// label are considered to be in the same zone. Defaults to "topology.kubernetes.io/zone".
+	ZoneLabel string
+	// When set, nodes which have not passed after-reboot checks within this period are handled according
+	// to AfterRebootTimeoutPolicy. Disabled if zero.
+	AfterRebootTimeout time.Duration
+	// What happens with nodes which have not passed after-reboot checks within AfterRebootTimeout.
+	// Defaults to AfterRebootTimeoutPolicyFinalize.
+	AfterRebootTimeoutPolicy AfterRebootTimeoutPolicy
+	// When set, nodes which have not passed before-reboot checks within this period are marked with
+	// before-reboot-timed-out annotation and a warning event is emitted for them. Disabled if zero.
+	BeforeRebootTimeout time.Duration
+	// Address to serve /healthz and /readyz endpoints on while Run is running, e.g. ":8081".
+	// Disabled if empty.
+	HealthAddress string
+	// Registerer for operator metrics. If not set, metrics are registered in a new registry.
+	MetricsRegisterer prometheus.Registerer
+	// When set, nodes are evaluated and events are emitted as usual, but nodes are neither labeled for
+	// before nor after reboot checks, nor approved to reboot. Operator only logs what it would do instead.
+	DryRun bool
+	// Name of ConfigMap in Namespace, which, when it has key "paused" set to "true", pauses scheduling and
+	// approving new reboots of all nodes, while nodes which already rebooted are still handled.
+	// Disabled if empty.
+	PauseConfigMap string
 }
 
 // Kontroller implement operator part of FLUO.
@@ -108,38 +299,171 @@ type Kontroller struct {
 	beforeRebootAnnotations []string
 	afterRebootAnnotations  []string
 
+	// Groups of annotations, where all annotations from any of the groups must be set.
+	beforeRebootAnnotationGroups [][]string
+	afterRebootAnnotationGroups  [][]string
+
 	// Namespace is the kubernetes namespace any resources (e.g. locks,
 	// configmaps, agents) should be created and read under.
 	// It will be set to the namespace the operator is running in automatically.
 	namespace string
 
+	// Namespace where leader election events are published.
+	eventNamespace string
+
 	// Reboot window.
-	rebootWindow *Periodic
+	rebootWindow rebootWindow
+	// Location in which reboot window is evaluated.
+	rebootWindowLocation *time.Location
 
 	maxRebootingNodes int
 
+	// Computes maximum number of rebooting nodes from cluster size. Nil if not configured.
+	adaptiveConcurrency *adaptiveConcurrency
+
+	rebootDeadline           time.Duration
+	overdueMaxRebootingNodes int
+
+	maxPreparingNodes int
+
+	minReadyNodes        int
+	minReadyNodesPercent int
+
+	// Names of nodes waiting for a reboot, which an event about not enough Ready nodes has been emitted for
+	// during the current shortage of Ready nodes.
+	notReadyNodesReported map[string]struct{}
+
+	maxRebootingNodesPerZone int
+	zoneLabel                string
+
+	nodeUpdateConcurrency int
+
+	oneShot bool
+
+	agentHeartbeatTimeout time.Duration
+
+	rebootBlocker RebootBlocker
+
+	staleAnnotationsTimeout time.Duration
+
+	staleRebootInProgressTimeout time.Duration
+
+	// Nodes to reboot for maintenance. Nil if no nodes should be rebooted for maintenance.
+	maintenanceNodeSelector labels.Selector
+	// Label selector of nodes managed by the operator. Empty if all nodes are managed.
+	nodeSelector string
+
+	// Name of ConfigMap persisting scheduling state. Empty if not configured.
+	stateConfigMap string
+
+	nodeOrdering             NodeOrdering
+	rebootPriorityAnnotation string
+
+	reconcileToken string
+
+	reconcileErrorHandler func(error)
+
+	postRebootReadyPeriod time.Duration
+
+	// Statuses of node conditions by type, which node must have before its reboot is approved.
+	requiredNodeConditions map[corev1.NodeConditionType]corev1.ConditionStatus
+
+	requireManualApproval bool
+
+	updateErrorStatusTimeout time.Duration
+	rebootPauseTimeout       time.Duration
+
+	afterRebootTimeout       time.Duration
+	afterRebootTimeoutPolicy AfterRebootTimeoutPolicy
+	beforeRebootTimeout      time.Duration
+
+	// Set of update_engine statuses considered as errors.
+	updateErrorStatuses map[string]struct{}
+
+	// Set of values of before and after reboot annotations considered as set.
+	annotationTrueValues map[string]struct{}
+
+	rebootBudget       *RebootBudget
+	rebootBudgetMember string
+
+	rebootControlPlane bool
+
+	rebootRequestAnnotation string
+
+	blockDowngrades bool
+
+	cordonBeforeReboot bool
+
+	dryRun bool
+
+	// Name of ConfigMap pausing reboots of all nodes. Empty if not configured.
+	pauseConfigMap string
+
+	// Tracks since when nodes are in their current update phase.
+	phases *phaseCollector
+
+	// Observes durations of finished reboots.
+	rebootDuration prometheus.Histogram
+
+	// Records events about nodes.
+	recorder record.EventRecorder
+
+	// Requests to run reconciliation immediately, buffered to coalesce multiple requests
+	// received while reconciliation is running.
+	reconcileRequests chan struct{}
+
+	healthAddress string
+	// Closed once the first reconciliation has succeeded after becoming a leader.
+	ready     chan struct{}
+	readyOnce sync.Once
+
 	reconciliationPeriod time.Duration
+	hookPollPeriod       time.Duration
+
+	// No new reboots are scheduled nor approved until this time.
+	warmupPeriod time.Duration
+	warmupUntil  time.Time
+
+	// Operator may have previously stopped in the middle of moving nodes between phases, so until
+	// state of all nodes has been cleaned up once, phase labels not matching node state are removed.
+	phaseLabelsReconciled bool
 
-	leaderElectionLease time.Duration
+	leaderElectionLease         time.Duration
+	leaderElectionRenewDeadline time.Duration
+	leaderElectionRetryPeriod   time.Duration
+	leaderHandoffGracePeriod    time.Duration
 
 	resourceLock resourcelock.Interface
 }
 
 // New initializes a new Kontroller.
+//
+//nolint:funlen // Just many configuration options to process.
 func New(config Config) (*Kontroller, error) {
 	if err := checkConfig(config); err != nil {
 		return nil, fmt.Errorf("check configuration: %w", err)
 	}
 
+	beforeRebootAnnotationGroups := annotationGroups(config.BeforeRebootAnnotations, config.BeforeRebootAnnotationGroups)
+	afterRebootAnnotationGroups := annotationGroups(config.AfterRebootAnnotations, config.AfterRebootAnnotationGroups)
+
+	if len(allAnnotations(beforeRebootAnnotationGroups)) > 0 {
+		klog.Infof("Configured before reboot annotation groups: %v", beforeRebootAnnotationGroups)
+	}
+
+	if len(allAnnotations(afterRebootAnnotationGroups)) > 0 {
+		klog.Infof("Configured after reboot annotation groups: %v", afterRebootAnnotationGroups)
+	}
+
 	resourceLock, err := newResourceLock(config)
 	if err != nil {
 		return nil, fmt.Errorf("creating new resource lock: %w", err)
 	}
 
-	var rebootWindow *Periodic
+	var rebootWindow rebootWindow
 
 	if config.RebootWindowStart != "" && config.RebootWindowLength != "" {
-		rw, err := ParsePeriodic(config.RebootWindowStart, config.RebootWindowLength)
+		rw, err := window.ParsePeriodic(config.RebootWindowStart, config.RebootWindowLength)
 		if err != nil {
 			return nil, fmt.Errorf("parsing reboot window: %w", err)
 		}
@@ -147,6 +471,66 @@ func New(config Config) (*Kontroller, error) {
 		rebootWindow = rw
 	}
 
+	if config.RebootWindowCron != "" && config.RebootWindowLength != "" {
+		rw, err := window.ParseCronPeriodic(config.RebootWindowCron, config.RebootWindowLength)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reboot window cron expression: %w", err)
+		}
+
+		rebootWindow = rw
+	}
+
+	rebootWindowLocation := time.UTC
+
+	if config.RebootWindowTimezone != "" {
+		rebootWindowLocation, err = time.LoadLocation(config.RebootWindowTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("loading reboot window timezone: %w", err)
+		}
+	}
+
+	nodeSelector, err := labels.Parse(config.NodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing node selector: %w", err)
+	}
+
+	var maintenanceNodeSelector labels.Selector
+
+	if config.MaintenanceNodeSelector != "" {
+		maintenanceNodeSelector, err = labels.Parse(config.MaintenanceNodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("parsing maintenance node selector: %w", err)
+		}
+	}
+
+	var adaptiveConcurrency *adaptiveConcurrency
+
+	if config.AdaptiveMaxRebootingNodes != "" {
+		adaptiveConcurrency, err = parseAdaptiveConcurrency(config.AdaptiveMaxRebootingNodes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing adaptive max rebooting nodes: %w", err)
+		}
+	}
+
+	if config.MaxRebootingNodesPercent != 0 {
+		adaptiveConcurrency = percentConcurrency(config.MaxRebootingNodesPercent, config.MaxRebootingNodes)
+	}
+
+	requiredNodeConditions, err := parseRequiredNodeConditions(config.RequiredNodeConditions)
+	if err != nil {
+		return nil, fmt.Errorf("parsing required node conditions: %w", err)
+	}
+
+	metricsRegisterer := config.MetricsRegisterer
+	if metricsRegisterer == nil {
+		metricsRegisterer = prometheus.NewRegistry()
+	}
+
+	phases, rebootDuration, err := newMetrics(metricsRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("creating metrics: %w", err)
+	}
+
 	reconciliationPeriod := config.ReconciliationPeriod
 	if reconciliationPeriod == 0 {
 		reconciliationPeriod = defaultReconciliationPeriod
@@ -157,22 +541,99 @@ func New(config Config) (*Kontroller, error) {
 		leaderElectionLeaseDuration = defaultLeaderElectionLease
 	}
 
+	leaderElectionRenewDeadline, leaderElectionRetryPeriod, err := leaderElectionPeriods(config,
+		leaderElectionLeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+
 	maxRebootingNodes := config.MaxRebootingNodes
 	if maxRebootingNodes == 0 {
 		maxRebootingNodes = defaultMaxRebootingNodes
 	}
 
+	nodeUpdateConcurrency := config.NodeUpdateConcurrency
+	if nodeUpdateConcurrency == 0 {
+		nodeUpdateConcurrency = defaultNodeUpdateConcurrency
+	}
+
+	afterRebootTimeoutPolicy := config.AfterRebootTimeoutPolicy
+	if afterRebootTimeoutPolicy == "" {
+		afterRebootTimeoutPolicy = AfterRebootTimeoutPolicyFinalize
+	}
+
+	zoneLabel := config.ZoneLabel
+	if zoneLabel == "" {
+		zoneLabel = corev1.LabelTopologyZone
+	}
+
 	return &Kontroller{
 		kc:                      config.Client,
 		nc:                      config.Client.CoreV1().Nodes(),
-		beforeRebootAnnotations: config.BeforeRebootAnnotations,
-		afterRebootAnnotations:  config.AfterRebootAnnotations,
-		namespace:               config.Namespace,
-		rebootWindow:            rebootWindow,
-		maxRebootingNodes:       maxRebootingNodes,
-		reconciliationPeriod:    reconciliationPeriod,
-		leaderElectionLease:     leaderElectionLeaseDuration,
-		resourceLock:            resourceLock,
+		beforeRebootAnnotations: allAnnotations(beforeRebootAnnotationGroups),
+		afterRebootAnnotations:  allAnnotations(afterRebootAnnotationGroups),
+
+		beforeRebootAnnotationGroups: beforeRebootAnnotationGroups,
+		afterRebootAnnotationGroups:  afterRebootAnnotationGroups,
+		namespace:                    config.Namespace,
+		eventNamespace:               eventNamespace(config),
+		rebootWindow:                 rebootWindow,
+		rebootWindowLocation:         rebootWindowLocation,
+		maxRebootingNodes:            maxRebootingNodes,
+		adaptiveConcurrency:          adaptiveConcurrency,
+		rebootDeadline:               config.RebootDeadline,
+		overdueMaxRebootingNodes:     config.OverdueMaxRebootingNodes,
+		maxPreparingNodes:            config.MaxPreparingNodes,
+		minReadyNodes:                config.MinReadyNodes,
+		minReadyNodesPercent:         config.MinReadyNodesPercent,
+		notReadyNodesReported:        map[string]struct{}{},
+		maxRebootingNodesPerZone:     config.MaxRebootingNodesPerZone,
+		zoneLabel:                    zoneLabel,
+		nodeUpdateConcurrency:        nodeUpdateConcurrency,
+		oneShot:                      config.OneShot,
+		agentHeartbeatTimeout:        config.AgentHeartbeatTimeout,
+		rebootBlocker:                config.RebootBlocker,
+		staleAnnotationsTimeout:      config.StaleAnnotationsTimeout,
+		staleRebootInProgressTimeout: config.StaleRebootInProgressTimeout,
+		maintenanceNodeSelector:      maintenanceNodeSelector,
+		stateConfigMap:               config.StateConfigMap,
+		nodeSelector:                 nodeSelector.String(),
+		nodeOrdering:                 config.NodeOrdering,
+		rebootPriorityAnnotation:     config.RebootPriorityAnnotation,
+		reconcileToken:               config.ReconcileToken,
+		reconcileErrorHandler:        config.ReconcileErrorHandler,
+		postRebootReadyPeriod:        config.PostRebootReadyPeriod,
+		requiredNodeConditions:       requiredNodeConditions,
+		requireManualApproval:        config.RequireManualApproval,
+		phases:                       phases,
+		rebootDuration:               rebootDuration,
+		rebootBudget:                 config.RebootBudget,
+		rebootBudgetMember:           config.RebootBudgetMember,
+		rebootControlPlane:           config.RebootControlPlane,
+		rebootRequestAnnotation:      config.RebootRequestAnnotation,
+		blockDowngrades:              config.BlockDowngrades,
+		cordonBeforeReboot:           config.CordonBeforeReboot,
+		updateErrorStatusTimeout:     config.UpdateErrorStatusTimeout,
+		rebootPauseTimeout:           config.RebootPauseTimeout,
+		afterRebootTimeout:           config.AfterRebootTimeout,
+		afterRebootTimeoutPolicy:     afterRebootTimeoutPolicy,
+		beforeRebootTimeout:          config.BeforeRebootTimeout,
+		updateErrorStatuses:          updateErrorStatusesSet(config.UpdateErrorStatuses),
+		annotationTrueValues:         annotationTrueValuesSet(config.AnnotationTrueValues),
+		recorder:                     newEventRecorder(config.Client),
+		reconcileRequests:            make(chan struct{}, 1),
+		healthAddress:                config.HealthAddress,
+		ready:                        make(chan struct{}),
+		reconciliationPeriod:         reconciliationPeriod,
+		hookPollPeriod:               config.HookPollPeriod,
+		warmupPeriod:                 config.WarmupPeriod,
+		leaderElectionLease:          leaderElectionLeaseDuration,
+		leaderElectionRenewDeadline:  leaderElectionRenewDeadline,
+		leaderElectionRetryPeriod:    leaderElectionRetryPeriod,
+		leaderHandoffGracePeriod:     config.LeaderHandoffGracePeriod,
+		resourceLock:                 resourceLock,
+		dryRun:                       config.DryRun,
+		pauseConfigMap:               config.PauseConfigMap,
 	}, nil
 }
 
@@ -191,210 +652,1052 @@ func checkConfig(config Config) error {
 		return fmt.Errorf("lockID must not be empty")
 	}
 
-	return nil
-}
+	if len(config.BeforeRebootAnnotations) > 0 && len(config.BeforeRebootAnnotationGroups) > 0 {
+		return fmt.Errorf("before reboot annotations and before reboot annotation groups are mutually exclusive")
+	}
 
-// newResourceLock creates a resource for locking on arbitrary resources
-// used in leader election.
-func newResourceLock(config Config) (resourcelock.Interface, error) {
-	lockType := config.LockType
-	if lockType == "" {
-		lockType = defaultLockType
+	if len(config.AfterRebootAnnotations) > 0 && len(config.AfterRebootAnnotationGroups) > 0 {
+		return fmt.Errorf("after reboot annotations and after reboot annotation groups are mutually exclusive")
 	}
 
-	leaderElectionBroadcaster := record.NewBroadcaster()
-	leaderElectionBroadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{
-		Interface: config.Client.CoreV1().Events(config.Namespace),
-	})
+	if err := checkAnnotationsConfig(config); err != nil {
+		return err
+	}
 
-	return resourcelock.New(
-		lockType,
-		config.Namespace,
-		leaderElectionResourceName,
-		config.Client.CoreV1(),
-		config.Client.CoordinationV1(),
-		resourcelock.ResourceLockConfig{
-			Identity: config.LockID,
-			EventRecorder: leaderElectionBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
-				Component: leaderElectionEventSourceComponent,
-			}),
-		},
-	)
-}
+	if config.RebootWindowStart != "" && config.RebootWindowCron != "" {
+		return fmt.Errorf("reboot window start and reboot window cron expression are mutually exclusive")
+	}
 
-// Run starts the operator reconcilitation process and runs until the stop
-// channel is closed.
-func (k *Kontroller) Run(stop <-chan struct{}) error {
-	errCh := make(chan error, 1)
+	if config.NodeUpdateConcurrency < 0 {
+		return fmt.Errorf("node update concurrency must not be negative")
+	}
 
-	// Leader election is responsible for shutting down the controller, so when leader election
-	// is lost, controller is immediately stopped, as shared context will be cancelled.
-	ctx := k.withLeaderElection(stop, errCh)
+	if config.AgentHeartbeatTimeout < 0 {
+		return fmt.Errorf("agent heartbeat timeout must not be negative")
+	}
 
-	klog.V(5).Info("Starting controller")
+	if config.PostRebootReadyPeriod < 0 {
+		return fmt.Errorf("post reboot ready period must not be negative")
+	}
 
-	// Call the process loop each period, until stop is closed.
-	wait.Until(func() { k.process(ctx) }, k.reconciliationPeriod, ctx.Done())
+	if config.StaleAnnotationsTimeout < 0 {
+		return fmt.Errorf("stale annotations timeout must not be negative")
+	}
 
-	klog.V(5).Info("Stopping controller")
+	if config.StaleRebootInProgressTimeout < 0 {
+		return fmt.Errorf("stale reboot in progress timeout must not be negative")
+	}
 
-	return <-errCh
-}
+	if config.RebootBudget != nil && config.RebootBudgetMember == "" {
+		return fmt.Errorf("reboot budget member must not be empty when reboot budget is configured")
+	}
 
-// withLeaderElection creates a new context which is cancelled when this
-// operator does not hold a lock to operate on the cluster.
-func (k *Kontroller) withLeaderElection(stop <-chan struct{}, errCh chan<- error) context.Context {
-	ctx, cancel := context.WithCancel(context.Background())
+	if config.RebootDeadline < 0 {
+		return fmt.Errorf("reboot deadline must not be negative")
+	}
 
-	go func() {
-		// When user requests to stop the controller, cancel context to interrupt any ongoing operation.
-		<-stop
-		errCh <- nil
+	if config.OverdueMaxRebootingNodes < 0 {
+		return fmt.Errorf("maximum number of rebooting nodes for overdue reboots must not be negative")
+	}
 
-		cancel()
-	}()
+	if config.OverdueMaxRebootingNodes > 0 && config.RebootDeadline == 0 {
+		return fmt.Errorf("reboot deadline must be set when maximum number of rebooting nodes for overdue " +
+			"reboots is configured")
+	}
 
-	waitLeading := make(chan struct{})
+	if config.EventNamespace != "" {
+		if errs := validation.IsDNS1123Label(config.EventNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid event namespace %q: %s", config.EventNamespace, strings.Join(errs, "; "))
+		}
+	}
 
-	go func() {
-		// Lease values inspired by a combination of
-		// https://github.com/kubernetes/kubernetes/blob/f7c07a121d2afadde7aa15b12a9d02858b30a0a9/pkg/apis/componentconfig/v1alpha1/defaults.go#L163-L174
-		// and the KVO values
-		// See also
-		// https://github.com/kubernetes/kubernetes/blob/fc31dae165f406026142f0dd9a98cada8474682a/pkg/client/leaderelection/leaderelection.go#L17
-		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
-			Lock:          k.resourceLock,
-			LeaseDuration: k.leaderElectionLease,
-			//nolint:gomnd // Set renew deadline to 2/3rd of the lease duration to give
-			//             // controller enough time to renew the lease.
-			RenewDeadline: k.leaderElectionLease * 2 / 3,
-			//nolint:gomnd // Retry duration is usually around 1/10th of lease duration,
-			//             // but given low dynamics of FLUO, 1/3rd should also be fine.
-			RetryPeriod: k.leaderElectionLease / 3,
-			Callbacks: leaderelection.LeaderCallbacks{
-				OnStartedLeading: func(ctx context.Context) { // was: func(stop <-chan struct{
-					klog.V(5).Info("Started leading")
-					waitLeading <- struct{}{}
-				},
-				OnStoppedLeading: func() {
-					errCh <- fmt.Errorf("leaderelection lost")
-					cancel()
-				},
-			},
-		})
-	}()
+	if config.AdaptiveMaxRebootingNodes != "" && config.MaxRebootingNodes != 0 {
+		return fmt.Errorf("adaptive max rebooting nodes and max rebooting nodes are mutually exclusive")
+	}
 
-	<-waitLeading
+	if config.MaxRebootingNodesPercent < 0 || config.MaxRebootingNodesPercent > maxPercent {
+		return fmt.Errorf("max rebooting nodes percent must be between 0 and %d, got %d",
+			maxPercent, config.MaxRebootingNodesPercent)
+	}
 
-	return ctx
-}
+	if config.MaxRebootingNodesPercent != 0 && config.AdaptiveMaxRebootingNodes != "" {
+		return fmt.Errorf("max rebooting nodes percent and adaptive max rebooting nodes are mutually exclusive")
+	}
 
-// process performs the reconcilitation to coordinate reboots.
-func (k *Kontroller) process(ctx context.Context) {
-	klog.V(4).Info("Going through a loop cycle")
+	if config.MinReadyNodes < 0 {
+		return fmt.Errorf("minimum number of ready nodes must not be negative")
+	}
 
-	// First make sure that all of our nodes are in a well-defined state with
-	// respect to our annotations and labels, and if they are not, then try to
-	// fix them.
-	klog.V(4).Info("Cleaning up node state")
+	if config.MinReadyNodesPercent < 0 || config.MinReadyNodesPercent > maxPercent {
+		return fmt.Errorf("min ready nodes percent must be between 0 and %d, got %d",
+			maxPercent, config.MinReadyNodesPercent)
+	}
 
-	if err := k.cleanupState(ctx); err != nil {
-		klog.Errorf("Failed to cleanup node state: %v", err)
+	if config.LeaderElectionLease < 0 {
+		return fmt.Errorf("leader election lease duration must not be negative")
+	}
 
-		return
+	if config.LeaderElectionRenewDeadline < 0 {
+		return fmt.Errorf("leader election renew deadline must not be negative")
 	}
 
-	// Find nodes with the after-reboot=true label and check if all provided
-	// annotations are set. if all annotations are set to true then remove the
-	// after-reboot=true label and set reboot-ok=false, telling the agent that
-	// the reboot has completed.
-	klog.V(4).Info("Checking if configured after-reboot annotations are set to true")
+	if config.LeaderElectionRetryPeriod < 0 {
+		return fmt.Errorf("leader election retry period must not be negative")
+	}
 
-	if err := k.checkAfterReboot(ctx); err != nil {
-		klog.Errorf("Failed to check after reboot: %v", err)
+	if config.LeaderHandoffGracePeriod < 0 {
+		return fmt.Errorf("leader handoff grace period must not be negative")
+	}
 
-		return
+	if config.MaxPreparingNodes < 0 {
+		return fmt.Errorf("maximum number of preparing nodes must not be negative")
 	}
 
-	// Find nodes which just rebooted but haven't run after-reboot checks.
-	// remove after-reboot annotations and add the after-reboot=true label.
-	klog.V(4).Info("Labeling rebooted nodes with after-reboot label")
+	if config.MaxRebootingNodesPerZone < 0 {
+		return fmt.Errorf("maximum number of rebooting nodes per zone must not be negative")
+	}
 
-	if err := k.markAfterReboot(ctx); err != nil {
-		klog.Errorf("Failed to update recently rebooted nodes: %v", err)
+	if config.ZoneLabel != "" {
+		if errs := validation.IsQualifiedName(config.ZoneLabel); len(errs) > 0 {
+			return fmt.Errorf("invalid zone label %q: %s", config.ZoneLabel, strings.Join(errs, "; "))
+		}
+	}
 
-		return
+	if config.WarmupPeriod < 0 {
+		return fmt.Errorf("warmup period must not be negative")
 	}
 
-	// Find nodes with the before-reboot=true label and check if all provided
-	// annotations are set. if all annotations are set to true then remove the
-	// before-reboot=true label and set reboot=ok=true, telling the agent it's
-	// time to reboot.
-	klog.V(4).Info("Checking if configured before-reboot annotations are set to true")
+	if config.HookPollPeriod < 0 {
+		return fmt.Errorf("hook poll period must not be negative")
+	}
 
-	if err := k.checkBeforeReboot(ctx); err != nil {
-		klog.Errorf("Failed to check before reboot: %v", err)
+	if config.RebootPauseTimeout < 0 {
+		return fmt.Errorf("reboot pause timeout must not be negative")
+	}
 
-		return
+	if config.UpdateErrorStatusTimeout < 0 {
+		return fmt.Errorf("update error status timeout must not be negative")
 	}
 
-	// Take some number of the rebootable nodes. remove before-reboot
-	// annotations and add the before-reboot=true label.
-	klog.V(4).Info("Labeling rebootable nodes with before-reboot label")
+	for _, status := range config.UpdateErrorStatuses {
+		if status == "" {
+			return fmt.Errorf("update error statuses must not be empty")
+		}
+	}
 
-	if err := k.markBeforeReboot(ctx); err != nil {
-		klog.Errorf("Failed to update rebootable nodes: %v", err)
+	for _, value := range config.AnnotationTrueValues {
+		if value == "" {
+			return fmt.Errorf("annotation true values must not be empty")
+		}
+	}
 
-		return
+	if err := checkNodeOrdering(config.NodeOrdering); err != nil {
+		return fmt.Errorf("checking node ordering: %w", err)
+	}
+
+	if config.RebootPriorityAnnotation != "" {
+		if errs := validation.IsQualifiedName(config.RebootPriorityAnnotation); len(errs) > 0 {
+			return fmt.Errorf("invalid reboot priority annotation %q: %s",
+				config.RebootPriorityAnnotation, strings.Join(errs, "; "))
+		}
+	}
+
+	if config.AfterRebootTimeout < 0 {
+		return fmt.Errorf("after reboot timeout must not be negative")
 	}
+
+	if err := checkAfterRebootTimeoutPolicy(config.AfterRebootTimeoutPolicy); err != nil {
+		return fmt.Errorf("checking after reboot timeout policy: %w", err)
+	}
+
+	if config.BeforeRebootTimeout < 0 {
+		return fmt.Errorf("before reboot timeout must not be negative")
+	}
+
+	return nil
 }
 
-// cleanupState attempts to make sure nodes are in a well-defined state before
-// performing state changes on them.
-// If there is an error getting the list of nodes or updating any of them, an
-// error is immediately returned.
-func (k *Kontroller) cleanupState(ctx context.Context) error {
-	nodelist, err := k.nc.List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("listing nodes: %w", err)
+// checkAnnotationsConfig checks configured before and after reboot annotations.
+func checkAnnotationsConfig(config Config) error {
+	beforeRebootAnnotations := allAnnotations(annotationGroups(config.BeforeRebootAnnotations,
+		config.BeforeRebootAnnotationGroups))
+	if err := checkAnnotations(beforeRebootAnnotations); err != nil {
+		return fmt.Errorf("checking before reboot annotations: %w", err)
 	}
 
-	for _, node := range nodelist.Items {
-		err = k8sutil.UpdateNodeRetry(ctx, k.nc, node.Name, func(node *corev1.Node) {
-			// Make sure that nodes with the before-reboot label actually
-			// still wants to reboot.
-			if _, exists := node.Labels[constants.LabelBeforeReboot]; !exists {
-				return
-			}
+	afterRebootAnnotations := allAnnotations(annotationGroups(config.AfterRebootAnnotations,
+		config.AfterRebootAnnotationGroups))
+	if err := checkAnnotations(afterRebootAnnotations); err != nil {
+		return fmt.Errorf("checking after reboot annotations: %w", err)
+	}
 
-			if rebootableSelector.Matches(fields.Set(node.Annotations)) {
-				return
-			}
+	if config.RebootRequestAnnotation != "" {
+		if err := checkAnnotations([]string{config.RebootRequestAnnotation}); err != nil {
+			return fmt.Errorf("checking reboot request annotation: %w", err)
+		}
+	}
 
-			klog.Warningf("Node %q no longer wanted to reboot while we were trying to label it so: %v",
-				node.Name, node.Annotations)
-			delete(node.Labels, constants.LabelBeforeReboot)
-			for _, annotation := range k.beforeRebootAnnotations {
-				delete(node.Annotations, annotation)
-			}
-		})
-		if err != nil {
-			return fmt.Errorf("cleaning up node %q: %w", node.Name, err)
+	return nil
+}
+
+// checkAnnotations checks if given annotations are syntactically valid Kubernetes annotation keys.
+func checkAnnotations(annotations []string) error {
+	for _, annotation := range annotations {
+		// Annotation keys are validated the same way as by the API server.
+		if errs := validation.IsQualifiedName(strings.ToLower(annotation)); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation key %q: %s", annotation, strings.Join(errs, "; "))
 		}
 	}
 
 	return nil
 }
 
-type checkRebootOptions struct {
-	req         *labels.Requirement
-	annotations []string
-	label       string
-	okToReboot  string
+// annotationGroups returns given annotation groups or a single group built from given annotations,
+// if no groups are given.
+func annotationGroups(annotations []string, groups [][]string) [][]string {
+	if len(groups) > 0 {
+		return groups
+	}
+
+	return [][]string{annotations}
 }
 
-// checkReboot gets all nodes with a given requirement and checks if all of the given annotations are set to true.
-//
+// allAnnotations returns unique annotations from all given groups.
+func allAnnotations(groups [][]string) []string {
+	annotations := []string{}
+	seen := map[string]struct{}{}
+
+	for _, group := range groups {
+		for _, annotation := range group {
+			if _, ok := seen[annotation]; ok {
+				continue
+			}
+
+			seen[annotation] = struct{}{}
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	return annotations
+}
+
+// leaderElectionPeriods returns configured leader election renew deadline and retry period, defaulting
+// them based on given lease duration. An error is returned if they would be rejected by leader election.
+func leaderElectionPeriods(config Config, leaseDuration time.Duration) (time.Duration, time.Duration, error) {
+	renewDeadline := config.LeaderElectionRenewDeadline
+	if renewDeadline == 0 {
+		//nolint:gomnd // Set renew deadline to 2/3rd of the lease duration to give
+		//             // controller enough time to renew the lease.
+		renewDeadline = leaseDuration * 2 / 3
+	}
+
+	retryPeriod := config.LeaderElectionRetryPeriod
+	if retryPeriod == 0 {
+		//nolint:gomnd // Retry duration is usually around 1/10th of lease duration,
+		//             // but given low dynamics of FLUO, 1/3rd should also be fine.
+		retryPeriod = leaseDuration / 3
+	}
+
+	if renewDeadline >= leaseDuration {
+		return 0, 0, fmt.Errorf("leader election renew deadline %v must be shorter than lease duration %v",
+			renewDeadline, leaseDuration)
+	}
+
+	if renewDeadline <= time.Duration(leaderelection.JitterFactor*float64(retryPeriod)) {
+		return 0, 0, fmt.Errorf("leader election renew deadline %v must be longer than retry period %v "+
+			"multiplied by jitter factor %v", renewDeadline, retryPeriod, leaderelection.JitterFactor)
+	}
+
+	return renewDeadline, retryPeriod, nil
+}
+
+// lockTypes maps supported leader election lock types, including their aliases, to resource lock types.
+//
+// Plain ConfigMap lock has been removed from client-go, so ConfigMap lock also maintains a Lease object,
+// which allows migrating to the Lease lock without losing the leadership.
+var lockTypes = map[string]string{
+	"":                 defaultLockType,
+	"configmap":        resourcelock.ConfigMapsLeasesResourceLock,
+	"configmaps":       resourcelock.ConfigMapsLeasesResourceLock,
+	"configmapsleases": resourcelock.ConfigMapsLeasesResourceLock,
+	"lease":            resourcelock.LeasesResourceLock,
+	"leases":           resourcelock.LeasesResourceLock,
+}
+
+// newResourceLock creates a resource for locking on arbitrary resources
+// used in leader election.
+func newResourceLock(config Config) (resourcelock.Interface, error) {
+	lockType, ok := lockTypes[config.LockType]
+	if !ok {
+		// Let resource lock report unsupported lock type.
+		lockType = config.LockType
+	}
+
+	var eventSink record.EventSink = &corev1client.EventSinkImpl{
+		Interface: config.Client.CoreV1().Events(eventNamespace(config)),
+	}
+
+	if eventNamespace(config) != config.Namespace {
+		eventSink = &namespacedEventSink{
+			EventSink: eventSink,
+			namespace: eventNamespace(config),
+		}
+	}
+
+	leaderElectionBroadcaster := record.NewBroadcaster()
+	leaderElectionBroadcaster.StartRecordingToSink(eventSink)
+
+	return resourcelock.New(
+		lockType,
+		config.Namespace,
+		leaderElectionResourceName,
+		config.Client.CoreV1(),
+		config.Client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity: config.LockID,
+			EventRecorder: leaderElectionBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+				Component: leaderElectionEventSourceComponent,
+			}),
+		},
+	)
+}
+
+// eventNamespace returns namespace where leader election events should be published.
+func eventNamespace(config Config) string {
+	if config.EventNamespace == "" {
+		return config.Namespace
+	}
+
+	return config.EventNamespace
+}
+
+// namespacedEventSink publishes events to a given namespace, regardless of the namespace of the involved object.
+// As API server rejects events in a namespace other than the namespace of the involved object, events are
+// recorded against leader election events ConfigMap in the given namespace instead.
+type namespacedEventSink struct {
+	record.EventSink
+
+	namespace string
+}
+
+// Create implements record.EventSink interface.
+func (s *namespacedEventSink) Create(event *corev1.Event) (*corev1.Event, error) {
+	return s.EventSink.Create(s.inNamespace(event))
+}
+
+// Update implements record.EventSink interface.
+func (s *namespacedEventSink) Update(event *corev1.Event) (*corev1.Event, error) {
+	return s.EventSink.Update(s.inNamespace(event))
+}
+
+// Patch implements record.EventSink interface.
+func (s *namespacedEventSink) Patch(event *corev1.Event, data []byte) (*corev1.Event, error) {
+	return s.EventSink.Patch(s.inNamespace(event), data)
+}
+
+func (s *namespacedEventSink) inNamespace(event *corev1.Event) *corev1.Event {
+	eventCopy := event.DeepCopy()
+	eventCopy.Namespace = s.namespace
+	eventCopy.InvolvedObject = corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  s.namespace,
+		Name:       leaderElectionEventsConfigMapName,
+	}
+
+	return eventCopy
+}
+
+// ensureEventNamespace creates namespace where leader election events are published and ConfigMap
+// events are recorded against if they do not exist yet.
+func (k *Kontroller) ensureEventNamespace(ctx context.Context) error {
+	if k.eventNamespace == k.namespace {
+		return nil
+	}
+
+	if err := k.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	return k.ensureLeaderElectionEventsConfigMap(ctx)
+}
+
+// ensureNamespace creates namespace where leader election events are published if it does not exist yet.
+func (k *Kontroller) ensureNamespace(ctx context.Context) error {
+	_, err := k.kc.CoreV1().Namespaces().Get(ctx, k.eventNamespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("getting namespace %q: %w", k.eventNamespace, err)
+	}
+
+	klog.Infof("Creating namespace %q for leader election events", k.eventNamespace)
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: k.eventNamespace,
+		},
+	}
+
+	if _, err := k.kc.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil &&
+		!apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace %q: %w", k.eventNamespace, err)
+	}
+
+	return nil
+}
+
+// ensureLeaderElectionEventsConfigMap creates ConfigMap leader election events are recorded against
+// if it does not exist yet.
+func (k *Kontroller) ensureLeaderElectionEventsConfigMap(ctx context.Context) error {
+	configMaps := k.kc.CoreV1().ConfigMaps(k.eventNamespace)
+
+	_, err := configMaps.Get(ctx, leaderElectionEventsConfigMapName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("getting ConfigMap %q: %w", leaderElectionEventsConfigMapName, err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaderElectionEventsConfigMapName,
+			Namespace: k.eventNamespace,
+		},
+		Data: map[string]string{
+			"lock": k.namespace + "/" + leaderElectionResourceName,
+		},
+	}
+
+	if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil &&
+		!apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating ConfigMap %q: %w", leaderElectionEventsConfigMapName, err)
+	}
+
+	return nil
+}
+
+// newEventRecorder creates event recorder publishing events about cluster-scoped objects like nodes.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{
+		Interface: client.CoreV1().Events(""),
+	})
+
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: eventSourceComponent,
+	})
+}
+
+// Run starts the operator reconcilitation process and runs until the stop
+// channel is closed.
+//
+// In one-shot mode, Run also returns once all nodes have converged.
+func (k *Kontroller) Run(stop <-chan struct{}) error {
+	errCh := make(chan error, 1)
+
+	// In one-shot mode, convergence of all nodes stops the controller the same way as user requested shutdown.
+	converged := make(chan struct{})
+
+	var convergedOnce sync.Once
+
+	if k.healthAddress != "" {
+		stopHealthServer, err := k.startHealthServer()
+		if err != nil {
+			return fmt.Errorf("starting health server: %w", err)
+		}
+
+		defer stopHealthServer()
+	}
+
+	if err := k.ensureEventNamespace(context.Background()); err != nil {
+		return fmt.Errorf("ensuring event namespace exists: %w", err)
+	}
+
+	// Nodes of a stopped Kontroller must not occupy the shared reboot budget of other members.
+	if k.rebootBudget != nil {
+		defer k.rebootBudget.release(k.rebootBudgetMember)
+	}
+
+	// Leader election is responsible for shutting down the controller, so when leader election
+	// is lost, controller is immediately stopped, as shared context will be cancelled.
+	ctx, releaseLeadership := k.withLeaderElection(anyClosed(stop, converged), errCh)
+
+	klog.V(5).Info("Starting controller")
+
+	// Previous leader may have stopped in the middle of a rollout, so continue from its state. Starting
+	// with empty state is safe, so failure to load it does not prevent reconciliation.
+	if err := k.loadState(ctx); err != nil {
+		klog.Errorf("Failed to load state: %v", err)
+	}
+
+	if k.warmupPeriod > 0 {
+		klog.Infof("Not approving new reboots for warmup period of %v", k.warmupPeriod)
+
+		k.warmupUntil = time.Now().Add(k.warmupPeriod)
+
+		// Do not wait for the next reconciliation period once warmup is over.
+		time.AfterFunc(k.warmupPeriod, k.requestReconcile)
+	}
+
+	if k.hookPollPeriod > 0 {
+		go k.pollHooksUntil(ctx)
+	}
+
+	// Call the process loop each period or when requested, until stop is closed.
+	k.reconcileUntil(ctx, func() {
+		if err := k.process(ctx); err != nil {
+			klog.Errorf("Failed reconciliation: %v", err)
+
+			if k.reconcileErrorHandler != nil {
+				k.reconcileErrorHandler(err)
+			}
+		} else {
+			k.readyOnce.Do(func() { close(k.ready) })
+		}
+
+		// State is saved even if reconciliation failed, as it might have changed before the failed step.
+		if err := k.saveState(ctx); err != nil {
+			klog.Errorf("Failed to save state: %v", err)
+		}
+
+		if k.oneShot && k.converged(ctx) {
+			klog.Info("All nodes have converged, stopping controller")
+
+			convergedOnce.Do(func() { close(converged) })
+		}
+	})
+
+	klog.V(5).Info("Stopping controller")
+
+	releaseLeadership()
+
+	return <-errCh
+}
+
+// reconcileUntil runs given function immediately and then each reconciliation period after the previous
+// run has finished or when reconciliation is requested, until given context is cancelled.
+func (k *Kontroller) reconcileUntil(ctx context.Context, reconcile func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		reconcile()
+
+		timer := time.NewTimer(k.reconciliationPeriod)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-k.reconcileRequests:
+			klog.Info("Reconciliation requested")
+
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// requestReconcile requests reconciliation to run immediately. If reconciliation has already been
+// requested, but not started yet, the request is coalesced with it.
+func (k *Kontroller) requestReconcile() {
+	select {
+	case k.reconcileRequests <- struct{}{}:
+	default:
+	}
+}
+
+// anyClosed returns a channel which gets closed when any of given channels gets closed.
+func anyClosed(a, b <-chan struct{}) <-chan struct{} {
+	closed := make(chan struct{})
+
+	go func() {
+		select {
+		case <-a:
+		case <-b:
+		}
+
+		close(closed)
+	}()
+
+	return closed
+}
+
+// withLeaderElection creates a new context which is cancelled when this
+// operator does not hold a lock to operate on the cluster. Returned function
+// releases the lock if leader handoff grace period is configured and must be
+// called once the operator no longer operates on the cluster.
+func (k *Kontroller) withLeaderElection(stop <-chan struct{}, errCh chan<- error) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Lease must not be released while reconciliation is still running, so when releasing leadership,
+	// leader election gets stopped only once reconciliation has stopped.
+	releaseOnStop := k.leaderHandoffGracePeriod > 0
+	leaderElectionCtx, stopLeaderElection := ctx, context.CancelFunc(func() {})
+
+	if releaseOnStop {
+		leaderElectionCtx, stopLeaderElection = context.WithCancel(context.Background())
+	}
+
+	leaderElectionStopped := make(chan struct{})
+
+	go func() {
+		// When user requests to stop the controller, cancel context to interrupt any ongoing operation.
+		<-stop
+		errCh <- nil
+
+		cancel()
+	}()
+
+	waitLeading := make(chan struct{})
+
+	go func() {
+		defer close(leaderElectionStopped)
+
+		// Lease values inspired by a combination of
+		// https://github.com/kubernetes/kubernetes/blob/f7c07a121d2afadde7aa15b12a9d02858b30a0a9/pkg/apis/componentconfig/v1alpha1/defaults.go#L163-L174
+		// and the KVO values
+		// See also
+		// https://github.com/kubernetes/kubernetes/blob/fc31dae165f406026142f0dd9a98cada8474682a/pkg/client/leaderelection/leaderelection.go#L17
+		leaderelection.RunOrDie(leaderElectionCtx, leaderelection.LeaderElectionConfig{
+			Lock:            k.resourceLock,
+			ReleaseOnCancel: releaseOnStop,
+			LeaseDuration:   k.leaderElectionLease,
+			RenewDeadline:   k.leaderElectionRenewDeadline,
+			RetryPeriod:     k.leaderElectionRetryPeriod,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) { // was: func(stop <-chan struct{
+					klog.V(5).Info("Started leading")
+					waitLeading <- struct{}{}
+				},
+				OnStoppedLeading: func() {
+					// Leadership is expected to stop when user requests to stop the controller.
+					select {
+					case <-stop:
+					default:
+						errCh <- fmt.Errorf("leaderelection lost")
+					}
+
+					cancel()
+				},
+			},
+		})
+	}()
+
+	<-waitLeading
+
+	releaseLeadership := func() {
+		if !releaseOnStop {
+			return
+		}
+
+		stopLeaderElection()
+
+		select {
+		case <-leaderElectionStopped:
+			klog.Info("Released leadership")
+		case <-time.After(k.leaderHandoffGracePeriod):
+			klog.Warningf("Failed to release leadership within %v, it will be taken over once lease expires",
+				k.leaderHandoffGracePeriod)
+		}
+	}
+
+	return ctx, releaseLeadership
+}
+
+// process performs the reconcilitation to coordinate reboots.
+//
+// Returned error means that the reconciliation has stopped at the failed step.
+//
+//nolint:funlen // Just many reconciliation steps.
+func (k *Kontroller) process(ctx context.Context) error {
+	klog.V(4).Info("Going through a loop cycle")
+
+	// Report rebooting nodes to the shared reboot budget before any other step, as reconciliation
+	// may stop before scheduling new reboots, e.g. when reboots are deferred, and other members
+	// should not be limited by nodes which have already finished rebooting.
+	if err := k.reportRebootingNodes(ctx); err != nil {
+		return fmt.Errorf("reporting rebooting nodes to reboot budget: %w", err)
+	}
+
+	// First make sure that all of our nodes are in a well-defined state with
+	// respect to our annotations and labels, and if they are not, then try to
+	// fix them.
+	klog.V(4).Info("Cleaning up node state")
+
+	if err := k.cleanupState(ctx); err != nil {
+		return fmt.Errorf("cleaning up node state: %w", err)
+	}
+
+	// Pruning stale annotations is not essential for coordinating reboots, so failure
+	// should not prevent reboots from being processed.
+	if err := k.pruneStaleAnnotations(ctx); err != nil {
+		klog.Errorf("Failed to prune stale annotations: %v", err)
+	}
+
+	// Clear reboot-in-progress state left behind by agents which died in the middle of rebooting,
+	// so such nodes do not occupy rebooting capacity forever.
+	if err := k.clearStaleRebootInProgress(ctx); err != nil {
+		return fmt.Errorf("clearing stale reboot-in-progress annotations: %w", err)
+	}
+
+	// Find nodes with the after-reboot=true label and check if all provided
+	// annotations are set. if all annotations are set to true then remove the
+	// after-reboot=true label and set reboot-ok=false, telling the agent that
+	// the reboot has completed.
+	klog.V(4).Info("Checking if configured after-reboot annotations are set to true")
+
+	if err := k.checkAfterReboot(ctx); err != nil {
+		return fmt.Errorf("checking after reboot: %w", err)
+	}
+
+	// Stop waiting for after-reboot checks which have not passed in time.
+	if err := k.checkAfterRebootTimeouts(ctx); err != nil {
+		return fmt.Errorf("checking after reboot timeouts: %w", err)
+	}
+
+	// Mark nodes which have not passed before-reboot checks in time, even if no new reboots are
+	// approved right now, so stuck before-reboot hooks do not go unnoticed.
+	if err := k.checkBeforeRebootTimeouts(ctx); err != nil {
+		return fmt.Errorf("checking before reboot timeouts: %w", err)
+	}
+
+	// Find nodes which just rebooted but haven't run after-reboot checks.
+	// remove after-reboot annotations and add the after-reboot=true label.
+	klog.V(4).Info("Labeling rebooted nodes with after-reboot label")
+
+	if err := k.markAfterReboot(ctx); err != nil {
+		return fmt.Errorf("updating recently rebooted nodes: %w", err)
+	}
+
+	// Nodes which already rebooted are handled above, but no new reboots should
+	// be scheduled nor approved while warming up or while reboots are blocked or paused.
+	if time.Now().Before(k.warmupUntil) {
+		klog.V(4).Info("Warming up, not approving new reboots")
+
+		return k.deferReboots(ctx, constants.RebootDeferredReasonWarmup)
+	}
+
+	if k.rebootsBlocked(ctx) {
+		return k.deferReboots(ctx, constants.RebootDeferredReasonBlocked)
+	}
+
+	if k.rebootsPaused(ctx) {
+		return k.deferReboots(ctx, constants.RebootDeferredReasonPaused)
+	}
+
+	// Neither while too few nodes are Ready.
+	enoughReady, err := k.enoughNodesReady(ctx)
+	if err != nil {
+		return fmt.Errorf("checking ready nodes: %w", err)
+	}
+
+	if !enoughReady {
+		return k.deferReboots(ctx, constants.RebootDeferredReasonNotEnoughReady)
+	}
+
+	// Neither while recently rebooted nodes are being verified to stay Ready.
+	pending, err := k.postRebootVerificationPending(ctx)
+	if err != nil {
+		return fmt.Errorf("verifying recently rebooted nodes: %w", err)
+	}
+
+	if pending {
+		return k.deferReboots(ctx, constants.RebootDeferredReasonPostRebootVerification)
+	}
+
+	// Find nodes with the before-reboot=true label and check if all provided
+	// annotations are set. if all annotations are set to true then remove the
+	// before-reboot=true label and set reboot=ok=true, telling the agent it's
+	// time to reboot.
+	klog.V(4).Info("Checking if configured before-reboot annotations are set to true")
+
+	if err := k.checkBeforeReboot(ctx); err != nil {
+		return fmt.Errorf("checking before reboot: %w", err)
+	}
+
+	// Take some number of the rebootable nodes. remove before-reboot
+	// annotations and add the before-reboot=true label.
+	klog.V(4).Info("Labeling rebootable nodes with before-reboot label")
+
+	if err := k.markBeforeReboot(ctx); err != nil {
+		return fmt.Errorf("updating rebootable nodes: %w", err)
+	}
+
+	return nil
+}
+
+// rebootsBlocked checks if configured reboot blocker blocks reboots. If the check fails,
+// reboots are considered blocked to be on the safe side.
+//
+// If reboot blocker is not configured, false is always returned.
+func (k *Kontroller) rebootsBlocked(ctx context.Context) bool {
+	if k.rebootBlocker == nil {
+		return false
+	}
+
+	blocked, reason, err := k.rebootBlocker.RebootBlocked(ctx)
+	if err != nil {
+		klog.Errorf("Failed checking if reboots are blocked, not approving new reboots: %v", err)
+
+		return true
+	}
+
+	if blocked {
+		klog.Infof("Reboots are blocked, not approving new reboots: %s", reason)
+	}
+
+	return blocked
+}
+
+// converged checks if all nodes have converged. Errors are logged and treated as not converged.
+func (k *Kontroller) converged(ctx context.Context) bool {
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		klog.Errorf("Failed listing nodes to check convergence: %v", err)
+
+		return false
+	}
+
+	return k.nodesConverged(nodelist.Items)
+}
+
+// nodesConverged returns true if none of given nodes need a reboot and none of them is in the
+// process of rebooting. Nodes with reboot paused or cancelled, exempt control-plane nodes and nodes
+// with agent not running are ignored, as operator will not reboot them. So are nodes awaiting manual
+// uncordon, as they wait for a human rather than for the operator.
+func (k *Kontroller) nodesConverged(nodes []corev1.Node) bool {
+	now := time.Now()
+
+	for _, node := range nodes {
+		if k.notRebootedByOperator(node) {
+			continue
+		}
+
+		if node.Annotations[constants.AnnotationRebootNeeded] == constants.True &&
+			node.Annotations[constants.AnnotationRebootPaused] != constants.True &&
+			k.agentAlive(node, now) {
+			return false
+		}
+
+		if node.Annotations[constants.AnnotationOkToReboot] == constants.True ||
+			node.Annotations[constants.AnnotationRebootInProgress] == constants.True {
+			return false
+		}
+
+		if node.Labels[constants.LabelBeforeReboot] == constants.True ||
+			node.Labels[constants.LabelAfterReboot] == constants.True {
+			return false
+		}
+	}
+
+	return true
+}
+
+// notRebootedByOperator checks if a given node is not going to be rebooted by the operator, either
+// because it is exempt from reboots, or because its reboot has been cancelled before it started.
+func (k *Kontroller) notRebootedByOperator(node corev1.Node) bool {
+	if node.Annotations[constants.AnnotationAwaitingManualUncordon] == constants.True {
+		return true
+	}
+
+	if !k.rebootControlPlane && controlPlaneNode(node) {
+		return true
+	}
+
+	return node.Annotations[constants.AnnotationCancelReboot] == constants.True &&
+		node.Annotations[constants.AnnotationRebootInProgress] != constants.True
+}
+
+// cleanupState attempts to make sure nodes are in a well-defined state before
+// performing state changes on them.
+// On the first successful run, it also removes after-reboot labels from nodes
+// which are not waiting for after-reboot checks.
+// If there is an error getting the list of nodes or updating any of them, an
+// error is immediately returned.
+func (k *Kontroller) cleanupState(ctx context.Context) error {
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	nodeNames := make([]string, 0, len(nodelist.Items))
+	for _, node := range nodelist.Items {
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	k.phases.retain(nodeNames)
+
+	rebootRequestingPods, err := k.rebootRequestingPods(ctx)
+	if err != nil {
+		return fmt.Errorf("getting pods requesting reboot: %w", err)
+	}
+
+	now := time.Now()
+
+	err = k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		updatedNode := &corev1.Node{}
+		updateFailed := false
+		pausedTooLong := false
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			previousPhase := nodePhase(node)
+
+			// Withdraw reboot approval from nodes which reboot has been cancelled before
+			// the agent started rebooting.
+			if rebootCancelledSelector.Matches(fields.Set(node.Annotations)) {
+				klog.Infof("Reboot of node %q has been cancelled, withdrawing reboot approval", node.Name)
+				node.Annotations[constants.AnnotationOkToReboot] = constants.False
+
+				makeSchedulable(node)
+			}
+
+			k.updateMaintenanceState(node)
+			k.updateRebootRequestState(node, rebootRequestingPods[node.Name], now)
+			k.cleanupBeforeRebootState(node)
+
+			if !k.phaseLabelsReconciled {
+				k.cleanupAfterRebootState(node)
+			}
+
+			updateFailed = k.updateErrorStatusState(node, now)
+
+			updatePhaseTransitionTime(node, previousPhase)
+
+			pausedTooLong = k.updateRebootPauseState(node, now)
+
+			clearAfterRebootTimeoutState(node)
+			clearBeforeRebootTimeoutState(node)
+
+			updatedNode = node
+		})
+		if err != nil {
+			return fmt.Errorf("cleaning up node %q: %w", nodeName, err)
+		}
+
+		k.phases.update(updatedNode)
+
+		if updateFailed {
+			k.emitUpdateFailedEvent(updatedNode)
+		}
+
+		if pausedTooLong {
+			k.emitRebootPausedTooLongEvent(updatedNode)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	k.phaseLabelsReconciled = true
+
+	return nil
+}
+
+// afterRebootStateValid returns true if given node may run after-reboot checks, which means its reboot
+// has been approved and agent is not rebooting it anymore.
+func afterRebootStateValid(node *corev1.Node) bool {
+	return node.Annotations[constants.AnnotationOkToReboot] == constants.True &&
+		node.Annotations[constants.AnnotationRebootInProgress] != constants.True
+}
+
+// cleanupAfterRebootState makes sure that node with the after-reboot label is actually waiting for after-reboot
+// checks. If not, after-reboot label and annotations are removed from it. If its reboot is no longer approved,
+// it is also made schedulable again.
+func (k *Kontroller) cleanupAfterRebootState(node *corev1.Node) {
+	if _, exists := node.Labels[constants.LabelAfterReboot]; !exists {
+		return
+	}
+
+	if afterRebootStateValid(node) {
+		return
+	}
+
+	klog.Warningf("Node %q is labeled for after reboot checks, but it is not waiting for them: %v",
+		node.Name, node.Annotations)
+	delete(node.Labels, constants.LabelAfterReboot)
+
+	for _, annotation := range k.afterRebootAnnotations {
+		delete(node.Annotations, annotation)
+	}
+
+	if node.Annotations[constants.AnnotationOkToReboot] != constants.True {
+		makeSchedulable(node)
+	}
+}
+
+// cleanupBeforeRebootState makes sure that node with the before-reboot label actually still wants to reboot.
+// If not, before-reboot label and annotations are removed from it.
+func (k *Kontroller) cleanupBeforeRebootState(node *corev1.Node) {
+	if _, exists := node.Labels[constants.LabelBeforeReboot]; !exists {
+		return
+	}
+
+	if rebootableSelector.Matches(fields.Set(node.Annotations)) {
+		return
+	}
+
+	klog.Warningf("Node %q no longer wanted to reboot while we were trying to label it so: %v",
+		node.Name, node.Annotations)
+	delete(node.Labels, constants.LabelBeforeReboot)
+	for _, annotation := range k.beforeRebootAnnotations {
+		delete(node.Annotations, annotation)
+	}
+
+	delete(node.Annotations, constants.AnnotationPendingApproval)
+	delete(node.Annotations, constants.AnnotationApprovedBy)
+	delete(node.Annotations, constants.AnnotationRebootEscalated)
+
+	makeSchedulable(node)
+}
+
+type checkRebootOptions struct {
+	req              *labels.Requirement
+	annotations      []string
+	annotationGroups [][]string
+	label            string
+	okToReboot       string
+
+	// When set, reboot finished time annotation is set on updated nodes.
+	setRebootFinishedTime bool
+
+	// When set, reboot started time annotation is set on updated nodes and reboot completed time
+	// annotation is removed from them.
+	setRebootStartedTime bool
+
+	// When set, reboot completed time annotation is set on updated nodes and reboot duration is observed.
+	setRebootCompletedTime bool
+
+	// When set, updated nodes made unschedulable by operator are made schedulable again.
+	makeSchedulable bool
+
+	// When set, nodes not meeting required node conditions are not updated.
+	checkNodeConditions bool
+
+	// When set, only nodes with approved-by annotation are updated, other nodes are marked as pending approval.
+	requireManualApproval bool
+
+	// When set, version updated nodes run is recorded, so it can be checked after the reboot.
+	recordVersionBeforeReboot bool
+
+	// When set, no more nodes are updated than remaining rebooting capacity allows.
+	limitToRebootingCapacity bool
+}
+
+// checkReboot gets all nodes with a given requirement and checks if all of the annotations from any of
+// the given annotation groups are set to true.
+//
 // If they are, it deletes given annotations and label, then sets ok-to-reboot annotation to either true or false,
 // depending on the given parameter.
 //
@@ -405,72 +1708,198 @@ type checkRebootOptions struct {
 // If there is an error getting the list of nodes or updating any of them, an
 // error is immediately returned.
 func (k *Kontroller) checkReboot(ctx context.Context, opt checkRebootOptions) error {
-	nodelist, err := k.nc.List(ctx, metav1.ListOptions{})
+	nodelist, err := k.listNodes(ctx, "")
 	if err != nil {
 		return fmt.Errorf("listing nodes: %w", err)
 	}
 
-	nodes := k8sutil.FilterNodesByRequirement(nodelist.Items, opt.req)
+	nodes := k8sutil.FilterNodesByRequirement(nodelist.Items, opt.req)
+
+	nodeNames := []string{}
+	pendingApprovalNodeNames := []string{}
+
+	for i, node := range nodes {
+		if !k.hasAnyAnnotationGroup(node, opt.annotationGroups) {
+			continue
+		}
+
+		if opt.checkNodeConditions && !k.requiredNodeConditionsMet(&nodes[i]) {
+			continue
+		}
+
+		if opt.requireManualApproval && node.Annotations[constants.AnnotationApprovedBy] == "" {
+			if node.Annotations[constants.AnnotationPendingApproval] != constants.True {
+				pendingApprovalNodeNames = append(pendingApprovalNodeNames, node.Name)
+			}
+
+			continue
+		}
+
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	if err := k.markPendingApproval(ctx, pendingApprovalNodeNames); err != nil {
+		return err
+	}
+
+	if opt.limitToRebootingCapacity {
+		nodeNames = k.limitToRebootingCapacity(nodelist, nodeNames)
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		return k.updateCheckedNode(ctx, nodeName, opt)
+	})
+}
+
+// makeSchedulable marks a given node as schedulable if it has been made unschedulable by the operator.
+func makeSchedulable(node *corev1.Node) {
+	if node.Annotations[constants.AnnotationOperatorMadeUnschedulable] != constants.True {
+		return
+	}
+
+	klog.Infof("Marking node %q made unschedulable by operator as schedulable", node.Name)
+
+	node.Spec.Unschedulable = false
+	node.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.False
+}
+
+// updateCheckedNode deletes given label and annotations from a node which passed the check and sets
+// ok-to-reboot annotation to the given value.
+//
+// In dry run mode, nodes are not approved to reboot.
+func (k *Kontroller) updateCheckedNode(ctx context.Context, nodeName string, opt checkRebootOptions) error {
+	if k.dryRun && opt.okToReboot == constants.True {
+		klog.Infof("Dry run: would delete label %q and set annotation %q to %q for node %q",
+			opt.label, constants.AnnotationOkToReboot, opt.okToReboot, nodeName)
+
+		return nil
+	}
+
+	updatedNode := &corev1.Node{}
+	approvedBy := ""
+	rebootStartedTime := ""
 
-	for _, node := range nodes {
-		if !hasAllAnnotations(node, opt.annotations) {
-			continue
+	klog.V(4).Infof("Deleting label %q for %q", opt.label, nodeName)
+	klog.V(4).Infof("Setting annotation %q to %q for %q",
+		constants.AnnotationOkToReboot, opt.okToReboot, nodeName)
+
+	if err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+		previousPhase := nodePhase(node)
+
+		delete(node.Labels, opt.label)
+
+		// Cleanup the annotations.
+		for _, annotation := range opt.annotations {
+			klog.V(4).Infof("Deleting annotation %q from node %q", annotation, node.Name)
+			delete(node.Annotations, annotation)
 		}
 
-		klog.V(4).Infof("Deleting label %q for %q", opt.label, node.Name)
-		klog.V(4).Infof("Setting annotation %q to %q for %q",
-			constants.AnnotationOkToReboot, opt.okToReboot, node.Name)
+		node.Annotations[constants.AnnotationOkToReboot] = opt.okToReboot
+
+		if opt.setRebootFinishedTime {
+			node.Annotations[constants.AnnotationRebootFinishedTime] = time.Now().UTC().Format(time.RFC3339)
+		}
 
-		if err := k8sutil.UpdateNodeRetry(ctx, k.nc, node.Name, func(node *corev1.Node) {
-			delete(node.Labels, opt.label)
+		if opt.setRebootStartedTime {
+			node.Annotations[constants.AnnotationRebootStartedTime] = time.Now().UTC().Format(time.RFC3339)
+			delete(node.Annotations, constants.AnnotationRebootCompletedTime)
+		}
 
-			// Cleanup the annotations.
-			for _, annotation := range opt.annotations {
-				klog.V(4).Infof("Deleting annotation %q from node %q", annotation, node.Name)
-				delete(node.Annotations, annotation)
-			}
+		if opt.setRebootCompletedTime {
+			node.Annotations[constants.AnnotationRebootCompletedTime] = time.Now().UTC().Format(time.RFC3339)
+			rebootStartedTime = node.Annotations[constants.AnnotationRebootStartedTime]
+		}
 
-			node.Annotations[constants.AnnotationOkToReboot] = opt.okToReboot
-		}); err != nil {
-			return fmt.Errorf("updating node %q: %w", node.Name, err)
+		if opt.makeSchedulable {
+			makeSchedulable(node)
 		}
+
+		if opt.recordVersionBeforeReboot {
+			recordVersionBeforeReboot(node)
+		}
+
+		delete(node.Annotations, constants.AnnotationRebootEscalated)
+
+		if opt.requireManualApproval {
+			approvedBy = node.Annotations[constants.AnnotationApprovedBy]
+
+			delete(node.Annotations, constants.AnnotationPendingApproval)
+			delete(node.Annotations, constants.AnnotationApprovedBy)
+		}
+
+		updatePhaseTransitionTime(node, previousPhase)
+
+		updatedNode = node
+	}); err != nil {
+		return fmt.Errorf("updating node %q: %w", nodeName, err)
+	}
+
+	if opt.setRebootCompletedTime {
+		k.observeRebootDuration(nodeName, rebootStartedTime)
+	}
+
+	if opt.requireManualApproval {
+		klog.Infof("Reboot of node %q has been approved by %q", nodeName, approvedBy)
+
+		k.recorder.Eventf(updatedNode, corev1.EventTypeNormal, eventReasonRebootApproved,
+			"Reboot approved by %q", approvedBy)
 	}
 
 	return nil
 }
 
 // checkBeforeReboot gets all nodes with the before-reboot=true label and checks
-// if all of the configured before-reboot annotations are set to true. If they
-// are, it deletes the before-reboot=true label and sets reboot-ok=true to tell
-// the agent that it is ready to start the actual reboot process.
+// if all of the configured before-reboot annotations from any of the configured
+// annotation groups are set to true and if required node conditions are met.
+// If they are, it deletes the before-reboot=true label and sets reboot-ok=true
+// to tell the agent that it is ready to start the actual reboot process.
+// If manual approval is required, nodes which reboot has not been approved yet
+// are marked as pending approval instead.
 // If there is an error getting the list of nodes or updating any of them, an
 // error is immediately returned.
 func (k *Kontroller) checkBeforeReboot(ctx context.Context) error {
 	opt := checkRebootOptions{
-		req:         beforeRebootReq,
-		annotations: k.beforeRebootAnnotations,
-		label:       constants.LabelBeforeReboot,
-		okToReboot:  constants.True,
+		req:              beforeRebootReq,
+		annotations:      k.beforeRebootAnnotations,
+		annotationGroups: k.beforeRebootAnnotationGroups,
+		label:            constants.LabelBeforeReboot,
+		okToReboot:       constants.True,
+
+		checkNodeConditions:      true,
+		requireManualApproval:    k.requireManualApproval,
+		limitToRebootingCapacity: k.maxPreparingNodes > 0,
+
+		recordVersionBeforeReboot: true,
+		setRebootStartedTime:      true,
 	}
 
 	return k.checkReboot(ctx, opt)
 }
 
 // checkAfterReboot gets all nodes with the after-reboot=true label and checks
-// if all of the configured after-reboot annotations are set to true. If they
-// are, it deletes the after-reboot=true label and sets reboot-ok=false to tell
-// the agent that it has completed it's reboot successfully.
+// if all of the configured after-reboot annotations from any of the configured
+// annotation groups are set to true. If they are, it deletes the after-reboot=true
+// label and sets reboot-ok=false to tell the agent that it has completed it's
+// reboot successfully.
 // If there is an error getting the list of nodes or updating any of them, an
 // error is immediately returned.
 func (k *Kontroller) checkAfterReboot(ctx context.Context) error {
-	opt := checkRebootOptions{
-		req:         afterRebootReq,
-		annotations: k.afterRebootAnnotations,
-		label:       constants.LabelAfterReboot,
-		okToReboot:  constants.False,
-	}
+	return k.checkReboot(ctx, k.afterRebootCheckOptions())
+}
 
-	return k.checkReboot(ctx, opt)
+// afterRebootCheckOptions returns options for updating nodes which have finished after-reboot checks.
+func (k *Kontroller) afterRebootCheckOptions() checkRebootOptions {
+	return checkRebootOptions{
+		req:              afterRebootReq,
+		annotations:      k.afterRebootAnnotations,
+		annotationGroups: k.afterRebootAnnotationGroups,
+		label:            constants.LabelAfterReboot,
+		okToReboot:       constants.False,
+
+		setRebootFinishedTime:  k.postRebootReadyPeriod > 0,
+		setRebootCompletedTime: true,
+		makeSchedulable:        true,
+	}
 }
 
 // insideRebootWindow checks if process is inside reboot window at the time
@@ -482,10 +1911,7 @@ func (k *Kontroller) insideRebootWindow() bool {
 		return true
 	}
 
-	// Most recent reboot window might still be open.
-	mostRecentRebootWindow := k.rebootWindow.Previous(time.Now())
-
-	return time.Now().Before(mostRecentRebootWindow.End)
+	return k.rebootWindow.Contains(time.Now().In(k.rebootWindowLocation))
 }
 
 // remainingRebootingCapacity calculates how many more nodes can be rebooted at a time based
@@ -493,48 +1919,256 @@ func (k *Kontroller) insideRebootWindow() bool {
 //
 // If maximum capacity is reached, it is logged and list of rebooting nodes is logged as well.
 func (k *Kontroller) remainingRebootingCapacity(nodelist *corev1.NodeList) int {
-	rebootingNodes := k8sutil.FilterNodesByAnnotation(nodelist.Items, stillRebootingSelector)
-
-	// Nodes running before and after reboot checks are still considered to be "rebooting" to us.
-	beforeRebootNodes := k8sutil.FilterNodesByRequirement(nodelist.Items, beforeRebootReq)
-	afterRebootNodes := k8sutil.FilterNodesByRequirement(nodelist.Items, afterRebootReq)
+	rebootingNodes := rebootingNodes(nodelist)
+	maxRebootingNodes := k.effectiveMaxRebootingNodes(nodelist)
 
-	rebootingNodes = append(append(rebootingNodes, beforeRebootNodes...), afterRebootNodes...)
-
-	remainingCapacity := k.maxRebootingNodes - len(rebootingNodes)
+	remainingCapacity := maxRebootingNodes - len(rebootingNodes)
 
-	if remainingCapacity == 0 {
+	if remainingCapacity <= 0 {
 		for _, n := range rebootingNodes {
 			klog.Infof("Found node %q still rebooting, waiting", n.Name)
 		}
 
-		klog.Infof("Found %d (of max %d) rebooting nodes; waiting for completion", len(rebootingNodes), k.maxRebootingNodes)
+		klog.Infof("Found %d (of max %d) rebooting nodes; waiting for completion", len(rebootingNodes), maxRebootingNodes)
 	}
 
 	return remainingCapacity
 }
 
 // nodesRequiringReboot filters given list of nodes and returns ones which requires a reboot.
+//
+// Nodes which agent is not alive are skipped, as they would never proceed with rebooting.
+// Returned nodes are sorted according to configured node ordering and reboot priority, with nodes which
+// reboot is overdue first.
 func (k *Kontroller) nodesRequiringReboot(nodelist *corev1.NodeList) []corev1.Node {
 	rebootableNodes := k8sutil.FilterNodesByAnnotation(nodelist.Items, rebootableSelector)
+	rebootableNodes = k8sutil.FilterNodesByRequirement(rebootableNodes, notBeforeRebootReq)
+
+	now := time.Now()
+	nodes := []corev1.Node{}
+
+	for _, node := range rebootableNodes {
+		if !k.rebootControlPlane && controlPlaneNode(node) {
+			klog.V(4).Infof("Node %q needs a reboot, but it is a control-plane node, skipping", node.Name)
+
+			continue
+		}
+
+		if node.Annotations[constants.AnnotationAwaitingManualUncordon] == constants.True {
+			klog.Infof("Node %q needs a reboot, but it still awaits manual uncordon after the previous one, skipping",
+				node.Name)
+
+			continue
+		}
+
+		if !k.agentAlive(node, now) {
+			klog.Warningf("Node %q needs a reboot, but its agent has not reported a heartbeat within %v, skipping",
+				node.Name, k.agentHeartbeatTimeout)
+
+			continue
+		}
+
+		if deferral, ok := k.versionDeferral(&node); ok {
+			klog.Infof("Node %q needs a reboot, but it is deferred: %s", node.Name, deferral.message)
+
+			continue
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	sortNodes(nodes, k.nodeOrdering)
+	sortNodesByRebootPriority(nodes, k.rebootPriorityAnnotation)
+	k.prioritizeOverdueNodes(nodes, now)
+
+	return nodes
+}
+
+// controlPlaneNode checks if a given node is a control-plane node based on standard node role labels and taints.
+func controlPlaneNode(node corev1.Node) bool {
+	for _, key := range []string{labelNodeRoleControlPlane, labelNodeRoleMaster} {
+		if _, ok := node.Labels[key]; ok {
+			return true
+		}
+
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// agentAlive checks if agent running on a given node has reported a heartbeat within configured
+// timeout at a given time. Nodes without a valid heartbeat are considered to have agent not running.
+//
+// If agent heartbeat timeout is not configured, true is always returned.
+func (k *Kontroller) agentAlive(node corev1.Node, now time.Time) bool {
+	if k.agentHeartbeatTimeout == 0 {
+		return true
+	}
+
+	heartbeat, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationAgentHeartbeat])
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(heartbeat) <= k.agentHeartbeatTimeout
+}
+
+// remainingPreparingCapacity calculates how many more nodes can run before-reboot checks at a time
+// based on a given list of nodes.
+func (k *Kontroller) remainingPreparingCapacity(nodelist *corev1.NodeList) int {
+	preparingNodes := k8sutil.FilterNodesByRequirement(nodelist.Items, beforeRebootReq)
+
+	remainingCapacity := k.maxPreparingNodes - len(preparingNodes)
+
+	if remainingCapacity <= 0 {
+		klog.Infof("Found %d (of max %d) nodes running before-reboot checks; waiting for completion",
+			len(preparingNodes), k.maxPreparingNodes)
+	}
+
+	return remainingCapacity
+}
+
+// limitToRebootingCapacity returns at most as many of given node names as can be approved to reboot
+// without exceeding maximum number of rebooting nodes. Nodes running before-reboot checks are not counted
+// as rebooting. Nodes scheduled for rebooting beyond regular rebooting capacity, as their reboot was overdue,
+// are also approved beyond it, up to configured maximum number of rebooting nodes for overdue reboots.
+func (k *Kontroller) limitToRebootingCapacity(nodelist *corev1.NodeList, nodeNames []string) []string {
+	rebooting := k8sutil.FilterNodesByAnnotation(nodelist.Items, stillRebootingSelector)
+	rebooting = append(rebooting, k8sutil.FilterNodesByRequirement(nodelist.Items, afterRebootReq)...)
+	rebooting = append(rebooting, k8sutil.FilterNodesByAnnotation(nodelist.Items, awaitingManualUncordonSelector)...)
+	rebooting = uniqueNodes(rebooting)
+
+	maxRebootingNodes := k.effectiveMaxRebootingNodes(nodelist)
+
+	remainingCapacity := maxRebootingNodes - len(rebooting)
+	if remainingCapacity < 0 {
+		remainingCapacity = 0
+	}
+
+	if len(nodeNames) <= remainingCapacity {
+		return nodeNames
+	}
+
+	klog.Infof("Found %d (of max %d) rebooting nodes; approving %d of %d nodes which passed before-reboot checks",
+		len(rebooting), maxRebootingNodes, remainingCapacity, len(nodeNames))
+
+	approvedNodeNames := nodeNames[:remainingCapacity]
+
+	if k.overdueMaxRebootingNodes > 0 {
+		escalated := k.escalatedNodeNames(nodelist, nodeNames[remainingCapacity:], len(rebooting)+remainingCapacity)
 
-	return k8sutil.FilterNodesByRequirement(rebootableNodes, notBeforeRebootReq)
+		approvedNodeNames = append(approvedNodeNames, escalated...)
+	}
+
+	return approvedNodeNames
 }
 
 // rebootableNodes returns list of nodes which can be marked for rebooting based on remaining capacity.
-func (k *Kontroller) rebootableNodes(nodelist *corev1.NodeList) []*corev1.Node {
-	remainingCapacity := k.remainingRebootingCapacity(nodelist)
+//
+// If maximum number of preparing nodes is configured, remaining capacity is based on number of nodes
+// running before-reboot checks instead of number of rebooting nodes.
+func (k *Kontroller) rebootableNodes(nodelist *corev1.NodeList) ([]*corev1.Node, map[string]struct{}) {
+	var remainingCapacity int
+	if k.maxPreparingNodes > 0 {
+		remainingCapacity = k.remainingPreparingCapacity(nodelist)
+	} else {
+		remainingCapacity = k.remainingRebootingCapacity(nodelist)
+	}
 
-	nodesRequiringReboot := k.nodesRequiringReboot(nodelist)
+	nodesRequiringReboot := k.limitPerZone(nodelist, k.nodesRequiringReboot(nodelist), remainingCapacity)
 
-	chosenNodes := make([]*corev1.Node, 0, remainingCapacity)
+	chosenNodes := []*corev1.Node{}
 	for i := 0; i < remainingCapacity && i < len(nodesRequiringReboot); i++ {
 		chosenNodes = append(chosenNodes, &nodesRequiringReboot[i])
 	}
 
+	regularNodes := len(chosenNodes)
+
+	// Overdue reboots may exceed regular rebooting capacity.
+	if k.overdueMaxRebootingNodes > 0 {
+		rebooting := len(rebootingNodes(nodelist)) + len(chosenNodes)
+
+		chosenNodes = append(chosenNodes, k.escalatedNodes(nodesRequiringReboot[len(chosenNodes):], rebooting)...)
+	}
+
+	// Shared reboot budget limits overdue reboots as well, as other members rely on it not being exceeded.
+	if k.rebootBudget != nil {
+		granted := k.rebootBudget.reserve(k.rebootBudgetMember, len(rebootingNodes(nodelist)), len(chosenNodes))
+
+		chosenNodes = chosenNodes[:granted]
+	}
+
+	escalated := map[string]struct{}{}
+
+	if len(chosenNodes) > regularNodes {
+		k.reportEscalatedNodes(chosenNodes[regularNodes:])
+
+		for _, node := range chosenNodes[regularNodes:] {
+			escalated[node.Name] = struct{}{}
+		}
+	}
+
 	klog.Infof("Found %d nodes that need a reboot", len(chosenNodes))
 
-	return chosenNodes
+	return chosenNodes, escalated
+}
+
+// reportRebootingNodes reports number of nodes in the process of rebooting to the shared reboot budget.
+//
+// If shared reboot budget is not configured, nothing is done.
+func (k *Kontroller) reportRebootingNodes(ctx context.Context) error {
+	if k.rebootBudget == nil {
+		return nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	k.rebootBudget.report(k.rebootBudgetMember, len(rebootingNodes(nodelist)))
+
+	return nil
+}
+
+// rebootingNodes returns nodes from a given list which are in the process of rebooting.
+func rebootingNodes(nodelist *corev1.NodeList) []corev1.Node {
+	rebootingNodes := k8sutil.FilterNodesByAnnotation(nodelist.Items, stillRebootingSelector)
+
+	// Nodes running before and after reboot checks are still considered to be "rebooting" to us.
+	beforeRebootNodes := k8sutil.FilterNodesByRequirement(nodelist.Items, beforeRebootReq)
+	afterRebootNodes := k8sutil.FilterNodesByRequirement(nodelist.Items, afterRebootReq)
+
+	// So are nodes which have rebooted, but are not verified and uncordoned by the administrator yet.
+	awaitingManualUncordonNodes := k8sutil.FilterNodesByAnnotation(nodelist.Items, awaitingManualUncordonSelector)
+
+	rebootingNodes = append(append(rebootingNodes, beforeRebootNodes...), afterRebootNodes...)
+
+	return uniqueNodes(append(rebootingNodes, awaitingManualUncordonNodes...))
+}
+
+// uniqueNodes returns given nodes without duplicates, as a node may match more than one selector,
+// e.g. when it runs after-reboot checks while awaiting manual uncordon. Order of nodes is preserved.
+func uniqueNodes(nodes []corev1.Node) []corev1.Node {
+	seen := map[string]struct{}{}
+	unique := []corev1.Node{}
+
+	for _, node := range nodes {
+		if _, ok := seen[node.Name]; ok {
+			continue
+		}
+
+		seen[node.Name] = struct{}{}
+
+		unique = append(unique, node)
+	}
+
+	return unique
 }
 
 // markBeforeReboot gets nodes which want to reboot and marks them with the
@@ -548,7 +2182,7 @@ func (k *Kontroller) rebootableNodes(nodelist *corev1.NodeList) []*corev1.Node {
 // If there is an error getting the list of nodes or updating any of them, an
 // error is immediately returned.
 func (k *Kontroller) markBeforeReboot(ctx context.Context) error {
-	nodelist, err := k.nc.List(ctx, metav1.ListOptions{})
+	nodelist, err := k.listNodes(ctx, "")
 	if err != nil {
 		return fmt.Errorf("listing nodes: %w", err)
 	}
@@ -556,18 +2190,125 @@ func (k *Kontroller) markBeforeReboot(ctx context.Context) error {
 	if !k.insideRebootWindow() {
 		klog.V(4).Info("We are outside the reboot window; not labeling rebootable nodes for now")
 
-		return nil
+		return k.updateRebootDeferredReason(ctx, nodelist, constants.RebootDeferredReasonOutsideWindow)
+	}
+
+	if err := k.updateRebootDeferredReason(ctx, nodelist, ""); err != nil {
+		return err
+	}
+
+	// Nodes are chosen before any of them gets updated, so the rebooting capacity
+	// is respected regardless of the order in which updates complete.
+	chosenNodes, escalated := k.rebootableNodes(nodelist)
+
+	nodeNames := make([]string, 0, len(chosenNodes))
+	for _, n := range chosenNodes {
+		nodeNames = append(nodeNames, n.Name)
 	}
 
 	// Set before-reboot=true for the chosen nodes.
-	for _, n := range k.rebootableNodes(nodelist) {
-		err = k.mark(ctx, n.Name, constants.LabelBeforeReboot, "before-reboot", k.beforeRebootAnnotations)
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		// Escalated nodes are recorded, so they can be approved to reboot beyond regular rebooting capacity.
+		extraAnnotations := map[string]string{}
+		if _, ok := escalated[nodeName]; ok {
+			extraAnnotations[constants.AnnotationRebootEscalated] = constants.True
+		}
+
+		err := k.mark(ctx, nodeName, constants.LabelBeforeReboot, "before-reboot", k.beforeRebootAnnotations,
+			extraAnnotations, k.cordonBeforeReboot)
 		if err != nil {
 			return fmt.Errorf("labeling node for before reboot checks: %w", err)
 		}
+
+		return nil
+	})
+}
+
+// deferReboots updates reboot deferred reason annotation of all nodes, when no new reboots are
+// scheduled in the current reconciliation for a given reason.
+func (k *Kontroller) deferReboots(ctx context.Context, reason string) error {
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	return k.updateRebootDeferredReason(ctx, nodelist, reason)
+}
+
+// updateRebootDeferredReason sets reboot deferred reason annotation to a given reason on nodes which
+// need a reboot and removes it from all other nodes. If given reason is empty, the annotation is removed
+// from all nodes. Nodes which are deferred because of the version they would reboot into get the
+// respective reason regardless of the given one. Nodes which already have the right annotation value are
+// not updated, so events about deferred nodes are only emitted when their reason changes.
+func (k *Kontroller) updateRebootDeferredReason(ctx context.Context, nodelist *corev1.NodeList, reason string) error {
+	deferredNodes := map[string]rebootDeferral{}
+
+	if reason != "" {
+		for _, n := range k.nodesRequiringReboot(nodelist) {
+			deferredNodes[n.Name] = rebootDeferral{reason: reason}
+		}
 	}
 
-	return nil
+	needingReboot := k8sutil.FilterNodesByAnnotation(nodelist.Items, rebootableSelector)
+	needingReboot = k8sutil.FilterNodesByRequirement(needingReboot, notBeforeRebootReq)
+
+	for i, n := range needingReboot {
+		if deferral, ok := k.versionDeferral(&needingReboot[i]); ok {
+			deferredNodes[n.Name] = deferral
+		}
+	}
+
+	nodeNames := []string{}
+
+	for _, n := range nodelist.Items {
+		deferral, deferred := deferredNodes[n.Name]
+		currentReason, hasReason := n.Annotations[constants.AnnotationRebootDeferredReason]
+
+		if (deferred && currentReason != deferral.reason) || (!deferred && hasReason) {
+			nodeNames = append(nodeNames, n.Name)
+		}
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		deferral, deferred := deferredNodes[nodeName]
+
+		updatedNode := &corev1.Node{}
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			updatedNode = node
+
+			if !deferred {
+				delete(node.Annotations, constants.AnnotationRebootDeferredReason)
+
+				return
+			}
+
+			node.Annotations[constants.AnnotationRebootDeferredReason] = deferral.reason
+		})
+		if err != nil {
+			return fmt.Errorf("updating annotation %q on node %q: %w",
+				constants.AnnotationRebootDeferredReason, nodeName, err)
+		}
+
+		if deferral.eventReason != "" {
+			k.recorder.Event(updatedNode, corev1.EventTypeWarning, deferral.eventReason, deferral.message)
+		}
+
+		return nil
+	})
+}
+
+// listNodes lists nodes managed by the operator, which additionally match given label selector, if not empty.
+func (k *Kontroller) listNodes(ctx context.Context, labelSelector string) (*corev1.NodeList, error) {
+	selectors := []string{}
+
+	for _, selector := range []string{k.nodeSelector, labelSelector} {
+		if selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+
+	return k.nc.List(ctx, metav1.ListOptions{LabelSelector: strings.Join(selectors, ",")})
 }
 
 // markAfterReboot gets nodes which have completed rebooting and marks them with
@@ -579,10 +2320,8 @@ func (k *Kontroller) markBeforeReboot(ctx context.Context) error {
 // If there is an error getting the list of nodes or updating any of them, an
 // error is immediately returned.
 func (k *Kontroller) markAfterReboot(ctx context.Context) error {
-	nodelist, err := k.nc.List(ctx, metav1.ListOptions{
-		// Filter out any nodes that are already labeled with after-reboot=true.
-		LabelSelector: fmt.Sprintf("%s!=%s", constants.LabelAfterReboot, constants.True),
-	})
+	// Filter out any nodes that are already labeled with after-reboot=true.
+	nodelist, err := k.listNodes(ctx, fmt.Sprintf("%s!=%s", constants.LabelAfterReboot, constants.True))
 	if err != nil {
 		return fmt.Errorf("listing nodes: %w", err)
 	}
@@ -592,26 +2331,139 @@ func (k *Kontroller) markAfterReboot(ctx context.Context) error {
 
 	klog.Infof("Found %d rebooted nodes", len(justRebootedNodes))
 
-	// For all the nodes which just rebooted, remove any old annotations and add the after-reboot=true label.
+	nodeNames := make([]string, 0, len(justRebootedNodes))
+	versionRecorded := map[string]bool{}
+
 	for _, n := range justRebootedNodes {
-		err = k.mark(ctx, n.Name, constants.LabelAfterReboot, "after-reboot", k.afterRebootAnnotations)
+		nodeNames = append(nodeNames, n.Name)
+
+		_, versionRecorded[n.Name] = n.Annotations[constants.AnnotationVersionBeforeReboot]
+	}
+
+	// For all the nodes which just rebooted, check if they have been updated, remove any old annotations
+	// and add the after-reboot=true label.
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		if versionRecorded[nodeName] {
+			if err := k.checkRebootedVersion(ctx, nodeName); err != nil {
+				return err
+			}
+		}
+
+		err := k.mark(ctx, nodeName, constants.LabelAfterReboot, "after-reboot", k.afterRebootAnnotations, nil, false)
 		if err != nil {
 			return fmt.Errorf("labeling node for after reboot checks: %w", err)
 		}
+
+		return nil
+	})
+}
+
+// forEachNode calls given function for each of given node names using up to configured
+// number of concurrent workers.
+//
+// Once any call returns an error, remaining nodes are not processed anymore, context passed
+// to ongoing calls is cancelled and the first error is returned after all workers are done.
+func (k *Kontroller) forEachNode(
+	ctx context.Context, nodeNames []string, f func(ctx context.Context, nodeName string) error,
+) error {
+	workersCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := k.nodeUpdateConcurrency
+	if workers > len(nodeNames) {
+		workers = len(nodeNames)
+	}
+
+	nodeNamesCh := make(chan string)
+	// Each worker reports at most one error.
+	errCh := make(chan error, workers)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for nodeName := range nodeNamesCh {
+				if err := f(workersCtx, nodeName); err != nil {
+					errCh <- err
+
+					cancel()
+
+					return
+				}
+			}
+		}()
+	}
+
+	interrupted := false
+
+feed:
+	for _, nodeName := range nodeNames {
+		select {
+		case nodeNamesCh <- nodeName:
+		case <-workersCtx.Done():
+			interrupted = true
+
+			break feed
+		}
+	}
+
+	close(nodeNamesCh)
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	if interrupted {
+		return fmt.Errorf("processing nodes: %w", ctx.Err())
 	}
 
 	return nil
 }
 
-func (k *Kontroller) mark(ctx context.Context, nodeName, label, annotationsType string, annotations []string) error {
+// mark sets given label to true and given extra annotations on a given node and deletes given
+// annotations from it.
+//
+// In dry run mode, node is not updated.
+func (k *Kontroller) mark(
+	ctx context.Context, nodeName, label, annotationsType string, annotations []string,
+	extraAnnotations map[string]string, cordon bool,
+) error {
+	if k.dryRun {
+		klog.Infof("Dry run: would set label %q to %q for node %q", label, constants.True, nodeName)
+
+		return nil
+	}
+
 	klog.V(4).Infof("Deleting annotations %v for %q", annotations, nodeName)
 	klog.V(4).Infof("Setting label %q to %q for node %q", label, constants.True, nodeName)
 
 	err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+		previousPhase := nodePhase(node)
+
 		for _, annotation := range annotations {
 			delete(node.Annotations, annotation)
 		}
 		node.Labels[label] = constants.True
+
+		for key, value := range extraAnnotations {
+			node.Annotations[key] = value
+		}
+
+		// Nodes made unschedulable by someone else are left for them to make schedulable again.
+		if cordon && !node.Spec.Unschedulable {
+			klog.Infof("Marking node %q as unschedulable", node.Name)
+
+			node.Spec.Unschedulable = true
+			node.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+		}
+
+		updatePhaseTransitionTime(node, previousPhase)
 	})
 	if err != nil {
 		return fmt.Errorf("setting label %q to %q on node %q: %w", label, constants.True, nodeName, err)
@@ -624,15 +2476,45 @@ func (k *Kontroller) mark(ctx context.Context, nodeName, label, annotationsType
 	return nil
 }
 
-func hasAllAnnotations(node corev1.Node, annotations []string) bool {
+func (k *Kontroller) hasAnyAnnotationGroup(node corev1.Node, annotationGroups [][]string) bool {
+	for _, annotations := range annotationGroups {
+		if k.hasAllAnnotations(node, annotations) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasAllAnnotations checks if all given annotations are set on a given node to one of values
+// considered as true.
+func (k *Kontroller) hasAllAnnotations(node corev1.Node, annotations []string) bool {
 	nodeAnnotations := node.GetAnnotations()
 
 	for _, annotation := range annotations {
 		value, ok := nodeAnnotations[annotation]
-		if !ok || value != constants.True {
+		if !ok {
+			return false
+		}
+
+		if _, isTrue := k.annotationTrueValues[value]; !isTrue {
 			return false
 		}
 	}
 
 	return true
 }
+
+// annotationTrueValuesSet returns given annotation values as a set, defaulting to "true".
+func annotationTrueValuesSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		values = []string{constants.True}
+	}
+
+	result := map[string]struct{}{}
+	for _, value := range values {
+		result[value] = struct{}{}
+	}
+
+	return result
+}
@@ -3,15 +3,20 @@ package operator_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	k8stesting "k8s.io/client-go/testing"
@@ -27,6 +32,8 @@ const (
 	testAfterRebootAnnotation         = "test-after-annotation"
 	testAnotherAfterRebootAnnotation  = "test-another-after-annotation"
 	testNamespace                     = "default"
+
+	testNodeConditionType corev1.NodeConditionType = "StorageHealthy"
 )
 
 //nolint:funlen // Just many test cases.
@@ -44,6 +51,18 @@ func Test_Creating_new_operator(t *testing.T) {
 			}
 		})
 
+		t.Run("valid_reboot_window_cron_expression_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.RebootWindowCron = "0 14 * * Mon"
+			config.RebootWindowLength = "1h"
+
+			if _, err := operator.New(config); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+
 		t.Run("valid_reboot_window_configured", func(t *testing.T) {
 			t.Parallel()
 
@@ -100,473 +119,5431 @@ func Test_Creating_new_operator(t *testing.T) {
 			}
 		})
 
-		t.Run("invalid_reboot_window_is_configured", func(t *testing.T) {
+		t.Run("negative_node_update_concurrency_is_configured", func(t *testing.T) {
 			t.Parallel()
 
 			config := validOperatorConfig()
-			config.RebootWindowStart = "Mon 14"
-			config.RebootWindowLength = "0s"
+			config.NodeUpdateConcurrency = -1
 
 			if _, err := operator.New(config); err == nil {
 				t.Fatalf("Expected error")
 			}
 		})
-	})
-}
 
-func Test_Operator_exits_gracefully_when_user_requests_shutdown(t *testing.T) {
-	t.Parallel()
+		t.Run("negative_agent_heartbeat_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	rebootCancelledNode := rebootCancelledNode()
+			config := validOperatorConfig()
+			config.AgentHeartbeatTimeout = -1 * time.Second
 
-	config, fakeClient := testConfig(rebootCancelledNode)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	ctx := contextWithDeadline(t)
+		t.Run("negative_post_reboot_ready_period_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	<-process(ctx, t, config, fakeClient)
+			config := validOperatorConfig()
+			config.PostRebootReadyPeriod = -1 * time.Second
 
-	updatedNode := node(contextWithDeadline(t), t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node", constants.LabelBeforeReboot)
-	}
-}
+		t.Run("negative_stale_annotations_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-//nolint:funlen // TODO: Should likely be refactored.
-func Test_Operator_shuts_down_leader_election_process_when_user_requests_shutdown(t *testing.T) {
-	t.Parallel()
+			config := validOperatorConfig()
+			config.StaleAnnotationsTimeout = -1 * time.Second
 
-	rebootCancelledNode := rebootCancelledNode()
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	config, fakeClient := testConfig(rebootCancelledNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
-	config.ReconciliationPeriod = 1 * time.Second
-	config.LeaderElectionLease = 2 * time.Second
-	testKontroller := kontrollerWithObjects(t, config)
-	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+		t.Run("negative_stale_reboot_in_progress_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	stop := make(chan struct{})
-	stopped := make(chan struct{})
+			config := validOperatorConfig()
+			config.StaleRebootInProgressTimeout = -1 * time.Second
 
-	go func() {
-		if err := testKontroller.Run(stop); err != nil {
-			fmt.Printf("Error running operator: %v\n", err)
-			t.Fail()
-		}
-		stopped <- struct{}{}
-	}()
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	// Wait for one reconciliation cycle to run.
-	<-nodeUpdated
+		t.Run("negative_update_error_status_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	ctx := contextWithDeadline(t)
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			config := validOperatorConfig()
+			config.UpdateErrorStatusTimeout = -1 * time.Second
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	close(stop)
+		t.Run("malformed_maintenance_node_selector_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	<-stopped
+			config := validOperatorConfig()
+			config.MaintenanceNodeSelector = "foo=bar,"
 
-	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating Node object: %v", err)
-	}
+		t.Run("required_node_condition_with_invalid_status_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	config.LockID = "bar"
+			config := validOperatorConfig()
+			config.RequiredNodeConditions = []string{"StorageHealthy=Yes"}
 
-	parallelKontroller := kontrollerWithObjects(t, config)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	stop = make(chan struct{})
+		t.Run("unsupported_node_ordering_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	t.Cleanup(func() {
-		close(stop)
-	})
+			config := validOperatorConfig()
+			config.NodeOrdering = "foo"
 
-	go func() {
-		if err := parallelKontroller.Run(stop); err != nil {
-			fmt.Printf("Error running operator: %v\n", err)
-			t.Fail()
-		}
-	}()
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	time.Sleep(config.LeaderElectionLease * 2)
+		t.Run("malformed_before_reboot_annotation_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			config := validOperatorConfig()
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation, " " + testAnotherBeforeRebootAnnotation}
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
-}
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-func Test_Operator_emits_events_about_leader_election_to_configured_namespace(t *testing.T) {
-	t.Parallel()
+		t.Run("malformed_after_reboot_annotation_in_annotation_group_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	config, fakeClient := testConfig()
+			config := validOperatorConfig()
+			config.AfterRebootAnnotationGroups = [][]string{{testAfterRebootAnnotation}, {"example.com/foo/bar"}}
 
-	<-process(contextWithDeadline(t), t, config, fakeClient)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	events, err := config.Client.CoreV1().Events(config.Namespace).List(contextWithDeadline(t), metav1.ListOptions{})
-	if err != nil {
-		t.Fatalf("Failed listing events: %v", err)
-	}
+		t.Run("invalid_reboot_window_cron_expression_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	if len(events.Items) == 0 {
-		t.Fatalf("Expected at least one event to be published")
-	}
-}
+			config := validOperatorConfig()
+			config.RebootWindowCron = "0 14 * *"
+			config.RebootWindowLength = "1h"
 
-func Test_Operator_returns_error_when_leadership_is_lost(t *testing.T) {
-	t.Parallel()
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	rebootCancelledNode := rebootCancelledNode()
+		t.Run("both_reboot_window_start_and_cron_expression_are_configured", func(t *testing.T) {
+			t.Parallel()
 
-	config, fakeClient := testConfig(rebootCancelledNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
-	config.ReconciliationPeriod = 1 * time.Second
-	config.LeaderElectionLease = 2 * time.Second
-	testKontroller := kontrollerWithObjects(t, config)
-	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+			config := validOperatorConfig()
+			config.RebootWindowStart = "Mon 14:00"
+			config.RebootWindowCron = "0 14 * * Mon"
+			config.RebootWindowLength = "1h"
 
-	stop := make(chan struct{})
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	t.Cleanup(func() {
-		close(stop)
-	})
+		t.Run("invalid_reboot_window_timezone_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	errCh := make(chan error, 1)
+			config := validOperatorConfig()
+			config.RebootWindowStart = "14:00"
+			config.RebootWindowLength = "1h"
+			config.RebootWindowTimezone = "Foo/Bar"
 
-	go func() {
-		errCh <- testKontroller.Run(stop)
-	}()
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	// Wait for one reconciliation cycle to run.
-	<-nodeUpdated
+		t.Run("both_before_reboot_annotations_and_annotation_groups_are_configured", func(t *testing.T) {
+			t.Parallel()
 
-	ctx := contextWithDeadline(t)
+			config := validOperatorConfig()
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.BeforeRebootAnnotationGroups = [][]string{{testAnotherBeforeRebootAnnotation}}
 
-	// Ensure operator is functional.
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
+		t.Run("both_after_reboot_annotations_and_annotation_groups_are_configured", func(t *testing.T) {
+			t.Parallel()
 
-	stealLeaderElection(ctx, t, config)
+			config := validOperatorConfig()
+			config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+			config.AfterRebootAnnotationGroups = [][]string{{testAnotherAfterRebootAnnotation}}
 
-	// Wait lease time to ensure operator lost it.
-	time.Sleep(config.LeaderElectionLease)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	// Patch node object again to verify if operator is functional.
-	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+		t.Run("malformed_reboot_request_annotation_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating Node object: %v", err)
-	}
+			config := validOperatorConfig()
+			config.RebootRequestAnnotation = "example.com/foo/bar"
 
-	time.Sleep(config.ReconciliationPeriod)
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
 
-	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+		t.Run("empty_annotation_true_value_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
-		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
-	}
+			config := validOperatorConfig()
+			config.AnnotationTrueValues = []string{constants.True, ""}
 
-	if err := <-errCh; err == nil {
-		t.Fatalf("Expected operator to return error when leader election is lost")
-	}
-}
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
 
-func stealLeaderElection(ctx context.Context, t *testing.T, config operator.Config) {
-	t.Helper()
+		t.Run("negative_reboot_deadline_is_configured", func(t *testing.T) {
+			t.Parallel()
 
-	configMapClient := config.Client.CoreV1().ConfigMaps(config.Namespace)
+			config := validOperatorConfig()
+			config.RebootDeadline = -time.Hour
 
-	configMaps, err := configMapClient.List(ctx, metav1.ListOptions{})
-	if err != nil {
-		t.Fatalf("Failed listing ConfigMaps: %v", err)
-	}
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
 
-	if c := len(configMaps.Items); c != 1 {
-		t.Fatalf("Expected exactly one ConfigMap to exist, got %d", c)
-	}
+		t.Run("max_rebooting_nodes_for_overdue_reboots_is_configured_without_reboot_deadline", func(t *testing.T) {
+			t.Parallel()
 
-	lock := configMaps.Items[0]
+			config := validOperatorConfig()
+			config.OverdueMaxRebootingNodes = 2
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_adaptive_max_rebooting_nodes_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			for name, formula := range map[string]string{
+				"without_percent":           "min=1",
+				"with_zero_percent":         "percent=0",
+				"with_percent_above_100":    "percent=101",
+				"with_unsupported_rounding": "percent=10,rounding=round",
+				"with_zero_min":             "percent=10,min=0",
+				"with_max_lower_than_min":   "percent=10,min=3,max=2",
+				"with_unknown_parameter":    "percent=10,foo=1",
+				"with_non_numeric_value":    "percent=ten",
+				"with_malformed_parameter":  "percent",
+			} {
+				formula := formula
+
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+
+					config := validOperatorConfig()
+					config.AdaptiveMaxRebootingNodes = formula
+
+					if _, err := operator.New(config); err == nil {
+						t.Fatalf("Expected error creating operator")
+					}
+				})
+			}
+		})
+
+		t.Run("adaptive_max_rebooting_nodes_is_configured_together_with_max_rebooting_nodes", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.AdaptiveMaxRebootingNodes = "percent=10"
+			config.MaxRebootingNodes = 2
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_node_selector_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.NodeSelector = "foo=bar=baz"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_max_rebooting_nodes_percent_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			for name, percent := range map[string]int{
+				"negative":  -1,
+				"above_100": 101,
+			} {
+				percent := percent
+
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+
+					config := validOperatorConfig()
+					config.MaxRebootingNodesPercent = percent
+
+					if _, err := operator.New(config); err == nil {
+						t.Fatalf("Expected error creating operator")
+					}
+				})
+			}
+		})
+
+		t.Run("negative_min_ready_nodes_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.MinReadyNodes = -1
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_min_ready_nodes_percent_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			for name, percent := range map[string]int{
+				"negative":  -1,
+				"above_100": 101,
+			} {
+				percent := percent
+
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+
+					config := validOperatorConfig()
+					config.MinReadyNodesPercent = percent
+
+					if _, err := operator.New(config); err == nil {
+						t.Fatalf("Expected error creating operator")
+					}
+				})
+			}
+		})
+
+		t.Run("max_rebooting_nodes_percent_is_configured_together_with_adaptive_max_rebooting_nodes", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.MaxRebootingNodesPercent = 10
+			config.AdaptiveMaxRebootingNodes = "percent=10"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_leader_election_lease_duration_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionLease = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_leader_election_renew_deadline_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionRenewDeadline = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_leader_election_retry_period_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionRetryPeriod = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("leader_election_renew_deadline_is_not_shorter_than_lease_duration", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionLease = 10 * time.Second
+			config.LeaderElectionRenewDeadline = 10 * time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("leader_election_retry_period_is_too_long_for_renew_deadline", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionRenewDeadline = 10 * time.Second
+			config.LeaderElectionRetryPeriod = 9 * time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("default_leader_election_renew_deadline_is_not_longer_than_configured_retry_period", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderElectionRetryPeriod = time.Minute
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_leader_handoff_grace_period_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.LeaderHandoffGracePeriod = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_event_namespace_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.EventNamespace = "Foo_Bar"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_max_rebooting_nodes_per_zone_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.MaxRebootingNodesPerZone = -1
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_zone_label_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.ZoneLabel = "foo bar"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_after_reboot_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.AfterRebootTimeout = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("unsupported_after_reboot_timeout_policy_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.AfterRebootTimeoutPolicy = "foo"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("invalid_reboot_priority_annotation_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.RebootPriorityAnnotation = "foo bar"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_before_reboot_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.BeforeRebootTimeout = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_max_preparing_nodes_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.MaxPreparingNodes = -1
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_warmup_period_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.WarmupPeriod = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_hook_poll_period_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.HookPollPeriod = -time.Second
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error creating operator")
+			}
+		})
+
+		t.Run("negative_reboot_pause_timeout_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.RebootPauseTimeout = -time.Hour
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
+
+		t.Run("reboot_budget_is_configured_without_member", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.RebootBudget = operator.NewRebootBudget(1)
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
+
+		t.Run("invalid_reboot_window_is_configured", func(t *testing.T) {
+			t.Parallel()
+
+			config := validOperatorConfig()
+			config.RebootWindowStart = "Mon 14"
+			config.RebootWindowLength = "0s"
+
+			if _, err := operator.New(config); err == nil {
+				t.Fatalf("Expected error")
+			}
+		})
+	})
+}
+
+func Test_Operator_exits_gracefully_when_user_requests_shutdown(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, fakeClient := testConfig(rebootCancelledNode)
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	updatedNode := node(contextWithDeadline(t), t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node", constants.LabelBeforeReboot)
+	}
+}
+
+//nolint:funlen // TODO: Should likely be refactored.
+func Test_Operator_shuts_down_leader_election_process_when_user_requests_shutdown(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, fakeClient := testConfig(rebootCancelledNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.ReconciliationPeriod = 1 * time.Second
+	config.LeaderElectionLease = 2 * time.Second
+	testKontroller := kontrollerWithObjects(t, config)
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		if err := testKontroller.Run(stop); err != nil {
+			fmt.Printf("Error running operator: %v\n", err)
+			t.Fail()
+		}
+		stopped <- struct{}{}
+	}()
+
+	// Wait for one reconciliation cycle to run.
+	<-nodeUpdated
+
+	ctx := contextWithDeadline(t)
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+
+	close(stop)
+
+	<-stopped
+
+	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating Node object: %v", err)
+	}
+
+	config.LockID = "bar"
+
+	parallelKontroller := kontrollerWithObjects(t, config)
+
+	stop = make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	go func() {
+		if err := parallelKontroller.Run(stop); err != nil {
+			fmt.Printf("Error running operator: %v\n", err)
+			t.Fail()
+		}
+	}()
+
+	time.Sleep(config.LeaderElectionLease * 2)
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_with_leader_handoff_grace_period_configured_releases_leadership_on_shutdown(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	config, _ := testConfig()
+	config.LeaderElectionLease = time.Minute
+	config.LeaderHandoffGracePeriod = 5 * time.Second
+
+	newReconciledKontroller := func(config operator.Config) (*operator.Kontroller, <-chan struct{}) {
+		reconciled := make(chan struct{}, 1)
+
+		config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+			select {
+			case reconciled <- struct{}{}:
+			default:
+			}
+
+			return true, "test", nil
+		})
+
+		return kontrollerWithObjects(t, config), reconciled
+	}
+
+	testKontroller, reconciled := newReconciledKontroller(config)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		if err := testKontroller.Run(stop); err != nil {
+			fmt.Printf("Error running operator: %v\n", err)
+			t.Fail()
+		}
+		stopped <- struct{}{}
+	}()
+
+	<-reconciled
+
+	close(stop)
+
+	<-stopped
+
+	config.LockID = "bar"
+
+	standbyKontroller, standbyReconciled := newReconciledKontroller(config)
+
+	standbyStop := make(chan struct{})
+	t.Cleanup(func() {
+		close(standbyStop)
+	})
+
+	go func() {
+		if err := standbyKontroller.Run(standbyStop); err != nil {
+			fmt.Printf("Error running operator: %v\n", err)
+			t.Fail()
+		}
+	}()
+
+	// Standby operator should take over well before the lease expires.
+	select {
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for standby operator to reconcile")
+	case <-time.After(config.LeaderElectionLease / 2):
+		t.Fatal("Standby operator did not take over leadership before lease expired")
+	case <-standbyReconciled:
+	}
+}
+
+func Test_Operator_emits_events_about_leader_election_to_configured_namespace(t *testing.T) {
+	t.Parallel()
+
+	config, fakeClient := testConfig()
+
+	<-process(contextWithDeadline(t), t, config, fakeClient)
+
+	events, err := config.Client.CoreV1().Events(config.Namespace).List(contextWithDeadline(t), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed listing events: %v", err)
+	}
+
+	if len(events.Items) == 0 {
+		t.Fatalf("Expected at least one event to be published")
+	}
+}
+
+func Test_Operator_emits_events_about_leader_election_to_configured_event_namespace(t *testing.T) {
+	t.Parallel()
+
+	eventNamespace := "events"
+
+	config, fakeClient := testConfig()
+	config.EventNamespace = eventNamespace
+
+	<-process(contextWithDeadline(t), t, config, fakeClient)
+
+	ctx := contextWithDeadline(t)
+
+	if _, err := config.Client.CoreV1().Namespaces().Get(ctx, eventNamespace, metav1.GetOptions{}); err != nil {
+		t.Fatalf("Expected event namespace to be created, got: %v", err)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for events to be published in namespace %q", eventNamespace)
+		case <-ticker.C:
+		}
+
+		events, err := config.Client.CoreV1().Events(eventNamespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed listing events: %v", err)
+		}
+
+		if len(events.Items) == 0 {
+			continue
+		}
+
+		// API server rejects events which namespace does not match namespace of the involved object.
+		for _, event := range events.Items {
+			if event.InvolvedObject.Namespace != event.Namespace {
+				t.Fatalf("Expected involved object namespace to be %q, got %q",
+					event.Namespace, event.InvolvedObject.Namespace)
+			}
+
+			involvedObject := event.InvolvedObject
+
+			_, err := config.Client.CoreV1().ConfigMaps(involvedObject.Namespace).Get(ctx, involvedObject.Name,
+				metav1.GetOptions{})
+			if involvedObject.Kind != "ConfigMap" || err != nil {
+				t.Fatalf("Expected event to be recorded against existing ConfigMap, got %v: %v", involvedObject, err)
+			}
+		}
+
+		return
+	}
+}
+
+func Test_Operator_returns_error_when_leadership_is_lost(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, fakeClient := testConfig(rebootCancelledNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.ReconciliationPeriod = 1 * time.Second
+	config.LeaderElectionLease = 2 * time.Second
+	testKontroller := kontrollerWithObjects(t, config)
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+
+	stop := make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- testKontroller.Run(stop)
+	}()
+
+	// Wait for one reconciliation cycle to run.
+	<-nodeUpdated
+
+	ctx := contextWithDeadline(t)
+
+	// Ensure operator is functional.
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+
+	stealLeaderElection(ctx, t, config)
+
+	// Wait lease time to ensure operator lost it.
+	time.Sleep(config.LeaderElectionLease)
+
+	// Patch node object again to verify if operator is functional.
+	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating Node object: %v", err)
+	}
+
+	time.Sleep(config.ReconciliationPeriod)
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
+	}
+
+	if err := <-errCh; err == nil {
+		t.Fatalf("Expected operator to return error when leader election is lost")
+	}
+}
+
+func Test_Operator_with_leader_election_periods_configured_reconciles_nodes(t *testing.T) {
+	t.Parallel()
+
+	config, fakeClient := testConfig(rebootCancelledNode())
+	config.LeaderElectionLease = 3 * time.Second
+	config.LeaderElectionRenewDeadline = 2 * time.Second
+	config.LeaderElectionRetryPeriod = 500 * time.Millisecond
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode().Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node", constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_acquires_leader_election_lock_of_configured_type(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		lockType          string
+		expectedConfigMap bool
+	}{
+		"configmap_by_default": {
+			expectedConfigMap: true,
+		},
+		"configmap": {
+			lockType:          "configmap",
+			expectedConfigMap: true,
+		},
+		"lease": {
+			lockType: "lease",
+		},
+		"leases": {
+			lockType: "leases",
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, fakeClient := testConfig()
+			config.LockType = testCase.lockType
+
+			ctx := contextWithDeadline(t)
+
+			<-process(ctx, t, config, fakeClient)
+
+			// Lease is maintained by all lock types, which allows migrating between them.
+			lease, err := config.Client.CoordinationV1().Leases(config.Namespace).Get(ctx,
+				"flatcar-linux-update-operator-lock", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Getting lock Lease: %v", err)
+			}
+
+			if holder := lease.Spec.HolderIdentity; holder == nil || *holder != config.LockID {
+				t.Fatalf("Expected Lease to be held by %q, got %v", config.LockID, holder)
+			}
+
+			configMaps, err := config.Client.CoreV1().ConfigMaps(config.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Listing ConfigMaps: %v", err)
+			}
+
+			if configMapCreated := len(configMaps.Items) > 0; configMapCreated != testCase.expectedConfigMap {
+				t.Fatalf("Expected lock ConfigMap to be created: %t, got ConfigMaps %v",
+					testCase.expectedConfigMap, configMaps.Items)
+			}
+		})
+	}
+}
+
+func stealLeaderElection(ctx context.Context, t *testing.T, config operator.Config) {
+	t.Helper()
+
+	configMapClient := config.Client.CoreV1().ConfigMaps(config.Namespace)
+
+	configMaps, err := configMapClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed listing ConfigMaps: %v", err)
+	}
+
+	if c := len(configMaps.Items); c != 1 {
+		t.Fatalf("Expected exactly one ConfigMap to exist, got %d", c)
+	}
+
+	lock := configMaps.Items[0]
 
 	leaderAnnotation := "control-plane.alpha.kubernetes.io/leader"
 
-	leader, ok := lock.Annotations[leaderAnnotation]
-	if !ok {
-		t.Fatalf("Expected annotation %q not found", leaderAnnotation)
+	leader, ok := lock.Annotations[leaderAnnotation]
+	if !ok {
+		t.Fatalf("Expected annotation %q not found", leaderAnnotation)
+	}
+
+	leaderLease := &struct {
+		HolderIdentity       string    `json:"holderIdentity"`
+		LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
+		AcquireTime          time.Time `json:"acquireTime"`
+		RenewTime            time.Time `json:"renewTime"`
+		LeaderTransitions    int       `json:"leaderTransitions"`
+	}{}
+
+	if err := json.Unmarshal([]byte(leader), leaderLease); err != nil {
+		t.Fatalf("Decoding leader annotation data %q: %v", leader, err)
+	}
+
+	leaderLease.HolderIdentity = "baz"
+
+	leaderBytes, err := json.Marshal(leaderLease)
+	if err != nil {
+		t.Fatalf("Encoding leader annotation data: %q: %v", leader, err)
+	}
+
+	lock.Annotations[leaderAnnotation] = string(leaderBytes)
+
+	if _, err := configMapClient.Update(ctx, &lock, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating lock ConfigMap: %v", err)
+	}
+}
+
+func Test_Operator_waits_for_leader_election_before_reconciliation(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, _ := testConfig(rebootCancelledNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.ReconciliationPeriod = 1 * time.Second
+	testKontroller := kontrollerWithObjects(t, config)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		if err := testKontroller.Run(stop); err != nil {
+			fmt.Printf("Error running operator: %v\n", err)
+			t.Fail()
+		}
+		stopped <- struct{}{}
+	}()
+
+	time.Sleep(config.ReconciliationPeriod)
+
+	close(stop)
+
+	<-stopped
+
+	ctx := contextWithDeadline(t)
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+
+	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating Node object: %v", err)
+	}
+
+	config.LockID = "bar"
+	parallelKontroller := kontrollerWithObjects(t, config)
+
+	stop = make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, parallelKontroller, stop)
+
+	time.Sleep(config.ReconciliationPeriod)
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_stops_reconciliation_loop_when_control_channel_is_closed(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, fakeClient := testConfig(rebootCancelledNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.ReconciliationPeriod = 1 * time.Second
+	testKontroller := kontrollerWithObjects(t, config)
+
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+
+	stop := make(chan struct{})
+
+	ctx := contextWithDeadline(t)
+
+	runOperator(ctx, t, testKontroller, stop)
+
+	<-nodeUpdated
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+
+	close(stop)
+
+	time.Sleep(config.ReconciliationPeriod * 2)
+
+	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating Node object: %v", err)
+	}
+
+	time.Sleep(config.ReconciliationPeriod * 2)
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_reconciles_objects_every_configured_period(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	config, _ := testConfig(rebootCancelledNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.ReconciliationPeriod = 1 * time.Second
+	testKontroller := kontrollerWithObjects(t, config)
+
+	stop := make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	ctx := contextWithDeadline(t)
+
+	runOperator(ctx, t, testKontroller, stop)
+
+	time.Sleep(config.ReconciliationPeriod)
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+
+	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating Node object: %v", err)
+	}
+
+	time.Sleep(config.ReconciliationPeriod * 2)
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected label %q to be removed from Node after waiting another reconciliation period",
+			constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_in_one_shot_mode(t *testing.T) {
+	t.Parallel()
+
+	pausedNode := rebootableNode()
+	pausedNode.Name = "paused"
+	pausedNode.Annotations[constants.AnnotationRebootPaused] = constants.True
+
+	cancelledNode := rebootableNode()
+	cancelledNode.Name = "cancelled"
+	cancelledNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+
+	approvedCancelledNode := rebootNotConfirmedNode()
+	approvedCancelledNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+
+	controlPlaneNode := rebootableNode()
+	controlPlaneNode.Name = "control-plane"
+	controlPlaneNode.Labels["node-role.kubernetes.io/control-plane"] = ""
+
+	// Node needing another reboot, which is not scheduled until it is uncordoned.
+	awaitingManualUncordonNode := rebootableNode()
+	awaitingManualUncordonNode.Name = "awaiting-manual-uncordon"
+	awaitingManualUncordonNode.Annotations[constants.AnnotationAwaitingManualUncordon] = constants.True
+
+	for name, nodes := range map[string][]runtime.Object{
+		"returns_when_all_nodes_have_converged":                           {idleNode(), pausedNode},
+		"returns_when_remaining_nodes_have_reboot_cancelled":              {cancelledNode, approvedCancelledNode},
+		"returns_when_remaining_nodes_are_exempt_control_plane_nodes":     {controlPlaneNode},
+		"returns_when_remaining_nodes_await_manual_uncordon_after_reboot": {awaitingManualUncordonNode},
+	} {
+		nodes := nodes
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, _ := testConfig(nodes...)
+			config.OneShot = true
+
+			errCh := make(chan error, 1)
+
+			go func() {
+				errCh <- kontrollerWithObjects(t, config).Run(make(chan struct{}))
+			}()
+
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			case <-contextWithDeadline(t).Done():
+				t.Fatalf("Timed out waiting for operator to return")
+			}
+		})
+	}
+
+	for name, n := range map[string]*corev1.Node{
+		"keeps_running_while_nodes_need_reboot":   rebootableNode(),
+		"keeps_running_while_nodes_are_rebooting": rebootingNode(),
+	} {
+		n := n
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, _ := testConfig(n)
+			config.OneShot = true
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			stop := make(chan struct{})
+			errCh := make(chan error, 1)
+
+			go func() {
+				errCh <- kontrollerWithObjects(t, config).Run(stop)
+			}()
+
+			select {
+			case err := <-errCh:
+				t.Fatalf("Unexpected operator return with error %v", err)
+			case <-time.After(10 * config.ReconciliationPeriod):
+			}
+
+			close(stop)
+
+			if err := <-errCh; err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func Test_Operator_in_dry_run_mode(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+	rebootableNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+	for name, n := range map[string]*corev1.Node{
+		"does_not_schedule_reboot_process_for_rebootable_nodes":         rebootableNode,
+		"does_not_approve_reboot_process_for_nodes_which_passed_checks": readyToRebootNode(),
+		"does_not_label_rebooted_nodes_with_after_reboot_label":         justRebootedNode(),
+	} {
+		n := n
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config, fakeClient := testConfig(n)
+			config.DryRun = true
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			fakeClient.PrependReactor("update", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				updatedNode, ok := action.(k8stesting.UpdateAction).GetObject().(*corev1.Node)
+				if !ok {
+					return false, nil, nil
+				}
+
+				for _, label := range []string{constants.LabelBeforeReboot, constants.LabelAfterReboot} {
+					if v := updatedNode.Labels[label]; v != n.Labels[label] {
+						t.Errorf("Unexpected label %q set to %q on node %q", label, v, updatedNode.Name)
+					}
+				}
+
+				okToReboot := constants.AnnotationOkToReboot
+				if v := updatedNode.Annotations[okToReboot]; v != n.Annotations[okToReboot] {
+					t.Errorf("Unexpected annotation %q set to %q on node %q", okToReboot, v, updatedNode.Name)
+				}
+
+				return false, nil, nil
+			})
+
+			ctx := contextWithDeadline(t)
+
+			reconciled := process(ctx, t, config, fakeClient)
+			<-reconciled
+			<-reconciled
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), n.Name)
+
+			for _, annotation := range []string{testBeforeRebootAnnotation, testAfterRebootAnnotation} {
+				if v := updatedNode.Annotations[annotation]; v != n.Annotations[annotation] {
+					t.Fatalf("Expected annotation %q to remain %q, got %q", annotation, n.Annotations[annotation], v)
+				}
+			}
+		})
+	}
+}
+
+// before-reboot label is intended to be used as a selector for pre-reboot hooks, so it should only
+// be set for nodes, which are ready to start rebooting any minute.
+func Test_Operator_cleans_up_nodes_which_cannot_be_rebooted(t *testing.T) {
+	t.Parallel()
+
+	rebootCancelledNode := rebootCancelledNode()
+
+	toBeRebootedNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "bar",
+			Annotations: map[string]string{
+				testBeforeRebootAnnotation: "",
+			},
+		},
+	}
+
+	config, fakeClient := testConfig(rebootCancelledNode, toBeRebootedNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+
+	t.Run("by", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("removing_before_reboot_label", func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected label %q found", constants.LabelBeforeReboot)
+			}
+		})
+
+		t.Run("removing_configured_before_reboot_annotations", func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; ok {
+				t.Fatalf("Unexpected annotation %q found for node %q", testBeforeRebootAnnotation, rebootCancelledNode.Name)
+			}
+
+			updatedToBeRebootedNode := node(ctx, t, config.Client.CoreV1().Nodes(), toBeRebootedNode.Name)
+
+			if _, ok := updatedToBeRebootedNode.Annotations[testBeforeRebootAnnotation]; !ok {
+				t.Fatalf("Annotation %q has been removed from wrong node %q",
+					testBeforeRebootAnnotation, toBeRebootedNode.Name)
+			}
+		})
+	})
+
+	// To avoid rebooting nodes which executed before-reboot hooks, but don't need a reboot anymore.
+	t.Run("before_reboot_is_approved", func(t *testing.T) {
+		t.Parallel()
+
+		if v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]; ok && v == "true" {
+			t.Fatalf("Unexpected reboot approval")
+		}
+	})
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_on_startup_removes_after_reboot_label_from_nodes_which_are_not_waiting_for_after_reboot_checks(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	t.Run("when_reboot_is_no_longer_approved", func(t *testing.T) {
+		t.Parallel()
+
+		staleNode := finishedRebootingNode()
+		staleNode.Annotations[constants.AnnotationOkToReboot] = constants.False
+		staleNode.Annotations[constants.AnnotationRebootNeeded] = constants.False
+		staleNode.Annotations[testAfterRebootAnnotation] = constants.False
+		staleNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+		staleNode.Spec.Unschedulable = true
+
+		config, fakeClient := testConfig(staleNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), staleNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; ok {
+			t.Fatalf("Unexpected label %q found", constants.LabelAfterReboot)
+		}
+
+		if _, ok := updatedNode.Annotations[testAfterRebootAnnotation]; ok {
+			t.Fatalf("Unexpected annotation %q found", testAfterRebootAnnotation)
+		}
+
+		if updatedNode.Spec.Unschedulable {
+			t.Fatalf("Expected node to be made schedulable")
+		}
+	})
+
+	t.Run("when_node_is_still_rebooting", func(t *testing.T) {
+		t.Parallel()
+
+		staleNode := rebootingNode()
+		staleNode.Labels[constants.LabelAfterReboot] = constants.True
+
+		config, fakeClient := testConfig(staleNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), staleNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; ok {
+			t.Fatalf("Unexpected label %q found", constants.LabelAfterReboot)
+		}
+
+		if updatedNode.Annotations[constants.AnnotationOkToReboot] != constants.True {
+			t.Fatalf("Expected reboot approval to be kept for rebooting node")
+		}
+	})
+
+	t.Run("but_keeps_it_on_nodes_waiting_for_after_reboot_checks", func(t *testing.T) {
+		t.Parallel()
+
+		finishedRebootingNode := finishedRebootingNode()
+		finishedRebootingNode.Annotations[testAfterRebootAnnotation] = constants.False
+
+		config, fakeClient := testConfig(finishedRebootingNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), finishedRebootingNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; !ok {
+			t.Fatalf("Expected label %q to be kept", constants.LabelAfterReboot)
+		}
+	})
+}
+
+func Test_Operator_withdraws_reboot_approval_from_nodes_which_reboot_has_been_cancelled(t *testing.T) {
+	t.Parallel()
+
+	rebootNotConfirmedNode := rebootNotConfirmedNode()
+	rebootNotConfirmedNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+
+	rebootingNode := rebootingNode()
+	rebootingNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+
+	config, fakeClient := testConfig(rebootNotConfirmedNode, rebootingNode)
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	t.Run("when_agent_has_not_started_rebooting_yet", func(t *testing.T) {
+		t.Parallel()
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootNotConfirmedNode.Name)
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.False {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationOkToReboot, constants.False, v)
+		}
+	})
+
+	// Reboot which is already in progress cannot be cancelled.
+	t.Run("except_when_agent_is_already_rebooting", func(t *testing.T) {
+		t.Parallel()
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootingNode.Name)
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationOkToReboot, constants.True, v)
+		}
+	})
+}
+
+func Test_Operator_does_not_count_nodes_as_rebooting_which(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]struct {
+		expectedNodeUpdates int
+		extraNode           *corev1.Node
+	}{
+		"has_finished_rebooting": {
+			expectedNodeUpdates: 3,
+			extraNode:           finishedRebootingNode(),
+		},
+		"are_idle": {
+			expectedNodeUpdates: 2,
+			extraNode:           idleNode(),
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+
+			config, fakeClient := testConfig(testCase.extraNode, rebootableNode)
+
+			nodeUpdated := nodeUpdatedNTimes(fakeClient, testCase.expectedNodeUpdates)
+			<-process(ctx, t, config, fakeClient)
+			<-nodeUpdated
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+			beforeReboot, ok := updatedNode.Labels[constants.LabelBeforeReboot]
+			if !ok {
+				t.Fatalf("Expected label %q", constants.LabelBeforeReboot)
+			}
+
+			if beforeReboot != constants.True {
+				t.Fatalf("Expected value %q for label %q, got: %q", constants.True, constants.LabelBeforeReboot, beforeReboot)
+			}
+		})
+	}
+}
+
+// This test attempts to schedule a reboot for a schedulable node and depending on the
+// state of the rebooting node, controller will either proceed with scheduling or will
+// not do anything.
+func Test_Operator_counts_nodes_as_rebooting_which(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]*corev1.Node{
+		"are_scheduled_for_reboot_already": scheduledForRebootNode(),
+		"are_ready_to_reboot":              readyToRebootNode(),
+		"has_reboot_approved":              rebootNotConfirmedNode(),
+		"are_rebooting":                    rebootingNode(),
+		"just_rebooted":                    justRebootedNode(),
+	}
+
+	for name, extraNode := range cases {
+		extraNode := extraNode
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+
+			config, fakeClient := testConfig(extraNode, rebootableNode)
+
+			// Required to test selecting rebooting nodes only with before-reboot label, otherwise
+			// it gets removed before we schedule nodes for rebooting.
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected node %q scheduled for rebooting", rebootableNode.Name)
+			}
+		})
+	}
+}
+
+func Test_Operator_does_not_count_nodes_as_rebootable_which(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]func(*corev1.Node){
+		"do_not_require_reboot": func(updatedNode *corev1.Node) {
+			updatedNode.Annotations[constants.AnnotationRebootNeeded] = constants.False
+		},
+		"are_already_rebooting": func(updatedNode *corev1.Node) {
+			*updatedNode = *rebootingNode()
+			updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+			updatedNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.True
+		},
+		"has_reboot_paused": func(updatedNode *corev1.Node) {
+			updatedNode.Annotations[constants.AnnotationRebootPaused] = constants.True
+		},
+		"has_reboot_cancelled": func(updatedNode *corev1.Node) {
+			updatedNode.Annotations[constants.AnnotationCancelReboot] = constants.True
+		},
+		"has_reboot_already_scheduled": func(updatedNode *corev1.Node) {
+			updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
+			updatedNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.False
+		},
+		"are_labeled_as_control_plane_nodes": func(updatedNode *corev1.Node) {
+			updatedNode.Labels["node-role.kubernetes.io/control-plane"] = ""
+		},
+		"are_labeled_as_master_nodes": func(updatedNode *corev1.Node) {
+			updatedNode.Labels["node-role.kubernetes.io/master"] = ""
+		},
+		"are_tainted_as_control_plane_nodes": func(updatedNode *corev1.Node) {
+			updatedNode.Spec.Taints = append(updatedNode.Spec.Taints, corev1.Taint{
+				Key:    "node-role.kubernetes.io/control-plane",
+				Effect: corev1.TaintEffectNoSchedule,
+			})
+		},
+	}
+
+	for name, mutateF := range cases {
+		mutateF := mutateF
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+			rebootableNode.Annotations[testBeforeRebootAnnotation] = constants.True
+			rebootableNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.True
+
+			mutateF(rebootableNode)
+
+			config, fakeClient := testConfig(rebootableNode)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation, testAnotherBeforeRebootAnnotation}
+			// To test filter on before-reboot label.
+			config.MaxRebootingNodes = 2
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; !ok {
+				t.Fatalf("Unexpected node %q scheduled for rebooting", rebootableNode.Name)
+			}
+		})
+	}
+}
+
+func Test_Operator_counts_nodes_as_rebootable_which_needs_reboot_and_has_all_other_conditions_met(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(rebootableNode)
+
+	ctx := contextWithDeadline(t)
+
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+	<-process(ctx, t, config, fakeClient)
+	<-nodeUpdated
+
+	updatedNode := node(contextWithDeadline(t), t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+	v, ok := updatedNode.Labels[constants.LabelBeforeReboot]
+	if !ok || v != constants.True {
+		t.Fatalf("Expected node %q to be scheduled for rebooting", rebootableNode.Name)
+	}
+}
+
+func Test_Operator_with_control_plane_reboots_enabled_schedules_reboot_process_for_control_plane_nodes(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+	rebootableNode.Labels["node-role.kubernetes.io/control-plane"] = ""
+
+	config, fakeClient := testConfig(rebootableNode)
+	config.RebootControlPlane = true
+
+	ctx := contextWithDeadline(t)
+
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+	<-process(ctx, t, config, fakeClient)
+	<-nodeUpdated
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected control-plane node %q to be scheduled for reboot", rebootableNode.Name)
+	}
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_reboot_deadline_configured(t *testing.T) {
+	t.Parallel()
+
+	longAgo := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+
+	overdueNode := func() *corev1.Node {
+		n := rebootableNode()
+		n.Name = "overdue"
+		n.Annotations[constants.AnnotationPhaseTransitionTime] = longAgo
+
+		return n
+	}
+
+	t.Run("schedules_reboot_process_for_overdue_nodes_first", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootableNode := rebootableNode()
+		rebootableNode.Name = "a-rebootable"
+		overdueNode := overdueNode()
+
+		config, _ := testConfig(rebootableNode, overdueNode)
+		config.RebootDeadline = time.Hour
+		config.NodeOrdering = operator.NodeOrderingOldestFirst
+
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+
+		runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+		nc := config.Client.CoreV1().Nodes()
+
+		waitForNodeLabel(ctx, t, nc, overdueNode.Name, constants.LabelBeforeReboot)
+
+		if _, ok := node(ctx, t, nc, rebootableNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+			t.Fatalf("Expected node %q not to be scheduled for reboot", rebootableNode.Name)
+		}
+	})
+
+	t.Run("schedules_reboot_process_for_overdue_nodes_beyond_max_rebooting_nodes_when_configured",
+		func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			overdueNode := overdueNode()
+
+			config, _ := testConfig(rebootingNode(), overdueNode)
+			config.RebootDeadline = time.Hour
+			config.OverdueMaxRebootingNodes = 2
+
+			stop := make(chan struct{})
+			t.Cleanup(func() { close(stop) })
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			waitForNodeLabel(ctx, t, config.Client.CoreV1().Nodes(), overdueNode.Name, constants.LabelBeforeReboot)
+			waitForWarningEvent(ctx, t, config.Client, overdueNode.Name, "RebootDeadlineExceeded")
+		})
+
+	t.Run("does_not_schedule_reboot_process_for_overdue_nodes_beyond_configured_max_rebooting_nodes",
+		func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			overdueNode := overdueNode()
+			anotherOverdueNode := overdueNode.DeepCopy()
+			anotherOverdueNode.Name = "another-overdue"
+
+			config, fakeClient := testConfig(rebootingNode(), overdueNode, anotherOverdueNode)
+			config.RebootDeadline = time.Hour
+			config.OverdueMaxRebootingNodes = 2
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			scheduled := 0
+
+			for _, name := range []string{overdueNode.Name, anotherOverdueNode.Name} {
+				updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), name)
+
+				_, beforeReboot := updatedNode.Labels[constants.LabelBeforeReboot]
+				if beforeReboot || updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True {
+					scheduled++
+				}
+			}
+
+			if scheduled != 1 {
+				t.Fatalf("Expected exactly one overdue node to be scheduled for reboot, got %d", scheduled)
+			}
+		})
+
+	t.Run("does_not_schedule_reboot_process_for_overdue_nodes_beyond_shared_reboot_budget", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		overdueNode := overdueNode()
+
+		config, fakeClient := testConfig(rebootingNode(), overdueNode)
+		config.RebootDeadline = time.Hour
+		config.OverdueMaxRebootingNodes = 2
+		config.RebootBudget = operator.NewRebootBudget(1)
+		config.RebootBudgetMember = "first"
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for the second cycle, so the first one has completed.
+		<-reconcileCycle
+		<-reconcileCycle
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), overdueNode.Name)
+
+		_, beforeReboot := updatedNode.Labels[constants.LabelBeforeReboot]
+		if beforeReboot || updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True {
+			t.Fatalf("Unexpected overdue node scheduled for reboot beyond shared reboot budget")
+		}
+	})
+
+	t.Run("reserves_shared_reboot_budget_for_overdue_nodes_scheduled_beyond_max_rebooting_nodes", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootBudget := operator.NewRebootBudget(2)
+
+		overdueNode := overdueNode()
+
+		firstConfig, _ := testConfig(rebootingNode(), overdueNode)
+		firstConfig.RebootDeadline = time.Hour
+		firstConfig.OverdueMaxRebootingNodes = 2
+		firstConfig.RebootBudget = rebootBudget
+		firstConfig.RebootBudgetMember = "first"
+
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+
+		runOperator(ctx, t, kontrollerWithObjects(t, firstConfig), stop)
+
+		waitForNodeLabel(ctx, t, firstConfig.Client.CoreV1().Nodes(), overdueNode.Name, constants.LabelBeforeReboot)
+
+		secondNode := rebootableNode()
+		secondConfig, secondFakeClient := testConfig(secondNode)
+		secondConfig.RebootBudget = rebootBudget
+		secondConfig.RebootBudgetMember = "second"
+		secondConfig.ReconciliationPeriod = 100 * time.Millisecond
+
+		// Wait for the second cycle, so the first one has completed.
+		secondCycles := process(ctx, t, secondConfig, secondFakeClient)
+		<-secondCycles
+		<-secondCycles
+
+		updatedNode := node(ctx, t, secondConfig.Client.CoreV1().Nodes(), secondNode.Name)
+
+		_, beforeReboot := updatedNode.Labels[constants.LabelBeforeReboot]
+		if beforeReboot || updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True {
+			t.Fatalf("Unexpected node scheduled for reboot while budget is used by overdue node of other operator")
+		}
+	})
+
+	t.Run("does_not_schedule_reboot_process_for_overdue_nodes_beyond_max_rebooting_nodes_by_default",
+		func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			overdueNode := overdueNode()
+
+			config, fakeClient := testConfig(rebootingNode(), overdueNode)
+			config.RebootDeadline = time.Hour
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), overdueNode.Name)
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Expected overdue node %q not to be scheduled for reboot", overdueNode.Name)
+			}
+		})
+}
+
+func Test_Operator_with_adaptive_max_rebooting_nodes_configured_schedules_reboot_process_for(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		formula           string
+		nodes             int
+		expectedScheduled int
+	}{
+		"percentage_of_nodes_rounded_down_by_default": {
+			formula:           "percent=20",
+			nodes:             9,
+			expectedScheduled: 1,
+		},
+		"percentage_of_nodes_rounded_up_when_configured": {
+			formula:           "percent=20,rounding=ceil",
+			nodes:             6,
+			expectedScheduled: 2,
+		},
+		"at_least_configured_minimum_number_of_nodes": {
+			formula:           "percent=10,min=2",
+			nodes:             3,
+			expectedScheduled: 2,
+		},
+		"at_most_configured_maximum_number_of_nodes": {
+			formula:           "percent=50,max=2",
+			nodes:             8,
+			expectedScheduled: 2,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			nodes := []runtime.Object{}
+
+			for i := 0; i < testCase.nodes; i++ {
+				n := rebootableNode()
+				n.Name = fmt.Sprintf("rebootable-%d", i)
+				nodes = append(nodes, n)
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.AdaptiveMaxRebootingNodes = testCase.formula
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			nodeList, err := config.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Listing nodes: %v", err)
+			}
+
+			scheduled := 0
+
+			for _, n := range nodeList.Items {
+				if n.Labels[constants.LabelBeforeReboot] == constants.True {
+					scheduled++
+				}
+			}
+
+			if scheduled != testCase.expectedScheduled {
+				t.Fatalf("Expected %d nodes to be scheduled for reboot, got %d", testCase.expectedScheduled, scheduled)
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_max_rebooting_nodes_percent_configured_schedules_reboot_process_for(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		percent           int
+		maxRebootingNodes int
+		nodes             int
+		expectedScheduled int
+	}{
+		"percentage_of_nodes_rounded_down": {
+			percent:           50,
+			nodes:             5,
+			expectedScheduled: 2,
+		},
+		"at_least_one_node_in_small_cluster": {
+			percent:           20,
+			nodes:             4,
+			expectedScheduled: 1,
+		},
+		"all_nodes_when_percentage_is_100": {
+			percent:           100,
+			nodes:             3,
+			expectedScheduled: 3,
+		},
+		"configured_maximum_number_of_nodes_when_it_is_smaller": {
+			percent:           50,
+			maxRebootingNodes: 2,
+			nodes:             8,
+			expectedScheduled: 2,
+		},
+		"percentage_of_nodes_when_it_is_smaller_than_configured_maximum_number_of_nodes": {
+			percent:           25,
+			maxRebootingNodes: 5,
+			nodes:             8,
+			expectedScheduled: 2,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			nodes := []runtime.Object{}
+
+			for i := 0; i < testCase.nodes; i++ {
+				n := rebootableNode()
+				n.Name = fmt.Sprintf("rebootable-%d", i)
+				nodes = append(nodes, n)
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.MaxRebootingNodesPercent = testCase.percent
+			config.MaxRebootingNodes = testCase.maxRebootingNodes
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			nodeList, err := config.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Listing nodes: %v", err)
+			}
+
+			scheduled := 0
+
+			for _, n := range nodeList.Items {
+				if n.Labels[constants.LabelBeforeReboot] == constants.True {
+					scheduled++
+				}
+			}
+
+			if scheduled != testCase.expectedScheduled {
+				t.Fatalf("Expected %d nodes to be scheduled for reboot, got %d", testCase.expectedScheduled, scheduled)
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_min_ready_nodes_configured(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		minReadyNodes        int
+		minReadyNodesPercent int
+		notReadyNodes        int
+		expectBlocked        bool
+	}{
+		"does_not_schedule_nor_approve_reboot_process_when_fewer_nodes_are_ready": {
+			minReadyNodes: 3,
+			notReadyNodes: 1,
+			expectBlocked: true,
+		},
+		"does_not_schedule_nor_approve_reboot_process_when_smaller_percentage_of_nodes_is_ready": {
+			minReadyNodesPercent: 75,
+			notReadyNodes:        2,
+			expectBlocked:        true,
+		},
+		"schedules_and_approves_reboot_process_when_enough_nodes_are_ready": {
+			minReadyNodes:        2,
+			minReadyNodesPercent: 50,
+			notReadyNodes:        1,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			rebootableNode := rebootableNode()
+			readyToRebootNode := readyToRebootNode()
+			nodes := []runtime.Object{rebootableNode, readyToRebootNode}
+
+			for i := 0; i < testCase.notReadyNodes; i++ {
+				n := idleNode()
+				n.Name = fmt.Sprintf("not-ready-%d", i)
+				n.Status.Conditions = []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+				}
+				nodes = append(nodes, n)
+			}
+
+			for _, n := range []*corev1.Node{rebootableNode, readyToRebootNode} {
+				n.Status.Conditions = []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				}
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.MaxRebootingNodes = 2
+			config.MinReadyNodes = testCase.minReadyNodes
+			config.MinReadyNodesPercent = testCase.minReadyNodesPercent
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			nc := config.Client.CoreV1().Nodes()
+
+			_, scheduled := node(ctx, t, nc, rebootableNode.Name).Labels[constants.LabelBeforeReboot]
+			approved := node(ctx, t, nc, readyToRebootNode.Name).Annotations[constants.AnnotationOkToReboot] ==
+				constants.True
+
+			if !testCase.expectBlocked {
+				if !scheduled || !approved {
+					t.Fatalf("Expected node %q to be scheduled and node %q to be approved for reboot",
+						rebootableNode.Name, readyToRebootNode.Name)
+				}
+
+				return
+			}
+
+			if scheduled {
+				t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+			}
+
+			if approved {
+				t.Fatalf("Unexpected node %q approved for reboot", readyToRebootNode.Name)
+			}
+
+			waitForEvent(ctx, t, config.Client, rebootableNode.Name, corev1.EventTypeNormal,
+				"RebootBlockedByNotReadyNodes")
+		})
+	}
+}
+
+func Test_Operator_with_min_ready_nodes_configured_emits_event_about_not_ready_nodes_once_per_waiting_node(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(rebootableNode)
+	config.MinReadyNodes = 2
+	config.ReconciliationPeriod = 100 * time.Millisecond
+
+	reconcileCycle := process(ctx, t, config, fakeClient)
+
+	waitForEvent(ctx, t, config.Client, rebootableNode.Name, corev1.EventTypeNormal, "RebootBlockedByNotReadyNodes")
+
+	// Wait for few more cycles, so events from them are recorded as well.
+	for i := 0; i < 3; i++ {
+		<-reconcileCycle
+	}
+
+	events, err := config.Client.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed listing events: %v", err)
+	}
+
+	emitted := int32(0)
+
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name == rebootableNode.Name && event.Reason == "RebootBlockedByNotReadyNodes" {
+			emitted += event.Count
+		}
+	}
+
+	if emitted != 1 {
+		t.Fatalf("Expected event about not ready nodes to be emitted once, got %d", emitted)
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_max_rebooting_nodes_per_zone_configured_schedules_reboot_process_for(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		zoneLabel         string
+		rebootableZones   []string
+		rebootingZones    []string
+		expectedScheduled int
+	}{
+		"no_more_nodes_in_zone_than_configured": {
+			rebootableZones:   []string{"a", "a", "a", "b"},
+			expectedScheduled: 3,
+		},
+		"nodes_in_zone_counting_nodes_which_are_already_rebooting": {
+			rebootableZones:   []string{"a", "a", "b"},
+			rebootingZones:    []string{"a"},
+			expectedScheduled: 2,
+		},
+		"nodes_without_zone_label_as_nodes_in_the_same_zone": {
+			rebootableZones:   []string{"", "", ""},
+			expectedScheduled: 2,
+		},
+		"nodes_in_zone_given_by_configured_zone_label": {
+			zoneLabel:         "example.com/rack",
+			rebootableZones:   []string{"a", "a", "a", "b", "b"},
+			expectedScheduled: 4,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			zoneLabel := corev1.LabelTopologyZone
+			if testCase.zoneLabel != "" {
+				zoneLabel = testCase.zoneLabel
+			}
+
+			nodes := []runtime.Object{}
+
+			for i, zone := range testCase.rebootableZones {
+				n := rebootableNode()
+				n.Name = fmt.Sprintf("rebootable-%d", i)
+
+				if zone != "" {
+					n.Labels[zoneLabel] = zone
+				}
+
+				nodes = append(nodes, n)
+			}
+
+			for i, zone := range testCase.rebootingZones {
+				n := rebootingNode()
+				n.Name = fmt.Sprintf("rebooting-%d", i)
+				n.Labels[zoneLabel] = zone
+				nodes = append(nodes, n)
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.MaxRebootingNodes = 10
+			config.MaxRebootingNodesPerZone = 2
+			config.ZoneLabel = testCase.zoneLabel
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			nodeList, err := config.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Listing nodes: %v", err)
+			}
+
+			scheduled := 0
+
+			for _, n := range nodeList.Items {
+				if n.Labels[constants.LabelBeforeReboot] == constants.True {
+					scheduled++
+				}
+			}
+
+			if scheduled != testCase.expectedScheduled {
+				t.Fatalf("Expected %d nodes to be scheduled for reboot, got %d", testCase.expectedScheduled, scheduled)
+			}
+		})
+	}
+}
+
+func Test_Operator_with_max_rebooting_nodes_per_zone_configured_emits_event_for_node_deferred_by_zone_limit(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	nodes := []runtime.Object{}
+
+	for i := 0; i < 2; i++ {
+		n := rebootableNode()
+		n.Name = fmt.Sprintf("rebootable-%d", i)
+		n.Labels[corev1.LabelTopologyZone] = "a"
+		nodes = append(nodes, n)
+	}
+
+	config, fakeClient := testConfig(nodes...)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.MaxRebootingNodes = 2
+	config.MaxRebootingNodesPerZone = 1
+
+	ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+	t.Cleanup(cancel)
+
+	<-process(ctx, t, config, fakeClient)
+
+	// Which of the nodes gets deferred is not deterministic, so accept event about any of them.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for event about reboot of one of the nodes being deferred")
+		case <-ticker.C:
+		}
+
+		events, err := config.Client.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed listing events: %v", err)
+		}
+
+		for _, event := range events.Items {
+			if event.InvolvedObject.Kind == "Node" && event.Type == corev1.EventTypeNormal &&
+				event.Reason == "RebootDeferredByZoneLimit" {
+				return
+			}
+		}
+	}
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_after_reboot_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	afterRebootNode := func(since time.Duration) *corev1.Node {
+		n := finishedRebootingNode()
+		n.Annotations[testAfterRebootAnnotation] = constants.False
+		n.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-since).UTC().Format(time.RFC3339)
+
+		return n
+	}
+
+	t.Run("finalizes_reboot_of_node_which_has_not_passed_after_reboot_checks_in_time_by_default", func(t *testing.T) {
+		t.Parallel()
+
+		timedOutNode := afterRebootNode(2 * time.Hour)
+
+		config, fakeClient := testConfig(timedOutNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.AfterRebootTimeout = time.Hour
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), timedOutNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; ok {
+			t.Fatalf("Unexpected label %q found", constants.LabelAfterReboot)
+		}
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.False {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationOkToReboot, constants.False, v)
+		}
+
+		waitForWarningEvent(ctx, t, config.Client, timedOutNode.Name, "AfterRebootChecksTimedOut")
+	})
+
+	t.Run("holds_node_which_has_not_passed_after_reboot_checks_in_time_when_configured", func(t *testing.T) {
+		t.Parallel()
+
+		timedOutNode := afterRebootNode(2 * time.Hour)
+
+		config, fakeClient := testConfig(timedOutNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.AfterRebootTimeout = time.Hour
+		config.AfterRebootTimeoutPolicy = operator.AfterRebootTimeoutPolicyHold
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), timedOutNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; !ok {
+			t.Fatalf("Expected label %q to be kept", constants.LabelAfterReboot)
+		}
+
+		if v := updatedNode.Annotations[constants.AnnotationAfterRebootTimedOut]; v != constants.True {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationAfterRebootTimedOut, constants.True, v)
+		}
+
+		waitForWarningEvent(ctx, t, config.Client, timedOutNode.Name, "AfterRebootChecksTimedOut")
+	})
+
+	t.Run("keeps_waiting_for_after_reboot_checks_within_timeout", func(t *testing.T) {
+		t.Parallel()
+
+		afterRebootNode := afterRebootNode(time.Minute)
+
+		config, fakeClient := testConfig(afterRebootNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.AfterRebootTimeout = time.Hour
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), afterRebootNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; !ok {
+			t.Fatalf("Expected label %q to be kept", constants.LabelAfterReboot)
+		}
+
+		if _, ok := updatedNode.Annotations[constants.AnnotationAfterRebootTimedOut]; ok {
+			t.Fatalf("Unexpected annotation %q found", constants.AnnotationAfterRebootTimedOut)
+		}
+	})
+
+	t.Run("removes_after_reboot_timed_out_annotation_once_held_node_passes_after_reboot_checks", func(t *testing.T) {
+		t.Parallel()
+
+		heldNode := afterRebootNode(2 * time.Hour)
+		heldNode.Annotations[testAfterRebootAnnotation] = constants.True
+		heldNode.Annotations[constants.AnnotationAfterRebootTimedOut] = constants.True
+
+		config, fakeClient := testConfig(heldNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.AfterRebootTimeout = time.Hour
+		config.AfterRebootTimeoutPolicy = operator.AfterRebootTimeoutPolicyHold
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		ctx := contextWithDeadline(t)
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for the second cycle, so the first one has completed.
+		<-reconcileCycle
+		<-reconcileCycle
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), heldNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; ok {
+			t.Fatalf("Unexpected label %q found", constants.LabelAfterReboot)
+		}
+
+		if _, ok := updatedNode.Annotations[constants.AnnotationAfterRebootTimedOut]; ok {
+			t.Fatalf("Unexpected annotation %q found", constants.AnnotationAfterRebootTimedOut)
+		}
+	})
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_before_reboot_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	beforeRebootNode := func(since time.Duration) *corev1.Node {
+		n := scheduledForRebootNode()
+		n.Annotations[testBeforeRebootAnnotation] = constants.False
+		n.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-since).UTC().Format(time.RFC3339)
+
+		return n
+	}
+
+	t.Run("marks_node_which_has_not_passed_before_reboot_checks_in_time_as_timed_out", func(t *testing.T) {
+		t.Parallel()
+
+		timedOutNode := beforeRebootNode(2 * time.Hour)
+
+		config, fakeClient := testConfig(timedOutNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.BeforeRebootTimeout = time.Hour
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), timedOutNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+			t.Fatalf("Expected label %q to be kept", constants.LabelBeforeReboot)
+		}
+
+		if v := updatedNode.Annotations[constants.AnnotationBeforeRebootTimedOut]; v != constants.True {
+			t.Fatalf("Expected annotation %q to be %q, got %q",
+				constants.AnnotationBeforeRebootTimedOut, constants.True, v)
+		}
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.False {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationOkToReboot, constants.False, v)
+		}
+
+		waitForWarningEvent(ctx, t, config.Client, timedOutNode.Name, "BeforeRebootChecksTimedOut")
+	})
+
+	t.Run("keeps_waiting_for_before_reboot_checks_within_timeout", func(t *testing.T) {
+		t.Parallel()
+
+		beforeRebootNode := beforeRebootNode(time.Minute)
+
+		config, fakeClient := testConfig(beforeRebootNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.BeforeRebootTimeout = time.Hour
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), beforeRebootNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+			t.Fatalf("Expected label %q to be kept", constants.LabelBeforeReboot)
+		}
+
+		if _, ok := updatedNode.Annotations[constants.AnnotationBeforeRebootTimedOut]; ok {
+			t.Fatalf("Unexpected annotation %q found", constants.AnnotationBeforeRebootTimedOut)
+		}
+	})
+
+	t.Run("removes_before_reboot_timed_out_annotation_once_node_passes_before_reboot_checks", func(t *testing.T) {
+		t.Parallel()
+
+		timedOutNode := beforeRebootNode(2 * time.Hour)
+		timedOutNode.Annotations[testBeforeRebootAnnotation] = constants.True
+		timedOutNode.Annotations[constants.AnnotationBeforeRebootTimedOut] = constants.True
+
+		config, fakeClient := testConfig(timedOutNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.BeforeRebootTimeout = time.Hour
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		ctx := contextWithDeadline(t)
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for the second cycle, so the first one has completed.
+		<-reconcileCycle
+		<-reconcileCycle
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), timedOutNode.Name)
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationOkToReboot, constants.True, v)
+		}
+
+		if _, ok := updatedNode.Annotations[constants.AnnotationBeforeRebootTimedOut]; ok {
+			t.Fatalf("Unexpected annotation %q found", constants.AnnotationBeforeRebootTimedOut)
+		}
+	})
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_max_preparing_nodes_configured(t *testing.T) {
+	t.Parallel()
+
+	rebootableNodes := func(names ...string) []runtime.Object {
+		nodes := []runtime.Object{}
+
+		for _, name := range names {
+			n := rebootableNode()
+			n.Name = name
+			nodes = append(nodes, n)
+		}
+
+		return nodes
+	}
+
+	countNodesWithBeforeRebootLabel := func(ctx context.Context, t *testing.T, config operator.Config) int {
+		t.Helper()
+
+		nodes, err := config.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Listing nodes: %v", err)
+		}
+
+		count := 0
+
+		for _, n := range nodes.Items {
+			if n.Labels[constants.LabelBeforeReboot] == constants.True {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	t.Run("schedules_reboot_process_for_nodes_while_other_node_is_rebooting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		config, fakeClient := testConfig(append(rebootableNodes("foo", "bar"), rebootingNode())...)
+		config.MaxPreparingNodes = 2
+
+		<-process(ctx, t, config, fakeClient)
+
+		for _, name := range []string{"foo", "bar"} {
+			waitForNodeLabel(ctx, t, config.Client.CoreV1().Nodes(), name, constants.LabelBeforeReboot)
+		}
+	})
+
+	t.Run("does_not_schedule_reboot_process_for_more_nodes_than_configured", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		config, fakeClient := testConfig(append(rebootableNodes("foo", "bar", "baz"), rebootingNode())...)
+		config.MaxPreparingNodes = 2
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for the second cycle, so the first one has completed.
+		<-reconcileCycle
+		<-reconcileCycle
+
+		if count := countNodesWithBeforeRebootLabel(ctx, t, config); count != config.MaxPreparingNodes {
+			t.Fatalf("Expected %d nodes to be scheduled for reboot, got %d", config.MaxPreparingNodes, count)
+		}
+	})
+
+	t.Run("does_not_approve_reboot_of_more_nodes_than_max_rebooting_nodes", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		readyToRebootNode := readyToRebootNode()
+		anotherReadyToRebootNode := readyToRebootNode.DeepCopy()
+		anotherReadyToRebootNode.Name = "another-ready-to-reboot"
+
+		config, fakeClient := testConfig(readyToRebootNode, anotherReadyToRebootNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.MaxPreparingNodes = 2
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for the second cycle, so the first one has completed.
+		<-reconcileCycle
+		<-reconcileCycle
+
+		approved := 0
+
+		for _, name := range []string{readyToRebootNode.Name, anotherReadyToRebootNode.Name} {
+			if node(ctx, t, config.Client.CoreV1().Nodes(), name).Annotations[constants.AnnotationOkToReboot] ==
+				constants.True {
+				approved++
+			}
+		}
+
+		if approved != 1 {
+			t.Fatalf("Expected exactly one node to be approved for reboot, got %d", approved)
+		}
+	})
+
+	t.Run("does_not_approve_reboot_while_max_rebooting_nodes_are_rebooting", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		readyToRebootNode := readyToRebootNode()
+
+		config, fakeClient := testConfig(readyToRebootNode, rebootingNode())
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.MaxPreparingNodes = 2
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+		if updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True {
+			t.Fatalf("Expected node %q reboot not to be approved", readyToRebootNode.Name)
+		}
+	})
+
+	t.Run("approves_reboot_of_overdue_nodes_beyond_max_rebooting_nodes_when_configured", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		preparingNode := readyToRebootNode()
+		preparingNode.Annotations[testBeforeRebootAnnotation] = constants.False
+
+		overdueNode := rebootableNode()
+		overdueNode.Name = "overdue"
+		overdueNode.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-2 * time.Hour).UTC().
+			Format(time.RFC3339)
+
+		config, _ := testConfig(rebootingNode(), preparingNode, overdueNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.MaxPreparingNodes = 1
+		config.RebootDeadline = time.Hour
+		config.OverdueMaxRebootingNodes = 3
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+
+		runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+		nc := config.Client.CoreV1().Nodes()
+
+		waitForNodeAnnotationValue(ctx, t, nc, overdueNode.Name, constants.AnnotationRebootEscalated, constants.True)
+
+		updatedNode := node(ctx, t, nc, overdueNode.Name)
+		updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+		if _, err := nc.Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Updating Node object: %v", err)
+		}
+
+		waitForNodeAnnotationValue(ctx, t, nc, overdueNode.Name, constants.AnnotationOkToReboot, constants.True)
+
+		if v, ok := node(ctx, t, nc, overdueNode.Name).Annotations[constants.AnnotationRebootEscalated]; ok {
+			t.Fatalf("Unexpected annotation %q with value %q", constants.AnnotationRebootEscalated, v)
+		}
+	})
+}
+
+func Test_Operator_with_agent_heartbeat_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	for name, testCase := range map[string]struct {
+		heartbeat       string
+		expectScheduled bool
+	}{
+		"schedules_reboot_process_for_nodes_with_recent_agent_heartbeat": {
+			heartbeat:       time.Now().Format(time.RFC3339),
+			expectScheduled: true,
+		},
+		"does_not_schedule_reboot_process_for_nodes_with_stale_agent_heartbeat": {
+			heartbeat: time.Now().Add(-time.Hour).Format(time.RFC3339),
+		},
+		"does_not_schedule_reboot_process_for_nodes_without_agent_heartbeat": {},
+		"does_not_schedule_reboot_process_for_nodes_with_malformed_agent_heartbeat": {
+			heartbeat: "foo",
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+			if testCase.heartbeat != "" {
+				rebootableNode.Annotations[constants.AnnotationAgentHeartbeat] = testCase.heartbeat
+			}
+
+			config, fakeClient := testConfig(rebootableNode)
+			config.AgentHeartbeatTimeout = 10 * time.Minute
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			nc := config.Client.CoreV1().Nodes()
+
+			if testCase.expectScheduled {
+				waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
+
+				return
+			}
+
+			// Wait for the second cycle, so the first one has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			if _, ok := node(ctx, t, nc, rebootableNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+			}
+		})
+	}
+}
+
+func Test_Operator_schedules_reboot_process_for_nodes_in_configured_order(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	for name, testCase := range map[string]struct {
+		ordering          operator.NodeOrdering
+		expectedScheduled string
+	}{
+		"oldest_first": {
+			ordering:          operator.NodeOrderingOldestFirst,
+			expectedScheduled: "oldest",
+		},
+		"newest_first": {
+			ordering:          operator.NodeOrderingNewestFirst,
+			expectedScheduled: "newest",
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Now()
+			nodes := []runtime.Object{}
+			nodeNames := []string{}
+
+			for nodeName, age := range map[string]time.Duration{
+				"middle": time.Hour,
+				"oldest": 2 * time.Hour,
+				"newest": 0,
+			} {
+				n := rebootableNode()
+				n.Name = nodeName
+				n.CreationTimestamp = metav1.NewTime(now.Add(-age))
+
+				nodes = append(nodes, n)
+				nodeNames = append(nodeNames, nodeName)
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.NodeOrdering = testCase.ordering
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			reconcileCycle := process(ctx, t, config, fakeClient)
+
+			nc := config.Client.CoreV1().Nodes()
+
+			waitForNodeLabel(ctx, t, nc, testCase.expectedScheduled, constants.LabelBeforeReboot)
+
+			// Wait for the second cycle, so the one which scheduled the node has completed.
+			<-reconcileCycle
+			<-reconcileCycle
+
+			for _, nodeName := range nodeNames {
+				if nodeName == testCase.expectedScheduled {
+					continue
+				}
+
+				if _, ok := node(ctx, t, nc, nodeName).Labels[constants.LabelBeforeReboot]; ok {
+					t.Fatalf("Unexpected node %q scheduled for reboot", nodeName)
+				}
+			}
+		})
+	}
+}
+
+func Test_Operator_schedules_reboot_process_for_nodes_with_higher_reboot_priority_first(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	priorityAnnotation := "example.com/reboot-priority"
+
+	// Nodes are named so the expected node is not the first one returned by the API server.
+	for name, testCase := range map[string]struct {
+		priorities        map[string]string
+		expectedScheduled string
+	}{
+		"when_both_nodes_have_priority": {
+			priorities:        map[string]string{"a": "1", "b": "10"},
+			expectedScheduled: "b",
+		},
+		"when_other_node_has_no_priority": {
+			priorities:        map[string]string{"a": "", "b": "1"},
+			expectedScheduled: "b",
+		},
+		"when_other_node_has_negative_priority": {
+			priorities:        map[string]string{"a": "-1", "b": ""},
+			expectedScheduled: "b",
+		},
+		"when_other_node_has_invalid_priority": {
+			priorities:        map[string]string{"a": "foo", "b": "1"},
+			expectedScheduled: "b",
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			nodes := []runtime.Object{}
+
+			for nodeName, priority := range testCase.priorities {
+				n := rebootableNode()
+				n.Name = nodeName
+
+				if priority != "" {
+					n.Annotations[priorityAnnotation] = priority
+				}
+
+				nodes = append(nodes, n)
+			}
+
+			config, fakeClient := testConfig(nodes...)
+			config.RebootPriorityAnnotation = priorityAnnotation
+
+			process(ctx, t, config, fakeClient)
+
+			nc := config.Client.CoreV1().Nodes()
+
+			waitForNodeLabel(ctx, t, nc, testCase.expectedScheduled, constants.LabelBeforeReboot)
+
+			for nodeName := range testCase.priorities {
+				if nodeName == testCase.expectedScheduled {
+					continue
+				}
+
+				if _, scheduled := node(ctx, t, nc, nodeName).Labels[constants.LabelBeforeReboot]; scheduled {
+					t.Fatalf("Expected node %q with lower priority to not be scheduled for reboot", nodeName)
+				}
+			}
+		})
+	}
+}
+
+func Test_Operator_does_not_schedule_reboot_process_while_other_node_awaits_manual_uncordon(t *testing.T) {
+	t.Parallel()
+
+	awaitingNode := idleNode()
+	awaitingNode.Annotations[constants.AnnotationAwaitingManualUncordon] = constants.True
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(awaitingNode, rebootableNode)
+	config.ReconciliationPeriod = 100 * time.Millisecond
+
+	ctx := contextWithDeadline(t)
+
+	reconcileCycle := process(ctx, t, config, fakeClient)
+
+	// Wait for the second cycle, so the first one has completed.
+	<-reconcileCycle
+	<-reconcileCycle
+
+	nc := config.Client.CoreV1().Nodes()
+
+	if _, ok := node(ctx, t, nc, rebootableNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Expected node %q to not be scheduled for reboot while other node awaits manual uncordon",
+			rebootableNode.Name)
+	}
+
+	updatedNode := node(ctx, t, nc, awaitingNode.Name)
+	delete(updatedNode.Annotations, constants.AnnotationAwaitingManualUncordon)
+
+	if _, err := nc.Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed removing annotation %q: %v", constants.AnnotationAwaitingManualUncordon, err)
+	}
+
+	waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
+}
+
+func Test_Operator_counts_node_awaiting_manual_uncordon_and_running_after_reboot_checks_as_single_rebooting_node(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	awaitingNode := func() *corev1.Node {
+		awaitingNode := finishedRebootingNode()
+		awaitingNode.Annotations[testAfterRebootAnnotation] = constants.False
+		awaitingNode.Annotations[constants.AnnotationAwaitingManualUncordon] = constants.True
+
+		return awaitingNode
+	}
+
+	t.Run("when_scheduling_reboot_processes", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(awaitingNode(), rebootableNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.MaxRebootingNodes = 2
+
+		process(ctx, t, config, fakeClient)
+
+		waitForNodeLabel(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name, constants.LabelBeforeReboot)
+	})
+
+	t.Run("when_approving_reboots", func(t *testing.T) {
+		t.Parallel()
+
+		readyToRebootNode := readyToRebootNode()
+
+		config, fakeClient := testConfig(awaitingNode(), readyToRebootNode)
+		config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+		config.MaxRebootingNodes = 2
+		config.MaxPreparingNodes = 1
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected node %q to be approved to reboot, got annotations %v", updatedNode.Name, updatedNode.Annotations)
+		}
+	})
+}
+
+func Test_Operator_with_stale_annotations_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	staleLastCheckedTime := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+
+	for name, testCase := range map[string]struct {
+		node                    func() *corev1.Node
+		lastCheckedTime         string
+		staleAnnotationsTimeout time.Duration
+		expectPruned            bool
+	}{
+		"prunes_status_annotations_from_idle_nodes_without_recent_update_check": {
+			node:                    idleNode,
+			lastCheckedTime:         staleLastCheckedTime,
+			staleAnnotationsTimeout: time.Hour,
+			expectPruned:            true,
+		},
+		"keeps_status_annotations_on_idle_nodes_with_recent_update_check": {
+			node:                    idleNode,
+			lastCheckedTime:         strconv.FormatInt(time.Now().Unix(), 10),
+			staleAnnotationsTimeout: time.Hour,
+		},
+		"keeps_status_annotations_on_idle_nodes_with_malformed_last_checked_time": {
+			node:                    idleNode,
+			lastCheckedTime:         "foo",
+			staleAnnotationsTimeout: time.Hour,
+		},
+		"keeps_status_annotations_on_nodes_needing_reboot": {
+			node:                    rebootableNode,
+			lastCheckedTime:         staleLastCheckedTime,
+			staleAnnotationsTimeout: time.Hour,
+		},
+		"keeps_status_annotations_on_rebooting_nodes": {
+			node:                    rebootingNode,
+			lastCheckedTime:         staleLastCheckedTime,
+			staleAnnotationsTimeout: time.Hour,
+		},
+		"keeps_status_annotations_when_timeout_is_zero": {
+			node:            idleNode,
+			lastCheckedTime: staleLastCheckedTime,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := testCase.node()
+			testNode.Annotations[constants.AnnotationStatus] = "UPDATE_STATUS_IDLE"
+			testNode.Annotations[constants.AnnotationNewVersion] = "0.0.0"
+			testNode.Annotations[constants.AnnotationLastCheckedTime] = testCase.lastCheckedTime
+
+			config, _ := testConfig(testNode)
+			config.StaleAnnotationsTimeout = testCase.staleAnnotationsTimeout
+
+			// Reboot blocker is consulted after stale annotations are pruned, so use it
+			// to wait until pruning is done.
+			pruningDone := make(chan struct{})
+
+			var pruningDoneOnce sync.Once
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				pruningDoneOnce.Do(func() { close(pruningDone) })
+
+				return true, "test", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-pruningDone
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			for _, annotation := range []string{
+				constants.AnnotationStatus,
+				constants.AnnotationNewVersion,
+				constants.AnnotationLastCheckedTime,
+			} {
+				if _, ok := updatedNode.Annotations[annotation]; ok == testCase.expectPruned {
+					t.Fatalf("Expected annotation %q to be pruned: %t, got annotations: %v",
+						annotation, testCase.expectPruned, updatedNode.Annotations)
+				}
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_stale_reboot_in_progress_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	longAgo := time.Now().Add(-2 * time.Hour)
+
+	for name, testCase := range map[string]struct {
+		node                         func() *corev1.Node
+		heartbeat                    string
+		lastCheckedTime              string
+		staleRebootInProgressTimeout time.Duration
+		expectCleared                bool
+	}{
+		"clears_reboot_in_progress_of_node_which_agent_has_not_reported_a_heartbeat_within_timeout": {
+			node:                         rebootingNode,
+			heartbeat:                    longAgo.UTC().Format(time.RFC3339),
+			staleRebootInProgressTimeout: time.Hour,
+			expectCleared:                true,
+		},
+		"clears_reboot_in_progress_of_node_without_heartbeat_which_has_not_reported_update_check_within_timeout": {
+			node:                         rebootingNode,
+			lastCheckedTime:              strconv.FormatInt(longAgo.Unix(), 10),
+			staleRebootInProgressTimeout: time.Hour,
+			expectCleared:                true,
+		},
+		"keeps_reboot_in_progress_of_node_which_agent_reported_a_heartbeat_within_timeout": {
+			node:                         rebootingNode,
+			heartbeat:                    time.Now().UTC().Format(time.RFC3339),
+			lastCheckedTime:              strconv.FormatInt(longAgo.Unix(), 10),
+			staleRebootInProgressTimeout: time.Hour,
+		},
+		"keeps_reboot_in_progress_of_node_without_valid_heartbeat_nor_last_checked_time": {
+			node:                         rebootingNode,
+			heartbeat:                    "foo",
+			staleRebootInProgressTimeout: time.Hour,
+		},
+		"keeps_reboot_in_progress_when_timeout_is_zero": {
+			node:      rebootingNode,
+			heartbeat: longAgo.UTC().Format(time.RFC3339),
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := testCase.node()
+
+			if testCase.heartbeat != "" {
+				testNode.Annotations[constants.AnnotationAgentHeartbeat] = testCase.heartbeat
+			}
+
+			if testCase.lastCheckedTime != "" {
+				testNode.Annotations[constants.AnnotationLastCheckedTime] = testCase.lastCheckedTime
+			}
+
+			config, _ := testConfig(testNode)
+			config.StaleRebootInProgressTimeout = testCase.staleRebootInProgressTimeout
+
+			// Reboot blocker is consulted after stale reboot in progress is cleared, so use it
+			// to wait until clearing is done.
+			clearingDone := make(chan struct{})
+
+			var clearingDoneOnce sync.Once
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				clearingDoneOnce.Do(func() { close(clearingDone) })
+
+				return true, "test", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-clearingDone
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			expectedValue := constants.True
+			if testCase.expectCleared {
+				expectedValue = constants.False
+			}
+
+			for _, annotation := range []string{
+				constants.AnnotationRebootInProgress,
+				constants.AnnotationOkToReboot,
+			} {
+				if v := updatedNode.Annotations[annotation]; v != expectedValue {
+					t.Fatalf("Expected annotation %q to be %q, got %q", annotation, expectedValue, v)
+				}
+			}
+
+			if testCase.expectCleared {
+				waitForWarningEvent(ctx, t, config.Client, testNode.Name, "StaleRebootInProgressCleared")
+			}
+		})
+	}
+}
+
+func Test_Operator_with_stale_reboot_in_progress_timeout_configured_uncordons_node_cordoned_by_operator(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	testNode := rebootingNode()
+	testNode.Spec.Unschedulable = true
+	testNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+	testNode.Annotations[constants.AnnotationAgentHeartbeat] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+
+	config, _ := testConfig(testNode)
+	config.StaleRebootInProgressTimeout = time.Hour
+	config.CordonBeforeReboot = true
+
+	// Reboot blocker is consulted after stale reboot in progress is cleared, so use it
+	// to wait until clearing is done.
+	clearingDone := make(chan struct{})
+
+	var clearingDoneOnce sync.Once
+
+	config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+		clearingDoneOnce.Do(func() { close(clearingDone) })
+
+		return true, "test", nil
+	})
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+	<-clearingDone
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+	if updatedNode.Spec.Unschedulable {
+		t.Fatalf("Expected node to be uncordoned")
+	}
+
+	if v := updatedNode.Annotations[constants.AnnotationOperatorMadeUnschedulable]; v != constants.False {
+		t.Fatalf("Expected annotation %q to be %q, got %q",
+			constants.AnnotationOperatorMadeUnschedulable, constants.False, v)
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_update_error_status_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	longAgo := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recently := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+
+	for name, testCase := range map[string]struct {
+		status              string
+		errorStatusSince    string
+		updateFailed        string
+		updateErrorStatuses []string
+		expectTracked       bool
+		expectFailed        bool
+	}{
+		"starts_tracking_node_reporting_error_status": {
+			status:        "UPDATE_STATUS_REPORTING_ERROR_EVENT",
+			expectTracked: true,
+		},
+		"does_not_mark_node_reporting_error_status_within_timeout_as_failed": {
+			status:           "UPDATE_STATUS_REPORTING_ERROR_EVENT",
+			errorStatusSince: recently,
+			expectTracked:    true,
+		},
+		"marks_node_reporting_error_status_for_longer_than_timeout_as_failed": {
+			status:           "UPDATE_STATUS_REPORTING_ERROR_EVENT",
+			errorStatusSince: longAgo,
+			expectTracked:    true,
+			expectFailed:     true,
+		},
+		"marks_node_reporting_configured_error_status_for_longer_than_timeout_as_failed": {
+			status:              "UPDATE_STATUS_CHECKING_FOR_UPDATE",
+			errorStatusSince:    longAgo,
+			updateErrorStatuses: []string{"UPDATE_STATUS_CHECKING_FOR_UPDATE"},
+			expectTracked:       true,
+			expectFailed:        true,
+		},
+		"clears_state_of_node_no_longer_reporting_error_status": {
+			status:           "UPDATE_STATUS_IDLE",
+			errorStatusSince: longAgo,
+			updateFailed:     constants.True,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := idleNode()
+			testNode.Annotations[constants.AnnotationStatus] = testCase.status
+
+			if testCase.errorStatusSince != "" {
+				testNode.Annotations[constants.AnnotationErrorStatusSince] = testCase.errorStatusSince
+			}
+
+			if testCase.updateFailed != "" {
+				testNode.Annotations[constants.AnnotationUpdateFailed] = testCase.updateFailed
+			}
+
+			config, fakeClient := testConfig(testNode)
+			config.UpdateErrorStatusTimeout = time.Hour
+			config.UpdateErrorStatuses = testCase.updateErrorStatuses
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			if _, ok := updatedNode.Annotations[constants.AnnotationErrorStatusSince]; ok != testCase.expectTracked {
+				t.Fatalf("Expected annotation %q to be present: %t, got annotations: %v",
+					constants.AnnotationErrorStatusSince, testCase.expectTracked, updatedNode.Annotations)
+			}
+
+			failed := updatedNode.Annotations[constants.AnnotationUpdateFailed] == constants.True
+			if failed != testCase.expectFailed {
+				t.Fatalf("Expected annotation %q to be set: %t, got annotations: %v",
+					constants.AnnotationUpdateFailed, testCase.expectFailed, updatedNode.Annotations)
+			}
+
+			if testCase.expectFailed {
+				waitForWarningEvent(ctx, t, config.Client, testNode.Name, "UpdateStuckInErrorStatus")
+			}
+		})
+	}
+}
+
+func Test_Operator_with_reboot_pause_timeout_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	longAgo := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recently := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+
+	for name, testCase := range map[string]struct {
+		paused              bool
+		phaseTransitionTime string
+		pausedTooLong       string
+		expectPausedTooLong bool
+	}{
+		"does_not_mark_node_needing_reboot_with_reboot_paused_within_timeout": {
+			paused:              true,
+			phaseTransitionTime: recently,
+		},
+		"marks_node_needing_reboot_with_reboot_paused_for_longer_than_timeout": {
+			paused:              true,
+			phaseTransitionTime: longAgo,
+			expectPausedTooLong: true,
+		},
+		"clears_mark_of_node_which_no_longer_has_reboot_paused": {
+			phaseTransitionTime: longAgo,
+			pausedTooLong:       constants.True,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := rebootableNode()
+			testNode.Annotations[constants.AnnotationPhaseTransitionTime] = testCase.phaseTransitionTime
+
+			if testCase.paused {
+				testNode.Annotations[constants.AnnotationRebootPaused] = constants.True
+			}
+
+			if testCase.pausedTooLong != "" {
+				testNode.Annotations[constants.AnnotationRebootPausedTooLong] = testCase.pausedTooLong
+			}
+
+			config, fakeClient := testConfig(testNode)
+			config.RebootPauseTimeout = time.Hour
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			pausedTooLong := updatedNode.Annotations[constants.AnnotationRebootPausedTooLong] == constants.True
+			if pausedTooLong != testCase.expectPausedTooLong {
+				t.Fatalf("Expected annotation %q to be set: %t, got annotations: %v",
+					constants.AnnotationRebootPausedTooLong, testCase.expectPausedTooLong, updatedNode.Annotations)
+			}
+
+			if testCase.expectPausedTooLong {
+				waitForWarningEvent(ctx, t, config.Client, testNode.Name, "RebootPausedTooLong")
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_maintenance_node_selector_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	for name, testCase := range map[string]struct {
+		node               func() *corev1.Node
+		matching           bool
+		state              string
+		expectedState      string
+		expectRebootNeeded bool
+	}{
+		"requests_maintenance_reboot_of_matching_nodes": {
+			node:               idleNode,
+			matching:           true,
+			expectedState:      constants.MaintenanceRebootRequested,
+			expectRebootNeeded: true,
+		},
+		"does_not_request_maintenance_reboot_of_not_matching_nodes": {
+			node: idleNode,
+		},
+		"requests_maintenance_reboot_again_when_agent_reset_reboot_needed_annotation": {
+			node:               idleNode,
+			matching:           true,
+			state:              constants.MaintenanceRebootRequested,
+			expectedState:      constants.MaintenanceRebootRequested,
+			expectRebootNeeded: true,
+		},
+		"completes_requested_maintenance_reboot_of_nodes_which_stopped_matching": {
+			node:               idleNode,
+			state:              constants.MaintenanceRebootRequested,
+			expectedState:      constants.MaintenanceRebootRequested,
+			expectRebootNeeded: true,
+		},
+		"marks_maintenance_reboot_as_completed_when_node_has_rebooted": {
+			node:          justRebootedNode,
+			matching:      true,
+			state:         constants.MaintenanceRebootRequested,
+			expectedState: constants.MaintenanceRebootCompleted,
+		},
+		"does_not_request_maintenance_reboot_again_once_completed": {
+			node:          idleNode,
+			matching:      true,
+			state:         constants.MaintenanceRebootCompleted,
+			expectedState: constants.MaintenanceRebootCompleted,
+		},
+		"clears_maintenance_state_of_completed_nodes_which_no_longer_match": {
+			node:  idleNode,
+			state: constants.MaintenanceRebootCompleted,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := testCase.node()
+
+			if testCase.matching {
+				testNode.Labels["maintenance"] = constants.True
+			}
+
+			if testCase.state != "" {
+				testNode.Annotations[constants.AnnotationMaintenanceReboot] = testCase.state
+			}
+
+			config, _ := testConfig(testNode)
+			config.MaintenanceNodeSelector = "maintenance=true"
+
+			// Reboot blocker is consulted after maintenance state is updated, so use it to wait for it.
+			// Blocking reboots ensures node state is not changed any further.
+			stateUpdated := make(chan struct{})
+
+			var stateUpdatedOnce sync.Once
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				stateUpdatedOnce.Do(func() { close(stateUpdated) })
+
+				return true, "test", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-stateUpdated
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			if state := updatedNode.Annotations[constants.AnnotationMaintenanceReboot]; state != testCase.expectedState {
+				t.Fatalf("Expected maintenance state %q, got %q", testCase.expectedState, state)
+			}
+
+			rebootNeeded := updatedNode.Annotations[constants.AnnotationRebootNeeded] == constants.True
+			if rebootNeeded != testCase.expectRebootNeeded {
+				t.Fatalf("Expected reboot needed to be %t, got annotations: %v",
+					testCase.expectRebootNeeded, updatedNode.Annotations)
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_reboot_request_annotation_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	longAgo := time.Now().Add(-2 * time.Hour)
+	recently := time.Now().Add(-10 * time.Minute)
+
+	for name, testCase := range map[string]struct {
+		node                 func() *corev1.Node
+		nodeRequest          string
+		requestedBy          string
+		requestCompletedTime time.Time
+		podCreationTime      time.Time
+		expectedRequestedBy  string
+		expectRebootNeeded   bool
+		expectCompleted      bool
+	}{
+		"requests_reboot_of_node_with_request_annotation": {
+			node:                idleNode,
+			nodeRequest:         constants.True,
+			expectedRequestedBy: "node",
+			expectRebootNeeded:  true,
+		},
+		"requests_reboot_of_node_running_pod_with_request_annotation": {
+			node:                idleNode,
+			podCreationTime:     recently,
+			expectedRequestedBy: "pod/default/requesting",
+			expectRebootNeeded:  true,
+		},
+		"does_not_request_reboot_of_node_running_pod_with_request_annotation_when_node_request_is_false": {
+			node:            idleNode,
+			nodeRequest:     constants.False,
+			podCreationTime: recently,
+		},
+		"does_not_request_reboot_of_node_running_pod_created_before_last_requested_reboot_has_completed": {
+			node:                 idleNode,
+			requestCompletedTime: recently,
+			podCreationTime:      longAgo,
+		},
+		"requests_reboot_again_when_agent_reset_reboot_needed_annotation": {
+			node:                idleNode,
+			requestedBy:         "node",
+			expectedRequestedBy: "node",
+			expectRebootNeeded:  true,
+		},
+		"completes_requested_reboot_when_node_has_rebooted": {
+			node:            justRebootedNode,
+			nodeRequest:     constants.True,
+			requestedBy:     "node",
+			expectCompleted: true,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testNode := testCase.node()
+
+			if testCase.nodeRequest != "" {
+				testNode.Annotations[constants.AnnotationRebootRequested] = testCase.nodeRequest
+			}
+
+			if testCase.requestedBy != "" {
+				testNode.Annotations[constants.AnnotationRebootRequestedBy] = testCase.requestedBy
+			}
+
+			if !testCase.requestCompletedTime.IsZero() {
+				testNode.Annotations[constants.AnnotationRebootRequestCompletedTime] = testCase.requestCompletedTime.
+					UTC().Format(time.RFC3339)
+			}
+
+			objects := []runtime.Object{testNode}
+
+			if !testCase.podCreationTime.IsZero() {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "requesting",
+						Namespace:         "default",
+						CreationTimestamp: metav1.NewTime(testCase.podCreationTime),
+						Annotations: map[string]string{
+							constants.AnnotationRebootRequested: constants.True,
+						},
+					},
+					Spec: corev1.PodSpec{
+						NodeName: testNode.Name,
+					},
+				})
+			}
+
+			config, _ := testConfig(objects...)
+			config.RebootRequestAnnotation = constants.AnnotationRebootRequested
+
+			// Reboot blocker is consulted after reboot request state is updated, so use it to wait for it.
+			// Blocking reboots ensures node state is not changed any further.
+			stateUpdated := make(chan struct{})
+
+			var stateUpdatedOnce sync.Once
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				stateUpdatedOnce.Do(func() { close(stateUpdated) })
+
+				return true, "test", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-stateUpdated
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), testNode.Name)
+
+			requestedBy := updatedNode.Annotations[constants.AnnotationRebootRequestedBy]
+			if requestedBy != testCase.expectedRequestedBy {
+				t.Fatalf("Expected reboot to be requested by %q, got %q", testCase.expectedRequestedBy, requestedBy)
+			}
+
+			rebootNeeded := updatedNode.Annotations[constants.AnnotationRebootNeeded] == constants.True
+			if rebootNeeded != testCase.expectRebootNeeded {
+				t.Fatalf("Expected reboot needed to be %t, got annotations: %v",
+					testCase.expectRebootNeeded, updatedNode.Annotations)
+			}
+
+			if !testCase.expectCompleted {
+				return
+			}
+
+			if _, ok := updatedNode.Annotations[constants.AnnotationRebootRequestCompletedTime]; !ok {
+				t.Fatalf("Expected reboot request completed time to be set, got annotations: %v", updatedNode.Annotations)
+			}
+
+			if _, ok := updatedNode.Annotations[constants.AnnotationRebootRequested]; ok {
+				t.Fatalf("Expected reboot request annotation to be removed from node")
+			}
+		})
+	}
+}
+
+func Test_Operator_schedules_and_approves_maintenance_reboot_through_regular_reboot_process(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	maintenanceNode := idleNode()
+	maintenanceNode.Labels["maintenance"] = constants.True
+
+	config, _ := testConfig(maintenanceNode)
+	config.MaintenanceNodeSelector = "maintenance=true"
+	config.ReconciliationPeriod = 100 * time.Millisecond
+
+	// Node gets scheduled in the first cycle and approved in the second one, as no before reboot
+	// annotations are configured. Wait for the third cycle to start to ensure the second one has finished.
+	calls := 0
+	thirdCycleStarted := make(chan struct{})
+
+	config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+		calls++
+		if calls == 3 {
+			close(thirdCycleStarted)
+		}
+
+		return false, "", nil
+	})
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+	<-thirdCycleStarted
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), maintenanceNode.Name)
+	if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+		t.Fatalf("Expected node %q to be approved for reboot, got annotations: %v",
+			maintenanceNode.Name, updatedNode.Annotations)
+	}
+}
+
+func Test_Operator_makes_schedulable_rebooted_nodes_which_it_made_unschedulable(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	for name, testCase := range map[string]struct {
+		operatorMadeUnschedulable string
+		expectSchedulable         bool
+	}{
+		"marks_node_as_schedulable_when_operator_made_it_unschedulable": {
+			operatorMadeUnschedulable: constants.True,
+			expectSchedulable:         true,
+		},
+		"leaves_node_unschedulable_when_operator_did_not_make_it_unschedulable": {
+			operatorMadeUnschedulable: constants.False,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			finishedRebootingNode := finishedRebootingNode()
+			finishedRebootingNode.Spec.Unschedulable = true
+			finishedRebootingNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] = testCase.operatorMadeUnschedulable
+
+			config, _ := testConfig(finishedRebootingNode)
+			config.AfterRebootAnnotations = []string{testAfterRebootAnnotation, testAnotherAfterRebootAnnotation}
+
+			// Reboot blocker is consulted after rebooted nodes are processed, so use it to wait for it.
+			cycleFinished := make(chan struct{})
+
+			var cycleFinishedOnce sync.Once
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				cycleFinishedOnce.Do(func() { close(cycleFinished) })
+
+				return true, "test", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-cycleFinished
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), finishedRebootingNode.Name)
+
+			if updatedNode.Annotations[constants.AnnotationOkToReboot] != constants.False {
+				t.Fatalf("Expected reboot process to be finished, got annotations %v", updatedNode.Annotations)
+			}
+
+			if schedulable := !updatedNode.Spec.Unschedulable; schedulable != testCase.expectSchedulable {
+				t.Fatalf("Expected node to be schedulable: %t, got %t", testCase.expectSchedulable, schedulable)
+			}
+
+			if testCase.expectSchedulable &&
+				updatedNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] != constants.False {
+				t.Fatalf("Expected operator-made-unschedulable annotation to be set to false, got %v",
+					updatedNode.Annotations)
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_cordon_before_reboot_configured(t *testing.T) {
+	t.Parallel()
+
+	t.Run("marks_node_as_unschedulable_when_scheduling_it_for_reboot", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.CordonBeforeReboot = true
+
+		<-process(ctx, t, config, fakeClient)
+
+		nc := config.Client.CoreV1().Nodes()
+
+		waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
+
+		updatedNode := node(ctx, t, nc, rebootableNode.Name)
+
+		if !updatedNode.Spec.Unschedulable {
+			t.Fatalf("Expected node %q to be marked as unschedulable", rebootableNode.Name)
+		}
+
+		if updatedNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] != constants.True {
+			t.Fatalf("Expected operator-made-unschedulable annotation to be set to true, got %v",
+				updatedNode.Annotations)
+		}
+	})
+
+	t.Run("does_not_take_ownership_of_node_already_marked_as_unschedulable", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootableNode := rebootableNode()
+		rebootableNode.Spec.Unschedulable = true
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.CordonBeforeReboot = true
+
+		<-process(ctx, t, config, fakeClient)
+
+		nc := config.Client.CoreV1().Nodes()
+
+		waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
+
+		updatedNode := node(ctx, t, nc, rebootableNode.Name)
+
+		if value, ok := updatedNode.Annotations[constants.AnnotationOperatorMadeUnschedulable]; ok {
+			t.Fatalf("Expected operator-made-unschedulable annotation not to be set, got %q", value)
+		}
+	})
+
+	t.Run("marks_node_as_schedulable_when_it_no_longer_needs_a_reboot", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		scheduledForRebootNode := scheduledForRebootNode()
+		scheduledForRebootNode.Spec.Unschedulable = true
+		scheduledForRebootNode.Annotations[constants.AnnotationRebootNeeded] = constants.False
+		scheduledForRebootNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] = constants.True
+
+		config, fakeClient := testConfig(scheduledForRebootNode)
+		config.CordonBeforeReboot = true
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), scheduledForRebootNode.Name)
+
+		if updatedNode.Spec.Unschedulable {
+			t.Fatalf("Expected node %q to be marked as schedulable", scheduledForRebootNode.Name)
+		}
+
+		if updatedNode.Annotations[constants.AnnotationOperatorMadeUnschedulable] != constants.False {
+			t.Fatalf("Expected operator-made-unschedulable annotation to be set to false, got %v",
+				updatedNode.Annotations)
+		}
+	})
+}
+
+func Test_Operator_with_post_reboot_ready_period_configured_sets_reboot_finished_time_on_rebooted_nodes(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	finishedRebootingNode := finishedRebootingNode()
+
+	config, _ := testConfig(finishedRebootingNode)
+	config.AfterRebootAnnotations = []string{testAfterRebootAnnotation, testAnotherAfterRebootAnnotation}
+	config.PostRebootReadyPeriod = time.Hour
+
+	// Reboot blocker is consulted after rebooted nodes are processed, so use it to wait for it.
+	cycleFinished := make(chan struct{})
+
+	var cycleFinishedOnce sync.Once
+
+	config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+		cycleFinishedOnce.Do(func() { close(cycleFinished) })
+
+		return true, "test", nil
+	})
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	before := time.Now().Truncate(time.Second)
+
+	runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+	<-cycleFinished
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), finishedRebootingNode.Name)
+
+	finished, err := time.Parse(time.RFC3339, updatedNode.Annotations[constants.AnnotationRebootFinishedTime])
+	if err != nil {
+		t.Fatalf("Failed parsing reboot finished time: %v", err)
+	}
+
+	if finished.Before(before) || finished.After(time.Now()) {
+		t.Fatalf("Expected reboot finished time between %v and now, got %v", before, finished)
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_with_post_reboot_ready_period_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	now := time.Now()
+
+	for name, testCase := range map[string]struct {
+		finishedTime          time.Time
+		readySince            time.Time
+		notReady              bool
+		verificationFailed    bool
+		expectScheduled       bool
+		expectFinishedTimeSet bool
+		expectFailed          bool
+	}{
+		"does_not_schedule_reboot_process_until_rebooted_node_stays_ready_for_configured_period": {
+			finishedTime:          now,
+			readySince:            now.Add(-time.Minute),
+			expectFinishedTimeSet: true,
+		},
+		"schedules_reboot_process_once_rebooted_node_stayed_ready_for_configured_period": {
+			finishedTime:    now.Add(-2 * time.Hour),
+			readySince:      now.Add(-3 * time.Hour),
+			expectScheduled: true,
+		},
+		"halts_reboots_when_rebooted_node_is_not_ready": {
+			finishedTime: now.Add(-time.Minute),
+			readySince:   now.Add(-time.Hour),
+			notReady:     true,
+			expectFailed: true,
+		},
+		"halts_reboots_when_rebooted_node_became_ready_again_after_finishing_reboot": {
+			finishedTime: now.Add(-time.Minute),
+			readySince:   now,
+			expectFailed: true,
+		},
+		"does_not_schedule_reboot_process_while_some_node_failed_verification": {
+			verificationFailed: true,
+			expectFailed:       true,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootedNode := idleNode()
+			rebootedNode.Name = "rebooted"
+
+			if !testCase.finishedTime.IsZero() {
+				rebootedNode.Annotations[constants.AnnotationRebootFinishedTime] = testCase.finishedTime.Format(time.RFC3339)
+			}
+
+			if testCase.verificationFailed {
+				rebootedNode.Annotations[constants.AnnotationPostRebootVerificationFailed] = constants.True
+			}
+
+			readyStatus := corev1.ConditionTrue
+			if testCase.notReady {
+				readyStatus = corev1.ConditionFalse
+			}
+
+			rebootedNode.Status.Conditions = []corev1.NodeCondition{
+				{
+					Type:               corev1.NodeReady,
+					Status:             readyStatus,
+					LastTransitionTime: metav1.NewTime(testCase.readySince),
+				},
+			}
+
+			rebootableNode := rebootableNode()
+
+			config, _ := testConfig(rebootedNode, rebootableNode)
+			config.PostRebootReadyPeriod = time.Hour
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			// Wait for the next cycle to start to ensure the previous one has finished.
+			calls := 0
+			secondCycleStarted := make(chan struct{})
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				calls++
+				if calls == 2 {
+					close(secondCycleStarted)
+				}
+
+				return false, "", nil
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-secondCycleStarted
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+			// With no before reboot annotations configured, scheduled node may get approved already
+			// in the second cycle.
+			_, scheduled := updatedNode.Labels[constants.LabelBeforeReboot]
+			scheduled = scheduled || updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True
+
+			if scheduled != testCase.expectScheduled {
+				t.Fatalf("Expected node %q to be scheduled for reboot: %t, got %t",
+					rebootableNode.Name, testCase.expectScheduled, scheduled)
+			}
+
+			updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootedNode.Name)
+
+			_, finishedTimeSet := updatedNode.Annotations[constants.AnnotationRebootFinishedTime]
+			if finishedTimeSet != testCase.expectFinishedTimeSet {
+				t.Fatalf("Expected reboot finished time annotation to be set: %t, got annotations: %v",
+					testCase.expectFinishedTimeSet, updatedNode.Annotations)
+			}
+
+			failed := updatedNode.Annotations[constants.AnnotationPostRebootVerificationFailed] == constants.True
+			if failed != testCase.expectFailed {
+				t.Fatalf("Expected node %q to fail post reboot verification: %t, got annotations: %v",
+					rebootedNode.Name, testCase.expectFailed, updatedNode.Annotations)
+			}
+
+			if testCase.expectFailed && !testCase.verificationFailed {
+				waitForWarningEvent(ctx, t, config.Client, rebootedNode.Name, "PostRebootVerificationFailed")
+			}
+		})
+	}
+}
+
+func Test_Operator_does_not_schedule_nor_approve_reboot_process_when_reboots_are(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	for name, testCase := range map[string]struct {
+		blocked bool
+		err     error
+	}{
+		"blocked": {
+			blocked: true,
+		},
+		"possibly_blocked_as_checking_fails": {
+			err: fmt.Errorf("test"),
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+			readyToRebootNode := readyToRebootNode()
+
+			config, _ := testConfig(rebootableNode, readyToRebootNode)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.MaxRebootingNodes = 2
+			config.ReconciliationPeriod = 100 * time.Millisecond
+
+			// Blocked reconciliation cycle returns early, so wait for the next cycle to start
+			// to ensure the previous one has finished.
+			calls := 0
+			secondCycleStarted := make(chan struct{})
+
+			config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+				calls++
+				if calls == 2 {
+					close(secondCycleStarted)
+				}
+
+				return testCase.blocked, "test", testCase.err
+			})
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			<-secondCycleStarted
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+			}
+
+			updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+			if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v == constants.True {
+				t.Fatalf("Unexpected node %q approved for reboot", readyToRebootNode.Name)
+			}
+		})
+	}
+}
+
+func Test_Operator_schedules_reboot_process_when_reboots_are_not_blocked(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(rebootableNode)
+	config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+		return false, "", nil
+	})
+
+	ctx := contextWithDeadline(t)
+
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+	<-process(ctx, t, config, fakeClient)
+	<-nodeUpdated
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected node %q to be scheduled for reboot", rebootableNode.Name)
+	}
+}
+
+func Test_Operator_with_pause_configmap_configured_does_not_schedule_nor_approve_reboot_process_while_paused(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+	readyToRebootNode := readyToRebootNode()
+
+	pauseConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pause",
+			Namespace: testNamespace,
+		},
+		Data: map[string]string{
+			"paused": constants.True,
+		},
+	}
+
+	config, fakeClient := testConfig(rebootableNode, readyToRebootNode, pauseConfigMap)
+	config.PauseConfigMap = pauseConfigMap.Name
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.MaxRebootingNodes = 2
+	config.ReconciliationPeriod = 100 * time.Millisecond
+
+	// Paused reconciliation cycle returns early, so wait for the next cycle to check the pause
+	// to ensure the previous one has finished.
+	pauseChecked := make(chan struct{}, 1)
+
+	fakeClient.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == pauseConfigMap.Name {
+			select {
+			case pauseChecked <- struct{}{}:
+			default:
+			}
+		}
+
+		return false, nil, nil
+	})
+
+	ctx := contextWithDeadline(t)
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+	<-pauseChecked
+	<-pauseChecked
+
+	nc := config.Client.CoreV1().Nodes()
+
+	if _, ok := node(ctx, t, nc, rebootableNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Unexpected node %q scheduled for reboot while reboots are paused", rebootableNode.Name)
+	}
+
+	if v := node(ctx, t, nc, readyToRebootNode.Name).Annotations[constants.AnnotationOkToReboot]; v == constants.True {
+		t.Fatalf("Unexpected node %q approved for reboot while reboots are paused", readyToRebootNode.Name)
+	}
+
+	pauseConfigMap.Data["paused"] = constants.False
+
+	configMapClient := config.Client.CoreV1().ConfigMaps(testNamespace)
+	if _, err := configMapClient.Update(ctx, pauseConfigMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Updating pause ConfigMap: %v", err)
+	}
+
+	waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
+}
+
+func Test_Operator_with_shared_reboot_budget_configured(t *testing.T) {
+	t.Parallel()
+
+	t.Run("does_not_schedule_reboot_process_when_budget_is_used_by_other_operator", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootBudget := operator.NewRebootBudget(1)
+
+		firstNode := rebootableNode()
+		firstConfig, firstFakeClient := testConfig(firstNode)
+		firstConfig.MaxRebootingNodes = 2
+		firstConfig.RebootBudget = rebootBudget
+		firstConfig.RebootBudgetMember = "first"
+
+		firstNodeUpdated := nodeUpdatedNTimes(firstFakeClient, 1)
+		<-process(ctx, t, firstConfig, firstFakeClient)
+		<-firstNodeUpdated
+
+		updatedNode := node(ctx, t, firstConfig.Client.CoreV1().Nodes(), firstNode.Name)
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+			t.Fatalf("Expected node %q to be scheduled for reboot", firstNode.Name)
+		}
+
+		secondNode := rebootableNode()
+		secondConfig, secondFakeClient := testConfig(secondNode)
+		secondConfig.MaxRebootingNodes = 2
+		secondConfig.RebootBudget = rebootBudget
+		secondConfig.RebootBudgetMember = "second"
+		secondConfig.ReconciliationPeriod = 100 * time.Millisecond
+
+		// Wait for two cycles to ensure scheduling in the first one has finished.
+		secondCycles := process(ctx, t, secondConfig, secondFakeClient)
+		<-secondCycles
+		<-secondCycles
+
+		updatedNode = node(ctx, t, secondConfig.Client.CoreV1().Nodes(), secondNode.Name)
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+			t.Fatalf("Unexpected node %q scheduled for reboot while budget is used by other operator", secondNode.Name)
+		}
+	})
+
+	t.Run("schedules_reboot_process_when_budget_is_available", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootBudget := operator.NewRebootBudget(2)
+
+		for _, member := range []string{"first", "second"} {
+			rebootableNode := rebootableNode()
+			config, fakeClient := testConfig(rebootableNode)
+			config.MaxRebootingNodes = 2
+			config.RebootBudget = rebootBudget
+			config.RebootBudgetMember = member
+
+			nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+			<-process(ctx, t, config, fakeClient)
+			<-nodeUpdated
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+				t.Fatalf("Expected node %q of %q to be scheduled for reboot", rebootableNode.Name, member)
+			}
+		}
+	})
+
+	t.Run("schedules_reboot_process_when_other_operator_deferring_reboots_has_no_rebooting_nodes", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+		t.Cleanup(cancel)
+
+		rebootBudget := operator.NewRebootBudget(1)
+
+		firstRebootingNode := rebootingNode()
+		firstConfig, _ := testConfig(firstRebootingNode)
+		firstConfig.RebootBudget = rebootBudget
+		firstConfig.RebootBudgetMember = "first"
+		firstConfig.ReconciliationPeriod = 100 * time.Millisecond
+
+		var blockedMu sync.Mutex
+
+		blocked := false
+		blockerCalls := make(chan bool, 1)
+
+		// Reboot blocker is consulted before new reboots are scheduled, so use it to track reconciliations.
+		firstConfig.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+			blockedMu.Lock()
+			defer blockedMu.Unlock()
+
+			select {
+			case blockerCalls <- blocked:
+			default:
+			}
+
+			return blocked, "test", nil
+		})
+
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
+
+		runOperator(ctx, t, kontrollerWithObjects(t, firstConfig), stop)
+
+		// Wait for the second reconciliation, so rebooting node got counted while scheduling in the first one.
+		<-blockerCalls
+		<-blockerCalls
+
+		if err := firstConfig.Client.CoreV1().Nodes().Delete(ctx, firstRebootingNode.Name,
+			metav1.DeleteOptions{}); err != nil {
+			t.Fatalf("Deleting rebooting node: %v", err)
+		}
+
+		blockedMu.Lock()
+		blocked = true
+		blockedMu.Unlock()
+
+		// Wait for the second blocked reconciliation, so the whole of it has run without rebooting node.
+		for blockedCalls := 0; blockedCalls < 2; {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for reconciliation with reboots blocked")
+			case wasBlocked := <-blockerCalls:
+				if wasBlocked {
+					blockedCalls++
+				}
+			}
+		}
+
+		secondNode := rebootableNode()
+		secondConfig, _ := testConfig(secondNode)
+		secondConfig.RebootBudget = rebootBudget
+		secondConfig.RebootBudgetMember = "second"
+		secondConfig.ReconciliationPeriod = 100 * time.Millisecond
+		secondConfig.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+		runOperator(ctx, t, kontrollerWithObjects(t, secondConfig), stop)
+
+		waitForNodeLabel(ctx, t, secondConfig.Client.CoreV1().Nodes(), secondNode.Name, constants.LabelBeforeReboot)
+	})
+
+	t.Run("schedules_reboot_process_when_other_operator_with_rebooting_nodes_has_stopped", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+		t.Cleanup(cancel)
+
+		rebootBudget := operator.NewRebootBudget(1)
+
+		firstConfig, _ := testConfig(rebootingNode())
+		firstConfig.RebootBudget = rebootBudget
+		firstConfig.RebootBudgetMember = "first"
+		firstConfig.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconciled := make(chan struct{}, 1)
+
+		// Reboot blocker is consulted before new reboots are scheduled, so use it to track reconciliations.
+		firstConfig.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+			select {
+			case reconciled <- struct{}{}:
+			default:
+			}
+
+			return false, "", nil
+		})
+
+		firstKontroller := kontrollerWithObjects(t, firstConfig)
+
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+
+		go func() {
+			if err := firstKontroller.Run(stop); err != nil {
+				fmt.Printf("Error running operator: %v\n", err)
+				t.Fail()
+			}
+			close(stopped)
+		}()
+
+		// Wait for the second reconciliation, so rebooting node got counted while scheduling in the first one.
+		<-reconciled
+		<-reconciled
+
+		close(stop)
+
+		<-stopped
+
+		secondNode := rebootableNode()
+		secondConfig, secondFakeClient := testConfig(secondNode)
+		secondConfig.RebootBudget = rebootBudget
+		secondConfig.RebootBudgetMember = "second"
+		secondConfig.ReconciliationPeriod = 100 * time.Millisecond
+		secondConfig.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+		process(ctx, t, secondConfig, secondFakeClient)
+
+		waitForNodeLabel(ctx, t, secondConfig.Client.CoreV1().Nodes(), secondNode.Name, constants.LabelBeforeReboot)
+	})
+}
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_state_configmap_configured(t *testing.T) {
+	t.Parallel()
+
+	const stateConfigMapName = "flatcar-linux-update-operator-state"
+
+	type persistedState struct {
+		NotReadyNodesReported []string `json:"notReadyNodesReported"`
+	}
+
+	stateConfigMap := func(state string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      stateConfigMapName,
+				Namespace: testNamespace,
+			},
+			Data: map[string]string{
+				"state": state,
+			},
+		}
+	}
+
+	// Check state after each reconciliation cycle, so operator is not blocked on reporting the cycle.
+	waitForPersistedState := func(ctx context.Context, t *testing.T, client kubernetes.Interface,
+		reconcileCycle <-chan struct{}, expected func(persistedState) bool,
+	) {
+		t.Helper()
+
+		for {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Timed out waiting for expected state to be persisted in ConfigMap %q", stateConfigMapName)
+			case <-reconcileCycle:
+			}
+
+			configMap, err := client.CoreV1().ConfigMaps(testNamespace).Get(ctx, stateConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			state := persistedState{}
+
+			if err := json.Unmarshal([]byte(configMap.Data["state"]), &state); err == nil && expected(state) {
+				return
+			}
+		}
+	}
+
+	anyState := func(persistedState) bool { return true }
+
+	t.Run("persists_state_in_created_configmap", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+		t.Cleanup(cancel)
+
+		config, fakeClient := testConfig(rebootableNode())
+		config.StateConfigMap = stateConfigMapName
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		waitForPersistedState(ctx, t, config.Client, reconcileCycle, anyState)
+	})
+
+	t.Run("replaces_malformed_persisted_state", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+		t.Cleanup(cancel)
+
+		config, fakeClient := testConfig(rebootableNode(), stateConfigMap("foo"))
+		config.StateConfigMap = stateConfigMapName
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		waitForPersistedState(ctx, t, config.Client, reconcileCycle, anyState)
+	})
+
+	t.Run("persists_nodes_reported_about_not_ready_nodes", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+		t.Cleanup(cancel)
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.MinReadyNodes = 2
+		config.StateConfigMap = stateConfigMapName
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		waitForPersistedState(ctx, t, config.Client, reconcileCycle, func(state persistedState) bool {
+			return len(state.NotReadyNodesReported) == 1 && state.NotReadyNodesReported[0] == rebootableNode.Name
+		})
+	})
+
+	t.Run("does_not_emit_event_about_not_ready_nodes_again_for_nodes_reported_before_restart", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := contextWithDeadline(t)
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode,
+			stateConfigMap(fmt.Sprintf(`{"notReadyNodesReported":[%q]}`, rebootableNode.Name)))
+		config.MinReadyNodes = 2
+		config.StateConfigMap = stateConfigMapName
+		config.ReconciliationPeriod = 100 * time.Millisecond
+
+		reconcileCycle := process(ctx, t, config, fakeClient)
+
+		// Wait for few cycles, so events from them are recorded as well.
+		for i := 0; i < 3; i++ {
+			<-reconcileCycle
+		}
+
+		events, err := config.Client.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed listing events: %v", err)
+		}
+
+		for _, event := range events.Items {
+			if event.InvolvedObject.Name == rebootableNode.Name && event.Reason == "RebootBlockedByNotReadyNodes" {
+				t.Fatalf("Expected no event about not ready nodes to be emitted, got %q", event.Message)
+			}
+		}
+	})
+}
+
+func Test_Operator_does_not_schedules_reboot_process_outside_reboot_window(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(rebootableNode)
+	config.RebootWindowStart = "Mon 14:00"
+	config.RebootWindowLength = "0s"
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+	if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok && v == constants.True {
+		t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+	}
+}
+
+func Test_Operator_with_node_selector_configured_does_not_schedule_reboot_process_for_nodes_not_matching_selector(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	matchingNode := rebootableNode()
+	matchingNode.Name = "matching"
+	matchingNode.Labels["example.com/pool"] = "workers"
+
+	notMatchingNode := rebootableNode()
+	notMatchingNode.Name = "not-matching"
+
+	config, fakeClient := testConfig(matchingNode, notMatchingNode)
+	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config.NodeSelector = "example.com/pool=workers"
+	config.MaxRebootingNodes = 2
+	config.ReconciliationPeriod = 100 * time.Millisecond
+
+	ctx := contextWithDeadline(t)
+
+	reconcileCycle := process(ctx, t, config, fakeClient)
+
+	// Wait for the second cycle, so the first one has completed.
+	<-reconcileCycle
+	<-reconcileCycle
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), matchingNode.Name)
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected node %q matching selector to be scheduled for reboot", matchingNode.Name)
+	}
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), notMatchingNode.Name)
+	if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Unexpected label %q with value %q on node %q not matching selector",
+			constants.LabelBeforeReboot, v, notMatchingNode.Name)
+	}
+}
+
+func Test_Operator_evaluates_reboot_window_in_configured_timezone(t *testing.T) {
+	t.Parallel()
+
+	location, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Loading location: %v", err)
+	}
+
+	// Window opened 5 minutes ago in Tokyo, which is 9 hours ahead of UTC.
+	rebootWindowStart := time.Now().In(location).Add(-5 * time.Minute).Format("15:04")
+
+	t.Run("schedules_reboot_process_inside_reboot_window_in_configured_timezone", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.RebootWindowStart = rebootWindowStart
+		config.RebootWindowLength = "10m"
+		config.RebootWindowTimezone = location.String()
+
+		ctx := contextWithDeadline(t)
+
+		nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+		<-process(ctx, t, config, fakeClient)
+		<-nodeUpdated
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+			t.Fatalf("Expected node %q to be scheduled for reboot", rebootableNode.Name)
+		}
+	})
+
+	t.Run("evaluates_reboot_window_in_UTC_by_default", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.RebootWindowStart = rebootWindowStart
+		config.RebootWindowLength = "10m"
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok && v == constants.True {
+			t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+		}
+	})
+}
+
+func Test_Operator_annotates_nodes_which_reboot_is_deferred_outside_reboot_window(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+	idleNode := idleNode()
+
+	config, fakeClient := testConfig(rebootableNode, idleNode)
+	config.RebootWindowStart = "Mon 14:00"
+	config.RebootWindowLength = "0s"
+
+	ctx := contextWithDeadline(t)
+
+	// Wait for both nodes to be cleaned up and for the annotation to be set.
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 2)
+	<-process(ctx, t, config, fakeClient)
+	<-nodeUpdated
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+	v := updatedNode.Annotations[constants.AnnotationRebootDeferredReason]
+	if v != constants.RebootDeferredReasonOutsideWindow {
+		t.Fatalf("Expected annotation %q to be %q, got %q",
+			constants.AnnotationRebootDeferredReason, constants.RebootDeferredReasonOutsideWindow, v)
+	}
+
+	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), idleNode.Name)
+	if v, ok := updatedNode.Annotations[constants.AnnotationRebootDeferredReason]; ok {
+		t.Fatalf("Unexpected annotation %q with value %q on node %q",
+			constants.AnnotationRebootDeferredReason, v, idleNode.Name)
+	}
+}
+
+func Test_Operator_removes_reboot_deferred_reason_annotation_inside_reboot_window(t *testing.T) {
+	t.Parallel()
+
+	rebootableNode := rebootableNode()
+	rebootableNode.Annotations[constants.AnnotationRebootDeferredReason] = constants.RebootDeferredReasonOutsideWindow
+
+	config, fakeClient := testConfig(rebootableNode)
+
+	ctx := contextWithDeadline(t)
+
+	// Wait for node to be cleaned up, for the annotation to be removed and for node to be labeled.
+	nodeUpdated := nodeUpdatedNTimes(fakeClient, 2)
+	<-process(ctx, t, config, fakeClient)
+	<-nodeUpdated
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+	if v, ok := updatedNode.Annotations[constants.AnnotationRebootDeferredReason]; ok {
+		t.Fatalf("Unexpected annotation %q with value %q", constants.AnnotationRebootDeferredReason, v)
+	}
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+		t.Fatalf("Expected node %q to be scheduled for reboot", rebootableNode.Name)
+	}
+}
+
+//nolint:funlen // Just many test cases.
+func Test_Operator_updates_reboot_deferred_reason_annotation_when_reboots_are_not_scheduled(t *testing.T) {
+	t.Parallel()
+
+	for name, testCase := range map[string]struct {
+		configure      func(*operator.Config)
+		extraObjects   func() []runtime.Object
+		expectedReason string
+	}{
+		"during_warmup_period": {
+			configure: func(config *operator.Config) {
+				config.WarmupPeriod = time.Hour
+			},
+			expectedReason: constants.RebootDeferredReasonWarmup,
+		},
+		"while_reboots_are_blocked": {
+			configure: func(config *operator.Config) {
+				config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+					return true, "test", nil
+				})
+			},
+			expectedReason: constants.RebootDeferredReasonBlocked,
+		},
+		"while_reboots_are_paused": {
+			configure: func(config *operator.Config) {
+				config.PauseConfigMap = "pause"
+			},
+			extraObjects: func() []runtime.Object {
+				return []runtime.Object{
+					&corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pause",
+							Namespace: testNamespace,
+						},
+						Data: map[string]string{
+							"paused": constants.True,
+						},
+					},
+				}
+			},
+			expectedReason: constants.RebootDeferredReasonPaused,
+		},
+		"while_not_enough_nodes_are_ready": {
+			configure: func(config *operator.Config) {
+				config.MinReadyNodes = 2
+			},
+			expectedReason: constants.RebootDeferredReasonNotEnoughReady,
+		},
+		"while_some_node_failed_post_reboot_verification": {
+			configure: func(config *operator.Config) {
+				config.PostRebootReadyPeriod = time.Hour
+			},
+			extraObjects: func() []runtime.Object {
+				rebootedNode := idleNode()
+				rebootedNode.Name = "rebooted"
+				rebootedNode.Annotations[constants.AnnotationPostRebootVerificationFailed] = constants.True
+				rebootedNode.Status.Conditions = []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				}
+
+				return []runtime.Object{rebootedNode}
+			},
+			expectedReason: constants.RebootDeferredReasonPostRebootVerification,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rebootableNode := rebootableNode()
+			rebootableNode.Annotations[constants.AnnotationRebootDeferredReason] = constants.RebootDeferredReasonOutsideWindow
+			rebootableNode.Status.Conditions = []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}
+
+			objects := []runtime.Object{rebootableNode}
+			if testCase.extraObjects != nil {
+				objects = append(objects, testCase.extraObjects()...)
+			}
+
+			config, _ := testConfig(objects...)
+			config.ReconciliationPeriod = 100 * time.Millisecond
+			testCase.configure(&config)
+
+			ctx := contextWithDeadline(t)
+
+			stop := make(chan struct{})
+			t.Cleanup(func() {
+				close(stop)
+			})
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			waitForNodeAnnotationValue(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name,
+				constants.AnnotationRebootDeferredReason, testCase.expectedReason)
+		})
+	}
+}
+
+// To schedule pre-reboot hooks.
+//
+//nolint:funlen // Just many test cases.
+func Test_Operator_schedules_reboot_process(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	t.Run("only_during_reboot_window", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.RebootWindowStart = "Mon 00:00"
+		config.RebootWindowLength = fmt.Sprintf("%ds", (7*24*60*60)-1)
+
+		nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+		<-process(ctx, t, config, fakeClient)
+		<-nodeUpdated
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+			t.Fatalf("Expected node %q to be scheduled for reboot", rebootableNode.Name)
+		}
+	})
+
+	t.Run("only_for_maximum_number_of_rebooting_nodes_in_parallel", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+
+		config, fakeClient := testConfig(rebootableNode, rebootNotConfirmedNode())
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok && v == constants.True {
+			t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+		}
+	})
+
+	t.Run("for_nodes_which_are_rebootable", func(t *testing.T) {
+		t.Parallel()
+
+		scheduledForRebootNode := scheduledForRebootNode()
+
+		config, fakeClient := testConfig(scheduledForRebootNode)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), scheduledForRebootNode.Name)
+
+		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+			t.Fatalf("Unexpected node %q scheduled for reboot", updatedNode.Name)
+		}
+	})
+
+	t.Run("by", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+		rebootableNode.Annotations[testBeforeRebootAnnotation] = constants.True
+
+		config, fakeClient := testConfig(rebootableNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+		nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+		<-process(ctx, t, config, fakeClient)
+		<-nodeUpdated
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+		t.Run("removing_all_before_reboot_annotations", func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; ok {
+				t.Fatalf("Unexpected annotation %q found", testBeforeRebootAnnotation)
+			}
+		})
+
+		t.Run("setting_before_reboot_label_to_true", func(t *testing.T) {
+			t.Parallel()
+
+			beforeReboot, ok := updatedNode.Labels[constants.LabelBeforeReboot]
+			if !ok {
+				t.Fatalf("Expected label %q not found, got %v instead", constants.LabelBeforeReboot, updatedNode.Labels)
+			}
+
+			if beforeReboot != constants.True {
+				t.Fatalf("Unexpected label value: %q", beforeReboot)
+			}
+		})
+	})
+}
+
+func Test_Operator_approves_reboot_process_for_nodes_which_have(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]struct {
+		mutateF        func(*corev1.Node)
+		expectRebootOK bool
+	}{
+		"all_conditions_met": {
+			// Node without mutation should get ok-to-reboot.
+			expectRebootOK: true,
+		},
+		"before_reboot_label": {
+			mutateF: func(updatedNode *corev1.Node) {
+				// Node without before-reboot label won't get ok-to-reboot.
+				delete(updatedNode.Labels, constants.LabelBeforeReboot)
+			},
+		},
+		"all_before_reboot_annotations_set_to_true": {
+			mutateF: func(updatedNode *corev1.Node) {
+				// Node without all before reboot annotations won't get ok-to-reboot.
+				updatedNode.Annotations[testBeforeRebootAnnotation] = constants.False
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			readyToRebootNode := readyToRebootNode()
+			if testCase.mutateF != nil {
+				testCase.mutateF(readyToRebootNode)
+			}
+
+			config, fakeClient := testConfig(readyToRebootNode)
+
+			// Use beforeRebootAnnotations to be able to test moment when node has before-reboot
+			// label, but it cannot be removed yet.
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+			v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]
+			if testCase.expectRebootOK && (!ok || v != constants.True) {
+				t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+			}
+
+			if !testCase.expectRebootOK && ok && v == constants.True {
+				t.Fatalf("Unexpected reboot-ok annotation")
+			}
+		})
+	}
+}
+
+func Test_Operator_with_before_reboot_annotation_groups_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]struct {
+		groups         [][]string
+		expectRebootOK bool
+	}{
+		"approves_reboot_process_when_all_annotations_from_any_group_are_set_to_true": {
+			groups:         [][]string{{testAnotherBeforeRebootAnnotation}, {testBeforeRebootAnnotation}},
+			expectRebootOK: true,
+		},
+		"does_not_approve_reboot_process_when_no_group_has_all_annotations_set_to_true": {
+			groups: [][]string{
+				{testAnotherBeforeRebootAnnotation},
+				{testBeforeRebootAnnotation, testAnotherBeforeRebootAnnotation},
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			readyToRebootNode := readyToRebootNode()
+			readyToRebootNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.False
+
+			config, fakeClient := testConfig(readyToRebootNode)
+			config.BeforeRebootAnnotationGroups = testCase.groups
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+			v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]
+			if testCase.expectRebootOK && (!ok || v != constants.True) {
+				t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+			}
+
+			if !testCase.expectRebootOK && ok && v == constants.True {
+				t.Fatalf("Unexpected reboot-ok annotation")
+			}
+
+			if !testCase.expectRebootOK {
+				return
+			}
+
+			for _, annotation := range []string{testBeforeRebootAnnotation, testAnotherBeforeRebootAnnotation} {
+				if _, ok := updatedNode.Annotations[annotation]; ok {
+					t.Fatalf("Expected annotation %q to be removed from all groups", annotation)
+				}
+			}
+		})
+	}
+}
+
+func Test_Operator_with_required_node_conditions_configured(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]struct {
+		conditions     []corev1.NodeCondition
+		expectRebootOK bool
+	}{
+		"approves_reboot_process_when_all_required_conditions_are_met": {
+			conditions: []corev1.NodeCondition{
+				{Type: testNodeConditionType, Status: corev1.ConditionTrue},
+				{Type: corev1.NodeNetworkUnavailable, Status: corev1.ConditionFalse},
+			},
+			expectRebootOK: true,
+		},
+		"does_not_approve_reboot_process_when_required_condition_has_different_status": {
+			conditions: []corev1.NodeCondition{
+				{Type: testNodeConditionType, Status: corev1.ConditionFalse},
+				{Type: corev1.NodeNetworkUnavailable, Status: corev1.ConditionFalse},
+			},
+		},
+		"does_not_approve_reboot_process_when_required_condition_is_missing": {
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeNetworkUnavailable, Status: corev1.ConditionFalse},
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			readyToRebootNode := readyToRebootNode()
+			readyToRebootNode.Status.Conditions = testCase.conditions
+
+			config, fakeClient := testConfig(readyToRebootNode)
+			config.RequiredNodeConditions = []string{
+				string(testNodeConditionType),
+				string(corev1.NodeNetworkUnavailable) + "=" + string(corev1.ConditionFalse),
+			}
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+			v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]
+			if testCase.expectRebootOK && (!ok || v != constants.True) {
+				t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+			}
+
+			if testCase.expectRebootOK {
+				return
+			}
+
+			if ok && v == constants.True {
+				t.Fatalf("Unexpected reboot-ok annotation")
+			}
+
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+				t.Fatalf("Expected before-reboot label to be kept on node")
+			}
+
+			waitForWarningEvent(ctx, t, config.Client, readyToRebootNode.Name, "RebootBlockedByNodeConditions")
+		})
 	}
+}
 
-	leaderLease := &struct {
-		HolderIdentity       string    `json:"holderIdentity"`
-		LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
-		AcquireTime          time.Time `json:"acquireTime"`
-		RenewTime            time.Time `json:"renewTime"`
-		LeaderTransitions    int       `json:"leaderTransitions"`
-	}{}
+func Test_Operator_with_annotation_true_values_configured(t *testing.T) {
+	t.Parallel()
 
-	if err := json.Unmarshal([]byte(leader), leaderLease); err != nil {
-		t.Fatalf("Decoding leader annotation data %q: %v", leader, err)
+	ctx := contextWithDeadline(t)
+
+	cases := map[string]struct {
+		value          string
+		expectRebootOK bool
+	}{
+		"approves_reboot_process_when_before_reboot_annotation_is_set_to_configured_value": {
+			value:          "1",
+			expectRebootOK: true,
+		},
+		"approves_reboot_process_when_before_reboot_annotation_is_set_to_other_configured_value": {
+			value:          "True",
+			expectRebootOK: true,
+		},
+		"does_not_approve_reboot_process_when_before_reboot_annotation_is_set_to_value_not_configured": {
+			value: constants.True,
+		},
 	}
 
-	leaderLease.HolderIdentity = "baz"
+	for name, testCase := range cases {
+		testCase := testCase
 
-	leaderBytes, err := json.Marshal(leaderLease)
-	if err != nil {
-		t.Fatalf("Encoding leader annotation data: %q: %v", leader, err)
-	}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	lock.Annotations[leaderAnnotation] = string(leaderBytes)
+			readyToRebootNode := readyToRebootNode()
+			readyToRebootNode.Annotations[testBeforeRebootAnnotation] = testCase.value
 
-	if _, err := configMapClient.Update(ctx, &lock, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating lock ConfigMap: %v", err)
+			config, fakeClient := testConfig(readyToRebootNode)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.AnnotationTrueValues = []string{"True", "1"}
+
+			<-process(ctx, t, config, fakeClient)
+
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+			rebootOK := updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True
+			if rebootOK != testCase.expectRebootOK {
+				t.Fatalf("Expected reboot-ok annotation to be set: %t, got annotations %v",
+					testCase.expectRebootOK, updatedNode.Annotations)
+			}
+		})
 	}
 }
 
-func Test_Operator_waits_for_leader_election_before_reconciliation(t *testing.T) {
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_downgrades_blocked(t *testing.T) {
 	t.Parallel()
 
-	rebootCancelledNode := rebootCancelledNode()
+	ctx := contextWithDeadline(t)
 
-	config, _ := testConfig(rebootCancelledNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
-	config.ReconciliationPeriod = 1 * time.Second
-	testKontroller := kontrollerWithObjects(t, config)
+	cases := map[string]struct {
+		currentVersion  string
+		newVersion      string
+		expectScheduled bool
+	}{
+		"schedules_reboot_process_when_new_version_is_higher": {
+			currentVersion:  "3227.2.0",
+			newVersion:      "3227.2.1",
+			expectScheduled: true,
+		},
+		"schedules_reboot_process_when_current_version_is_not_semver": {
+			currentVersion:  "unknown",
+			newVersion:      "3227.2.0",
+			expectScheduled: true,
+		},
+		"schedules_reboot_process_when_new_version_is_not_semver": {
+			currentVersion:  "3227.2.1",
+			newVersion:      "",
+			expectScheduled: true,
+		},
+		"does_not_schedule_reboot_process_when_new_version_is_lower": {
+			currentVersion: "3227.2.1",
+			newVersion:     "3139.2.3",
+		},
+	}
 
-	stop := make(chan struct{})
-	stopped := make(chan struct{})
+	for name, testCase := range cases {
+		testCase := testCase
 
-	go func() {
-		if err := testKontroller.Run(stop); err != nil {
-			fmt.Printf("Error running operator: %v\n", err)
-			t.Fail()
-		}
-		stopped <- struct{}{}
-	}()
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	time.Sleep(config.ReconciliationPeriod)
+			rebootableNode := rebootableNode()
+			rebootableNode.Labels[constants.LabelVersion] = testCase.currentVersion
+			rebootableNode.Annotations[constants.AnnotationNewVersion] = testCase.newVersion
 
-	close(stop)
+			config, fakeClient := testConfig(rebootableNode)
+			config.BlockDowngrades = true
 
-	<-stopped
+			<-process(ctx, t, config, fakeClient)
 
-	ctx := contextWithDeadline(t)
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			nc := config.Client.CoreV1().Nodes()
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
+			if testCase.expectScheduled {
+				waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
 
-	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+				return
+			}
 
-	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating Node object: %v", err)
+			waitForWarningEvent(ctx, t, config.Client, rebootableNode.Name, "RebootBlockedByDowngrade")
+
+			updatedNode := node(ctx, t, nc, rebootableNode.Name)
+
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected before-reboot label on node which would be downgraded")
+			}
+
+			v := updatedNode.Annotations[constants.AnnotationRebootDeferredReason]
+			if v != constants.RebootDeferredReasonDowngrade {
+				t.Fatalf("Expected annotation %q to be %q, got %q",
+					constants.AnnotationRebootDeferredReason, constants.RebootDeferredReasonDowngrade, v)
+			}
+		})
 	}
 
-	config.LockID = "bar"
-	parallelKontroller := kontrollerWithObjects(t, config)
+	t.Run("schedules_reboot_process_of_other_node_while_node_which_would_be_downgraded_waits", func(t *testing.T) {
+		t.Parallel()
 
-	stop = make(chan struct{})
+		// Listed first, so it would take the only rebooting slot if it was scheduled.
+		downgradingNode := rebootableNode()
+		downgradingNode.Name = "a-downgrading"
+		downgradingNode.Labels[constants.LabelVersion] = "3227.2.1"
+		downgradingNode.Annotations[constants.AnnotationNewVersion] = "3139.2.3"
 
-	t.Cleanup(func() {
-		close(stop)
+		updatingNode := rebootableNode()
+		updatingNode.Name = "b-updating"
+		updatingNode.Labels[constants.LabelVersion] = "3139.2.3"
+		updatingNode.Annotations[constants.AnnotationNewVersion] = "3227.2.1"
+
+		config, fakeClient := testConfig(downgradingNode, updatingNode)
+		config.BlockDowngrades = true
+
+		<-process(ctx, t, config, fakeClient)
+
+		nc := config.Client.CoreV1().Nodes()
+
+		waitForNodeLabel(ctx, t, nc, updatingNode.Name, constants.LabelBeforeReboot)
+
+		if _, ok := node(ctx, t, nc, downgradingNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+			t.Fatalf("Unexpected before-reboot label on node which would be downgraded")
+		}
 	})
 
-	runOperator(ctx, t, parallelKontroller, stop)
+	t.Run("schedules_downgrading_reboot_process_when_not_configured", func(t *testing.T) {
+		t.Parallel()
 
-	time.Sleep(config.ReconciliationPeriod)
+		rebootableNode := rebootableNode()
+		rebootableNode.Labels[constants.LabelVersion] = "3227.2.1"
+		rebootableNode.Annotations[constants.AnnotationNewVersion] = "3139.2.3"
 
-	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+		config, fakeClient := testConfig(rebootableNode)
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
-		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
-	}
+		<-process(ctx, t, config, fakeClient)
+
+		waitForNodeLabel(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name, constants.LabelBeforeReboot)
+	})
 }
 
-func Test_Operator_stops_reconciliation_loop_when_control_channel_is_closed(t *testing.T) {
+//nolint:funlen // Just many subtests.
+func Test_Operator_with_max_version_annotation_on_node(t *testing.T) {
 	t.Parallel()
 
-	rebootCancelledNode := rebootCancelledNode()
+	ctx := contextWithDeadline(t)
 
-	config, fakeClient := testConfig(rebootCancelledNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
-	config.ReconciliationPeriod = 1 * time.Second
-	testKontroller := kontrollerWithObjects(t, config)
+	cases := map[string]struct {
+		maxVersion      string
+		newVersion      string
+		expectScheduled bool
+	}{
+		"schedules_reboot_process_when_new_version_is_lower": {
+			maxVersion:      "3227.2.1",
+			newVersion:      "3227.2.0",
+			expectScheduled: true,
+		},
+		"schedules_reboot_process_when_new_version_is_equal": {
+			maxVersion:      "3227.2.1",
+			newVersion:      "3227.2.1",
+			expectScheduled: true,
+		},
+		"does_not_schedule_reboot_process_when_new_version_is_greater": {
+			maxVersion: "3227.2.1",
+			newVersion: "3374.2.0",
+		},
+		"does_not_schedule_reboot_process_when_max_version_is_not_semver": {
+			maxVersion: "latest",
+			newVersion: "3227.2.0",
+		},
+		"does_not_schedule_reboot_process_when_new_version_is_not_semver": {
+			maxVersion: "3227.2.1",
+			newVersion: "",
+		},
+	}
 
-	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
+	for name, testCase := range cases {
+		testCase := testCase
 
-	stop := make(chan struct{})
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	ctx := contextWithDeadline(t)
+			rebootableNode := rebootableNode()
+			rebootableNode.Annotations[constants.AnnotationMaxVersion] = testCase.maxVersion
+			rebootableNode.Annotations[constants.AnnotationNewVersion] = testCase.newVersion
 
-	runOperator(ctx, t, testKontroller, stop)
+			config, fakeClient := testConfig(rebootableNode)
 
-	<-nodeUpdated
+			<-process(ctx, t, config, fakeClient)
 
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+			nc := config.Client.CoreV1().Nodes()
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
+			if testCase.expectScheduled {
+				waitForNodeLabel(ctx, t, nc, rebootableNode.Name, constants.LabelBeforeReboot)
 
-	close(stop)
+				return
+			}
 
-	time.Sleep(config.ReconciliationPeriod * 2)
+			waitForWarningEvent(ctx, t, config.Client, rebootableNode.Name, "RebootBlockedByMaxVersion")
 
-	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+			updatedNode := node(ctx, t, nc, rebootableNode.Name)
 
-	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating Node object: %v", err)
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+				t.Fatalf("Unexpected before-reboot label on node pinned to lower version")
+			}
+
+			v := updatedNode.Annotations[constants.AnnotationRebootDeferredReason]
+			if v != constants.RebootDeferredReasonMaxVersion {
+				t.Fatalf("Expected annotation %q to be %q, got %q",
+					constants.AnnotationRebootDeferredReason, constants.RebootDeferredReasonMaxVersion, v)
+			}
+		})
 	}
 
-	time.Sleep(config.ReconciliationPeriod * 2)
+	t.Run("schedules_reboot_process_of_other_node_while_node_pinned_to_lower_version_waits", func(t *testing.T) {
+		t.Parallel()
 
-	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+		// Listed first, so it would take the only rebooting slot if it was scheduled.
+		pinnedNode := rebootableNode()
+		pinnedNode.Name = "a-pinned"
+		pinnedNode.Annotations[constants.AnnotationMaxVersion] = "3227.2.1"
+		pinnedNode.Annotations[constants.AnnotationNewVersion] = "3374.2.0"
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
-		t.Fatalf("Expected label %q to remain on Node", constants.LabelBeforeReboot)
-	}
-}
+		otherNode := rebootableNode()
+		otherNode.Name = "b-other"
+		otherNode.Annotations[constants.AnnotationNewVersion] = "3374.2.0"
 
-func Test_Operator_reconciles_objects_every_configured_period(t *testing.T) {
-	t.Parallel()
+		config, fakeClient := testConfig(pinnedNode, otherNode)
 
-	rebootCancelledNode := rebootCancelledNode()
+		<-process(ctx, t, config, fakeClient)
 
-	config, _ := testConfig(rebootCancelledNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
-	config.ReconciliationPeriod = 1 * time.Second
-	testKontroller := kontrollerWithObjects(t, config)
+		nc := config.Client.CoreV1().Nodes()
 
-	stop := make(chan struct{})
+		waitForNodeLabel(ctx, t, nc, otherNode.Name, constants.LabelBeforeReboot)
 
-	t.Cleanup(func() {
-		close(stop)
+		if _, ok := node(ctx, t, nc, pinnedNode.Name).Labels[constants.LabelBeforeReboot]; ok {
+			t.Fatalf("Unexpected before-reboot label on node pinned to lower version")
+		}
 	})
 
-	ctx := contextWithDeadline(t)
+	t.Run("schedules_reboot_process_when_annotation_is_not_set", func(t *testing.T) {
+		t.Parallel()
 
-	runOperator(ctx, t, testKontroller, stop)
+		rebootableNode := rebootableNode()
+		rebootableNode.Annotations[constants.AnnotationNewVersion] = "3374.2.0"
 
-	time.Sleep(config.ReconciliationPeriod)
+		config, fakeClient := testConfig(rebootableNode)
 
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+		<-process(ctx, t, config, fakeClient)
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting the reconciliation period",
-			constants.LabelBeforeReboot)
-	}
+		waitForNodeLabel(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name, constants.LabelBeforeReboot)
+	})
+}
 
-	updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-	updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
+func Test_Operator_records_version_before_reboot_when_approving_reboot_process(t *testing.T) {
+	t.Parallel()
 
-	if _, err := config.Client.CoreV1().Nodes().Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
-		t.Fatalf("Updating Node object: %v", err)
-	}
+	readyToRebootNode := readyToRebootNode()
+	readyToRebootNode.Labels[constants.LabelVersion] = "3227.2.0"
+	readyToRebootNode.Annotations[constants.AnnotationNewVersion] = "3374.2.0"
 
-	time.Sleep(config.ReconciliationPeriod * 2)
+	config, fakeClient := testConfig(readyToRebootNode)
 
-	updatedNode = node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+	ctx := contextWithDeadline(t)
 
-	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-		t.Fatalf("Expected label %q to be removed from Node after waiting another reconciliation period",
-			constants.LabelBeforeReboot)
+	<-process(ctx, t, config, fakeClient)
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+	if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+		t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+	}
+
+	if v := updatedNode.Annotations[constants.AnnotationVersionBeforeReboot]; v != "3227.2.0" {
+		t.Fatalf("Expected version before reboot %q, got %q", "3227.2.0", v)
 	}
 }
 
-// before-reboot label is intended to be used as a selector for pre-reboot hooks, so it should only
-// be set for nodes, which are ready to start rebooting any minute.
-func Test_Operator_cleans_up_nodes_which_cannot_be_rebooted(t *testing.T) {
+func Test_Operator_does_not_record_version_before_reboot_when_approving_maintenance_reboot(t *testing.T) {
 	t.Parallel()
 
-	rebootCancelledNode := rebootCancelledNode()
-
-	toBeRebootedNode := &corev1.Node{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "bar",
-			Annotations: map[string]string{
-				testBeforeRebootAnnotation: "",
-			},
-		},
-	}
+	readyToRebootNode := readyToRebootNode()
+	readyToRebootNode.Labels["maintenance"] = constants.True
+	readyToRebootNode.Labels[constants.LabelVersion] = "3227.2.0"
+	readyToRebootNode.Annotations[constants.AnnotationMaintenanceReboot] = constants.MaintenanceRebootRequested
+	readyToRebootNode.Annotations[constants.AnnotationStatus] = "UPDATE_STATUS_IDLE"
+	readyToRebootNode.Annotations[constants.AnnotationNewVersion] = "0.0.0"
 
-	config, fakeClient := testConfig(rebootCancelledNode, toBeRebootedNode)
-	config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+	config, fakeClient := testConfig(readyToRebootNode)
+	config.MaintenanceNodeSelector = "maintenance=true"
 
 	ctx := contextWithDeadline(t)
 
 	<-process(ctx, t, config, fakeClient)
 
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootCancelledNode.Name)
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
 
-	t.Run("by", func(t *testing.T) {
-		t.Parallel()
+	if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+		t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+	}
 
-		t.Run("removing_before_reboot_label", func(t *testing.T) {
-			t.Parallel()
+	if v, ok := updatedNode.Annotations[constants.AnnotationVersionBeforeReboot]; ok {
+		t.Fatalf("Unexpected version before reboot annotation with value %q", v)
+	}
+}
 
-			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-				t.Fatalf("Unexpected label %q found", constants.LabelBeforeReboot)
-			}
-		})
+func Test_Operator_records_version_before_reboot_when_approving_reboot_process_into_downloaded_update(
+	t *testing.T,
+) {
+	t.Parallel()
 
-		t.Run("removing_configured_before_reboot_annotations", func(t *testing.T) {
-			t.Parallel()
+	readyToRebootNode := readyToRebootNode()
+	readyToRebootNode.Labels[constants.LabelVersion] = "latest"
+	readyToRebootNode.Annotations[constants.AnnotationStatus] = "UPDATE_STATUS_UPDATED_NEED_REBOOT"
+	readyToRebootNode.Annotations[constants.AnnotationNewVersion] = "nightly"
 
-			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; ok {
-				t.Fatalf("Unexpected annotation %q found for node %q", testBeforeRebootAnnotation, rebootCancelledNode.Name)
-			}
+	config, fakeClient := testConfig(readyToRebootNode)
 
-			updatedToBeRebootedNode := node(ctx, t, config.Client.CoreV1().Nodes(), toBeRebootedNode.Name)
+	ctx := contextWithDeadline(t)
 
-			if _, ok := updatedToBeRebootedNode.Annotations[testBeforeRebootAnnotation]; !ok {
-				t.Fatalf("Annotation %q has been removed from wrong node %q",
-					testBeforeRebootAnnotation, toBeRebootedNode.Name)
-			}
-		})
-	})
+	<-process(ctx, t, config, fakeClient)
 
-	// To avoid rebooting nodes which executed before-reboot hooks, but don't need a reboot anymore.
-	t.Run("before_reboot_is_approved", func(t *testing.T) {
-		t.Parallel()
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
 
-		if v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]; ok && v == "true" {
-			t.Fatalf("Unexpected reboot approval")
-		}
-	})
+	if v := updatedNode.Annotations[constants.AnnotationVersionBeforeReboot]; v != "latest" {
+		t.Fatalf("Expected version before reboot %q, got %q", "latest", v)
+	}
 }
 
-func Test_Operator_does_not_count_nodes_as_rebooting_which(t *testing.T) {
+//nolint:funlen // Just many test cases.
+func Test_Operator_finishes_reboot_process_of_nodes_which_rebooted(t *testing.T) {
 	t.Parallel()
 
 	ctx := contextWithDeadline(t)
 
 	cases := map[string]struct {
-		expectedNodeUpdates int
-		extraNode           *corev1.Node
+		versionBeforeReboot string
+		version             string
+		expectRebootFailed  bool
 	}{
-		"has_finished_rebooting": {
-			expectedNodeUpdates: 3,
-			extraNode:           finishedRebootingNode(),
+		"into_the_same_version_marking_them_as_failed": {
+			versionBeforeReboot: "3227.2.0",
+			version:             "3227.2.0",
+			expectRebootFailed:  true,
 		},
-		"are_idle": {
-			expectedNodeUpdates: 2,
-			extraNode:           idleNode(),
+		"into_an_older_version_marking_them_as_failed": {
+			versionBeforeReboot: "3227.2.0",
+			version:             "3139.2.3",
+			expectRebootFailed:  true,
+		},
+		"into_the_same_non_semver_version_marking_them_as_failed": {
+			versionBeforeReboot: "latest",
+			version:             "latest",
+			expectRebootFailed:  true,
+		},
+		"into_a_newer_version_without_marking_them_as_failed": {
+			versionBeforeReboot: "3227.2.0",
+			version:             "3374.2.0",
+		},
+		"into_a_different_non_semver_version_without_marking_them_as_failed": {
+			versionBeforeReboot: "latest",
+			version:             "3374.2.0",
 		},
 	}
 
@@ -576,314 +5553,429 @@ func Test_Operator_does_not_count_nodes_as_rebooting_which(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			rebootableNode := rebootableNode()
+			justRebootedNode := justRebootedNode()
+			justRebootedNode.Labels[constants.LabelVersion] = testCase.version
+			justRebootedNode.Annotations[constants.AnnotationVersionBeforeReboot] = testCase.versionBeforeReboot
+			justRebootedNode.Annotations[constants.AnnotationRebootFailed] = constants.True
 
-			config, fakeClient := testConfig(testCase.extraNode, rebootableNode)
+			config, fakeClient := testConfig(justRebootedNode)
+			config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
 
-			nodeUpdated := nodeUpdatedNTimes(fakeClient, testCase.expectedNodeUpdates)
 			<-process(ctx, t, config, fakeClient)
-			<-nodeUpdated
 
-			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), justRebootedNode.Name)
 
-			beforeReboot, ok := updatedNode.Labels[constants.LabelBeforeReboot]
-			if !ok {
-				t.Fatalf("Expected label %q", constants.LabelBeforeReboot)
+			if _, ok := updatedNode.Labels[constants.LabelAfterReboot]; !ok {
+				t.Fatalf("Expected node to be labeled with after-reboot label, got %v", updatedNode.Labels)
 			}
 
-			if beforeReboot != constants.True {
-				t.Fatalf("Expected value %q for label %q, got: %q", constants.True, constants.LabelBeforeReboot, beforeReboot)
+			if v, ok := updatedNode.Annotations[constants.AnnotationVersionBeforeReboot]; ok {
+				t.Fatalf("Unexpected version before reboot annotation with value %q", v)
+			}
+
+			v, ok := updatedNode.Annotations[constants.AnnotationRebootFailed]
+
+			if !testCase.expectRebootFailed {
+				if ok {
+					t.Fatalf("Expected reboot failed annotation to be removed, got %q", v)
+				}
+
+				return
 			}
+
+			if v != constants.True {
+				t.Fatalf("Expected reboot failed annotation, got %v", updatedNode.Annotations)
+			}
+
+			waitForWarningEvent(ctx, t, config.Client, justRebootedNode.Name, "RebootFailed")
 		})
 	}
 }
 
-// This test attempts to schedule a reboot for a schedulable node and depending on the
-// state of the rebooting node, controller will either proceed with scheduling or will
-// not do anything.
-func Test_Operator_counts_nodes_as_rebooting_which(t *testing.T) {
+func Test_Operator_with_warmup_period_configured(t *testing.T) {
 	t.Parallel()
 
-	ctx := contextWithDeadline(t)
+	t.Run("schedules_reboots_only_once_warmup_period_passes", func(t *testing.T) {
+		t.Parallel()
 
-	cases := map[string]*corev1.Node{
-		"are_scheduled_for_reboot_already": scheduledForRebootNode(),
-		"are_ready_to_reboot":              readyToRebootNode(),
-		"has_reboot_approved":              rebootNotConfirmedNode(),
-		"are_rebooting":                    rebootingNode(),
-		"just_rebooted":                    justRebootedNode(),
-	}
+		ctx := contextWithDeadline(t)
 
-	for name, extraNode := range cases {
-		extraNode := extraNode
+		rebootableNode := rebootableNode()
 
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
+		config, _ := testConfig(rebootableNode)
+		config.ReconciliationPeriod = time.Hour
+		config.WarmupPeriod = time.Second
 
-			rebootableNode := rebootableNode()
+		// Reboot blocker is consulted right before scheduling reboots, so use it to
+		// check when operator starts scheduling them.
+		schedulingStarted := make(chan struct{})
 
-			config, fakeClient := testConfig(extraNode, rebootableNode)
+		var schedulingStartedOnce sync.Once
 
-			// Required to test selecting rebooting nodes only with before-reboot label, otherwise
-			// it gets removed before we schedule nodes for rebooting.
-			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.RebootBlocker = rebootBlockerF(func(context.Context) (bool, string, error) {
+			schedulingStartedOnce.Do(func() { close(schedulingStarted) })
 
-			<-process(ctx, t, config, fakeClient)
+			return false, "", nil
+		})
 
-			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		stop := make(chan struct{})
+		t.Cleanup(func() { close(stop) })
 
-			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-				t.Fatalf("Unexpected node %q scheduled for rebooting", rebootableNode.Name)
-			}
-		})
-	}
+		started := time.Now()
+
+		runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for operator to start scheduling reboots")
+		case <-schedulingStarted:
+		}
+
+		if elapsed := time.Since(started); elapsed < config.WarmupPeriod {
+			t.Fatalf("Expected reboots to be scheduled after warmup period of %v, started after %v",
+				config.WarmupPeriod, elapsed)
+		}
+	})
 }
 
-func Test_Operator_does_not_count_nodes_as_rebootable_which(t *testing.T) {
+func Test_Operator_with_hook_poll_period_configured(t *testing.T) {
 	t.Parallel()
 
-	ctx := contextWithDeadline(t)
+	t.Run("approves_reboot_of_node_which_completed_before_reboot_checks_before_next_reconciliation",
+		func(t *testing.T) {
+			t.Parallel()
 
-	cases := map[string]func(*corev1.Node){
-		"do_not_require_reboot": func(updatedNode *corev1.Node) {
-			updatedNode.Annotations[constants.AnnotationRebootNeeded] = constants.False
-		},
-		"are_already_rebooting": func(updatedNode *corev1.Node) {
-			*updatedNode = *rebootingNode()
+			ctx, cancel := context.WithTimeout(contextWithDeadline(t), 10*time.Second)
+			t.Cleanup(cancel)
+
+			scheduledForRebootNode := scheduledForRebootNode()
+
+			config, _ := testConfig(scheduledForRebootNode)
+			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			config.ReconciliationPeriod = time.Hour
+			config.HookPollPeriod = 100 * time.Millisecond
+
+			stop := make(chan struct{})
+			t.Cleanup(func() { close(stop) })
+
+			runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+			// Give operator time to run initial reconciliation.
+			time.Sleep(5 * config.HookPollPeriod)
+
+			nc := config.Client.CoreV1().Nodes()
+
+			updatedNode := node(ctx, t, nc, scheduledForRebootNode.Name)
 			updatedNode.Annotations[testBeforeRebootAnnotation] = constants.True
-			updatedNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.True
-		},
-		"has_reboot_paused": func(updatedNode *corev1.Node) {
-			updatedNode.Annotations[constants.AnnotationRebootPaused] = constants.True
-		},
-		"has_reboot_already_scheduled": func(updatedNode *corev1.Node) {
-			updatedNode.Labels[constants.LabelBeforeReboot] = constants.True
-			updatedNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.False
-		},
-	}
 
-	for name, mutateF := range cases {
-		mutateF := mutateF
+			if _, err := nc.Update(ctx, updatedNode, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("Failed updating node: %v", err)
+			}
 
-		t.Run(name, func(t *testing.T) {
+			ticker := time.NewTicker(config.HookPollPeriod)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					t.Fatalf("Timed out waiting for reboot to be approved")
+				case <-ticker.C:
+				}
+
+				updatedNode := node(ctx, t, nc, scheduledForRebootNode.Name)
+				if updatedNode.Annotations[constants.AnnotationOkToReboot] == constants.True {
+					return
+				}
+			}
+		})
+}
+
+func Test_Operator_with_manual_approval_required(t *testing.T) {
+	t.Parallel()
+
+	t.Run("marks_node_which_passed_before_reboot_checks_as_pending_approval_instead_of_approving_reboot",
+		func(t *testing.T) {
 			t.Parallel()
 
-			rebootableNode := rebootableNode()
-			rebootableNode.Annotations[testBeforeRebootAnnotation] = constants.True
-			rebootableNode.Annotations[testAnotherBeforeRebootAnnotation] = constants.True
+			ctx := contextWithDeadline(t)
 
-			mutateF(rebootableNode)
+			readyToRebootNode := readyToRebootNode()
 
-			config, fakeClient := testConfig(rebootableNode)
-			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation, testAnotherBeforeRebootAnnotation}
-			// To test filter on before-reboot label.
-			config.MaxRebootingNodes = 2
+			config, fakeClient := testConfig(readyToRebootNode)
+			config.RequireManualApproval = true
 
 			<-process(ctx, t, config, fakeClient)
 
-			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
 
-			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; !ok {
-				t.Fatalf("Unexpected node %q scheduled for rebooting", rebootableNode.Name)
+			if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v == constants.True {
+				t.Fatalf("Unexpected reboot-ok annotation")
 			}
+
+			if v := updatedNode.Annotations[constants.AnnotationPendingApproval]; v != constants.True {
+				t.Fatalf("Expected annotation %q to be %q, got %q", constants.AnnotationPendingApproval, constants.True, v)
+			}
+
+			if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
+				t.Fatalf("Expected before-reboot label to be kept on node")
+			}
+
+			waitForEvent(ctx, t, config.Client, readyToRebootNode.Name, corev1.EventTypeNormal, "RebootPendingApproval")
 		})
-	}
-}
 
-func Test_Operator_counts_nodes_as_rebootable_which_needs_reboot_and_has_all_other_conditions_met(t *testing.T) {
-	t.Parallel()
+	t.Run("approves_reboot_of_node_pending_approval_once_approved", func(t *testing.T) {
+		t.Parallel()
 
-	rebootableNode := rebootableNode()
+		ctx := contextWithDeadline(t)
 
-	config, fakeClient := testConfig(rebootableNode)
+		approvedNode := readyToRebootNode()
+		approvedNode.Annotations[constants.AnnotationPendingApproval] = constants.True
+		approvedNode.Annotations[constants.AnnotationApprovedBy] = "jane"
 
-	ctx := contextWithDeadline(t)
+		config, fakeClient := testConfig(approvedNode)
+		config.RequireManualApproval = true
 
-	nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
-	<-process(ctx, t, config, fakeClient)
-	<-nodeUpdated
+		<-process(ctx, t, config, fakeClient)
 
-	updatedNode := node(contextWithDeadline(t), t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), approvedNode.Name)
 
-	v, ok := updatedNode.Labels[constants.LabelBeforeReboot]
-	if !ok || v != constants.True {
-		t.Fatalf("Expected node %q to be scheduled for rebooting", rebootableNode.Name)
-	}
-}
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+		}
 
-func Test_Operator_does_not_schedules_reboot_process_outside_reboot_window(t *testing.T) {
-	t.Parallel()
+		for _, annotation := range []string{constants.AnnotationPendingApproval, constants.AnnotationApprovedBy} {
+			if _, ok := updatedNode.Annotations[annotation]; ok {
+				t.Fatalf("Expected annotation %q to be removed", annotation)
+			}
+		}
 
-	rebootableNode := rebootableNode()
+		waitForEvent(ctx, t, config.Client, approvedNode.Name, corev1.EventTypeNormal, "RebootApproved")
+	})
 
-	config, fakeClient := testConfig(rebootableNode)
-	config.RebootWindowStart = "Mon 14:00"
-	config.RebootWindowLength = "0s"
+	t.Run("removes_approval_annotations_from_nodes_which_no_longer_need_reboot", func(t *testing.T) {
+		t.Parallel()
 
-	ctx := contextWithDeadline(t)
+		ctx := contextWithDeadline(t)
 
-	<-process(ctx, t, config, fakeClient)
+		noLongerRebootableNode := readyToRebootNode()
+		noLongerRebootableNode.Annotations[constants.AnnotationRebootNeeded] = constants.False
+		noLongerRebootableNode.Annotations[constants.AnnotationPendingApproval] = constants.True
+		noLongerRebootableNode.Annotations[constants.AnnotationApprovedBy] = "jane"
 
-	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
-	if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok && v == constants.True {
-		t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
-	}
+		config, fakeClient := testConfig(noLongerRebootableNode)
+		config.RequireManualApproval = true
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), noLongerRebootableNode.Name)
+
+		for _, annotation := range []string{constants.AnnotationPendingApproval, constants.AnnotationApprovedBy} {
+			if _, ok := updatedNode.Annotations[annotation]; ok {
+				t.Fatalf("Expected annotation %q to be removed", annotation)
+			}
+		}
+	})
 }
 
-// To schedule pre-reboot hooks.
-//
-//nolint:funlen // Just many test cases.
-func Test_Operator_schedules_reboot_process(t *testing.T) {
+func Test_Operator_reports_how_long_nodes_are_in_current_phase(t *testing.T) {
 	t.Parallel()
 
-	ctx := contextWithDeadline(t)
+	t.Run("by_stamping_phase_transition_time_on_phase_change", func(t *testing.T) {
+		t.Parallel()
 
-	t.Run("only_during_reboot_window", func(t *testing.T) {
+		ctx := contextWithDeadline(t)
+
+		readyToRebootNode := readyToRebootNode()
+		readyToRebootNode.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-time.Hour).
+			UTC().Format(time.RFC3339)
+
+		config, fakeClient := testConfig(readyToRebootNode)
+
+		<-process(ctx, t, config, fakeClient)
+
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+		}
+
+		transitionTime, err := time.Parse(time.RFC3339, updatedNode.Annotations[constants.AnnotationPhaseTransitionTime])
+		if err != nil {
+			t.Fatalf("Parsing phase transition time: %v", err)
+		}
+
+		if time.Since(transitionTime) > time.Minute {
+			t.Fatalf("Expected phase transition time to be updated, got %v", transitionTime)
+		}
+	})
+
+	t.Run("by_exposing_seconds_in_current_phase_metric", func(t *testing.T) {
 		t.Parallel()
 
-		rebootableNode := rebootableNode()
+		ctx := contextWithDeadline(t)
 
-		config, fakeClient := testConfig(rebootableNode)
-		config.RebootWindowStart = "Mon 00:00"
-		config.RebootWindowLength = fmt.Sprintf("%ds", (7*24*60*60)-1)
+		scheduledForRebootNode := scheduledForRebootNode()
+		scheduledForRebootNode.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-time.Hour).
+			UTC().Format(time.RFC3339)
+
+		registry := prometheus.NewRegistry()
+
+		config, fakeClient := testConfig(scheduledForRebootNode)
+		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config.MetricsRegisterer = registry
 
-		nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
 		<-process(ctx, t, config, fakeClient)
-		<-nodeUpdated
 
-		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
-		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; !ok {
-			t.Fatalf("Expected node %q to be scheduled for reboot", rebootableNode.Name)
+		seconds, ok := secondsInCurrentPhase(t, registry, scheduledForRebootNode.Name, "before-reboot")
+		if !ok {
+			t.Fatalf("Expected metric for node %q in before-reboot phase", scheduledForRebootNode.Name)
+		}
+
+		if seconds < time.Hour.Seconds() {
+			t.Fatalf("Expected node to be in current phase for at least an hour, got %v seconds", seconds)
 		}
 	})
 
-	t.Run("only_for_maximum_number_of_rebooting_nodes_in_parallel", func(t *testing.T) {
+	t.Run("by_exposing_seconds_in_paused_phase_for_nodes_needing_reboot_with_reboot_paused", func(t *testing.T) {
 		t.Parallel()
 
-		rebootableNode := rebootableNode()
+		ctx := contextWithDeadline(t)
 
-		config, fakeClient := testConfig(rebootableNode, rebootNotConfirmedNode())
+		pausedNode := rebootableNode()
+		pausedNode.Annotations[constants.AnnotationRebootPaused] = constants.True
+		pausedNode.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().Add(-time.Hour).
+			UTC().Format(time.RFC3339)
+
+		registry := prometheus.NewRegistry()
+
+		config, fakeClient := testConfig(pausedNode)
+		config.MetricsRegisterer = registry
 
 		<-process(ctx, t, config, fakeClient)
 
-		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
-		if v, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok && v == constants.True {
-			t.Fatalf("Unexpected node %q scheduled for reboot", rebootableNode.Name)
+		seconds, ok := secondsInCurrentPhase(t, registry, pausedNode.Name, "paused")
+		if !ok {
+			t.Fatalf("Expected metric for node %q in paused phase", pausedNode.Name)
+		}
+
+		if seconds < time.Hour.Seconds() {
+			t.Fatalf("Expected node to be paused for at least an hour, got %v seconds", seconds)
 		}
 	})
 
-	t.Run("for_nodes_which_are_rebootable", func(t *testing.T) {
+	t.Run("by_removing_phase_transition_time_from_nodes_which_are_not_updating", func(t *testing.T) {
 		t.Parallel()
 
-		scheduledForRebootNode := scheduledForRebootNode()
+		ctx := contextWithDeadline(t)
 
-		config, fakeClient := testConfig(scheduledForRebootNode)
+		idleNode := idleNode()
+		idleNode.Annotations[constants.AnnotationPhaseTransitionTime] = time.Now().UTC().Format(time.RFC3339)
+
+		registry := prometheus.NewRegistry()
+
+		config, fakeClient := testConfig(idleNode)
+		config.MetricsRegisterer = registry
 
 		<-process(ctx, t, config, fakeClient)
 
-		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), scheduledForRebootNode.Name)
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), idleNode.Name)
 
-		if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
-			t.Fatalf("Unexpected node %q scheduled for reboot", updatedNode.Name)
+		if _, ok := updatedNode.Annotations[constants.AnnotationPhaseTransitionTime]; ok {
+			t.Fatalf("Expected phase transition time annotation to be removed")
+		}
+
+		for _, phase := range []string{"scheduling", "before-reboot", "rebooting", "after-reboot"} {
+			if _, ok := secondsInCurrentPhase(t, registry, idleNode.Name, phase); ok {
+				t.Fatalf("Unexpected metric for node %q in %s phase", idleNode.Name, phase)
+			}
 		}
 	})
+}
 
-	t.Run("by", func(t *testing.T) {
+//nolint:funlen // Just many subtests.
+func Test_Operator_records_reboot_duration(t *testing.T) {
+	t.Parallel()
+
+	t.Run("by_setting_reboot_started_time_when_approving_reboot_process", func(t *testing.T) {
 		t.Parallel()
 
-		rebootableNode := rebootableNode()
-		rebootableNode.Annotations[testBeforeRebootAnnotation] = constants.True
+		readyToRebootNode := readyToRebootNode()
+		readyToRebootNode.Annotations[constants.AnnotationRebootCompletedTime] = "2023-08-01T12:00:00Z"
 
-		config, fakeClient := testConfig(rebootableNode)
-		config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+		config, fakeClient := testConfig(readyToRebootNode)
+
+		ctx := contextWithDeadline(t)
 
-		nodeUpdated := nodeUpdatedNTimes(fakeClient, 1)
 		<-process(ctx, t, config, fakeClient)
-		<-nodeUpdated
 
-		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+		updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
 
-		t.Run("removing_all_before_reboot_annotations", func(t *testing.T) {
-			t.Parallel()
+		if v := updatedNode.Annotations[constants.AnnotationOkToReboot]; v != constants.True {
+			t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
+		}
 
-			if _, ok := updatedNode.Annotations[testBeforeRebootAnnotation]; ok {
-				t.Fatalf("Unexpected annotation %q found", testBeforeRebootAnnotation)
-			}
-		})
+		startedTime := updatedNode.Annotations[constants.AnnotationRebootStartedTime]
+		if _, err := time.Parse(time.RFC3339, startedTime); err != nil {
+			t.Fatalf("Expected valid reboot started time, got %q: %v", startedTime, err)
+		}
 
-		t.Run("setting_before_reboot_label_to_true", func(t *testing.T) {
+		if v, ok := updatedNode.Annotations[constants.AnnotationRebootCompletedTime]; ok {
+			t.Fatalf("Expected reboot completed time of previous reboot to be removed, got %q", v)
+		}
+	})
+
+	t.Run("by_setting_reboot_completed_time_and_reporting_reboot_duration_when_finishing_reboot_process",
+		func(t *testing.T) {
 			t.Parallel()
 
-			beforeReboot, ok := updatedNode.Labels[constants.LabelBeforeReboot]
-			if !ok {
-				t.Fatalf("Expected label %q not found, got %v instead", constants.LabelBeforeReboot, updatedNode.Labels)
-			}
+			rebootDuration := 10 * time.Minute
 
-			if beforeReboot != constants.True {
-				t.Fatalf("Unexpected label value: %q", beforeReboot)
-			}
-		})
-	})
-}
+			finishedRebootingNode := finishedRebootingNode()
+			finishedRebootingNode.Annotations[constants.AnnotationRebootStartedTime] = time.Now().
+				Add(-rebootDuration).UTC().Format(time.RFC3339)
 
-func Test_Operator_approves_reboot_process_for_nodes_which_have(t *testing.T) {
-	t.Parallel()
+			registry := prometheus.NewRegistry()
 
-	ctx := contextWithDeadline(t)
+			config, fakeClient := testConfig(finishedRebootingNode)
+			config.AfterRebootAnnotations = []string{testAfterRebootAnnotation}
+			config.MetricsRegisterer = registry
 
-	cases := map[string]struct {
-		mutateF        func(*corev1.Node)
-		expectRebootOK bool
-	}{
-		"all_conditions_met": {
-			// Node without mutation should get ok-to-reboot.
-			expectRebootOK: true,
-		},
-		"before_reboot_label": {
-			mutateF: func(updatedNode *corev1.Node) {
-				// Node without before-reboot label won't get ok-to-reboot.
-				delete(updatedNode.Labels, constants.LabelBeforeReboot)
-			},
-		},
-		"all_before_reboot_annotations_set_to_true": {
-			mutateF: func(updatedNode *corev1.Node) {
-				// Node without all before reboot annotations won't get ok-to-reboot.
-				updatedNode.Annotations[testBeforeRebootAnnotation] = constants.False
-			},
-		},
-	}
+			ctx := contextWithDeadline(t)
 
-	for name, testCase := range cases {
-		testCase := testCase
+			<-process(ctx, t, config, fakeClient)
 
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
+			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), finishedRebootingNode.Name)
 
-			readyToRebootNode := readyToRebootNode()
-			if testCase.mutateF != nil {
-				testCase.mutateF(readyToRebootNode)
+			completedTime := updatedNode.Annotations[constants.AnnotationRebootCompletedTime]
+			if _, err := time.Parse(time.RFC3339, completedTime); err != nil {
+				t.Fatalf("Expected valid reboot completed time, got %q: %v", completedTime, err)
 			}
 
-			config, fakeClient := testConfig(readyToRebootNode)
+			metricFamilies, err := registry.Gather()
+			if err != nil {
+				t.Fatalf("Failed gathering metrics: %v", err)
+			}
 
-			// Use beforeRebootAnnotations to be able to test moment when node has before-reboot
-			// label, but it cannot be removed yet.
-			config.BeforeRebootAnnotations = []string{testBeforeRebootAnnotation}
+			for _, metricFamily := range metricFamilies {
+				if metricFamily.GetName() != "flatcar_linux_update_operator_node_reboot_duration_seconds" {
+					continue
+				}
 
-			<-process(ctx, t, config, fakeClient)
+				histogram := metricFamily.GetMetric()[0].GetHistogram()
 
-			updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), readyToRebootNode.Name)
+				if count := histogram.GetSampleCount(); count != 1 {
+					t.Fatalf("Expected exactly one reboot duration observed, got %d", count)
+				}
 
-			v, ok := updatedNode.Annotations[constants.AnnotationOkToReboot]
-			if testCase.expectRebootOK && (!ok || v != constants.True) {
-				t.Fatalf("Expected reboot-ok annotation, got %v", updatedNode.Annotations)
-			}
+				if sum := histogram.GetSampleSum(); sum < rebootDuration.Seconds() {
+					t.Fatalf("Expected reboot duration of at least %v, got %fs", rebootDuration, sum)
+				}
 
-			if !testCase.expectRebootOK && ok && v == constants.True {
-				t.Fatalf("Unexpected reboot-ok annotation")
+				return
 			}
+
+			t.Fatalf("Reboot duration metric not found")
 		})
-	}
 }
 
 // To inform agent it can proceed with node draining and rebooting.
@@ -1122,6 +6214,152 @@ func Test_Operator_counts_nodes_as_which_finished_rebooting_which_has(t *testing
 	}
 }
 
+func Test_Operator_updates_nodes_in_parallel_up_to_configured_concurrency(t *testing.T) {
+	t.Parallel()
+
+	nodes := []runtime.Object{}
+
+	for _, name := range []string{"foo", "bar", "baz"} {
+		n := idleNode()
+		n.Name = name
+		nodes = append(nodes, n)
+	}
+
+	config, fakeClient := testConfig(nodes...)
+	config.NodeUpdateConcurrency = 2
+
+	client := &concurrentUpdatesTrackingClient{
+		Interface:   config.Client,
+		concurrency: config.NodeUpdateConcurrency,
+		reached:     make(chan struct{}),
+	}
+	config.Client = client
+
+	ctx := contextWithDeadline(t)
+
+	<-process(ctx, t, config, fakeClient)
+
+	if maxInFlight := client.maxInFlightUpdates(); maxInFlight != config.NodeUpdateConcurrency {
+		t.Fatalf("Expected %d node updates in parallel, got %d", config.NodeUpdateConcurrency, maxInFlight)
+	}
+}
+
+// Each node update is delayed to simulate API server latency, which makes updating nodes in parallel worthwhile.
+func Benchmark_Operator_finishing_reboot_process_of_many_nodes(b *testing.B) {
+	nodesCount := 300
+	updateLatency := time.Millisecond
+
+	for _, concurrency := range []int{1, 10} {
+		concurrency := concurrency
+
+		b.Run(fmt.Sprintf("with_node_update_concurrency_%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+
+				nodes := make([]runtime.Object, 0, nodesCount)
+
+				for j := 0; j < nodesCount; j++ {
+					n := finishedRebootingNode()
+					n.Name = fmt.Sprintf("node-%d", j)
+					nodes = append(nodes, n)
+				}
+
+				config, _ := testConfig(nodes...)
+				config.Client = &slowUpdatesClient{Interface: config.Client, latency: updateLatency}
+				config.OneShot = true
+				config.NodeUpdateConcurrency = concurrency
+				config.AfterRebootAnnotations = []string{testAfterRebootAnnotation, testAnotherAfterRebootAnnotation}
+
+				kontroller, err := operator.New(config)
+				if err != nil {
+					b.Fatalf("Failed creating controller instance: %v", err)
+				}
+
+				b.StartTimer()
+
+				if err := kontroller.Run(make(chan struct{})); err != nil {
+					b.Fatalf("Unexpected error running operator: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_Operator_stops_current_reconciliation_when_parallel_node_update_fails(t *testing.T) {
+	t.Parallel()
+
+	firstIdleNode := idleNode()
+	firstIdleNode.Name = "foo"
+
+	secondIdleNode := idleNode()
+	secondIdleNode.Name = "bar"
+
+	rebootableNode := rebootableNode()
+
+	config, fakeClient := testConfig(firstIdleNode, secondIdleNode, rebootableNode)
+	config.NodeUpdateConcurrency = 2
+
+	requestFailed, failRequest := failOnNthCall(0, fmt.Errorf(t.Name()))
+	fakeClient.PrependReactor("update", "nodes", failRequest)
+
+	ctx, cancel := context.WithTimeout(contextWithDeadline(t), 5*time.Second)
+	t.Cleanup(cancel)
+
+	process(ctx, t, config, fakeClient)
+
+	select {
+	case <-requestFailed:
+	case <-ctx.Done():
+		t.Fatalf("Timed out waiting for request to fail")
+	}
+
+	updatedNode := node(ctx, t, config.Client.CoreV1().Nodes(), rebootableNode.Name)
+
+	if _, ok := updatedNode.Labels[constants.LabelBeforeReboot]; ok {
+		t.Fatalf("Unexpected label %q found", constants.LabelBeforeReboot)
+	}
+}
+
+func Test_Operator_calls_configured_reconcile_error_handler_when_reconciliation_fails(t *testing.T) {
+	t.Parallel()
+
+	config, fakeClient := testConfig(idleNode())
+
+	expectedErr := fmt.Errorf(t.Name())
+
+	fakeClient.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, expectedErr
+	})
+
+	reconcileErrors := make(chan error, 1)
+
+	config.ReconcileErrorHandler = func(err error) {
+		select {
+		case reconcileErrors <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(contextWithDeadline(t), 5*time.Second)
+	t.Cleanup(cancel)
+
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	runOperator(ctx, t, kontrollerWithObjects(t, config), stop)
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("Timed out waiting for reconcile error handler to be called")
+	case err := <-reconcileErrors:
+		if !errors.Is(err, expectedErr) {
+			t.Fatalf("Expected error %q, got %q", expectedErr, err)
+		}
+	}
+}
+
 //nolint:funlen // Just many sub-tests.
 func Test_Operator_stops_current_reconciliation_when(t *testing.T) {
 	t.Parallel()
@@ -1512,6 +6750,36 @@ func node(ctx context.Context, t *testing.T, nodeClient corev1client.NodeInterfa
 	return node
 }
 
+// secondsInCurrentPhase returns value of the seconds in current phase metric for given node and phase
+// and whether the metric has been found.
+func secondsInCurrentPhase(t *testing.T, gatherer prometheus.Gatherer, nodeName, phase string) (float64, bool) {
+	t.Helper()
+
+	metricFamilies, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed gathering metrics: %v", err)
+	}
+
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() != "flatcar_linux_update_operator_node_seconds_in_current_phase" {
+			continue
+		}
+
+		for _, metric := range metricFamily.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["node"] == nodeName && labels["phase"] == phase {
+				return metric.GetGauge().GetValue(), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
 func process(ctx context.Context, t *testing.T, config operator.Config, fakeClient *k8stesting.Fake) chan struct{} {
 	t.Helper()
 
@@ -1523,7 +6791,13 @@ func process(ctx context.Context, t *testing.T, config operator.Config, fakeClie
 		operatorListOperations := 4
 
 		if listCallsCount == operatorListOperations {
-			reconcileCycleCh <- struct{}{}
+			// Reactors are called with fake client lock held, so never block on tests which
+			// stopped listening, as that would block all other client calls.
+			select {
+			case reconcileCycleCh <- struct{}{}:
+			default:
+			}
+
 			listCallsCount = 0
 
 			return false, nil, nil
@@ -1545,6 +6819,131 @@ func process(ctx context.Context, t *testing.T, config operator.Config, fakeClie
 	return reconcileCycleCh
 }
 
+type rebootBlockerF func(ctx context.Context) (bool, string, error)
+
+func (f rebootBlockerF) RebootBlocked(ctx context.Context) (bool, string, error) {
+	return f(ctx)
+}
+
+// concurrentUpdatesTrackingClient holds Node update calls until configured number of them
+// is in flight, to detect how many updates are executed in parallel.
+type concurrentUpdatesTrackingClient struct {
+	kubernetes.Interface
+
+	concurrency int
+	reached     chan struct{}
+	reachedOnce sync.Once
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+type concurrentUpdatesTrackingCoreV1 struct {
+	corev1client.CoreV1Interface
+
+	client *concurrentUpdatesTrackingClient
+}
+
+type concurrentUpdatesTrackingNodes struct {
+	corev1client.NodeInterface
+
+	client *concurrentUpdatesTrackingClient
+}
+
+func (c *concurrentUpdatesTrackingClient) CoreV1() corev1client.CoreV1Interface {
+	return &concurrentUpdatesTrackingCoreV1{
+		CoreV1Interface: c.Interface.CoreV1(),
+		client:          c,
+	}
+}
+
+func (c *concurrentUpdatesTrackingClient) maxInFlightUpdates() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.maxInFlight
+}
+
+func (c *concurrentUpdatesTrackingCoreV1) Nodes() corev1client.NodeInterface {
+	return &concurrentUpdatesTrackingNodes{
+		NodeInterface: c.CoreV1Interface.Nodes(),
+		client:        c.client,
+	}
+}
+
+func (n *concurrentUpdatesTrackingNodes) Update(
+	ctx context.Context, node *corev1.Node, opts metav1.UpdateOptions,
+) (*corev1.Node, error) {
+	c := n.client
+
+	c.mu.Lock()
+	c.inFlight++
+
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+
+	if c.inFlight == c.concurrency {
+		c.reachedOnce.Do(func() { close(c.reached) })
+	}
+	c.mu.Unlock()
+
+	// Do not block forever if updates are not executed in parallel.
+	select {
+	case <-c.reached:
+	case <-time.After(time.Second):
+	}
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+
+	return n.NodeInterface.Update(ctx, node, opts)
+}
+
+// slowUpdatesClient delays each node update by given latency. Delaying updates using a reactor is not
+// possible, as fake client does not run reactors in parallel.
+type slowUpdatesClient struct {
+	kubernetes.Interface
+
+	latency time.Duration
+}
+
+type slowUpdatesCoreV1 struct {
+	corev1client.CoreV1Interface
+
+	latency time.Duration
+}
+
+type slowUpdatesNodes struct {
+	corev1client.NodeInterface
+
+	latency time.Duration
+}
+
+func (c *slowUpdatesClient) CoreV1() corev1client.CoreV1Interface {
+	return &slowUpdatesCoreV1{
+		CoreV1Interface: c.Interface.CoreV1(),
+		latency:         c.latency,
+	}
+}
+
+func (c *slowUpdatesCoreV1) Nodes() corev1client.NodeInterface {
+	return &slowUpdatesNodes{
+		NodeInterface: c.CoreV1Interface.Nodes(),
+		latency:       c.latency,
+	}
+}
+
+func (n *slowUpdatesNodes) Update(
+	ctx context.Context, node *corev1.Node, opts metav1.UpdateOptions,
+) (*corev1.Node, error) {
+	time.Sleep(n.latency)
+
+	return n.NodeInterface.Update(ctx, node, opts)
+}
+
 func nodeUpdatedNTimes(fakeClient *k8stesting.Fake, expectedUpdateCalls int) chan struct{} {
 	updateCallsCount := 0
 	nodeUpdatedCh := make(chan struct{}, 1)
@@ -1586,3 +6985,76 @@ func failOnNthCall(failingCall int, err error) (chan struct{}, k8stesting.Reacti
 		return true, nil, err
 	}
 }
+
+func waitForNodeLabel(ctx context.Context, t *testing.T, nc corev1client.NodeInterface, nodeName, label string) {
+	t.Helper()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for label %q on node %q", label, nodeName)
+		case <-ticker.C:
+		}
+
+		if _, ok := node(ctx, t, nc, nodeName).Labels[label]; ok {
+			return
+		}
+	}
+}
+
+func waitForNodeAnnotationValue(
+	ctx context.Context, t *testing.T, nc corev1client.NodeInterface, nodeName, key, value string,
+) {
+	t.Helper()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for annotation %q on node %q to be %q", key, nodeName, value)
+		case <-ticker.C:
+		}
+
+		if node(ctx, t, nc, nodeName).Annotations[key] == value {
+			return
+		}
+	}
+}
+
+func waitForWarningEvent(ctx context.Context, t *testing.T, client kubernetes.Interface, nodeName, reason string) {
+	t.Helper()
+
+	waitForEvent(ctx, t, client, nodeName, corev1.EventTypeWarning, reason)
+}
+
+func waitForEvent(ctx context.Context, t *testing.T, client kubernetes.Interface, nodeName, eventType, reason string) {
+	t.Helper()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for %s event %q about node %q", eventType, reason, nodeName)
+		case <-ticker.C:
+		}
+
+		events, err := client.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed listing events: %v", err)
+		}
+
+		for _, event := range events.Items {
+			if event.InvolvedObject.Kind == "Node" && event.InvolvedObject.Name == nodeName &&
+				event.Type == eventType && event.Reason == reason {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// NodeOrdering defines in which order nodes requiring a reboot are scheduled for rebooting.
+type NodeOrdering string
+
+const (
+	// NodeOrderingOldestFirst schedules nodes created first for rebooting first.
+	NodeOrderingOldestFirst NodeOrdering = "oldest-first"
+	// NodeOrderingNewestFirst schedules nodes created last for rebooting first.
+	NodeOrderingNewestFirst NodeOrdering = "newest-first"
+)
+
+// checkNodeOrdering checks if given node ordering is supported. Empty ordering means that
+// nodes are scheduled for rebooting in the order returned by the API server.
+func checkNodeOrdering(ordering NodeOrdering) error {
+	switch ordering {
+	case "", NodeOrderingOldestFirst, NodeOrderingNewestFirst:
+		return nil
+	default:
+		return fmt.Errorf("unsupported node ordering %q, expected one of: %q, %q",
+			ordering, NodeOrderingOldestFirst, NodeOrderingNewestFirst)
+	}
+}
+
+// sortNodes sorts given nodes in place according to given ordering. Nodes with the same
+// creation timestamp are sorted by name to keep the order stable between reconciliations.
+func sortNodes(nodes []corev1.Node, ordering NodeOrdering) {
+	if ordering == "" {
+		return
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		createdI, createdJ := nodes[i].CreationTimestamp, nodes[j].CreationTimestamp
+
+		if createdI.Equal(&createdJ) {
+			return nodes[i].Name < nodes[j].Name
+		}
+
+		if ordering == NodeOrderingNewestFirst {
+			return createdJ.Before(&createdI)
+		}
+
+		return createdI.Before(&createdJ)
+	})
+}
+
+// sortNodesByRebootPriority sorts given nodes in place by integer value of given annotation in descending
+// order, keeping the order of nodes with the same priority. Nodes without the annotation or with a value
+// which is not an integer have priority 0.
+//
+// If annotation is empty, nodes are not sorted.
+func sortNodesByRebootPriority(nodes []corev1.Node, annotation string) {
+	if annotation == "" {
+		return
+	}
+
+	priorities := make(map[string]int, len(nodes))
+
+	for _, node := range nodes {
+		priorities[node.Name] = rebootPriority(node, annotation)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return priorities[nodes[i].Name] > priorities[nodes[j].Name]
+	})
+}
+
+// rebootPriority returns reboot priority of a given node from a given annotation.
+func rebootPriority(node corev1.Node, annotation string) int {
+	value, ok := node.Annotations[annotation]
+	if !ok {
+		return 0
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("Node %q has invalid reboot priority %q in annotation %q, using priority 0: %v",
+			node.Name, value, annotation, err)
+
+		return 0
+	}
+
+	return priority
+}
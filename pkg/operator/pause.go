@@ -0,0 +1,90 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+const (
+	eventReasonRebootPausedTooLong = "RebootPausedTooLong"
+
+	// Key in pause ConfigMap, which when set to "true" pauses reboots of all nodes.
+	pauseConfigMapKey = "paused"
+)
+
+// updateRebootPauseState marks a given node which has needed a reboot while having reboot paused for longer than
+// configured reboot pause timeout, so long-forgotten pauses do not leave nodes outdated unnoticed. Time since the
+// node is paused is taken from its phase transition time, so the phase transition time must be up to date.
+// It returns true if the node has just been marked.
+//
+// If reboot pause timeout is not configured, nothing is done.
+func (k *Kontroller) updateRebootPauseState(node *corev1.Node, now time.Time) bool {
+	if k.rebootPauseTimeout == 0 {
+		return false
+	}
+
+	if nodePhase(node) != phasePaused {
+		delete(node.Annotations, constants.AnnotationRebootPausedTooLong)
+
+		return false
+	}
+
+	since, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationPhaseTransitionTime])
+	if err != nil {
+		return false
+	}
+
+	if now.Sub(since) <= k.rebootPauseTimeout ||
+		node.Annotations[constants.AnnotationRebootPausedTooLong] == constants.True {
+		return false
+	}
+
+	klog.Warningf("Node %q has needed a reboot with reboot paused for more than %v", node.Name, k.rebootPauseTimeout)
+
+	node.Annotations[constants.AnnotationRebootPausedTooLong] = constants.True
+
+	return true
+}
+
+// emitRebootPausedTooLongEvent emits an event about a given node having reboot paused for too long.
+func (k *Kontroller) emitRebootPausedTooLongEvent(node *corev1.Node) {
+	k.recorder.Eventf(node, corev1.EventTypeWarning, eventReasonRebootPausedTooLong,
+		"Node has needed a reboot with reboot paused for more than %v", k.rebootPauseTimeout)
+}
+
+// rebootsPaused checks if reboots of all nodes are paused using configured pause ConfigMap. If the check fails,
+// reboots are considered paused to be on the safe side.
+//
+// If pause ConfigMap is not configured or it does not exist, false is always returned.
+func (k *Kontroller) rebootsPaused(ctx context.Context) bool {
+	if k.pauseConfigMap == "" {
+		return false
+	}
+
+	configMap, err := k.kc.CoreV1().ConfigMaps(k.namespace).Get(ctx, k.pauseConfigMap, metav1.GetOptions{})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return false
+	case err != nil:
+		klog.Errorf("Failed checking if reboots are paused using ConfigMap %q, not approving new reboots: %v",
+			k.pauseConfigMap, err)
+
+		return true
+	}
+
+	if configMap.Data[pauseConfigMapKey] != constants.True {
+		return false
+	}
+
+	klog.Infof("Reboots are paused by ConfigMap %q, not approving new reboots", k.pauseConfigMap)
+
+	return true
+}
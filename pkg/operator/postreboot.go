@@ -0,0 +1,122 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const eventReasonPostRebootVerificationFailed = "PostRebootVerificationFailed"
+
+// postRebootVerificationPending checks if any node which finished rebooting has not stayed Ready for
+// configured post reboot ready period yet, or if verification of any node has failed, in which case
+// no new reboots should be scheduled nor approved.
+//
+// Nodes which stayed Ready for the whole period get their reboot finished time annotation removed.
+// Nodes which became not Ready within the period get marked as failing the verification, which halts
+// reboots of other nodes until the administrator removes the annotation.
+//
+// If post reboot ready period is not configured, false is always returned.
+func (k *Kontroller) postRebootVerificationPending(ctx context.Context) (bool, error) {
+	if k.postRebootReadyPeriod == 0 {
+		return false, nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return false, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	now := time.Now()
+	pending := false
+	verificationFailed := map[string]bool{}
+	nodeNames := []string{}
+
+	for _, node := range nodelist.Items {
+		if node.Annotations[constants.AnnotationPostRebootVerificationFailed] == constants.True {
+			klog.Warningf("Node %q has failed post reboot verification, not approving new reboots", node.Name)
+
+			pending = true
+
+			continue
+		}
+
+		finishedValue, ok := node.Annotations[constants.AnnotationRebootFinishedTime]
+		if !ok {
+			continue
+		}
+
+		finished, err := time.Parse(time.RFC3339, finishedValue)
+
+		switch {
+		case err != nil:
+			klog.Warningf("Node %q has malformed reboot finished time %q, skipping post reboot verification",
+				node.Name, finishedValue)
+		case !readySince(node, finished):
+			verificationFailed[node.Name] = true
+			pending = true
+		case now.Sub(finished) < k.postRebootReadyPeriod:
+			klog.Infof("Waiting for node %q to stay Ready for %v after reboot", node.Name, k.postRebootReadyPeriod)
+
+			pending = true
+
+			continue
+		default:
+			klog.Infof("Node %q stayed Ready for %v after reboot", node.Name, k.postRebootReadyPeriod)
+		}
+
+		nodeNames = append(nodeNames, node.Name)
+	}
+
+	err = k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		return k.finishPostRebootVerification(ctx, nodeName, verificationFailed[nodeName])
+	})
+
+	return pending, err
+}
+
+// finishPostRebootVerification removes reboot finished time annotation from a given node and if
+// the verification has failed, marks the node as such and emits an event about it.
+func (k *Kontroller) finishPostRebootVerification(ctx context.Context, nodeName string, failed bool) error {
+	updatedNode := &corev1.Node{}
+
+	err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+		delete(node.Annotations, constants.AnnotationRebootFinishedTime)
+
+		if failed {
+			node.Annotations[constants.AnnotationPostRebootVerificationFailed] = constants.True
+		}
+
+		updatedNode = node
+	})
+	if err != nil {
+		return fmt.Errorf("finishing post reboot verification of node %q: %w", nodeName, err)
+	}
+
+	if failed {
+		k.recorder.Eventf(updatedNode, corev1.EventTypeWarning, eventReasonPostRebootVerificationFailed,
+			"Node has not stayed Ready for %v after reboot, halting reboots of other nodes until annotation %q "+
+				"is removed", k.postRebootReadyPeriod, constants.AnnotationPostRebootVerificationFailed)
+	}
+
+	return nil
+}
+
+// readySince checks if given node has been continuously Ready since given time.
+func readySince(node corev1.Node, since time.Time) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+
+		return condition.Status == corev1.ConditionTrue && !condition.LastTransitionTime.Time.After(since)
+	}
+
+	return false
+}
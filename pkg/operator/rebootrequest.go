@@ -0,0 +1,126 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+// rebootRequestSourceNode is a value of reboot-requested-by annotation when reboot has been requested
+// using reboot request annotation on the node itself.
+const rebootRequestSourceNode = "node"
+
+// rebootRequestingPods returns pods which request a reboot of the node they run on using configured
+// reboot request annotation, by node name.
+//
+// If reboot request annotation is not configured, pods are not listed.
+func (k *Kontroller) rebootRequestingPods(ctx context.Context) (map[string][]corev1.Pod, error) {
+	if k.rebootRequestAnnotation == "" {
+		return nil, nil
+	}
+
+	podList, err := k.kc.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	pods := map[string][]corev1.Pod{}
+
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName != "" && pod.Annotations[k.rebootRequestAnnotation] == constants.True {
+			pods[pod.Spec.NodeName] = append(pods[pod.Spec.NodeName], pod)
+		}
+	}
+
+	return pods, nil
+}
+
+// updateRebootRequestState requests a reboot of a given node if it is requested using configured reboot
+// request annotation, either on the node itself or on any of given pods running on it, and tracks
+// the requested reboot until it completes.
+//
+// Like maintenance reboot, requested reboot is carried out by setting reboot-needed annotation, so
+// the node goes through regular reboot process. Reboot request annotation set on the node takes
+// precedence over pods: when set to "true", pods are not considered and when set to "false",
+// requests from pods are ignored. Request annotation on the node is removed once the reboot completes.
+// Pods created before the last requested reboot has completed are ignored, so pods surviving the reboot,
+// e.g. not drained DaemonSet pods, do not request another one.
+func (k *Kontroller) updateRebootRequestState(node *corev1.Node, pods []corev1.Pod, now time.Time) {
+	if k.rebootRequestAnnotation == "" {
+		return
+	}
+
+	if requestedBy, ok := node.Annotations[constants.AnnotationRebootRequestedBy]; ok {
+		if rebootCompleted(node) {
+			klog.Infof("Reboot of node %q requested by %q has completed", node.Name, requestedBy)
+
+			delete(node.Annotations, constants.AnnotationRebootRequestedBy)
+			node.Annotations[constants.AnnotationRebootRequestCompletedTime] = now.UTC().Format(time.RFC3339)
+
+			if requestedBy == rebootRequestSourceNode {
+				delete(node.Annotations, k.rebootRequestAnnotation)
+			}
+
+			return
+		}
+
+		keepRequestingReboot(node)
+
+		return
+	}
+
+	requestedBy := k.rebootRequestSource(node, pods)
+	if requestedBy == "" {
+		return
+	}
+
+	klog.Infof("Reboot of node %q has been requested by %q", node.Name, requestedBy)
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+
+	node.Annotations[constants.AnnotationRebootRequestedBy] = requestedBy
+
+	// Node which is already rebooting will have requested reboot completed once it reboots.
+	keepRequestingReboot(node)
+}
+
+// rebootRequestSource returns what requests a reboot of a given node running given pods. Empty string is
+// returned if reboot is not requested.
+func (k *Kontroller) rebootRequestSource(node *corev1.Node, pods []corev1.Pod) string {
+	switch node.Annotations[k.rebootRequestAnnotation] {
+	case constants.True:
+		return rebootRequestSourceNode
+	case constants.False:
+		return ""
+	}
+
+	completed, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationRebootRequestCompletedTime])
+	if err != nil {
+		completed = time.Time{}
+	}
+
+	sources := []string{}
+
+	for _, pod := range pods {
+		if pod.CreationTimestamp.Time.After(completed) {
+			sources = append(sources, "pod/"+pod.Namespace+"/"+pod.Name)
+		}
+	}
+
+	if len(sources) == 0 {
+		return ""
+	}
+
+	sort.Strings(sources)
+
+	return sources[0]
+}
@@ -0,0 +1,122 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const (
+	eventReasonRebootFailed = "RebootFailed"
+
+	updatedNeedRebootStatus = "UPDATE_STATUS_UPDATED_NEED_REBOOT"
+)
+
+// recordVersionBeforeReboot records the version a given node runs, if the node is going to reboot into an
+// update, so it can be checked after the reboot whether the update has been applied. The node is considered
+// as rebooting into an update if update_engine reports an update waiting for a reboot or a version newer
+// than the current one.
+//
+// Reboots not caused by updates, e.g. maintenance reboots or reboots requested by pods, are not recorded,
+// as the node is not expected to run a different version after them.
+func recordVersionBeforeReboot(node *corev1.Node) {
+	currentVersion, newVersion := node.Labels[constants.LabelVersion], node.Annotations[constants.AnnotationNewVersion]
+
+	updatePending := node.Annotations[constants.AnnotationStatus] == updatedNeedRebootStatus ||
+		newerVersion(currentVersion, newVersion)
+
+	if currentVersion == "" || newVersion == "" || newVersion == currentVersion || !updatePending {
+		delete(node.Annotations, constants.AnnotationVersionBeforeReboot)
+
+		return
+	}
+
+	node.Annotations[constants.AnnotationVersionBeforeReboot] = currentVersion
+}
+
+// checkRebootedVersion checks if a given node, which has just rebooted, runs a newer version than the version
+// recorded before the reboot. If it does not, the node is marked with reboot-failed annotation and an event
+// about it is emitted.
+//
+// If no version has been recorded before the reboot, the check is skipped.
+func (k *Kontroller) checkRebootedVersion(ctx context.Context, nodeName string) error {
+	updatedNode := &corev1.Node{}
+	versionBeforeReboot := ""
+	failed := false
+
+	err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+		version, ok := node.Annotations[constants.AnnotationVersionBeforeReboot]
+		if !ok {
+			return
+		}
+
+		versionBeforeReboot = version
+
+		delete(node.Annotations, constants.AnnotationVersionBeforeReboot)
+
+		failed = !versionAdvanced(versionBeforeReboot, node.Labels[constants.LabelVersion])
+		if !failed {
+			delete(node.Annotations, constants.AnnotationRebootFailed)
+
+			return
+		}
+
+		node.Annotations[constants.AnnotationRebootFailed] = constants.True
+
+		updatedNode = node
+	})
+	if err != nil {
+		return fmt.Errorf("checking version of rebooted node %q: %w", nodeName, err)
+	}
+
+	if !failed {
+		return nil
+	}
+
+	currentVersion := updatedNode.Labels[constants.LabelVersion]
+
+	klog.Warningf("Node %q has rebooted, but it does not run a newer version than %q, got %q",
+		nodeName, versionBeforeReboot, currentVersion)
+
+	k.recorder.Eventf(updatedNode, corev1.EventTypeWarning, eventReasonRebootFailed,
+		"Node has rebooted, but it still runs version %q, update has not been applied", currentVersion)
+
+	return nil
+}
+
+// versionAdvanced checks if given current version is newer than given previous version. Versions which
+// are not valid semver are only compared for equality.
+func versionAdvanced(previousVersion, currentVersion string) bool {
+	previous, err := semver.ParseTolerant(previousVersion)
+	if err != nil {
+		return currentVersion != previousVersion
+	}
+
+	current, err := semver.ParseTolerant(currentVersion)
+	if err != nil {
+		return currentVersion != previousVersion
+	}
+
+	return current.GT(previous)
+}
+
+// newerVersion checks if given new version is a valid semver newer than given current version.
+func newerVersion(currentVersion, newVersion string) bool {
+	current, err := semver.ParseTolerant(currentVersion)
+	if err != nil {
+		return false
+	}
+
+	updated, err := semver.ParseTolerant(newVersion)
+	if err != nil {
+		return false
+	}
+
+	return updated.GT(current)
+}
@@ -0,0 +1,183 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/k8sutil"
+)
+
+const eventReasonStaleRebootInProgressCleared = "StaleRebootInProgressCleared"
+
+// staleAnnotations are annotations reflecting update_engine status, which are pruned from
+// idle nodes when stale annotations timeout is configured.
+var staleAnnotations = []string{
+	constants.AnnotationStatus,
+	constants.AnnotationLastCheckedTime,
+	constants.AnnotationNewVersion,
+	constants.AnnotationLastUpdateAttemptError,
+}
+
+// pruneStaleAnnotations removes update_engine status annotations from nodes, which are not
+// in the process of rebooting and which have not reported an update check within configured
+// stale annotations timeout.
+//
+// If stale annotations timeout is not configured, nothing is done.
+func (k *Kontroller) pruneStaleAnnotations(ctx context.Context) error {
+	if k.staleAnnotationsTimeout == 0 {
+		return nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	now := time.Now()
+	nodeNames := []string{}
+
+	for _, node := range nodelist.Items {
+		if k.annotationsStale(node, now) {
+			nodeNames = append(nodeNames, node.Name)
+		}
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			// Node might have changed since it has been listed.
+			if !k.annotationsStale(*node, now) {
+				return
+			}
+
+			klog.Infof("Pruning stale annotations from idle node %q", node.Name)
+
+			for _, annotation := range staleAnnotations {
+				delete(node.Annotations, annotation)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("pruning stale annotations from node %q: %w", nodeName, err)
+		}
+
+		return nil
+	})
+}
+
+// annotationsStale checks if given node is idle and its update_engine status annotations have
+// not been updated within configured stale annotations timeout at a given time.
+//
+// Nodes needing a reboot or in the process of rebooting are never considered idle. Nodes with
+// missing or invalid last checked time are skipped, as staleness cannot be determined.
+func (k *Kontroller) annotationsStale(node corev1.Node, now time.Time) bool {
+	if node.Annotations[constants.AnnotationRebootNeeded] == constants.True ||
+		node.Annotations[constants.AnnotationOkToReboot] == constants.True ||
+		node.Annotations[constants.AnnotationRebootInProgress] == constants.True {
+		return false
+	}
+
+	if _, ok := node.Labels[constants.LabelBeforeReboot]; ok {
+		return false
+	}
+
+	if _, ok := node.Labels[constants.LabelAfterReboot]; ok {
+		return false
+	}
+
+	lastChecked, err := strconv.ParseInt(node.Annotations[constants.AnnotationLastCheckedTime], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(time.Unix(lastChecked, 0)) > k.staleAnnotationsTimeout
+}
+
+// clearStaleRebootInProgress clears reboot-in-progress annotation, withdraws reboot approval and uncordons
+// nodes cordoned by the operator, which agent has not reported a heartbeat within configured stale reboot in progress timeout,
+// e.g. because agent pod has been killed in the middle of rebooting. Otherwise, such nodes would be
+// considered rebooting forever. An event is emitted for each such node.
+//
+// If stale reboot in progress timeout is not configured, nothing is done.
+func (k *Kontroller) clearStaleRebootInProgress(ctx context.Context) error {
+	if k.staleRebootInProgressTimeout == 0 {
+		return nil
+	}
+
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+
+	now := time.Now()
+	nodeNames := []string{}
+
+	for _, node := range nodelist.Items {
+		if k.rebootInProgressStale(node, now) {
+			nodeNames = append(nodeNames, node.Name)
+		}
+	}
+
+	return k.forEachNode(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		var updatedNode *corev1.Node
+
+		err := k8sutil.UpdateNodeRetry(ctx, k.nc, nodeName, func(node *corev1.Node) {
+			// Agent might have reported a heartbeat since node has been listed.
+			if !k.rebootInProgressStale(*node, now) {
+				updatedNode = nil
+
+				return
+			}
+
+			klog.Warningf("Agent on node %q has not reported a heartbeat within %v while rebooting, "+
+				"clearing reboot-in-progress annotation", node.Name, k.staleRebootInProgressTimeout)
+
+			node.Annotations[constants.AnnotationRebootInProgress] = constants.False
+			node.Annotations[constants.AnnotationOkToReboot] = constants.False
+
+			// Agent does not uncordon nodes cordoned by the operator, so make sure they do
+			// not stay cordoned forever.
+			makeSchedulable(node)
+
+			updatedNode = node
+		})
+		if err != nil {
+			return fmt.Errorf("clearing stale reboot-in-progress annotation of node %q: %w", nodeName, err)
+		}
+
+		if updatedNode != nil {
+			k.recorder.Eventf(updatedNode, corev1.EventTypeWarning, eventReasonStaleRebootInProgressCleared,
+				"Agent has not reported a heartbeat within %v while rebooting, cleared reboot-in-progress "+
+					"annotation and withdrew reboot approval", k.staleRebootInProgressTimeout)
+		}
+
+		return nil
+	})
+}
+
+// rebootInProgressStale checks if given node reports reboot in progress, but its agent has not
+// reported a heartbeat within configured stale reboot in progress timeout at a given time.
+//
+// Agent heartbeat annotation is used if present, otherwise last checked time annotation. Nodes
+// with neither of them valid are skipped, as staleness cannot be determined.
+func (k *Kontroller) rebootInProgressStale(node corev1.Node, now time.Time) bool {
+	if node.Annotations[constants.AnnotationRebootInProgress] != constants.True {
+		return false
+	}
+
+	lastSeen, err := time.Parse(time.RFC3339, node.Annotations[constants.AnnotationAgentHeartbeat])
+	if err != nil {
+		lastChecked, err := strconv.ParseInt(node.Annotations[constants.AnnotationLastCheckedTime], 10, 64)
+		if err != nil {
+			return false
+		}
+
+		lastSeen = time.Unix(lastChecked, 0)
+	}
+
+	return now.Sub(lastSeen) > k.staleRebootInProgressTimeout
+}
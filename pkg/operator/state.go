@@ -0,0 +1,139 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Key in state ConfigMap holding JSON-encoded operator state.
+const stateConfigMapKey = "state"
+
+// operatorState is the scheduling state operator keeps in memory between reconciliations, which is not
+// stored in node labels and annotations. It is persisted in state ConfigMap, so it survives restarts
+// and leader failovers. Features keeping such state add it here, so it is persisted as well.
+type operatorState struct {
+	// Names of nodes which an event about not enough Ready nodes has been emitted for during the
+	// current shortage of Ready nodes.
+	NotReadyNodesReported []string `json:"notReadyNodesReported,omitempty"`
+}
+
+// currentState returns current in-memory scheduling state.
+func (k *Kontroller) currentState() operatorState {
+	state := operatorState{}
+
+	for nodeName := range k.notReadyNodesReported {
+		state.NotReadyNodesReported = append(state.NotReadyNodesReported, nodeName)
+	}
+
+	// Keep encoded state stable, so ConfigMap is only updated when state changes.
+	sort.Strings(state.NotReadyNodesReported)
+
+	return state
+}
+
+// restoreState replaces in-memory scheduling state with a given one.
+func (k *Kontroller) restoreState(state operatorState) {
+	k.notReadyNodesReported = map[string]struct{}{}
+
+	for _, nodeName := range state.NotReadyNodesReported {
+		k.notReadyNodesReported[nodeName] = struct{}{}
+	}
+}
+
+// loadState restores in-memory scheduling state from configured state ConfigMap, e.g. persisted by the
+// previous leader. Missing ConfigMap is not an error, as it is created when state is saved for the first time.
+//
+// If state ConfigMap is not configured, nothing is done.
+func (k *Kontroller) loadState(ctx context.Context) error {
+	if k.stateConfigMap == "" {
+		return nil
+	}
+
+	configMap, err := k.kc.CoreV1().ConfigMaps(k.namespace).Get(ctx, k.stateConfigMap, metav1.GetOptions{})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		klog.Infof("State ConfigMap %q not found, starting with empty state", k.stateConfigMap)
+
+		return nil
+	case err != nil:
+		return fmt.Errorf("getting ConfigMap %q: %w", k.stateConfigMap, err)
+	}
+
+	state := operatorState{}
+
+	if data := configMap.Data[stateConfigMapKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return fmt.Errorf("decoding state from ConfigMap %q: %w", k.stateConfigMap, err)
+		}
+	}
+
+	klog.Infof("Loaded state from ConfigMap %q", k.stateConfigMap)
+
+	k.restoreState(state)
+
+	return nil
+}
+
+// saveState persists in-memory scheduling state to configured state ConfigMap, creating it if it does not
+// exist yet. ConfigMap is not updated if persisted state has not changed.
+//
+// If state ConfigMap is not configured, nothing is done.
+func (k *Kontroller) saveState(ctx context.Context) error {
+	if k.stateConfigMap == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(k.currentState())
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	configMaps := k.kc.CoreV1().ConfigMaps(k.namespace)
+
+	configMap, err := configMaps.Get(ctx, k.stateConfigMap, metav1.GetOptions{})
+
+	switch {
+	case apierrors.IsNotFound(err):
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      k.stateConfigMap,
+				Namespace: k.namespace,
+			},
+			Data: map[string]string{
+				stateConfigMapKey: string(data),
+			},
+		}
+
+		if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating ConfigMap %q: %w", k.stateConfigMap, err)
+		}
+
+		return nil
+	case err != nil:
+		return fmt.Errorf("getting ConfigMap %q: %w", k.stateConfigMap, err)
+	}
+
+	if configMap.Data[stateConfigMapKey] == string(data) {
+		return nil
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+
+	configMap.Data[stateConfigMapKey] = string(data)
+
+	if _, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating ConfigMap %q: %w", k.stateConfigMap, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+// SupportBundle describes the state of the update process in the cluster, to be attached to bug reports.
+type SupportBundle struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Version and effective values of all flags of the operator binary. Not set by the operator itself.
+	Version string            `json:"version,omitempty"`
+	Flags   map[string]string `json:"flags,omitempty"`
+	// Current leader election record. Nil if no operator has become a leader yet.
+	Leader *resourcelock.LeaderElectionRecord `json:"leader,omitempty"`
+	Nodes  []SupportBundleNode                `json:"nodes"`
+	// Events about nodes and leader election, sorted from the oldest.
+	Events []SupportBundleEvent `json:"events"`
+}
+
+// SupportBundleNode describes the update state of a single node.
+type SupportBundleNode struct {
+	Name          string `json:"name"`
+	Unschedulable bool   `json:"unschedulable"`
+	// Only labels and annotations used by the operator and the agent are included.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SupportBundleEvent describes a single event.
+type SupportBundleEvent struct {
+	Namespace     string    `json:"namespace"`
+	Object        string    `json:"object"`
+	Type          string    `json:"type"`
+	Reason        string    `json:"reason"`
+	Message       string    `json:"message"`
+	Count         int32     `json:"count"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+}
+
+// SupportBundle gathers the state of the update process in the cluster using configured client.
+func (k *Kontroller) SupportBundle(ctx context.Context) (*SupportBundle, error) {
+	nodelist, err := k.listNodes(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	events, err := k.supportBundleEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gathering events: %w", err)
+	}
+
+	leader, _, err := k.resourceLock.Get(ctx)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		leader = nil
+	case err != nil:
+		return nil, fmt.Errorf("getting leader election record: %w", err)
+	}
+
+	nodes := make([]SupportBundleNode, 0, len(nodelist.Items))
+	for _, node := range nodelist.Items {
+		nodes = append(nodes, SupportBundleNode{
+			Name:          node.Name,
+			Unschedulable: node.Spec.Unschedulable,
+			Labels:        withPrefix(node.Labels, constants.Prefix),
+			Annotations:   withPrefix(node.Annotations, constants.Prefix),
+		})
+	}
+
+	return &SupportBundle{
+		GeneratedAt: time.Now().UTC(),
+		Leader:      leader,
+		Nodes:       nodes,
+		Events:      events,
+	}, nil
+}
+
+// supportBundleEvents returns events about nodes from all namespaces and leader election events
+// from the event namespace.
+func (k *Kontroller) supportBundleEvents(ctx context.Context) ([]SupportBundleEvent, error) {
+	nodeEvents, err := k.kc.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.kind", "Node").String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing node events: %w", err)
+	}
+
+	leaderElectionEvents, err := k.kc.CoreV1().Events(k.eventNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing events in namespace %q: %w", k.eventNamespace, err)
+	}
+
+	events := []SupportBundleEvent{}
+	seen := map[string]struct{}{}
+
+	for _, event := range append(nodeEvents.Items, leaderElectionEvents.Items...) {
+		key := event.Namespace + "/" + event.Name
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+
+		events = append(events, SupportBundleEvent{
+			Namespace:     event.Namespace,
+			Object:        event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+			Type:          event.Type,
+			Reason:        event.Reason,
+			Message:       event.Message,
+			Count:         event.Count,
+			LastTimestamp: eventTime(event),
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(events[j].LastTimestamp)
+	})
+
+	return events, nil
+}
+
+// eventTime returns when given event has occurred last, falling back to the event time used
+// by newer event recorders.
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.UTC()
+	}
+
+	return event.EventTime.UTC()
+}
+
+// withPrefix returns entries from given map which keys start with a given prefix.
+func withPrefix(values map[string]string, prefix string) map[string]string {
+	filtered := map[string]string{}
+
+	for key, value := range values {
+		if strings.HasPrefix(key, prefix) {
+			filtered[key] = value
+		}
+	}
+
+	return filtered
+}
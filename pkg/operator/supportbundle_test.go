@@ -0,0 +1,127 @@
+package operator_test
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/constants"
+)
+
+//nolint:funlen // Just many subtests.
+func Test_Operator_support_bundle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("includes_only_labels_and_annotations_of_nodes_used_for_updating", func(t *testing.T) {
+		t.Parallel()
+
+		rebootableNode := rebootableNode()
+		rebootableNode.Labels["foo"] = "bar"
+		rebootableNode.Annotations["baz"] = "qux"
+		rebootableNode.Spec.Unschedulable = true
+
+		config, _ := testConfig(rebootableNode)
+
+		bundle, err := kontrollerWithObjects(t, config).SupportBundle(contextWithDeadline(t))
+		if err != nil {
+			t.Fatalf("Unexpected error gathering support bundle: %v", err)
+		}
+
+		if len(bundle.Nodes) != 1 {
+			t.Fatalf("Expected exactly one node, got %d", len(bundle.Nodes))
+		}
+
+		node := bundle.Nodes[0]
+
+		if node.Name != rebootableNode.Name || !node.Unschedulable {
+			t.Fatalf("Unexpected node %+v", node)
+		}
+
+		if node.Labels[constants.LabelRebootNeeded] != constants.True {
+			t.Fatalf("Expected label %q to be included, got %v", constants.LabelRebootNeeded, node.Labels)
+		}
+
+		if node.Annotations[constants.AnnotationRebootNeeded] != constants.True {
+			t.Fatalf("Expected annotation %q to be included, got %v", constants.AnnotationRebootNeeded, node.Annotations)
+		}
+
+		if _, ok := node.Labels["foo"]; ok {
+			t.Fatalf("Unexpected unrelated label included")
+		}
+
+		if _, ok := node.Annotations["baz"]; ok {
+			t.Fatalf("Unexpected unrelated annotation included")
+		}
+	})
+
+	t.Run("includes_events_about_nodes", func(t *testing.T) {
+		t.Parallel()
+
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: metav1.NamespaceDefault,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Node",
+				Name: "rebootable",
+			},
+			Type:          corev1.EventTypeWarning,
+			Reason:        "RebootBlockedByNodeConditions",
+			Message:       "Reboot blocked",
+			Count:         2,
+			LastTimestamp: metav1.NewTime(time.Now()),
+		}
+
+		config, _ := testConfig(rebootableNode(), event)
+
+		bundle, err := kontrollerWithObjects(t, config).SupportBundle(contextWithDeadline(t))
+		if err != nil {
+			t.Fatalf("Unexpected error gathering support bundle: %v", err)
+		}
+
+		if len(bundle.Events) != 1 {
+			t.Fatalf("Expected exactly one event, got %+v", bundle.Events)
+		}
+
+		if e := bundle.Events[0]; e.Object != "Node/rebootable" || e.Reason != event.Reason || e.Count != event.Count {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	})
+
+	t.Run("includes_leader_election_record", func(t *testing.T) {
+		t.Parallel()
+
+		config, fakeClient := testConfig()
+
+		ctx := contextWithDeadline(t)
+
+		<-process(ctx, t, config, fakeClient)
+
+		bundle, err := kontrollerWithObjects(t, config).SupportBundle(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error gathering support bundle: %v", err)
+		}
+
+		if bundle.Leader == nil || bundle.Leader.HolderIdentity != config.LockID {
+			t.Fatalf("Expected leader %q, got %+v", config.LockID, bundle.Leader)
+		}
+	})
+
+	t.Run("does_not_include_leader_election_record_when_there_is_no_leader", func(t *testing.T) {
+		t.Parallel()
+
+		config, _ := testConfig()
+
+		bundle, err := kontrollerWithObjects(t, config).SupportBundle(contextWithDeadline(t))
+		if err != nil {
+			t.Fatalf("Unexpected error gathering support bundle: %v", err)
+		}
+
+		if bundle.Leader != nil {
+			t.Fatalf("Expected no leader, got %+v", bundle.Leader)
+		}
+	})
+}
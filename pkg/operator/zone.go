@@ -0,0 +1,55 @@
+package operator
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const eventReasonRebootDeferredByZoneLimit = "RebootDeferredByZoneLimit"
+
+// limitPerZone returns nodes from given list of nodes requiring a reboot, which can be rebooted without exceeding
+// configured maximum number of rebooting nodes per zone, keeping their order. Nodes from given node list which
+// are in the process of rebooting count toward the limit of their zone. Nodes without the zone label are
+// considered to be in the same zone.
+//
+// An event is emitted for each deferred node, which would otherwise fit within given remaining capacity.
+//
+// If maximum number of rebooting nodes per zone is not configured, given nodes are returned unchanged.
+func (k *Kontroller) limitPerZone(nodelist *corev1.NodeList, nodes []corev1.Node, capacity int) []corev1.Node {
+	if k.maxRebootingNodesPerZone == 0 {
+		return nodes
+	}
+
+	rebootingPerZone := map[string]int{}
+	for _, node := range rebootingNodes(nodelist) {
+		rebootingPerZone[node.Labels[k.zoneLabel]]++
+	}
+
+	allowed := []corev1.Node{}
+
+	for i := range nodes {
+		zone := nodes[i].Labels[k.zoneLabel]
+
+		if rebootingPerZone[zone] < k.maxRebootingNodesPerZone {
+			rebootingPerZone[zone]++
+
+			allowed = append(allowed, nodes[i])
+
+			continue
+		}
+
+		// Nodes which would not be chosen because of remaining capacity anyway are not reported.
+		if len(allowed) >= capacity {
+			continue
+		}
+
+		klog.Infof("Found %d (of max %d) rebooting nodes in zone %q; deferring reboot of node %q",
+			rebootingPerZone[zone], k.maxRebootingNodesPerZone, zone, nodes[i].Name)
+
+		k.recorder.Eventf(&nodes[i], corev1.EventTypeNormal, eventReasonRebootDeferredByZoneLimit,
+			"Reboot deferred, %d (of max %d) nodes are rebooting in zone %q",
+			rebootingPerZone[zone], k.maxRebootingNodesPerZone, zone)
+	}
+
+	return allowed
+}
@@ -15,9 +15,13 @@
 package updateengine
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	godbus "github.com/godbus/dbus/v5"
+	"k8s.io/klog/v2"
 
 	"github.com/flatcar/flatcar-linux-update-operator/pkg/dbus"
 )
@@ -33,16 +37,33 @@ const (
 	DBusSignalNameStatusUpdate = "StatusUpdate"
 	// DBusMethodNameGetStatus is a name of the method to get current update_engine status.
 	DBusMethodNameGetStatus = "GetStatus"
+	// DBusMethodNameGetLastAttemptError is a name of the method to get error code of the last update attempt.
+	DBusMethodNameGetLastAttemptError = "GetLastAttemptError"
+	// DBusMethodNameAttemptUpdate is a name of the method to trigger an update check.
+	DBusMethodNameAttemptUpdate = "AttemptUpdate"
 
 	signalBuffer = 32 // TODO(bp): What is a reasonable value here?
+
+	initialReconnectBackoff = time.Second
+	maxReconnectBackoff     = 30 * time.Second
 )
 
 // Client allows reading update_engine status using D-Bus.
 type Client interface {
 	// ReceiveStatuses listens for D-Bus signals coming from update_engine and converts them to Statuses
 	// emitted into a given channel. It returns when stop channel gets closed or when the value is sent to it.
+	//
+	// If the D-Bus connection gets closed, e.g. when system bus restarts, it reconnects with exponential
+	// backoff and emits the current status once reconnected, unless getting it fails.
 	ReceiveStatuses(rcvr chan<- Status, stop <-chan struct{})
 
+	// LastAttemptError returns error code of the last update attempt reported by update_engine.
+	LastAttemptError() (int32, error)
+
+	// AttemptUpdate asks update_engine to check for an update now, instead of waiting for the periodic check.
+	// Progress of the update is reported through status updates.
+	AttemptUpdate(ctx context.Context) error
+
 	// Close closes underlying connection to the DBus broker. It is up to the user to close the connection
 	// and avoid leaking it.
 	//
@@ -60,64 +81,177 @@ type DBusConnection interface {
 
 type caller interface {
 	Call(method string, flags godbus.Flags, args ...interface{}) *godbus.Call
+	CallWithContext(ctx context.Context, method string, flags godbus.Flags, args ...interface{}) *godbus.Call
 }
 
 type client struct {
+	connector   dbus.Connector
+	authMethods []godbus.Auth
+
+	path        string
+	destination string
+	iface       string
+
+	// Guards connection and object, as they are replaced when reconnecting.
+	mu     sync.Mutex
 	conn   DBusConnection
 	object caller
 	ch     chan *godbus.Signal
 }
 
-// New creates new instance of Client and initializes it.
-func New(connector dbus.Connector) (Client, error) {
-	conn, err := dbus.New(connector)
+// Config allows overriding D-Bus names under which update_engine is exposed, e.g. by derivatives using
+// a different bus name. Empty fields default to DBusPath, DBusDestination and DBusInterface respectively.
+type Config struct {
+	Path        string
+	Destination string
+	Interface   string
+}
+
+// New creates new instance of Client and initializes it. Given authentication methods are passed
+// to dbus.New.
+func New(connector dbus.Connector, authMethods ...godbus.Auth) (Client, error) {
+	return NewWithConfig(connector, Config{}, authMethods...)
+}
+
+// NewWithConfig creates new instance of Client using D-Bus names from a given config and initializes it.
+// Given authentication methods are passed to dbus.New.
+func NewWithConfig(connector dbus.Connector, config Config, authMethods ...godbus.Auth) (Client, error) {
+	c := &client{
+		connector:   connector,
+		authMethods: authMethods,
+		path:        valueOrDefault(config.Path, DBusPath),
+		destination: valueOrDefault(config.Destination, DBusDestination),
+		iface:       valueOrDefault(config.Interface, DBusInterface),
+	}
+
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// connect creates new D-Bus connection and subscribes to status update signals using it.
+func (c *client) connect() error {
+	conn, err := dbus.New(c.connector, c.authMethods...)
 	if err != nil {
-		return nil, fmt.Errorf("creating D-Bus client: %w", err)
+		return fmt.Errorf("creating D-Bus client: %w", err)
 	}
 
 	matchOptions := []godbus.MatchOption{
-		godbus.WithMatchInterface(DBusInterface),
+		godbus.WithMatchInterface(c.iface),
 		godbus.WithMatchMember(DBusSignalNameStatusUpdate),
 	}
 
 	if err := conn.AddMatchSignal(matchOptions...); err != nil {
-		return nil, fmt.Errorf("adding filter: %w", err)
+		// Best effort closing the connection.
+		//
+		//nolint:errcheck // Adding filter error is more relevant.
+		_ = conn.Close()
+
+		return fmt.Errorf("adding filter: %w", err)
 	}
 
 	ch := make(chan *godbus.Signal, signalBuffer)
 	conn.Signal(ch)
 
-	return &client{
-		ch:     ch,
-		conn:   conn,
-		object: conn.Object(DBusDestination, godbus.ObjectPath(DBusPath)),
-	}, nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ch = ch
+	c.conn = conn
+	c.object = conn.Object(c.destination, godbus.ObjectPath(c.path))
+
+	return nil
 }
 
 // ReceiveStatuses receives signal messages from dbus and sends them as Statues
 // on the rcvr channel, until the stop channel is closed. An attempt is made to
 // get the initial status and send it on the rcvr channel before receiving
-// starts.
+// starts and after reconnecting. If the attempt fails, no status is sent.
 func (c *client) ReceiveStatuses(rcvr chan<- Status, stop <-chan struct{}) {
-	// If there is an error getting the current status, ignore it and just
-	// move onto the main loop.
-	//
-	//nolint:errcheck // TODO: This will be fixed once we introduce error handling to receiving statuses.
-	st, _ := c.getStatus()
-	rcvr <- st
+	c.emitCurrentStatus(rcvr)
 
 	for {
 		select {
 		case <-stop:
 			return
-		case signal := <-c.ch:
-			rcvr <- NewStatus(signal.Body)
+		case signal, ok := <-c.ch:
+			if ok {
+				status, err := NewStatus(signal.Body)
+				if err != nil {
+					klog.Warningf("Ignoring malformed status signal from update_engine: %v", err)
+
+					continue
+				}
+
+				rcvr <- status
+
+				continue
+			}
+
+			klog.Warning("D-Bus signal channel has been closed, reconnecting to update_engine")
+
+			if !c.reconnect(stop) {
+				return
+			}
+
+			c.emitCurrentStatus(rcvr)
+		}
+	}
+}
+
+// emitCurrentStatus sends current status to given channel. If there is an error getting the current status,
+// it is logged and nothing is sent, as an empty status would overwrite the last known one. Status updates
+// received later are emitted as usual.
+func (c *client) emitCurrentStatus(rcvr chan<- Status) {
+	st, err := c.getStatus()
+	if err != nil {
+		klog.Warningf("Failed getting current update_engine status: %v", err)
+
+		return
+	}
+
+	rcvr <- st
+}
+
+// reconnect replaces closed D-Bus connection with a new one, retrying with exponential backoff until
+// it succeeds or until the stop channel is closed. It returns false if stopped before reconnecting.
+func (c *client) reconnect(stop <-chan struct{}) bool {
+	// Best effort closing the old connection.
+	//
+	//nolint:errcheck // Connection is already unusable.
+	_ = c.Close()
+
+	backoff := initialReconnectBackoff
+
+	for {
+		err := c.connect()
+		if err == nil {
+			klog.Info("Reconnected to update_engine")
+
+			return true
+		}
+
+		klog.Warningf("Failed reconnecting to update_engine, retrying in %v: %v", backoff, err)
+
+		select {
+		case <-stop:
+			return false
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
 		}
 	}
 }
 
 // Close closes internal D-Bus connection.
 func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.conn != nil {
 		return c.conn.Close()
 	}
@@ -125,12 +259,60 @@ func (c *client) Close() error {
 	return nil
 }
 
+// currentObject returns update_engine object of the current connection.
+func (c *client) currentObject() caller {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.object
+}
+
+// LastAttemptError gets error code of the last update attempt from update_engine.
+func (c *client) LastAttemptError() (int32, error) {
+	call := c.currentObject().Call(c.iface+"."+DBusMethodNameGetLastAttemptError, 0)
+	if call.Err != nil {
+		return 0, fmt.Errorf("calling %q method: %w", DBusMethodNameGetLastAttemptError, call.Err)
+	}
+
+	var code int32
+
+	if err := call.Store(&code); err != nil {
+		return 0, fmt.Errorf("decoding %q method response: %w", DBusMethodNameGetLastAttemptError, err)
+	}
+
+	return code, nil
+}
+
+// AttemptUpdate triggers an update check in update_engine.
+func (c *client) AttemptUpdate(ctx context.Context) error {
+	call := c.currentObject().CallWithContext(ctx, c.iface+"."+DBusMethodNameAttemptUpdate, 0)
+	if call.Err != nil {
+		return fmt.Errorf("calling %q method: %w", DBusMethodNameAttemptUpdate, call.Err)
+	}
+
+	return nil
+}
+
 // getStatus gets the current status from update_engine.
 func (c *client) getStatus() (Status, error) {
-	call := c.object.Call(DBusInterface+"."+DBusMethodNameGetStatus, 0)
+	call := c.currentObject().Call(c.iface+"."+DBusMethodNameGetStatus, 0)
 	if call.Err != nil {
 		return Status{}, call.Err
 	}
 
-	return NewStatus(call.Body), nil
+	status, err := NewStatus(call.Body)
+	if err != nil {
+		return Status{}, fmt.Errorf("parsing status: %w", err)
+	}
+
+	return status, nil
+}
+
+// valueOrDefault returns given value or given default value if the value is empty.
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
 }
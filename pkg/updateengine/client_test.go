@@ -1,6 +1,7 @@
 package updateengine_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -161,11 +162,9 @@ func Test_Receiving_status(t *testing.T) {
 		}
 	})
 
-	t.Run("returns_empty_status_when_getting_initial_status_fails", func(t *testing.T) {
+	t.Run("does_not_emit_status_when_getting_initial_status_fails", func(t *testing.T) {
 		t.Parallel()
 
-		expectedStatus := updateengine.Status{}
-
 		mockConnection := &dbus.MockConnection{
 			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
 				return &dbus.MockObject{
@@ -196,6 +195,56 @@ func Test_Receiving_status(t *testing.T) {
 
 		timeout := time.NewTimer(time.Second)
 
+		select {
+		case status := <-statusCh:
+			t.Fatalf("Unexpected status received when getting initial status fails: %v", status)
+		case <-timeout.C:
+		}
+	})
+
+	t.Run("skips_malformed_status_updates_received_from_update_engine", func(t *testing.T) {
+		t.Parallel()
+
+		expectedStatus := testStatus()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+						return &godbus.Call{
+							Body: []interface{}{"malformed"},
+						}
+					},
+				}
+			},
+			SignalF: func(ch chan<- *godbus.Signal) {
+				ch <- &godbus.Signal{
+					Body: []interface{}{"malformed"},
+				}
+				ch <- &godbus.Signal{
+					Body: statusToSignalBody(expectedStatus),
+				}
+			},
+		}
+
+		client, err := updateengine.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		stop := make(chan struct{})
+
+		t.Cleanup(func() {
+			close(stop)
+		})
+
+		statusCh := make(chan updateengine.Status, 1)
+
+		go client.ReceiveStatuses(statusCh, stop)
+
+		timeout := time.NewTimer(time.Second)
+
+		// Malformed initial status is not emitted, so the first received status is the well-formed signal.
 		select {
 		case status := <-statusCh:
 			if diff := cmp.Diff(expectedStatus, status); diff != "" {
@@ -207,6 +256,148 @@ func Test_Receiving_status(t *testing.T) {
 	})
 }
 
+func Test_Receiving_status_reconnects_to_D_Bus_and_emits_current_status_when_signal_channel_gets_closed(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	expectedStatus := testStatus()
+
+	connections := make(chan struct{}, 2)
+
+	connector := func() (dbus.Connection, error) {
+		connections <- struct{}{}
+
+		// First connection gets closed right away, like when system bus restarts.
+		reconnected := len(connections) > 1
+
+		return &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+						if !reconnected {
+							return &godbus.Call{Body: statusToSignalBody(updateengine.Status{})}
+						}
+
+						return &godbus.Call{Body: statusToSignalBody(expectedStatus)}
+					},
+				}
+			},
+			SignalF: func(ch chan<- *godbus.Signal) {
+				if !reconnected {
+					close(ch)
+				}
+			},
+		}, nil
+	}
+
+	client, err := updateengine.New(connector)
+	if err != nil {
+		t.Fatalf("Got unexpected error while creating client: %v", err)
+	}
+
+	stop := make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	statusCh := make(chan updateengine.Status, 1)
+
+	go client.ReceiveStatuses(statusCh, stop)
+
+	timeout := time.NewTimer(time.Second)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case status := <-statusCh:
+			if i == 0 {
+				continue
+			}
+
+			if diff := cmp.Diff(expectedStatus, status); diff != "" {
+				t.Fatalf("Unexpectected status values received after reconnecting:\n%s", diff)
+			}
+		case <-timeout.C:
+			t.Fatal("Failed getting status within expected timeframe")
+		}
+	}
+
+	if len(connections) != 2 {
+		t.Fatalf("Expected client to reconnect once, got %d connections", len(connections))
+	}
+}
+
+func Test_Receiving_status_does_not_emit_status_when_getting_current_status_fails_after_reconnecting(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	initialStatus := testStatus()
+
+	connections := make(chan struct{}, 2)
+
+	connector := func() (dbus.Connection, error) {
+		connections <- struct{}{}
+
+		// First connection gets closed right away, like when system bus restarts.
+		reconnected := len(connections) > 1
+
+		return &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+						if !reconnected {
+							return &godbus.Call{Body: statusToSignalBody(initialStatus)}
+						}
+
+						return &godbus.Call{Err: fmt.Errorf("some error")}
+					},
+				}
+			},
+			SignalF: func(ch chan<- *godbus.Signal) {
+				if !reconnected {
+					close(ch)
+				}
+			},
+		}, nil
+	}
+
+	client, err := updateengine.New(connector)
+	if err != nil {
+		t.Fatalf("Got unexpected error while creating client: %v", err)
+	}
+
+	stop := make(chan struct{})
+
+	t.Cleanup(func() {
+		close(stop)
+	})
+
+	statusCh := make(chan updateengine.Status, 2)
+
+	go client.ReceiveStatuses(statusCh, stop)
+
+	select {
+	case status := <-statusCh:
+		if diff := cmp.Diff(initialStatus, status); diff != "" {
+			t.Fatalf("Unexpectected initial status values received:\n%s", diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Failed getting initial status within expected timeframe")
+	}
+
+	select {
+	case status := <-statusCh:
+		t.Fatalf("Expected no status to be emitted after reconnecting, got %+v", status)
+	case <-time.After(time.Second):
+	}
+
+	if len(connections) != 2 {
+		t.Fatalf("Expected client to reconnect once, got %d connections", len(connections))
+	}
+}
+
 //nolint:funlen,gocognit,cyclop // Just many test cases.
 func Test_Creating_client(t *testing.T) {
 	t.Parallel()
@@ -253,6 +444,87 @@ func Test_Creating_client(t *testing.T) {
 		}
 	})
 
+	t.Run("uses_configured_D-Bus_names", func(t *testing.T) {
+		t.Parallel()
+
+		config := updateengine.Config{
+			Path:        "/org/example/update1",
+			Destination: "org.example.update1",
+			Interface:   "org.example.update1.Manager",
+		}
+
+		mockConnection := &dbus.MockConnection{
+			AddMatchSignalF: func(matchOptions ...godbus.MatchOption) error {
+				for _, option := range matchOptions {
+					optionValue := reflect.ValueOf(&option).Elem()
+
+					if key := optionValue.Field(0).String(); key == "interface" {
+						if value := optionValue.Field(1).String(); value != config.Interface {
+							t.Fatalf("Expected signals from interface %q, got %q", config.Interface, value)
+						}
+					}
+				}
+
+				return nil
+			},
+			ObjectF: func(dest string, path godbus.ObjectPath) godbus.BusObject {
+				if dest != config.Destination {
+					t.Fatalf("Expected destination %q, got %q", config.Destination, dest)
+				}
+
+				if path != godbus.ObjectPath(config.Path) {
+					t.Fatalf("Expected path %q, got %q", config.Path, path)
+				}
+
+				return &dbus.MockObject{
+					CallF: func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+						expectedMethod := config.Interface + "." + updateengine.DBusMethodNameGetLastAttemptError
+						if method != expectedMethod {
+							t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+						}
+
+						return &godbus.Call{Body: []interface{}{int32(0)}}
+					},
+				}
+			},
+		}
+
+		connector := func() (dbus.Connection, error) { return mockConnection, nil }
+
+		client, err := updateengine.NewWithConfig(connector, config)
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		if _, err := client.LastAttemptError(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("uses_default_D-Bus_names_when_not_configured", func(t *testing.T) {
+		t.Parallel()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(dest string, path godbus.ObjectPath) godbus.BusObject {
+				if dest != updateengine.DBusDestination {
+					t.Fatalf("Expected destination %q, got %q", updateengine.DBusDestination, dest)
+				}
+
+				if path != updateengine.DBusPath {
+					t.Fatalf("Expected path %q, got %q", updateengine.DBusPath, path)
+				}
+
+				return &dbus.MockObject{}
+			},
+		}
+
+		connector := func() (dbus.Connection, error) { return mockConnection, nil }
+
+		if _, err := updateengine.NewWithConfig(connector, updateengine.Config{}); err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+	})
+
 	t.Run("fails_when", func(t *testing.T) {
 		t.Parallel()
 
@@ -365,3 +637,117 @@ func testStatus() updateengine.Status {
 func statusToSignalBody(s updateengine.Status) []interface{} {
 	return []interface{}{s.LastCheckedTime, s.Progress, s.CurrentOperation, s.NewVersion, s.NewSize}
 }
+
+func Test_Getting_last_attempt_error(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, call *godbus.Call) updateengine.Client {
+		t.Helper()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallF: func(method string, flags godbus.Flags, args ...interface{}) *godbus.Call {
+						expectedMethod := updateengine.DBusInterface + "." + updateengine.DBusMethodNameGetLastAttemptError
+						if method != expectedMethod {
+							t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+						}
+
+						return call
+					},
+				}
+			},
+		}
+
+		client, err := updateengine.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		return client
+	}
+
+	t.Run("returns_error_code_reported_by_update_engine", func(t *testing.T) {
+		t.Parallel()
+
+		expectedCode := int32(37)
+
+		code, err := newClient(t, &godbus.Call{Body: []interface{}{expectedCode}}).LastAttemptError()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if code != expectedCode {
+			t.Fatalf("Expected error code %d, got %d", expectedCode, code)
+		}
+	})
+
+	t.Run("returns_error_when", func(t *testing.T) {
+		t.Parallel()
+
+		for name, call := range map[string]*godbus.Call{
+			"calling_update_engine_fails": {Err: fmt.Errorf("call error")},
+			"response_is_malformed":       {Body: []interface{}{"foo"}},
+		} {
+			call := call
+
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				if _, err := newClient(t, call).LastAttemptError(); err == nil {
+					t.Fatalf("Expected error")
+				}
+			})
+		}
+	})
+}
+
+func Test_Attempting_update(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, call *godbus.Call) updateengine.Client {
+		t.Helper()
+
+		mockConnection := &dbus.MockConnection{
+			ObjectF: func(string, godbus.ObjectPath) godbus.BusObject {
+				return &dbus.MockObject{
+					CallWithContextF: func(
+						_ context.Context, method string, _ godbus.Flags, _ ...interface{},
+					) *godbus.Call {
+						expectedMethod := updateengine.DBusInterface + "." + updateengine.DBusMethodNameAttemptUpdate
+						if method != expectedMethod {
+							t.Fatalf("Expected method %q to be called, got %q", expectedMethod, method)
+						}
+
+						return call
+					},
+				}
+			},
+		}
+
+		client, err := updateengine.New(func() (dbus.Connection, error) { return mockConnection, nil })
+		if err != nil {
+			t.Fatalf("Got unexpected error while creating client: %v", err)
+		}
+
+		return client
+	}
+
+	t.Run("calls_update_engine_method_to_check_for_update", func(t *testing.T) {
+		t.Parallel()
+
+		if err := newClient(t, &godbus.Call{}).AttemptUpdate(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("returns_error_when_calling_update_engine_fails", func(t *testing.T) {
+		t.Parallel()
+
+		call := &godbus.Call{Err: fmt.Errorf("call error")}
+
+		if err := newClient(t, call).AttemptUpdate(context.Background()); err == nil {
+			t.Fatalf("Expected error")
+		}
+	})
+}
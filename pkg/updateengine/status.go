@@ -42,17 +42,52 @@ type Status struct {
 	NewSize          int64
 }
 
-// NewStatus constructs status from received D-Bus signal body.
-//
-//nolint:forcetypeassert // TODO: To be refactored to return errors.
-func NewStatus(body []interface{}) Status {
-	return Status{
-		LastCheckedTime:  body[0].(int64),
-		Progress:         body[1].(float64),
-		CurrentOperation: body[2].(string),
-		NewVersion:       body[3].(string),
-		NewSize:          body[4].(int64),
+// statusValues is a number of values in status D-Bus signal body and GetStatus method response.
+const statusValues = 5
+
+// NewStatus constructs status from received D-Bus signal body. An error is returned when
+// the body has unexpected number of values or when any of the values has unexpected type.
+func NewStatus(body []interface{}) (Status, error) {
+	if len(body) != statusValues {
+		return Status{}, fmt.Errorf("expected %d values, got %d", statusValues, len(body))
+	}
+
+	lastCheckedTime, ok := body[0].(int64)
+	if !ok {
+		return Status{}, unexpectedTypeError(0, "LastCheckedTime", body[0])
+	}
+
+	progress, ok := body[1].(float64)
+	if !ok {
+		return Status{}, unexpectedTypeError(1, "Progress", body[1])
+	}
+
+	currentOperation, ok := body[2].(string)
+	if !ok {
+		return Status{}, unexpectedTypeError(2, "CurrentOperation", body[2])
 	}
+
+	newVersion, ok := body[3].(string)
+	if !ok {
+		return Status{}, unexpectedTypeError(3, "NewVersion", body[3])
+	}
+
+	newSize, ok := body[4].(int64)
+	if !ok {
+		return Status{}, unexpectedTypeError(4, "NewSize", body[4])
+	}
+
+	return Status{
+		LastCheckedTime:  lastCheckedTime,
+		Progress:         progress,
+		CurrentOperation: currentOperation,
+		NewVersion:       newVersion,
+		NewSize:          newSize,
+	}, nil
+}
+
+func unexpectedTypeError(index int, name string, value interface{}) error {
+	return fmt.Errorf("value %d (%s) has unexpected type %T", index, name, value)
 }
 
 // String implements Stringer interface for Status.
@@ -0,0 +1,53 @@
+package updateengine_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/updateengine"
+)
+
+func Test_Creating_status(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns_status_with_values_from_given_body", func(t *testing.T) {
+		t.Parallel()
+
+		expectedStatus := testStatus()
+
+		status, err := updateengine.NewStatus(statusToSignalBody(expectedStatus))
+		if err != nil {
+			t.Fatalf("Unexpected error creating status: %v", err)
+		}
+
+		if diff := cmp.Diff(expectedStatus, status); diff != "" {
+			t.Fatalf("Unexpectected status values (-expected/+got):\n%s", diff)
+		}
+	})
+
+	t.Run("returns_error_when_given_body_has", func(t *testing.T) {
+		t.Parallel()
+
+		for name, body := range map[string][]interface{}{
+			"no_values":                       nil,
+			"too_few_values":                  statusToSignalBody(testStatus())[:4],
+			"too_many_values":                 append(statusToSignalBody(testStatus()), "foo"),
+			"last_checked_time_of_wrong_type": {"foo", float64(0), "", "", int64(0)},
+			"progress_of_wrong_type":          {int64(0), "foo", "", "", int64(0)},
+			"current_operation_of_wrong_type": {int64(0), float64(0), 1, "", int64(0)},
+			"new_version_of_wrong_type":       {int64(0), float64(0), "", 1, int64(0)},
+			"new_size_of_wrong_type":          {int64(0), float64(0), "", "", "foo"},
+		} {
+			body := body
+
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				if _, err := updateengine.NewStatus(body); err == nil {
+					t.Fatalf("Expected error creating status")
+				}
+			})
+		}
+	})
+}
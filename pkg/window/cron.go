@@ -0,0 +1,46 @@
+package window
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CronPeriodic keeps track of a repeating period of time, which starts are
+// described using cron expression.
+type CronPeriodic struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// ParseCronPeriodic returns a CronPeriodic specified as a standard 5 fields cron
+// expression and duration.
+func ParseCronPeriodic(expression, duration string) (*CronPeriodic, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cron expression: %w", err)
+	}
+
+	result := &CronPeriodic{
+		schedule: schedule,
+	}
+
+	if result.duration, err = time.ParseDuration(duration); err != nil {
+		return nil, fmt.Errorf("parsing duration: %w", err)
+	}
+
+	if result.duration < time.Duration(0) {
+		return nil, fmt.Errorf("duration cannot be negative")
+	}
+
+	return result, nil
+}
+
+// Contains returns true if given time is within one of CronPeriodic periods.
+func (cp *CronPeriodic) Contains(ref time.Time) bool {
+	// First period which started after the earliest start still containing ref.
+	start := cp.schedule.Next(ref.Add(-cp.duration))
+
+	return !start.IsZero() && !start.After(ref)
+}
@@ -0,0 +1,141 @@
+package window_test
+
+import (
+	"testing"
+
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/window"
+)
+
+func TestCronPeriodicParse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expression string
+		duration   string
+		err        bool
+	}{
+		"valid_daily_expression": {
+			expression: "0 14 * * *",
+			duration:   "1h",
+		},
+		"valid_weekly_expression": {
+			expression: "30 2 * * Sat,Sun",
+			duration:   "1h30m",
+		},
+		"bad_expression": {
+			expression: "0 14 * *",
+			duration:   "1h",
+			err:        true,
+		},
+		"minute_out_of_range": {
+			expression: "61 14 * * *",
+			duration:   "1h",
+			err:        true,
+		},
+		"bad_duration": {
+			expression: "0 14 * * *",
+			duration:   "foo",
+			err:        true,
+		},
+		"negative_duration": {
+			expression: "0 14 * * *",
+			duration:   "-1h",
+			err:        true,
+		},
+	}
+
+	for name, testCase := range tests {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := window.ParseCronPeriodic(testCase.expression, testCase.duration)
+			if testCase.err && err == nil {
+				t.Fatalf("Expected error")
+			}
+
+			if !testCase.err && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+//nolint:funlen // Just many sub-tests.
+func TestCronPeriodicContains(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expression string
+		duration   string
+		time       string
+		contains   bool
+	}{
+		"daily_window_in_15_minutes": {
+			expression: "15 0 * * *",
+			duration:   "10s",
+			time:       "Thu May 21 00:00:00 PDT 2015",
+			contains:   false,
+		},
+		"daily_window_now": {
+			expression: "0 0 * * *",
+			duration:   "1h",
+			time:       "Thu May 21 00:00:00 PDT 2015",
+			contains:   true,
+		},
+		"daily_window_started_1_second_ago": {
+			expression: "0 0 * * *",
+			duration:   "1h",
+			time:       "Thu May 21 00:00:01 PDT 2015",
+			contains:   true,
+		},
+		"daily_window_just_closed": {
+			expression: "33 2 * * *",
+			duration:   "10h",
+			time:       "Thu May 21 12:33:00 PDT 2015",
+			contains:   false,
+		},
+		"daily_window_started_last_night_but_extends_into_next_day": {
+			expression: "5 23 * * *",
+			duration:   "11h",
+			time:       "Thu May 21 09:33:01 PDT 2015",
+			contains:   true,
+		},
+		"weekly_window_on_other_day": {
+			expression: "0 0 * * Fri",
+			duration:   "1h",
+			time:       "Thu May 21 00:30:00 PDT 2015",
+			contains:   false,
+		},
+		"weekly_window_started_in_previous_week": {
+			expression: "5 23 * * Sat",
+			duration:   "11h",
+			time:       "Sun May 17 09:33:01 PDT 2015",
+			contains:   true,
+		},
+		"zero_length_window": {
+			expression: "0 0 * * *",
+			duration:   "0s",
+			time:       "Thu May 21 00:00:00 PDT 2015",
+			contains:   false,
+		},
+	}
+
+	for name, testCase := range tests {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cronPeriodic, err := window.ParseCronPeriodic(testCase.expression, testCase.duration)
+			if err != nil {
+				t.Fatalf("Cron periodic parse failed: %v", err)
+			}
+
+			if contains := cronPeriodic.Contains(mustParseTime(testCase.time)); contains != testCase.contains {
+				t.Fatalf("Got %t, want %t", contains, testCase.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package window provides repeating periods of time, like reboot windows, shared by update-operator
+// and update-agent.
+package window
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package operator
+package window
 
 import (
 	"fmt"
@@ -80,6 +80,12 @@ func (pc *Periodic) DurationToStart(ref time.Time) time.Duration {
 	return pc.Next(ref).Start.Sub(ref)
 }
 
+// Contains returns true if given time is within one of Periodic's periods.
+func (pc *Periodic) Contains(ref time.Time) bool {
+	// Most recent period might still be open.
+	return ref.Before(pc.Previous(ref).End)
+}
+
 // Previous returns Periodic's previous Period occurrence relative to ref.
 func (pc *Periodic) Previous(ref time.Time) *Period {
 	previousPeriod := &Period{}
@@ -12,14 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package operator_test
+package window_test
 
 import (
 	"fmt"
 	"testing"
 	"time"
 
-	"github.com/flatcar/flatcar-linux-update-operator/pkg/operator"
+	"github.com/flatcar/flatcar-linux-update-operator/pkg/window"
 )
 
 //nolint:funlen // Just many sub-tests.
@@ -84,7 +84,7 @@ func TestPeriodicParse(t *testing.T) {
 		t.Run(fmt.Sprintf("%v", testCase), func(t *testing.T) {
 			t.Parallel()
 
-			_, err := operator.ParsePeriodic(testCase.start, testCase.duration)
+			_, err := window.ParsePeriodic(testCase.start, testCase.duration)
 			if err != nil && testCase.err == false {
 				t.Fatalf("Unexpected error: %v", err)
 			}
@@ -192,7 +192,7 @@ func TestToStart(t *testing.T) {
 		t.Run(testCase.time, func(t *testing.T) {
 			t.Parallel()
 
-			periodic, err := operator.ParsePeriodic(testCase.start, testCase.duration)
+			periodic, err := window.ParsePeriodic(testCase.start, testCase.duration)
 			if err != nil {
 				t.Fatalf("Periodic parse failed: %v", err)
 			}